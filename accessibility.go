@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Public returns a copy of the user safe to show to other users.
+// Accessibility needs are sensitive and only exposed when the owner opted in;
+// exact coordinates and linked contacts are never exposed. The contact is
+// only revealed to matches once both sides shared it (see Match.view).
+func (u User) Public() User {
+	if !u.ShareAccessibility {
+		u.AccessibilityNeeds = nil
+	}
+	u.Latitude, u.Longitude = nil, nil
+	u.Contact = ""
+	u.LinkedContacts = nil
+	return u
+}
+
+// AccessibilityCompatible reports whether candidate is a suitable partner for
+// requester in adaptive training: either the candidate has adaptive training
+// experience or both share at least one accessibility need.
+func AccessibilityCompatible(requester, candidate User) bool {
+	if candidate.AdaptiveTraining {
+		return true
+	}
+
+	for _, need := range requester.AccessibilityNeeds {
+		for _, other := range candidate.AccessibilityNeeds {
+			if strings.EqualFold(need, other) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func formList(r *http.Request, key string) []string {
+	var values []string
+	for _, v := range r.Form[key] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
+
+func formBool(r *http.Request, key string) bool {
+	b, _ := strconv.ParseBool(r.FormValue(key))
+	return b
+}
+
+// formHas reports whether any of keys was sent in the form, even empty.
+// Profile updates only change the fields a client sent.
+func formHas(r *http.Request, keys ...string) bool {
+	for _, key := range keys {
+		if _, ok := r.Form[key]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// AccountTombstone records an account merged into another, so its UID can't
+// be signed up again and clients can be pointed at the surviving account.
+type AccountTombstone struct {
+	UID        string    `json:"uid"`
+	MergedInto string    `json:"mergedInto"`
+	MergedBy   string    `json:"mergedBy"`
+	MergedAt   time.Time `json:"mergedAt"`
+}
+
+type MergeAccountRequest struct {
+	// SecondaryToken is a user token of the account to merge away, proving
+	// the caller owns both accounts.
+	SecondaryToken string `json:"secondaryToken"`
+}
+
+func (c *Controller) findTombstone(uid string) (AccountTombstone, bool) {
+	for _, t := range c.storage.Tombstones {
+		if t.UID == uid {
+			return t, true
+		}
+	}
+	return AccountTombstone{}, false
+}
+
+// accountMergePlan lists the records mergeAccounts moves from one account to
+// the other.
+func (c *Controller) accountMergePlan(from, into string) DestructivePlan {
+	affected := map[string][]string{"users": {from}}
+	add := func(kind, id string) {
+		affected[kind] = append(affected[kind], id)
+	}
+
+	matchIDs := make(map[string]bool)
+	for _, m := range c.storage.Matches {
+		if m.Has(from) {
+			matchIDs[m.ID()] = true
+			add("matches", m.ID())
+		}
+	}
+	for _, m := range c.storage.Messages {
+		if matchIDs[m.MatchID] {
+			add("messages", m.ID)
+		}
+	}
+	for _, s := range c.storage.Swipes {
+		if s.SwiperID == from || s.TargetID == from {
+			add("swipes", s.SwiperID+"->"+s.TargetID)
+		}
+	}
+	if u, ok := c.findUser(from); ok {
+		for _, url := range u.Photos {
+			add("photos", url)
+		}
+	}
+	for _, d := range c.storage.Devices {
+		if d.UserID == from {
+			add("devices", hashToken(d.Token)[:12])
+		}
+	}
+	return DestructivePlan{Affected: affected}
+}
+
+// mergeAccounts moves everything from one account to another and tombstones
+// it. Swipes, matches and blocks between the two accounts are dropped, and
+// duplicates keep the surviving account's record. The profile keeps into's
+// fields; from's photos are added to the gallery while there is room. The
+// caller must hold c.mu and save data.
+func (c *Controller) mergeAccounts(from, into, mergedBy string) {
+	rekey := func(id string) string {
+		if id == from {
+			return into
+		}
+		return id
+	}
+	s := &c.storage
+
+	fromUser, _ := c.findUser(from)
+	if i, ok := c.findUserIndex(into); ok {
+		before := s.Users[i]
+		photos := slices.Clone(before.Photos)
+		for _, url := range fromUser.Photos {
+			if len(photos) < maxPhotos && !slices.Contains(photos, url) {
+				photos = append(photos, url)
+			} else {
+				c.removeImage(url, from)
+				c.unindexImage(from, url)
+			}
+		}
+		s.Users[i].setPhotos(photos)
+
+		for _, lc := range fromUser.LinkedContacts {
+			if !slices.ContainsFunc(s.Users[i].LinkedContacts, func(own LinkedContact) bool { return own.Type == lc.Type }) {
+				s.Users[i].LinkedContacts = append(s.Users[i].LinkedContacts, lc)
+			}
+		}
+		if fromUser.CreatedAt.Before(before.CreatedAt) {
+			s.Users[i].CreatedAt = fromUser.CreatedAt
+		}
+		c.recordProfileChanges(before, s.Users[i], mergedBy)
+	}
+
+	swipes := s.Swipes[:0]
+	swipeIndex := make(map[string]int)
+	for _, sw := range s.Swipes {
+		sw.SwiperID, sw.TargetID = rekey(sw.SwiperID), rekey(sw.TargetID)
+		if sw.SwiperID == sw.TargetID {
+			continue
+		}
+		key := sw.SwiperID + "->" + sw.TargetID
+		if j, dup := swipeIndex[key]; dup {
+			swipes[j].IsLike = swipes[j].IsLike || sw.IsLike
+			continue
+		}
+		swipeIndex[key] = len(swipes)
+		swipes = append(swipes, sw)
+	}
+	s.Swipes = swipes
+
+	// Messages and scheduled messages follow their match to its new ID;
+	// those of a match between the two accounts are dropped with it.
+	matchIDs := make(map[string]string)
+	matches := s.Matches[:0]
+	for _, m := range s.Matches {
+		oldID := m.ID()
+		m.User1ID, m.User2ID = rekey(m.User1ID), rekey(m.User2ID)
+		if m.User1ID == m.User2ID {
+			continue
+		}
+		for k, id := range m.ContactSharedBy {
+			m.ContactSharedBy[k] = rekey(id)
+		}
+		if m.User1ID > m.User2ID {
+			m.User1ID, m.User2ID = m.User2ID, m.User1ID
+		}
+		matchIDs[oldID] = m.ID()
+
+		if j := slices.IndexFunc(matches, func(other Match) bool { return other.ID() == m.ID() }); j != -1 {
+			if m.CreatedAt.Before(matches[j].CreatedAt) {
+				matches[j].CreatedAt = m.CreatedAt
+			}
+			continue
+		}
+		matches = append(matches, m)
+	}
+	s.Matches = matches
+
+	dropped := make(map[string]bool)
+	messages := s.Messages[:0]
+	for _, m := range s.Messages {
+		newID, ok := matchIDs[m.MatchID]
+		if !ok {
+			dropped[m.ID] = true
+			continue
+		}
+		m.MatchID, m.SenderID = newID, rekey(m.SenderID)
+		messages = append(messages, m)
+	}
+	s.Messages = messages
+
+	edits := s.MessageEdits[:0]
+	for _, e := range s.MessageEdits {
+		if !dropped[e.MessageID] {
+			edits = append(edits, e)
+		}
+	}
+	s.MessageEdits = edits
+
+	scheduled := s.ScheduledMessages[:0]
+	for _, m := range s.ScheduledMessages {
+		newID, ok := matchIDs[m.MatchID]
+		if !ok {
+			continue
+		}
+		m.MatchID, m.SenderID = newID, rekey(m.SenderID)
+		scheduled = append(scheduled, m)
+	}
+	s.ScheduledMessages = scheduled
+
+	requests := s.MessageRequests[:0]
+	for _, req := range s.MessageRequests {
+		req.FromID, req.ToID = rekey(req.FromID), rekey(req.ToID)
+		if req.FromID != req.ToID {
+			requests = append(requests, req)
+		}
+	}
+	s.MessageRequests = requests
+
+	blocks := s.Blocks[:0]
+	for _, b := range s.Blocks {
+		b.BlockerID, b.BlockedID = rekey(b.BlockerID), rekey(b.BlockedID)
+		if b.BlockerID != b.BlockedID && !slices.ContainsFunc(blocks, func(other Block) bool {
+			return other.BlockerID == b.BlockerID && other.BlockedID == b.BlockedID
+		}) {
+			blocks = append(blocks, b)
+		}
+	}
+	s.Blocks = blocks
+
+	for i := range s.Reports {
+		s.Reports[i].ReporterID = rekey(s.Reports[i].ReporterID)
+		s.Reports[i].ReportedID = rekey(s.Reports[i].ReportedID)
+	}
+	for i := range s.Devices {
+		s.Devices[i].UserID = rekey(s.Devices[i].UserID)
+	}
+	for i := range s.Deliveries {
+		s.Deliveries[i].UserID = rekey(s.Deliveries[i].UserID)
+	}
+	for i := range s.CheckIns {
+		s.CheckIns[i].UserID = rekey(s.CheckIns[i].UserID)
+	}
+	for i := range s.NearbyAlerts {
+		s.NearbyAlerts[i].ToID = rekey(s.NearbyAlerts[i].ToID)
+		s.NearbyAlerts[i].AboutID = rekey(s.NearbyAlerts[i].AboutID)
+	}
+	for i := range s.SharedPlans {
+		s.SharedPlans[i].UserID = rekey(s.SharedPlans[i].UserID)
+		s.SharedPlans[i].PartnerID = rekey(s.SharedPlans[i].PartnerID)
+	}
+	for i := range s.GoalPosts {
+		s.GoalPosts[i].UserID = rekey(s.GoalPosts[i].UserID)
+		for j := range s.GoalPosts[i].Responses {
+			s.GoalPosts[i].Responses[j].UserID = rekey(s.GoalPosts[i].Responses[j].UserID)
+		}
+	}
+	for i := range s.Sessions {
+		s.Sessions[i].ProposedBy = rekey(s.Sessions[i].ProposedBy)
+		s.Sessions[i].ProposedTo = rekey(s.Sessions[i].ProposedTo)
+		s.Sessions[i].MatchID = Match{User1ID: s.Sessions[i].ProposedBy, User2ID: s.Sessions[i].ProposedTo}.ID()
+	}
+	for i := range s.Notifications {
+		s.Notifications[i].UserID = rekey(s.Notifications[i].UserID)
+	}
+	for i := range s.ImageHashes {
+		s.ImageHashes[i].UserID = rekey(s.ImageHashes[i].UserID)
+	}
+	for i := range s.PendingImages {
+		s.PendingImages[i].UserID = rekey(s.PendingImages[i].UserID)
+	}
+	for i := range s.ProfileHistory {
+		s.ProfileHistory[i].UserID = rekey(s.ProfileHistory[i].UserID)
+	}
+	for i := range s.UserTokens {
+		s.UserTokens[i].UserID = rekey(s.UserTokens[i].UserID)
+	}
+	for i := range s.AutomationRuns {
+		s.AutomationRuns[i].UserID = rekey(s.AutomationRuns[i].UserID)
+	}
+
+	changes := s.ContactChanges[:0]
+	for _, ch := range s.ContactChanges {
+		if ch.UserID != from {
+			changes = append(changes, ch)
+		}
+	}
+	s.ContactChanges = changes
+
+	// into keeps its own Telegram chat if it has one.
+	intoLinked := slices.ContainsFunc(s.TelegramChats, func(chat TelegramChat) bool { return chat.UserID == into })
+	chats := s.TelegramChats[:0]
+	for _, chat := range s.TelegramChats {
+		if chat.UserID == from {
+			if intoLinked {
+				continue
+			}
+			chat.UserID = into
+		}
+		chats = append(chats, chat)
+	}
+	s.TelegramChats = chats
+	codes := s.TelegramLinkCodes[:0]
+	for _, lc := range s.TelegramLinkCodes {
+		if lc.UserID != from {
+			codes = append(codes, lc)
+		}
+	}
+	s.TelegramLinkCodes = codes
+
+	if contacts, ok := s.EmergencyContacts[from]; ok {
+		if len(s.EmergencyContacts[into]) == 0 {
+			s.EmergencyContacts[into] = contacts
+		}
+		delete(s.EmergencyContacts, from)
+	}
+	if stats, ok := s.ResponseStats[from]; ok {
+		merged := s.ResponseStats[into]
+		merged.FirstMessagesReceived += stats.FirstMessagesReceived
+		merged.Responded += stats.Responded
+		merged.TotalResponseSeconds += stats.TotalResponseSeconds
+		s.ResponseStats[into] = merged
+		delete(s.ResponseStats, from)
+	}
+	delete(s.SwipeUndos, from)
+
+	users := s.Users[:0]
+	for _, u := range s.Users {
+		if u.FirebaseUID != from {
+			users = append(users, u)
+		}
+	}
+	s.Users = users
+
+	for i := range s.Tombstones {
+		// Accounts merged into from earlier now point at into.
+		if s.Tombstones[i].MergedInto == from {
+			s.Tombstones[i].MergedInto = into
+		}
+	}
+	s.Tombstones = append(s.Tombstones, AccountTombstone{
+		UID:        from,
+		MergedInto: into,
+		MergedBy:   mergedBy,
+		MergedAt:   time.Now(),
+	})
+}
+
+// AdminMergeUser handles POST /admin/users/{uid}/merge?from={otherUid},
+// merging the other account into uid through the dry-run confirmation flow.
+func (c *Controller) AdminMergeUser(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathUser(w, r)
+	if !ok {
+		return
+	}
+
+	into := c.storage.Users[i].FirebaseUID
+	from := r.URL.Query().Get("from")
+	if _, ok := c.findUser(from); !ok || from == into {
+		writeError(w, http.StatusNotFound, "MERGE_ACCOUNT_NOT_FOUND", "Account to merge not found")
+		return
+	}
+	if !c.confirmDestructive(w, r, c.accountMergePlan(from, into)) {
+		return
+	}
+
+	c.mergeAccounts(from, into, changedByAdmin)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MergeAccount handles POST /api/account/merge for users with two accounts.
+// The request is made with a user token of the account to keep and carries
+// a token of the account to merge into it.
+func (c *Controller) MergeAccount(w http.ResponseWriter, r *http.Request) {
+	primary, ok := UserTokenFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "USER_TOKEN_REQUIRED", "A user token of the account to keep is required")
+		return
+	}
+
+	var req MergeAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	secondary, ok := c.findUserToken(req.SecondaryToken)
+	if !ok || !secondary.Allows(ScopeProfileWrite) {
+		writeError(w, http.StatusForbidden, "INVALID_SECONDARY_TOKEN", "Invalid secondary token")
+		return
+	}
+	if secondary.UserID == primary.UserID {
+		writeError(w, http.StatusBadRequest, "SAME_ACCOUNT", "Both tokens belong to the same account")
+		return
+	}
+	if _, ok := c.findUser(primary.UserID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+	if _, ok := c.findUser(secondary.UserID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	c.mergeAccounts(secondary.UserID, primary.UserID, primary.UserID)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	u, _ := c.findUser(primary.UserID)
+	writeJSON(w, http.StatusOK, u)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// requireAdmin rejects requests that don't carry the ADMIN_API_KEY in the
+// X-Admin-Key header or as a bearer token, or a user token with the admin
+// scope for the method. The key is disabled when not configured. The caller
+// must hold c.mu.
+func (c *Controller) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if secret, ok := bearerUserToken(r); ok {
+			scope := ScopeAdminWrite
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = ScopeAdminRead
+			}
+
+			token, found := c.findUserToken(secret)
+			if !found || !token.Allows(scope) {
+				writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		key := os.Getenv("ADMIN_API_KEY")
+		given := r.Header.Get("X-Admin-Key")
+		if given == "" {
+			given = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if key == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (c *Controller) AdminReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	reports := c.storage.Reports
+	if reports == nil {
+		reports = []Report{}
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+type AdminUser struct {
+	User
+	Reports []Report `json:"reports"`
+}
+
+// AdminUsers lists every user with the reports filed against them. With
+// ?reported=true only reported users are returned.
+func (c *Controller) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	reportedOnly := r.URL.Query().Get("reported") == "true"
+
+	users := []AdminUser{}
+	for _, u := range c.storage.Users {
+		entry := AdminUser{User: u, Reports: []Report{}}
+		for _, report := range c.storage.Reports {
+			if report.ReportedID == u.FirebaseUID {
+				entry.Reports = append(entry.Reports, report)
+			}
+		}
+
+		if reportedOnly && len(entry.Reports) == 0 {
+			continue
+		}
+		users = append(users, entry)
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+// pathUser finds the user named by the {uid} path value, writing a 404 if
+// there is none.
+func (c *Controller) pathUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	for i, u := range c.storage.Users {
+		if u.FirebaseUID == r.PathValue("uid") {
+			return i, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+	return -1, false
+}
+
+// AdminDeleteUser handles DELETE /admin/users/{uid} to force-delete a
+// profile through the dry-run confirmation flow.
+func (c *Controller) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathUser(w, r)
+	if !ok {
+		return
+	}
+
+	uid := c.storage.Users[i].FirebaseUID
+	if !c.confirmDestructive(w, r, c.userErasePlan(uid)) {
+		return
+	}
+	c.deleteUser(uid)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminDeleteUserImage handles DELETE /admin/users/{uid}/image to remove the
+// primary photo through the dry-run confirmation flow. The next one in the
+// gallery takes its place.
+func (c *Controller) AdminDeleteUserImage(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathUser(w, r)
+	if !ok {
+		return
+	}
+	if !c.confirmDestructive(w, r, c.imagePurgePlan(c.storage.Users[i])) {
+		return
+	}
+
+	before := c.storage.Users[i]
+	if len(before.Photos) > 0 {
+		c.storage.Users[i].setPhotos(slices.Clone(before.Photos[1:]))
+	}
+	c.removeImage(before.ImageURL, before.FirebaseUID)
+	c.unindexImage(before.FirebaseUID, before.ImageURL)
+	c.recordProfileChanges(before, c.storage.Users[i], changedByAdmin)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminUserHistory handles GET /admin/users/{uid}/history to inspect profile
+// edits.
+func (c *Controller) AdminUserHistory(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathUser(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, c.profileHistory(c.storage.Users[i].FirebaseUID))
+}
+
+// deleteUser removes a user with everything that references them.
+func (c *Controller) deleteUser(uid string) {
+	matchIDs := make(map[string]bool)
+
+	if u, ok := c.findUser(uid); ok {
+		for _, url := range u.Photos {
+			c.removeImage(url, uid)
+		}
+	}
+	c.unindexImages(uid)
+	c.discardPendingImages(uid, false)
+	c.forgetProfileHistory(uid)
+	c.forgetGoals(uid)
+	c.forgetSessions(uid)
+	c.forgetHarassmentAlerts(uid)
+	c.forgetTelegram(uid)
+	c.forgetAutomationRuns(uid)
+
+	tokens := c.storage.UserTokens[:0]
+	for _, t := range c.storage.UserTokens {
+		if t.UserID != uid {
+			tokens = append(tokens, t)
+		}
+	}
+	c.storage.UserTokens = tokens
+
+	changes := c.storage.ContactChanges[:0]
+	for _, ch := range c.storage.ContactChanges {
+		if ch.UserID != uid {
+			changes = append(changes, ch)
+		}
+	}
+	c.storage.ContactChanges = changes
+
+	notifications := c.storage.Notifications[:0]
+	for _, n := range c.storage.Notifications {
+		if n.UserID != uid {
+			notifications = append(notifications, n)
+		}
+	}
+	c.storage.Notifications = notifications
+
+	users := c.storage.Users[:0]
+	for _, u := range c.storage.Users {
+		if u.FirebaseUID != uid {
+			users = append(users, u)
+		}
+	}
+	c.storage.Users = users
+
+	swipes := c.storage.Swipes[:0]
+	for _, s := range c.storage.Swipes {
+		if s.SwiperID != uid && s.TargetID != uid {
+			swipes = append(swipes, s)
+		}
+	}
+	c.storage.Swipes = swipes
+
+	matches := c.storage.Matches[:0]
+	for _, m := range c.storage.Matches {
+		if m.Has(uid) {
+			matchIDs[m.ID()] = true
+			continue
+		}
+		matches = append(matches, m)
+	}
+	c.storage.Matches = matches
+
+	messages := c.storage.Messages[:0]
+	for _, m := range c.storage.Messages {
+		if !matchIDs[m.MatchID] {
+			messages = append(messages, m)
+		}
+	}
+	c.storage.Messages = messages
+
+	devices := c.storage.Devices[:0]
+	for _, d := range c.storage.Devices {
+		if d.UserID != uid {
+			devices = append(devices, d)
+		}
+	}
+	c.storage.Devices = devices
+
+	deliveries := c.storage.Deliveries[:0]
+	for _, d := range c.storage.Deliveries {
+		if d.UserID != uid {
+			deliveries = append(deliveries, d)
+		}
+	}
+	c.storage.Deliveries = deliveries
+
+	tombstones := c.storage.Tombstones[:0]
+	for _, t := range c.storage.Tombstones {
+		if t.MergedInto != uid {
+			tombstones = append(tombstones, t)
+		}
+	}
+	c.storage.Tombstones = tombstones
+
+	delete(c.storage.EmergencyContacts, uid)
+	delete(c.storage.ResponseStats, uid)
+}
+
+func (c *Controller) imageRemovable(imageURL, ownerID string) bool {
+	if imageURL == "" || imageURL == defaultImageURL || !strings.HasPrefix(imageURL, "/images/") {
+		return false
+	}
+
+	for _, u := range c.storage.Users {
+		if u.FirebaseUID != ownerID && (u.ImageURL == imageURL || slices.Contains(u.Photos, imageURL)) {
+			return false
+		}
+	}
+	return true
+}
+
+// removeImage deletes an uploaded image from the store unless another user still
+// references it or it is the shared default.
+func (c *Controller) removeImage(imageURL, ownerID string) {
+	if !c.imageRemovable(imageURL, ownerID) {
+		return
+	}
+
+	key := imageKey(imageURL)
+	if err := c.images.Delete(context.Background(), key); err != nil {
+		slog.Error("Failed to remove image", "key", key, "err", err)
+	}
+	c.removeThumbnails(key)
+	c.removeImageVariants(key)
+}
+
+func (c *Controller) AdminSwipes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	swipes := c.storage.Swipes
+	if swipes == nil {
+		swipes = []Swipe{}
+	}
+	writeJSON(w, http.StatusOK, swipes)
+}
+
+func (c *Controller) AdminMatches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	matches := c.storage.Matches
+	if matches == nil {
+		matches = []Match{}
+	}
+	writeJSON(w, http.StatusOK, matches)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeAnnouncementsWrite = "announcements:write"
+
+	AnnouncementGeneral  = "general"
+	AnnouncementSchedule = "schedule"
+	AnnouncementClosure  = "closure"
+
+	maxAnnouncementTitle   = 80
+	maxAnnouncementBody    = 1000
+	maxAnnouncementsPerDay = 5
+)
+
+// gymTokenScopes are the scopes a gym partner token may be issued with.
+var gymTokenScopes = []string{ScopeStatsRead, ScopeAnnouncementsWrite, ScopeModerate}
+
+// GymAnnouncement is a message a gym sends to its members, such as a class
+// schedule change or a closure.
+type GymAnnouncement struct {
+	ID         string    `json:"id"`
+	GymID      string    `json:"gymId"`
+	Kind       string    `json:"kind"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	Recipients int       `json:"recipients"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type AnnouncementRequest struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func (req AnnouncementRequest) valid() bool {
+	switch req.Kind {
+	case AnnouncementGeneral, AnnouncementSchedule, AnnouncementClosure:
+	default:
+		return false
+	}
+	title, body := len([]rune(req.Title)), len([]rune(req.Body))
+	return title > 0 && title <= maxAnnouncementTitle && body > 0 && body <= maxAnnouncementBody
+}
+
+// PartnerAnnouncements handles GET and POST /api/partner/announcements for
+// gym partner tokens with the announcements:write scope. Announcements go to
+// every user whose home gym is the token's gym through the notification
+// subsystem, so they also land in the inbox.
+func (c *Controller) PartnerAnnouncements(w http.ResponseWriter, r *http.Request) {
+	token, ok := c.gymTokenFromRequest(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+		return
+	}
+	if !token.HasScope(ScopeAnnouncementsWrite) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		announcements := []GymAnnouncement{}
+		for _, a := range c.storage.Announcements {
+			if a.GymID == token.GymID {
+				announcements = append(announcements, a)
+			}
+		}
+		writeJSON(w, http.StatusOK, announcements)
+
+	case http.MethodPost:
+		var req AnnouncementRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+		req.Title, req.Body = strings.TrimSpace(req.Title), strings.TrimSpace(req.Body)
+		if req.Kind == "" {
+			req.Kind = AnnouncementGeneral
+		}
+		if !req.valid() {
+			writeError(w, http.StatusBadRequest, "INVALID_ANNOUNCEMENT", "Kind must be general, schedule or closure; title and body are required")
+			return
+		}
+
+		since := time.Now().Add(-24 * time.Hour)
+		recent := 0
+		for _, a := range c.storage.Announcements {
+			if a.GymID == token.GymID && a.CreatedAt.After(since) {
+				recent++
+			}
+		}
+		if recent >= maxAnnouncementsPerDay {
+			writeError(w, http.StatusTooManyRequests, "ANNOUNCEMENT_LIMIT", "Daily announcement limit reached")
+			return
+		}
+
+		announcement := GymAnnouncement{
+			ID:        newID(),
+			GymID:     token.GymID,
+			Kind:      req.Kind,
+			Title:     req.Title,
+			Body:      req.Body,
+			CreatedAt: time.Now(),
+		}
+		for _, u := range c.storage.Users {
+			if u.HomeGymID != token.GymID {
+				continue
+			}
+			c.notifyUser(u.FirebaseUID, Notification{
+				Title: announcement.Title,
+				Body:  announcement.Body,
+				Data: map[string]string{
+					"type":           "gym_announcement",
+					"kind":           announcement.Kind,
+					"gymId":          announcement.GymID,
+					"announcementId": announcement.ID,
+				},
+			})
+			announcement.Recipients++
+		}
+		c.storage.Announcements = append(c.storage.Announcements, announcement)
+
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, announcement)
+
+	}
+}
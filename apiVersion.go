@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	apiVersionHeader = "X-API-Version"
+	// Clients can also ask for a version with a vendor media type:
+	// Accept: application/vnd.gymbro.v2+json.
+	apiMediaTypePrefix = "application/vnd.gymbro.v"
+	apiMediaTypeSuffix = "+json"
+
+	// legacyAPIVersion is served on legacy /api/ paths to requests that
+	// don't ask for a version. It stays at 1 so released app builds keep
+	// the payloads they were written against.
+	legacyAPIVersion = 1
+)
+
+// apiVersions are the versions mounted under /api/v{N}/. Version 2 pages
+// GET /api/users.
+var apiVersions = []int{1, 2}
+
+// APIVersion returns the API version negotiated for the request that ctx
+// belongs to. Handlers branch on it when a payload changes shape.
+func APIVersion(ctx context.Context) int {
+	if v, ok := ctx.Value(apiVersionKey).(int); ok {
+		return v
+	}
+	return legacyAPIVersion
+}
+
+func supportedAPIVersion(v int) bool {
+	for _, supported := range apiVersions {
+		if v == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// versionedPath returns the path under /api/v{version}/ of a legacy /api/
+// path.
+func versionedPath(version int, path string) string {
+	return fmt.Sprintf("/api/v%d/%s", version, strings.TrimPrefix(path, "/api/"))
+}
+
+// HandleVersioned registers h on path, a legacy /api/ path, and on the same
+// path under each /api/v{N}/.
+func (rt *Router) HandleVersioned(method, path string, h http.HandlerFunc, mw ...Middleware) {
+	rt.Handle(method, path, h, append([]Middleware{versioned(0)}, mw...)...)
+	for _, v := range apiVersions {
+		rt.Handle(method, versionedPath(v, path), h, append([]Middleware{versioned(v)}, mw...)...)
+	}
+}
+
+// acceptedAPIVersion reads the version from a vendor media type in the
+// Accept header, or 0 when none is asked for.
+func acceptedAPIVersion(r *http.Request) (int, error) {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil || !strings.HasPrefix(mediaType, apiMediaTypePrefix) ||
+			!strings.HasSuffix(mediaType, apiMediaTypeSuffix) {
+			continue
+		}
+
+		v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(mediaType, apiMediaTypePrefix), apiMediaTypeSuffix))
+		if err != nil || !supportedAPIVersion(v) {
+			return 0, fmt.Errorf("unsupported API version in %q", mediaType)
+		}
+		return v, nil
+	}
+	return 0, nil
+}
+
+// versioned negotiates the API version of a request: the one of its path,
+// else one asked for in the Accept header, else legacyAPIVersion.
+func versioned(version int) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			v := version
+			if v == 0 {
+				accepted, err := acceptedAPIVersion(r)
+				if err != nil {
+					writeError(w, http.StatusNotAcceptable, "UNSUPPORTED_API_VERSION", err.Error())
+					return
+				}
+				v = accepted
+			}
+			if v == 0 {
+				v = legacyAPIVersion
+			}
+
+			w.Header().Set(apiVersionHeader, strconv.Itoa(v))
+			next(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey, v)))
+		}
+	}
+}
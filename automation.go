@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AutomationMatchSilent nudges both sides of a match nobody has written
+	// in since it was made.
+	AutomationMatchSilent = "match_silent"
+	// AutomationSessionStalled reminds the proposer of a session that is
+	// still unanswered, with no messages in the chat since it was proposed.
+	AutomationSessionStalled = "session_stalled"
+
+	automationInterval     = 5 * time.Minute
+	maxAutomationDelayDays = 30
+)
+
+// AutomationRule sends a lifecycle notification once per match or proposal
+// that has met its trigger for DelayHours. "{name}" in the title and body is
+// replaced with the partner's name. Only subjects that become due after the
+// rule was last changed are notified, so a new or re-enabled rule does not
+// nudge the whole backlog at once.
+type AutomationRule struct {
+	ID         string    `json:"id"`
+	Trigger    string    `json:"trigger"`
+	DelayHours int       `json:"delayHours"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type AutomationRuleRequest struct {
+	Trigger    string `json:"trigger"`
+	DelayHours int    `json:"delayHours"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	Enabled    bool   `json:"enabled"`
+}
+
+// AutomationRuleView is a rule as the admin API shows it.
+type AutomationRuleView struct {
+	AutomationRule
+	// Sent is how many notifications the rule has sent.
+	Sent int `json:"sent"`
+}
+
+// AutomationRun records that a rule notified a user about a subject, so it
+// does so only once.
+type AutomationRun struct {
+	RuleID string    `json:"ruleId"`
+	UserID string    `json:"userId"`
+	Key    string    `json:"key"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+func (req AutomationRuleRequest) valid() bool {
+	return (req.Trigger == AutomationMatchSilent || req.Trigger == AutomationSessionStalled) &&
+		req.DelayHours > 0 && req.DelayHours <= maxAutomationDelayDays*24 &&
+		strings.TrimSpace(req.Title) != "" && strings.TrimSpace(req.Body) != ""
+}
+
+func (rule AutomationRule) delay() time.Duration {
+	return time.Duration(rule.DelayHours) * time.Hour
+}
+
+// runAutomations sends the notifications of every enabled rule that came
+// due since the last run.
+func (c *Controller) runAutomations() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !slices.ContainsFunc(c.storage.AutomationRules, func(rule AutomationRule) bool { return rule.Enabled }) {
+		return
+	}
+
+	sent := make(map[string]bool, len(c.storage.AutomationRuns))
+	for _, run := range c.storage.AutomationRuns {
+		sent[run.RuleID+"|"+run.UserID+"|"+run.Key] = true
+	}
+	lastMessage := make(map[string]time.Time)
+	for _, m := range c.storage.Messages {
+		if m.CreatedAt.After(lastMessage[m.MatchID]) {
+			lastMessage[m.MatchID] = m.CreatedAt
+		}
+	}
+
+	now := time.Now()
+	notified := 0
+	notify := func(rule AutomationRule, userID, partnerID, key string, data map[string]string) {
+		if sent[rule.ID+"|"+userID+"|"+key] || c.storage.isBlocked(userID, partnerID) {
+			return
+		}
+		partner, _ := c.findUser(partnerID)
+		data["type"] = "automation"
+		data["trigger"] = rule.Trigger
+		c.notifyUser(userID, Notification{
+			Title: strings.ReplaceAll(rule.Title, "{name}", partner.Name),
+			Body:  strings.ReplaceAll(rule.Body, "{name}", partner.Name),
+			Data:  data,
+		})
+		c.storage.AutomationRuns = append(c.storage.AutomationRuns, AutomationRun{
+			RuleID: rule.ID,
+			UserID: userID,
+			Key:    key,
+			SentAt: now,
+		})
+		notified++
+	}
+
+	for _, rule := range c.storage.AutomationRules {
+		if !rule.Enabled {
+			continue
+		}
+		switch rule.Trigger {
+		case AutomationMatchSilent:
+			for _, m := range c.storage.Matches {
+				due := m.CreatedAt.Add(rule.delay())
+				if due.After(now) || due.Before(rule.UpdatedAt) || !lastMessage[m.ID()].IsZero() {
+					continue
+				}
+				for _, uid := range []string{m.User1ID, m.User2ID} {
+					notify(rule, uid, m.Partner(uid), m.ID(), map[string]string{"matchId": m.ID()})
+				}
+			}
+
+		case AutomationSessionStalled:
+			for _, s := range c.storage.Sessions {
+				due := s.UpdatedAt.Add(rule.delay())
+				if s.Status != SessionProposed || due.After(now) || due.Before(rule.UpdatedAt) ||
+					lastMessage[s.MatchID].After(s.UpdatedAt) || !s.StartsAt().After(now) {
+					continue
+				}
+				// A reschedule is a new proposal worth its own reminder.
+				key := s.ID + ":" + strconv.Itoa(s.Reschedules)
+				notify(rule, s.ProposedBy, s.ProposedTo, key, map[string]string{"matchId": s.MatchID, "sessionId": s.ID})
+			}
+		}
+	}
+
+	if notified == 0 {
+		return
+	}
+	slog.Info("Sent automation notifications", "count", notified)
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to save data", "err", err)
+	}
+}
+
+// forgetAutomationRuns removes the runs that notified uid. The caller must
+// hold c.mu and save data.
+func (c *Controller) forgetAutomationRuns(uid string) {
+	runs := c.storage.AutomationRuns[:0]
+	for _, run := range c.storage.AutomationRuns {
+		if run.UserID != uid {
+			runs = append(runs, run)
+		}
+	}
+	c.storage.AutomationRuns = runs
+}
+
+func (c *Controller) automationRuleView(rule AutomationRule) AutomationRuleView {
+	view := AutomationRuleView{AutomationRule: rule}
+	for _, run := range c.storage.AutomationRuns {
+		if run.RuleID == rule.ID {
+			view.Sent++
+		}
+	}
+	return view
+}
+
+// AdminAutomations handles GET /admin/automations.
+func (c *Controller) AdminAutomations(w http.ResponseWriter, r *http.Request) {
+	rules := make([]AutomationRuleView, 0, len(c.storage.AutomationRules))
+	for _, rule := range c.storage.AutomationRules {
+		rules = append(rules, c.automationRuleView(rule))
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// decodeAutomationRule reads a new rule from r's body, writing a 400 if it
+// is invalid.
+func decodeAutomationRule(w http.ResponseWriter, r *http.Request) (AutomationRule, bool) {
+	var req AutomationRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return AutomationRule{}, false
+	}
+	if !req.valid() {
+		writeError(w, http.StatusBadRequest, "INVALID_AUTOMATION_RULE",
+			"Trigger must be match_silent or session_stalled, delayHours 1 to 720, title and body set")
+		return AutomationRule{}, false
+	}
+
+	now := time.Now()
+	return AutomationRule{
+		ID:         newID(),
+		Trigger:    req.Trigger,
+		DelayHours: req.DelayHours,
+		Title:      strings.TrimSpace(req.Title),
+		Body:       strings.TrimSpace(req.Body),
+		Enabled:    req.Enabled,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, true
+}
+
+// AdminCreateAutomation handles POST /admin/automations.
+func (c *Controller) AdminCreateAutomation(w http.ResponseWriter, r *http.Request) {
+	rule, ok := decodeAutomationRule(w, r)
+	if !ok {
+		return
+	}
+	c.storage.AutomationRules = append(c.storage.AutomationRules, rule)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, c.automationRuleView(rule))
+}
+
+// pathAutomation finds the rule named by the {id} path value, writing a 404
+// if there is none.
+func (c *Controller) pathAutomation(w http.ResponseWriter, r *http.Request) (int, bool) {
+	i := slices.IndexFunc(c.storage.AutomationRules, func(rule AutomationRule) bool { return rule.ID == r.PathValue("id") })
+	if i == -1 {
+		writeError(w, http.StatusNotFound, "AUTOMATION_RULE_NOT_FOUND", "Automation rule not found")
+	}
+	return i, i != -1
+}
+
+// AdminUpdateAutomation handles PUT /admin/automations/{id}.
+func (c *Controller) AdminUpdateAutomation(w http.ResponseWriter, r *http.Request) {
+	rule, ok := decodeAutomationRule(w, r)
+	if !ok {
+		return
+	}
+	i, ok := c.pathAutomation(w, r)
+	if !ok {
+		return
+	}
+	rule.ID, rule.CreatedAt = c.storage.AutomationRules[i].ID, c.storage.AutomationRules[i].CreatedAt
+	c.storage.AutomationRules[i] = rule
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c.automationRuleView(rule))
+}
+
+// AdminDeleteAutomation handles DELETE /admin/automations/{id}, dropping the
+// rule's run history with it.
+func (c *Controller) AdminDeleteAutomation(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathAutomation(w, r)
+	if !ok {
+		return
+	}
+	id := c.storage.AutomationRules[i].ID
+	c.storage.AutomationRules = slices.Delete(c.storage.AutomationRules, i, i+1)
+	runs := c.storage.AutomationRuns[:0]
+	for _, run := range c.storage.AutomationRuns {
+		if run.RuleID != id {
+			runs = append(runs, run)
+		}
+	}
+	c.storage.AutomationRuns = runs
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
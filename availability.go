@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	maxAvailabilitySlots = 21
+
+	// legacySlotMinutes is how long the slot made from an old client's
+	// single day and time lasts.
+	legacySlotMinutes = 60
+)
+
+// Slot is a weekly window a user is free to train in.
+type Slot struct {
+	// Weekday is one of profileDays.
+	Weekday string `json:"weekday"`
+	// Start and End are HH:MM, with Start before End.
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (s Slot) String() string {
+	return s.Weekday + " " + s.Start + "-" + s.End
+}
+
+// minutes returns the slot's bounds in minutes after midnight. The slot must
+// be valid.
+func (s Slot) minutes() (start, end int) {
+	start, _ = parseClock(s.Start)
+	end, _ = parseClock(s.End)
+	return start, end
+}
+
+func (s Slot) validate() error {
+	if !slices.Contains(profileDays, s.Weekday) {
+		return fmt.Errorf("weekday must be one of %s", strings.Join(profileDays, ", "))
+	}
+	start, err := parseClock(s.Start)
+	if err != nil {
+		return fmt.Errorf("start must be HH:MM")
+	}
+	end, err := parseClock(s.End)
+	if err != nil {
+		return fmt.Errorf("end must be HH:MM")
+	}
+	if start >= end {
+		return errors.New("start must be before end")
+	}
+	return nil
+}
+
+// parseAvailability decodes the availability form field, a JSON array of
+// slots, and returns the slots sorted by weekday and start. Overlapping
+// slots on the same day are rejected.
+func parseAvailability(value string) ([]Slot, error) {
+	var slots []Slot
+	if err := json.Unmarshal([]byte(value), &slots); err != nil {
+		return nil, errors.New("must be a JSON array of {weekday, start, end}")
+	}
+	if len(slots) > maxAvailabilitySlots {
+		return nil, fmt.Errorf("at most %d slots are allowed", maxAvailabilitySlots)
+	}
+	for i, s := range slots {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("slot %d: %w", i+1, err)
+		}
+	}
+
+	sortSlots(slots)
+	for i := 1; i < len(slots); i++ {
+		prev, cur := slots[i-1], slots[i]
+		_, prevEnd := prev.minutes()
+		curStart, _ := cur.minutes()
+		if prev.Weekday == cur.Weekday && curStart < prevEnd {
+			return nil, fmt.Errorf("slots %s and %s overlap", prev, cur)
+		}
+	}
+	return slots, nil
+}
+
+func sortSlots(slots []Slot) {
+	sort.SliceStable(slots, func(i, j int) bool {
+		di, dj := slices.Index(profileDays, slots[i].Weekday), slices.Index(profileDays, slots[j].Weekday)
+		if di != dj {
+			return di < dj
+		}
+		si, _ := slots[i].minutes()
+		sj, _ := slots[j].minutes()
+		return si < sj
+	})
+}
+
+// overlapSlots returns the windows in which slots of a and b overlap by at
+// least minMinutes, and at least a minute, sorted like slots.
+func overlapSlots(a, b []Slot, minMinutes int) []Slot {
+	var windows []Slot
+	for _, x := range a {
+		xStart, xEnd := x.minutes()
+		for _, y := range b {
+			if x.Weekday != y.Weekday {
+				continue
+			}
+			yStart, yEnd := y.minutes()
+			start, end := max(xStart, yStart), min(xEnd, yEnd)
+			if end-start < max(minMinutes, 1) {
+				continue
+			}
+			windows = append(windows, Slot{Weekday: x.Weekday, Start: formatClock(start), End: formatClock(end)})
+		}
+	}
+	sortSlots(windows)
+	return windows
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// legacySlot turns the single day and time old clients send into a slot, or
+// returns nil when either is missing or invalid.
+func legacySlot(day, clock string) []Slot {
+	start, err := parseClock(clock)
+	if err != nil || !slices.Contains(profileDays, day) {
+		return nil
+	}
+	end := min(start+legacySlotMinutes, 23*60+59)
+	if end <= start {
+		return nil
+	}
+	return []Slot{{Weekday: day, Start: clock, End: formatClock(end)}}
+}
+
+// setAvailability replaces u's slots. The first one is mirrored into Day and
+// Time for clients that only know those.
+func (u *User) setAvailability(slots []Slot) {
+	u.Availability = slots
+	if len(slots) > 0 {
+		u.Day, u.Time = slots[0].Weekday, slots[0].Start
+	}
+}
+
+// formAvailability returns the slots a POST /api/profiles form sets, once
+// it has been validated. Old clients only send day and time: if those are
+// unchanged the stored slots of before are kept, otherwise they are
+// replaced by a single slot.
+func formAvailability(r *http.Request, before *User) []Slot {
+	if _, ok := r.Form["availability"]; ok {
+		slots, _ := parseAvailability(r.FormValue("availability"))
+		return slots
+	}
+
+	day, clock := r.FormValue("day"), r.FormValue("time")
+	if before != nil && before.Day == day && before.Time == clock {
+		return before.Availability
+	}
+	return legacySlot(day, clock)
+}
+
+func (u User) availabilityString() string {
+	parts := make([]string, len(u.Availability))
+	for i, s := range u.Availability {
+		parts[i] = s.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// migrateAvailability gives profiles saved with only a day and time the
+// matching slot.
+func (s *Storage) migrateAvailability() {
+	for i, u := range s.Users {
+		if len(u.Availability) == 0 {
+			s.Users[i].Availability = legacySlot(u.Day, u.Time)
+		}
+	}
+}
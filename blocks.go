@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Block struct {
+	BlockerID string    `json:"blockerId"`
+	BlockedID string    `json:"blockedId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Report struct {
+	ID         string    `json:"id"`
+	ReporterID string    `json:"reporterId"`
+	ReportedID string    `json:"reportedId"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details,omitempty"`
+	Context    string    `json:"context,omitempty"`
+	ContextID  string    `json:"contextId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Status is empty while the report is open, then dismissed or escalated
+	// by a gym moderator.
+	Status     string              `json:"status,omitempty"`
+	Moderation *ModerationDecision `json:"moderation,omitempty"`
+}
+
+type BlockRequest struct {
+	BlockerID string `json:"blockerId"`
+	BlockedID string `json:"blockedId"`
+}
+
+// ReportRequest can be sent from any context (profile, chat, session); Block
+// additionally blocks the reported user in the same call.
+type ReportRequest struct {
+	ReporterID string `json:"reporterId"`
+	ReportedID string `json:"reportedId"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+	Context    string `json:"context"`
+	ContextID  string `json:"contextId"`
+	Block      bool   `json:"block"`
+}
+
+// isBlocked reports whether either user has blocked the other.
+func (s *Storage) isBlocked(a, b string) bool {
+	for _, block := range s.Blocks {
+		if (block.BlockerID == a && block.BlockedID == b) ||
+			(block.BlockerID == b && block.BlockedID == a) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Controller) block(blockerID, blockedID string) {
+	if !c.storage.isBlocked(blockerID, blockedID) {
+		c.storage.Blocks = append(c.storage.Blocks, Block{
+			BlockerID: blockerID,
+			BlockedID: blockedID,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	matches := c.storage.Matches[:0]
+	for _, m := range c.storage.Matches {
+		if m.Has(blockerID) && m.Has(blockedID) {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	c.storage.Matches = matches
+}
+
+func (c *Controller) BlockUser(w http.ResponseWriter, r *http.Request) {
+	var req BlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	if req.BlockerID == "" || req.BlockedID == "" || req.BlockerID == req.BlockedID {
+		writeError(w, http.StatusBadRequest, "INVALID_BLOCK_REQUEST", "Invalid block request")
+		return
+	}
+
+	if _, ok := c.findUser(req.BlockedID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	c.block(req.BlockerID, req.BlockedID)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+func (c *Controller) ReportUser(w http.ResponseWriter, r *http.Request) {
+	var req ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	if req.ReporterID == "" || req.ReportedID == "" || req.Reason == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REPORT", "Reporter, reported user and reason are required")
+		return
+	}
+
+	if _, ok := c.findUser(req.ReportedID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	report := Report{
+		ID:         newID(),
+		ReporterID: req.ReporterID,
+		ReportedID: req.ReportedID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+		Context:    req.Context,
+		ContextID:  req.ContextID,
+		CreatedAt:  time.Now(),
+	}
+	c.storage.Reports = append(c.storage.Reports, report)
+	c.flagReport(report)
+
+	if req.Block {
+		c.block(req.ReporterID, req.ReportedID)
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, report)
+}
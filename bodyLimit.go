@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// swipeBodyBytes caps swipe and undo bodies, which only carry two IDs.
+const swipeBodyBytes = 1 << 10
+
+// limitBody caps request bodies at n bytes. A request declaring a larger
+// Content-Length is rejected with 413 before the handler runs; otherwise
+// reads past n fail, which decoding handlers report as an invalid body.
+func limitBody(n int64) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				writeBodyTooLarge(w, n)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next(w, r)
+		}
+	}
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	writeErrorDetails(w, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE",
+		fmt.Sprintf("Request body must be at most %d bytes", limit), map[string]int64{"limit": limit})
+}
+
+// parseUpload parses a multipart upload, keeping at most c.multipartMemory
+// bytes of it in memory and the rest in temporary files. It writes the error
+// response and returns false on failure.
+func (c *Controller) parseUpload(w http.ResponseWriter, r *http.Request) bool {
+	err := r.ParseMultipartForm(c.multipartMemory)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeBodyTooLarge(w, tooLarge.Limit)
+		return false
+	}
+	writeError(w, http.StatusBadRequest, "INVALID_MULTIPART_FORM", "Failed to parse multipart form")
+	return false
+}
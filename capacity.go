@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultCapacityWindowDays = 30
+	maxCapacityWindowDays     = 365
+
+	// dataFileLimitBytes is the snapshot size past which rewriting the
+	// whole JSON file on every save gets too slow, and the data should move
+	// to a database.
+	dataFileLimitBytes = 256 << 20
+
+	// capacitySampleSize is how many of the newest records per kind are
+	// marshaled to estimate their size.
+	capacitySampleSize = 500
+)
+
+type CapacityCounts struct {
+	Users      float64 `json:"users"`
+	Swipes     float64 `json:"swipes"`
+	Matches    float64 `json:"matches"`
+	Messages   float64 `json:"messages"`
+	Images     float64 `json:"images"`
+	ImageBytes float64 `json:"imageBytes"`
+}
+
+type DataFileCapacity struct {
+	Bytes        int64 `json:"bytes"`
+	JournalBytes int64 `json:"journalBytes"`
+	// GrowthBytesPerDay is estimated from the average JSON size of each
+	// kind of record and how fast it is being added.
+	GrowthBytesPerDay float64 `json:"growthBytesPerDay"`
+	LimitBytes        int64   `json:"limitBytes"`
+	// DaysUntilLimit is left out while the data file is not growing.
+	DaysUntilLimit    *float64 `json:"daysUntilLimit,omitempty"`
+	SaveLatencyMeanMs float64  `json:"saveLatencyMeanMs"`
+}
+
+// CapacityReport is the storage growth of the last WindowDays, for planning
+// migrations of the data file and image store.
+type CapacityReport struct {
+	WindowDays int              `json:"windowDays"`
+	Since      time.Time        `json:"since"`
+	Totals     CapacityCounts   `json:"totals"`
+	PerDay     CapacityCounts   `json:"perDay"`
+	DataFile   DataFileCapacity `json:"dataFile"`
+	ImageStore string           `json:"imageStore"`
+}
+
+// averageJSONSize returns the mean marshaled size of the newest records.
+func averageJSONSize[T any](records []T) float64 {
+	if len(records) == 0 {
+		return 0
+	}
+	sample := records[max(len(records)-capacitySampleSize, 0):]
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return 0
+	}
+	return float64(len(data)) / float64(len(sample))
+}
+
+// capacityReport measures growth over the last days. It lists the image
+// store, which may be slow for remote stores.
+func (c *Controller) capacityReport(r *http.Request, days int) CapacityReport {
+	since := time.Now().AddDate(0, 0, -days)
+	report := CapacityReport{WindowDays: days, Since: since, ImageStore: c.imageStoreName()}
+
+	var recent CapacityCounts
+	report.Totals.Users = float64(len(c.storage.Users))
+	for _, u := range c.storage.Users {
+		if u.CreatedAt.After(since) {
+			recent.Users++
+		}
+	}
+	report.Totals.Swipes = float64(len(c.storage.Swipes))
+	for _, s := range c.storage.Swipes {
+		if s.CreatedAt.After(since) {
+			recent.Swipes++
+		}
+	}
+	report.Totals.Matches = float64(len(c.storage.Matches))
+	for _, m := range c.storage.Matches {
+		if m.CreatedAt.After(since) {
+			recent.Matches++
+		}
+	}
+	report.Totals.Messages = float64(len(c.storage.Messages))
+	for _, m := range c.storage.Messages {
+		if m.CreatedAt.After(since) {
+			recent.Messages++
+		}
+	}
+
+	images, err := c.images.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list images", "err", err)
+	}
+	for _, img := range images {
+		report.Totals.Images++
+		report.Totals.ImageBytes += float64(img.Size)
+		if img.ModTime.After(since) {
+			recent.Images++
+			recent.ImageBytes += float64(img.Size)
+		}
+	}
+
+	perDay := func(n float64) float64 { return n / float64(days) }
+	report.PerDay = CapacityCounts{
+		Users:      perDay(recent.Users),
+		Swipes:     perDay(recent.Swipes),
+		Matches:    perDay(recent.Matches),
+		Messages:   perDay(recent.Messages),
+		Images:     perDay(recent.Images),
+		ImageBytes: perDay(recent.ImageBytes),
+	}
+
+	file := DataFileCapacity{
+		LimitBytes:        dataFileLimitBytes,
+		SaveLatencyMeanMs: c.metrics.StorageSave.Mean("") * 1000,
+		GrowthBytesPerDay: report.PerDay.Users*averageJSONSize(c.storage.Users) +
+			report.PerDay.Swipes*averageJSONSize(c.storage.Swipes) +
+			report.PerDay.Matches*averageJSONSize(c.storage.Matches) +
+			report.PerDay.Messages*averageJSONSize(c.storage.Messages),
+	}
+	if info, err := os.Stat(c.dataFile); err == nil {
+		file.Bytes = info.Size()
+	}
+	if info, err := os.Stat(c.journalPath()); err == nil {
+		file.JournalBytes = info.Size()
+	}
+	if file.GrowthBytesPerDay > 0 {
+		days := max(float64(file.LimitBytes-file.Bytes), 0) / file.GrowthBytesPerDay
+		file.DaysUntilLimit = &days
+	}
+	report.DataFile = file
+
+	return report
+}
+
+func (c *Controller) imageStoreName() string {
+	if _, ok := c.images.(*LocalImageStore); ok {
+		return "local"
+	}
+	return "s3"
+}
+
+// AdminCapacity handles GET /admin/capacity?days=30.
+func (c *Controller) AdminCapacity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	days := defaultCapacityWindowDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxCapacityWindowDays {
+			writeError(w, http.StatusBadRequest, "INVALID_WINDOW", "days must be between 1 and 365")
+			return
+		}
+		days = n
+	}
+
+	writeJSON(w, http.StatusOK, c.capacityReport(r, days))
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	messageEditWindow = 15 * time.Minute
+	maxMessageLength  = 2000
+)
+
+type Message struct {
+	ID        string          `json:"id"`
+	MatchID   string          `json:"matchId"`
+	SenderID  string          `json:"senderId"`
+	Type      string          `json:"type,omitempty"`
+	Text      string          `json:"text"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Preview   *LinkPreview    `json:"preview,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	EditedAt  *time.Time      `json:"editedAt,omitempty"`
+	DeletedAt *time.Time      `json:"deletedAt,omitempty"`
+	Deleted   bool            `json:"deleted,omitempty"`
+}
+
+// MessageEdit keeps the previous text of an edited or deleted message for
+// moderation. Edits are never returned to chat participants.
+type MessageEdit struct {
+	MessageID string    `json:"messageId"`
+	Text      string    `json:"text"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+type EditMessageRequest struct {
+	UserID string `json:"userId"`
+	Text   string `json:"text"`
+}
+
+// View returns the message as shown to chat participants; deleted messages
+// become a placeholder.
+func (m Message) View() Message {
+	if m.DeletedAt != nil {
+		m.Text = ""
+		m.Payload = nil
+		m.Preview = nil
+		m.Deleted = true
+	}
+	return m
+}
+
+// linkPreview builds the preview of the first link in text, or returns nil.
+// The page is fetched with c.mu released, so the caller must look storage
+// up again afterwards.
+func (c *Controller) linkPreview(ctx context.Context, text string) *LinkPreview {
+	link := FirstURL(text)
+	if link == "" {
+		return nil
+	}
+
+	var (
+		preview *LinkPreview
+		err     error
+	)
+	c.unlocked(ctx, func() { preview, err = c.previews.Preview(ctx, link) })
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to build link preview", "link", link, "err", err)
+		return nil
+	}
+	return preview
+}
+
+func (c *Controller) findMessage(id string) (int, bool) {
+	for i, m := range c.storage.Messages {
+		if m.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// editableMessage returns the index of message id if userID may edit it,
+// or writes the error response.
+func (c *Controller) editableMessage(w http.ResponseWriter, id, userID string) (int, bool) {
+	i, ok := c.findMessage(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "MESSAGE_NOT_FOUND", "Message not found")
+		return -1, false
+	}
+
+	m := c.storage.Messages[i]
+	if m.SenderID != userID {
+		writeError(w, http.StatusForbidden, "MESSAGE_EDIT_FORBIDDEN", "Only the sender can edit a message")
+		return -1, false
+	}
+	if m.DeletedAt != nil {
+		writeError(w, http.StatusConflict, "MESSAGE_DELETED", "Message was deleted")
+		return -1, false
+	}
+	if m.Type != "" && m.Type != MessageTypeText {
+		writeError(w, http.StatusConflict, "CARD_NOT_EDITABLE", "Cards cannot be edited")
+		return -1, false
+	}
+	if time.Since(m.CreatedAt) > messageEditWindow {
+		writeError(w, http.StatusForbidden, "EDIT_WINDOW_PASSED", "Edit window has passed")
+		return -1, false
+	}
+	return i, true
+}
+
+func (c *Controller) editMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" || len([]rune(req.Text)) > maxMessageLength {
+		writeError(w, http.StatusBadRequest, "INVALID_MESSAGE", "Invalid message text")
+		return
+	}
+	if _, ok := c.editableMessage(w, id, req.UserID); !ok {
+		return
+	}
+	preview := c.linkPreview(r.Context(), req.Text)
+	// The message may have been deleted while the preview was fetched.
+	i, ok := c.editableMessage(w, id, req.UserID)
+	if !ok {
+		return
+	}
+	m := c.storage.Messages[i]
+	now := time.Now()
+
+	c.storage.MessageEdits = append(c.storage.MessageEdits, MessageEdit{
+		MessageID: m.ID,
+		Text:      m.Text,
+		ChangedAt: now,
+	})
+
+	m.Text = req.Text
+	m.EditedAt = &now
+	m.Preview = preview
+	c.flagMessage(m)
+	c.storage.Messages[i] = m
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, m.View())
+}
+
+func (c *Controller) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	userID := r.URL.Query().Get("userId")
+
+	i, ok := c.findMessage(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "MESSAGE_NOT_FOUND", "Message not found")
+		return
+	}
+
+	m := c.storage.Messages[i]
+	if m.SenderID != userID {
+		writeError(w, http.StatusForbidden, "MESSAGE_DELETE_FORBIDDEN", "Only the sender can delete a message")
+		return
+	}
+
+	if m.DeletedAt == nil {
+		now := time.Now()
+		m.DeletedAt = &now
+		c.storage.Messages[i] = m
+
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, m.View())
+}
+
+const (
+	defaultMessagesLimit = 50
+	maxMessagesLimit     = 100
+)
+
+// SendMessageRequest carries either a text message or a typed card with an
+// optional text caption.
+type SendMessageRequest struct {
+	SenderID string          `json:"senderId"`
+	Type     string          `json:"type"`
+	Text     string          `json:"text"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+type MessagesPage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+// MatchMessages handles GET and POST on /api/matches/{matchId}/messages.
+func (c *Controller) MatchMessages(w http.ResponseWriter, r *http.Request) {
+	match, ok := c.findMatch(r.PathValue("matchId"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "MATCH_NOT_FOUND", "Match not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c.listMessages(w, r, match)
+	case http.MethodPost:
+		c.sendMessage(w, r, match)
+	}
+}
+
+var (
+	errNotParticipant       = errors.New("not a participant of this match")
+	errInvalidMessage       = errors.New("invalid message text")
+	errConversationLimit    = errors.New("too many new conversations today")
+	errMessageRequestDenied = errors.New("message request was declined")
+	errMessageRequestWait   = errors.New("message request is pending")
+)
+
+func messageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidMessage):
+		return http.StatusBadRequest
+	case errors.Is(err, errConversationLimit), errors.Is(err, errMessagingRestricted):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errNotParticipant),
+		errors.Is(err, errMessageRequestDenied),
+		errors.Is(err, errMessageRequestWait):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// postMessage appends a message from senderID to the match chat, applying
+// message request rules and responsiveness tracking. preview comes from
+// linkPreview on req.Text. The caller saves data.
+func (c *Controller) postMessage(match Match, req SendMessageRequest, preview *LinkPreview) (Message, error) {
+	senderID := req.SenderID
+	if !match.Has(senderID) {
+		return Message{}, errNotParticipant
+	}
+
+	text := strings.TrimSpace(req.Text)
+	if len([]rune(text)) > maxMessageLength {
+		return Message{}, errInvalidMessage
+	}
+
+	var payload json.RawMessage
+	if req.Type == "" || req.Type == MessageTypeText {
+		if text == "" {
+			return Message{}, errInvalidMessage
+		}
+	} else {
+		var err error
+		if payload, err = normalizeCard(req.Type, req.Payload); err != nil {
+			return Message{}, fmt.Errorf("%w: %v", errInvalidMessage, err)
+		}
+	}
+
+	now := time.Now()
+	if err := c.checkRestrictedSender(senderID, now); err != nil {
+		return Message{}, err
+	}
+	partnerID := match.Partner(senderID)
+
+	var first *Message
+	senderHasWritten := false
+	for i, m := range c.storage.Messages {
+		if m.MatchID != match.ID() {
+			continue
+		}
+		if first == nil {
+			first = &c.storage.Messages[i]
+		}
+		if m.SenderID == senderID {
+			senderHasWritten = true
+		}
+	}
+
+	if first == nil {
+		if !c.canStartConversation(senderID) {
+			return Message{}, errConversationLimit
+		}
+		c.openConversation(senderID, partnerID)
+		c.recordFirstMessage(partnerID)
+	} else if i, ok := c.findMessageRequest(first.SenderID, match.Partner(first.SenderID)); ok {
+		request := &c.storage.MessageRequests[i]
+		switch {
+		case request.Status == MessageRequestDeclined:
+			return Message{}, errMessageRequestDenied
+		case request.Status == MessageRequestPending && request.FromID == senderID:
+			return Message{}, errMessageRequestWait
+		case request.Status == MessageRequestPending:
+			// Replying to a request accepts it.
+			request.Status = MessageRequestAccepted
+			request.RespondedAt = &now
+		}
+	}
+
+	if first != nil && first.SenderID != senderID && !senderHasWritten {
+		c.recordFirstResponse(senderID, now.Sub(first.CreatedAt))
+	}
+
+	message := Message{
+		ID:        newID(),
+		MatchID:   match.ID(),
+		SenderID:  senderID,
+		Type:      req.Type,
+		Text:      text,
+		Payload:   payload,
+		Preview:   preview,
+		CreatedAt: now,
+	}
+	c.flagMessage(message)
+	c.storage.Messages = append(c.storage.Messages, message)
+	c.metrics.MessagesSent.Inc()
+	if message.Type == MessageTypeSessionProposal {
+		c.metrics.SessionsScheduled.Inc()
+	}
+
+	sender, _ := c.findUser(senderID)
+	c.events.Publish(partnerID, Notification{
+		Title: "Новое сообщение",
+		Body:  sender.Name,
+		Data: map[string]string{
+			"type":      "message",
+			"matchId":   match.ID(),
+			"messageId": message.ID,
+		},
+	})
+
+	return message, nil
+}
+
+func (c *Controller) sendMessage(w http.ResponseWriter, r *http.Request, match Match) {
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	if !c.requireFeature(w, r, "chat", req.SenderID) {
+		return
+	}
+
+	if !match.Has(req.SenderID) {
+		err := errNotParticipant
+		writeError(w, messageErrorStatus(err), errorCode(err, "INTERNAL_ERROR"), err.Error())
+		return
+	}
+
+	preview := c.linkPreview(r.Context(), req.Text)
+	// The match may have ended while the preview was fetched.
+	match, ok := c.findMatch(match.ID())
+	if !ok {
+		writeError(w, http.StatusNotFound, "MATCH_NOT_FOUND", "Match not found")
+		return
+	}
+
+	message, err := c.postMessage(match, req, preview)
+	if err != nil {
+		writeError(w, messageErrorStatus(err), errorCode(err, "INTERNAL_ERROR"), err.Error())
+		return
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, message)
+}
+
+// listMessages returns the newest messages first; older pages are fetched via
+// ?cursor= with the nextCursor of the previous response. Messages within a
+// page are in chronological order.
+func (c *Controller) listMessages(w http.ResponseWriter, r *http.Request, match Match) {
+	query := r.URL.Query()
+	if !match.Has(query.Get("userId")) {
+		writeError(w, http.StatusForbidden, "NOT_MATCH_PARTICIPANT", "Not a participant of this match")
+		return
+	}
+
+	limit, err := queryInt(query.Get("limit"), defaultMessagesLimit)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_LIMIT", "Invalid limit")
+		return
+	}
+	if limit > maxMessagesLimit {
+		limit = maxMessagesLimit
+	}
+
+	var messages []Message
+	for _, m := range c.storage.Messages {
+		if m.MatchID == match.ID() {
+			messages = append(messages, m)
+		}
+	}
+
+	end := len(messages)
+	if cursor := query.Get("cursor"); cursor != "" {
+		end = -1
+		for i, m := range messages {
+			if m.ID == cursor {
+				end = i
+				break
+			}
+		}
+		if end == -1 {
+			writeError(w, http.StatusBadRequest, "INVALID_CURSOR", "Invalid cursor")
+			return
+		}
+	}
+
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+
+	page := MessagesPage{Messages: make([]Message, 0, end-start)}
+	for _, m := range messages[start:end] {
+		page.Messages = append(page.Messages, m.View())
+	}
+	if start > 0 {
+		page.NextCursor = messages[start].ID
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
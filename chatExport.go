@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const deletedMessagePlaceholder = "<message deleted>"
+
+type ChatTranscript struct {
+	MatchID      string    `json:"matchId"`
+	Participants []User    `json:"participants"`
+	Messages     []Message `json:"messages"`
+}
+
+func (c *Controller) chatTranscript(match Match) ChatTranscript {
+	transcript := ChatTranscript{
+		MatchID:  match.ID(),
+		Messages: []Message{},
+	}
+
+	for _, id := range []string{match.User1ID, match.User2ID} {
+		if u, ok := c.findUser(id); ok {
+			transcript.Participants = append(transcript.Participants, u.Public())
+		}
+	}
+
+	for _, m := range c.storage.Messages {
+		if m.MatchID == transcript.MatchID {
+			transcript.Messages = append(transcript.Messages, m.View())
+		}
+	}
+
+	return transcript
+}
+
+func (t ChatTranscript) Text() string {
+	names := make(map[string]string)
+	for _, p := range t.Participants {
+		names[p.FirebaseUID] = p.Name
+	}
+
+	var b strings.Builder
+	for _, m := range t.Messages {
+		name := names[m.SenderID]
+		if name == "" {
+			name = m.SenderID
+		}
+
+		text := m.Text
+		if summary := cardSummary(m); summary != "" {
+			text = strings.TrimSpace(summary + " " + text)
+		}
+		if m.Deleted {
+			text = deletedMessagePlaceholder
+		}
+
+		fmt.Fprintf(&b, "[%s] %s: %s\n", m.CreatedAt.Format("2006-01-02 15:04"), name, text)
+	}
+	return b.String()
+}
+
+// ExportChat handles GET /api/matches/{matchId}/export?userId=...&format=json|text.
+func (c *Controller) ExportChat(w http.ResponseWriter, r *http.Request) {
+	matchID := r.PathValue("matchId")
+	match, ok := c.findMatch(matchID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "MATCH_NOT_FOUND", "Match not found")
+		return
+	}
+
+	if !match.Has(r.URL.Query().Get("userId")) {
+		writeError(w, http.StatusForbidden, "NOT_MATCH_PARTICIPANT", "Not a participant of this chat")
+		return
+	}
+
+	transcript := c.chatTranscript(match)
+	filename := "chat-" + strings.ReplaceAll(matchID, ":", "-")
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		writeJSON(w, http.StatusOK, transcript)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.txt"`)
+		fmt.Fprint(w, transcript.Text())
+	default:
+		writeError(w, http.StatusBadRequest, "UNSUPPORTED_FORMAT", "Unsupported format")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	checkInActiveFor      = 3 * time.Hour
+	checkInMaxDistanceKm  = 1.0
+	nearbyPairCooldown    = 12 * time.Hour
+	nearbyDailyAlertLimit = 3
+)
+
+type CheckIn struct {
+	ID     string    `json:"id"`
+	UserID string    `json:"userId"`
+	GymID  string    `json:"gymId"`
+	At     time.Time `json:"at"`
+}
+
+// NearbyAlert records a sent "partner is nearby" alert for rate capping.
+type NearbyAlert struct {
+	ToID    string    `json:"toId"`
+	AboutID string    `json:"aboutId"`
+	At      time.Time `json:"at"`
+}
+
+type CheckInRequest struct {
+	UserID    string   `json:"userId"`
+	GymID     string   `json:"gymId"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+func (c *Controller) CheckIn(w http.ResponseWriter, r *http.Request) {
+	var req CheckInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	if _, ok := c.findUser(req.UserID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	gi, ok := c.findGym(req.GymID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "GYM_NOT_FOUND", "Gym not found")
+		return
+	}
+
+	// A coarse location, when sent, must be near the gym.
+	if req.Latitude != nil && req.Longitude != nil {
+		gym := c.storage.Gyms[gi]
+		if HaversineKm(*req.Latitude, *req.Longitude, gym.Latitude, gym.Longitude) > checkInMaxDistanceKm {
+			writeError(w, http.StatusBadRequest, "TOO_FAR_FROM_GYM", "You are too far from this gym")
+			return
+		}
+	}
+
+	checkIn := CheckIn{
+		ID:     newID(),
+		UserID: req.UserID,
+		GymID:  req.GymID,
+		At:     time.Now(),
+	}
+	c.storage.CheckIns = append(c.storage.CheckIns, checkIn)
+
+	c.alertNearbyPartners(checkIn)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, checkIn)
+}
+
+// activeGym returns the gym userID checked into within checkInActiveFor.
+func (c *Controller) activeGym(userID string, now time.Time) string {
+	for i := len(c.storage.CheckIns) - 1; i >= 0; i-- {
+		ci := c.storage.CheckIns[i]
+		if now.Sub(ci.At) > checkInActiveFor {
+			break
+		}
+		if ci.UserID == userID {
+			return ci.GymID
+		}
+	}
+	return ""
+}
+
+func (c *Controller) canSendNearbyAlert(toID, aboutID string, now time.Time) bool {
+	sentToday := 0
+	for _, a := range c.storage.NearbyAlerts {
+		if a.ToID != toID {
+			continue
+		}
+		if a.AboutID == aboutID && now.Sub(a.At) < nearbyPairCooldown {
+			return false
+		}
+		if now.Sub(a.At) < 24*time.Hour {
+			sentToday++
+		}
+	}
+	return sentToday < nearbyDailyAlertLimit
+}
+
+// alertNearbyPartners tells matched partners who are checked into the same
+// gym right now. Both sides must have opted in to nearby alerts.
+func (c *Controller) alertNearbyPartners(checkIn CheckIn) {
+	user, _ := c.findUser(checkIn.UserID)
+	if !user.NearbyAlerts {
+		return
+	}
+
+	for _, m := range c.storage.Matches {
+		if !m.Has(checkIn.UserID) {
+			continue
+		}
+
+		partner, ok := c.findUser(m.Partner(checkIn.UserID))
+		if !ok || !partner.NearbyAlerts {
+			continue
+		}
+
+		if c.activeGym(partner.FirebaseUID, checkIn.At) != checkIn.GymID {
+			continue
+		}
+
+		if !c.canSendNearbyAlert(partner.FirebaseUID, user.FirebaseUID, checkIn.At) {
+			continue
+		}
+
+		c.storage.NearbyAlerts = append(c.storage.NearbyAlerts, NearbyAlert{
+			ToID:    partner.FirebaseUID,
+			AboutID: user.FirebaseUID,
+			At:      checkIn.At,
+		})
+		c.notifyUser(partner.FirebaseUID, Notification{
+			Title: "Напарник рядом",
+			Body:  user.Name + " тоже сейчас в зале",
+			Data: map[string]string{
+				"type":    "nearby",
+				"matchId": m.ID(),
+				"gymId":   checkIn.GymID,
+			},
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Package client is a Go client for the Gym Bro API, for integration tests
+// and internal tools. It covers the profile, swiping and chat endpoints;
+// other routes can be called through Do. Mobile and web clients are
+// generated from /openapi.json instead (make sdk).
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls one Gym Bro server.
+type Client struct {
+	// BaseURL is the server root, such as "https://api.gymbro.app".
+	BaseURL string
+	// Token is an optional gbu_ user token sent as a bearer token.
+	Token string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Error is an error response of the API. Code is stable and meant to be
+// branched on; Message is for humans.
+type Error struct {
+	Status  int             `json:"-"`
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gymbro: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// IsCode reports whether err is an API error with the given code.
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+type User struct {
+	FirebaseUID        string    `json:"firebaseUid"`
+	Name               string    `json:"name"`
+	ImageURL           string    `json:"imageUrl"`
+	Photos             []string  `json:"photos,omitempty"`
+	Time               string    `json:"time"`
+	Day                string    `json:"day"`
+	TextInfo           string    `json:"textInfo"`
+	BioLang            string    `json:"bioLang,omitempty"`
+	TrainType          string    `json:"trainType"`
+	Contact            string    `json:"contact"`
+	Availability       []Slot    `json:"availability,omitempty"`
+	AccessibilityNeeds []string  `json:"accessibilityNeeds,omitempty"`
+	AdaptiveTraining   bool      `json:"adaptiveTraining,omitempty"`
+	ShareAccessibility bool      `json:"shareAccessibility,omitempty"`
+	Latitude           *float64  `json:"latitude,omitempty"`
+	Longitude          *float64  `json:"longitude,omitempty"`
+	HomeGymID          string    `json:"homeGymId,omitempty"`
+	CreatedAt          time.Time `json:"createdAt"`
+	Responsiveness     string    `json:"responsiveness,omitempty"`
+	DistanceKm         *float64  `json:"distanceKm,omitempty"`
+	// Overlap is set on feed cards requested with minOverlap.
+	Overlap []Slot `json:"overlap,omitempty"`
+}
+
+// Slot is a weekly window a user is free to train in. Weekday is one of
+// Пн, Вт, Ср, Чт, Пт, Сб, Вс; Start and End are HH:MM.
+type Slot struct {
+	Weekday string `json:"weekday"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+type UsersPage struct {
+	Users  []User `json:"users"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// Profile is the form of UpsertProfile. Empty fields are not sent.
+type Profile struct {
+	FirebaseUID string
+	Name        string
+	Time        string
+	Day         string
+	TextInfo    string
+	TrainType   string
+	Contact     string
+	HomeGymID   string
+	// Availability replaces Time and Day when set.
+	Availability []Slot
+	// Image is the primary photo; ImageName is its file name.
+	Image     io.Reader
+	ImageName string
+}
+
+type Match struct {
+	User1ID         string    `json:"user1Id"`
+	User2ID         string    `json:"user2Id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	GoalID          string    `json:"goalId,omitempty"`
+	ContactSharedBy []string  `json:"contactSharedBy,omitempty"`
+}
+
+type MatchView struct {
+	ID                     string    `json:"id"`
+	User1ID                string    `json:"user1Id"`
+	User2ID                string    `json:"user2Id"`
+	CreatedAt              time.Time `json:"createdAt"`
+	GoalID                 string    `json:"goalId,omitempty"`
+	ContactSharedByMe      bool      `json:"contactSharedByMe"`
+	ContactSharedByPartner bool      `json:"contactSharedByPartner"`
+	Partner                User      `json:"partner"`
+}
+
+type SwipeRequest struct {
+	SwiperID string `json:"swiperId"`
+	TargetID string `json:"targetId"`
+	IsLike   bool   `json:"isLike"`
+	// DwellMs is how long the card was shown before the swipe.
+	DwellMs int64 `json:"dwellMs,omitempty"`
+}
+
+type SwipeResult struct {
+	Success bool   `json:"success"`
+	IsMatch bool   `json:"isMatch"`
+	Match   *Match `json:"match"`
+}
+
+type Message struct {
+	ID        string          `json:"id"`
+	MatchID   string          `json:"matchId"`
+	SenderID  string          `json:"senderId"`
+	Type      string          `json:"type,omitempty"`
+	Text      string          `json:"text"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	EditedAt  *time.Time      `json:"editedAt,omitempty"`
+	DeletedAt *time.Time      `json:"deletedAt,omitempty"`
+	Deleted   bool            `json:"deleted,omitempty"`
+}
+
+type MessagesPage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+// ListUsers calls GET /api/users. The offset is always sent, since
+// version 1 only answers with a page when paging is asked for.
+func (c *Client) ListUsers(ctx context.Context, limit, offset int) (*UsersPage, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	q.Set("offset", strconv.Itoa(offset))
+	var page UsersPage
+	return &page, c.Do(ctx, http.MethodGet, "/api/users", q, nil, &page)
+}
+
+// UpsertProfile calls POST /api/profiles.
+func (c *Client) UpsertProfile(ctx context.Context, p Profile) (*User, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := []struct{ name, value string }{
+		{"firebaseUid", p.FirebaseUID}, {"name", p.Name}, {"time", p.Time}, {"day", p.Day},
+		{"textInfo", p.TextInfo}, {"trainType", p.TrainType}, {"contact", p.Contact}, {"homeGymId", p.HomeGymID},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			form.WriteField(f.name, f.value)
+		}
+	}
+	if p.Availability != nil {
+		slots, err := json.Marshal(p.Availability)
+		if err != nil {
+			return nil, err
+		}
+		form.WriteField("availability", string(slots))
+	}
+	if p.Image != nil {
+		part, err := form.CreateFormFile("image", p.ImageName)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(part, p.Image); err != nil {
+			return nil, err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	var user User
+	return &user, c.send(ctx, http.MethodPost, "/api/profiles", nil, form.FormDataContentType(), &body, &user)
+}
+
+// NextUser calls GET /api/next-user/{uid}. An exhausted deck is an Error
+// with code DECK_EMPTY.
+func (c *Client) NextUser(ctx context.Context, uid string) (*User, error) {
+	var user User
+	return &user, c.Do(ctx, http.MethodGet, "/api/next-user/"+url.PathEscape(uid), nil, nil, &user)
+}
+
+// Feed calls GET /api/feed/{uid}. filter holds the optional query
+// parameters, such as count or trainType.
+func (c *Client) Feed(ctx context.Context, uid string, filter url.Values) ([]User, error) {
+	var users []User
+	err := c.Do(ctx, http.MethodGet, "/api/feed/"+url.PathEscape(uid), filter, nil, &users)
+	return users, err
+}
+
+// Swipe calls POST /api/swipe.
+func (c *Client) Swipe(ctx context.Context, req SwipeRequest) (*SwipeResult, error) {
+	var result SwipeResult
+	return &result, c.Do(ctx, http.MethodPost, "/api/swipe", nil, req, &result)
+}
+
+// UndoSwipe calls POST /api/swipe/undo.
+func (c *Client) UndoSwipe(ctx context.Context, swiperID string) error {
+	return c.Do(ctx, http.MethodPost, "/api/swipe/undo", nil, map[string]string{"swiperId": swiperID}, nil)
+}
+
+// Matches calls GET /api/matches/{uid}.
+func (c *Client) Matches(ctx context.Context, uid string) ([]MatchView, error) {
+	var matches []MatchView
+	err := c.Do(ctx, http.MethodGet, "/api/matches/"+url.PathEscape(uid), nil, nil, &matches)
+	return matches, err
+}
+
+// Messages calls GET /api/matches/{matchId}/messages. An empty cursor
+// returns the newest page.
+func (c *Client) Messages(ctx context.Context, matchID, userID, cursor string) (*MessagesPage, error) {
+	q := url.Values{"userId": {userID}}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	var page MessagesPage
+	return &page, c.Do(ctx, http.MethodGet, "/api/matches/"+url.PathEscape(matchID)+"/messages", q, nil, &page)
+}
+
+// SendMessage calls POST /api/matches/{matchId}/messages with a text
+// message.
+func (c *Client) SendMessage(ctx context.Context, matchID, senderID, text string) (*Message, error) {
+	body := map[string]string{"senderId": senderID, "text": text}
+	var msg Message
+	return &msg, c.Do(ctx, http.MethodPost, "/api/matches/"+url.PathEscape(matchID)+"/messages", nil, body, &msg)
+}
+
+// Do sends body as JSON to path and decodes the response into out, which
+// may be nil. Error responses are returned as *Error.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var (
+		r           io.Reader
+		contentType string
+	)
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r, contentType = bytes.NewReader(data), "application/json"
+	}
+	return c.send(ctx, method, path, query, contentType, r, out)
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var envelope struct {
+			Error *Error `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&envelope) != nil || envelope.Error == nil {
+			return &Error{Status: resp.StatusCode, Code: "UNKNOWN", Message: resp.Status}
+		}
+		envelope.Error.Status = resp.StatusCode
+		return envelope.Error
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	appVersionHeader  = "X-App-Version"
+	appPlatformHeader = "X-App-Platform"
+)
+
+type VersionPolicy struct {
+	MinSupported string `json:"minSupported,omitempty"`
+	Latest       string `json:"latest,omitempty"`
+	UpdateURL    string `json:"updateUrl,omitempty"`
+}
+
+type VersionSeen struct {
+	Version  string    `json:"version"`
+	Platform string    `json:"platform,omitempty"`
+	Requests int       `json:"requests"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// versionStats counts requests per client version since startup.
+type versionStats struct {
+	mu   sync.Mutex
+	seen map[string]*VersionSeen
+}
+
+func (s *versionStats) record(version, platform string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen == nil {
+		s.seen = make(map[string]*VersionSeen)
+	}
+
+	key := platform + "/" + version
+	v, ok := s.seen[key]
+	if !ok {
+		v = &VersionSeen{Version: version, Platform: platform}
+		s.seen[key] = v
+	}
+	v.Requests++
+	v.LastSeen = time.Now()
+}
+
+func (s *versionStats) snapshot() []VersionSeen {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]VersionSeen, 0, len(s.seen))
+	for _, v := range s.seen {
+		out = append(out, *v)
+	}
+	return out
+}
+
+// compareVersions compares dotted numeric versions like "1.4.2". Missing
+// or non-numeric components count as zero.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionGate records the client version and answers 426 Upgrade Required
+// to clients older than the minimum supported version. Requests without a
+// version header are let through.
+func (c *Controller) versionGate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version := r.Header.Get(appVersionHeader)
+		if version == "" {
+			next(w, r)
+			return
+		}
+
+		c.versions.record(version, r.Header.Get(appPlatformHeader))
+
+		c.mu.RLock()
+		policy := c.storage.VersionPolicy
+		c.mu.RUnlock()
+
+		if policy.MinSupported != "" && compareVersions(version, policy.MinSupported) < 0 {
+			writeErrorDetails(w, http.StatusUpgradeRequired, "UPGRADE_REQUIRED", "Please update the app", map[string]string{
+				"minSupported": policy.MinSupported,
+				"latest":       policy.Latest,
+				"updateUrl":    policy.UpdateURL,
+			})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (c *Controller) GetVersionPolicy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.storage.VersionPolicy)
+}
+
+// AdminVersionPolicy replaces the policy on PUT and returns it with the
+// versions seen since startup on GET.
+func (c *Controller) AdminVersionPolicy(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"policy": c.storage.VersionPolicy,
+			"seen":   c.versions.snapshot(),
+		})
+
+	case http.MethodPut:
+		var policy VersionPolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+
+		c.storage.VersionPolicy = policy
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+		writeJSON(w, http.StatusOK, policy)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+	}
+}
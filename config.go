@@ -0,0 +1,305 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the server settings. Values come from the defaults, then
+// environment variables, then command-line flags.
+type Config struct {
+	ListenAddr     string
+	DataFile       string
+	ImageDir       string
+	MaxUploadBytes int64
+	MaxImageBytes  int64
+	// MaxBodyBytes caps other request bodies. MultipartMemoryBytes of an
+	// upload are kept in memory while parsing, the rest in temporary files.
+	MaxBodyBytes         int64
+	MultipartMemoryBytes int64
+	CORSOrigins          []string
+	CORSMethods          []string
+	CORSHeaders          []string
+	LogLevel             slog.Level
+	ServeWebClient       bool
+
+	// RateLimitPerMinute and RateLimitBurst configure the per-client token
+	// bucket on /api/ routes; zero disables rate limiting.
+	RateLimitPerMinute int
+	RateLimitBurst     int
+	TrustProxy         bool
+
+	// TLSCertFile and TLSKeyFile switch the listener to HTTPS. With
+	// HTTPRedirectAddr set, plain HTTP requests there are redirected.
+	TLSCertFile      string
+	TLSKeyFile       string
+	HTTPRedirectAddr string
+
+	// GRPCAddr is where the gRPC API listens; empty disables it. It uses
+	// the TLS certificate when one is configured.
+	GRPCAddr string
+
+	// ImageStore is "local" to keep images in ImageDir or "s3" for an
+	// S3-compatible bucket. ImageURLs is "proxy" to serve images through
+	// the server or "signed" to redirect to presigned bucket URLs.
+	ImageStore        string
+	ImageURLs         string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+
+	// UniqueDisplayNames rejects profile names already used in the tenant.
+	UniqueDisplayNames bool
+
+	// MessageRequests makes the first message to a match a request the
+	// recipient has to accept. MaxNewConversations caps the conversations a
+	// user may open a day; zero disables the cap.
+	MessageRequests     bool
+	MaxNewConversations int
+
+	// BackupCount timestamped copies of the data file are kept, taken at
+	// most every BackupInterval. Zero disables backups.
+	BackupCount    int
+	BackupInterval time.Duration
+
+	// NewUserBoost is how long new profiles are boosted in others' decks.
+	// Zero disables the boost.
+	NewUserBoost time.Duration
+
+	// DecisionLogSample is the fraction of deck responses whose ranking is
+	// kept for /admin/decisions.
+	DecisionLogSample float64
+
+	// SignupRegions limits new sign-ups to these cities; others are put
+	// on a waitlist. Empty accepts sign-ups everywhere.
+	SignupRegions []Region
+
+	// LinkPreviewAllow limits chat link previews to these domains and their
+	// subdomains; empty allows any. LinkPreviewDeny is checked first.
+	LinkPreviewAllow []string
+	LinkPreviewDeny  []string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ListenAddr:     ":8080",
+		DataFile:       "data/storage.json",
+		ImageDir:       "data/images",
+		MaxUploadBytes: 10 << 20,
+		MaxImageBytes:  5 << 20,
+		MaxBodyBytes:   64 << 10,
+
+		MultipartMemoryBytes: 1 << 20,
+		CORSMethods:          []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		CORSHeaders: []string{"Content-Type", "Authorization", "X-App-Version", "X-App-Platform",
+			"X-Request-ID", "X-Tenant-ID"},
+		LogLevel:   slog.LevelInfo,
+		ImageStore: "local",
+		ImageURLs:  ImageURLsProxy,
+
+		BackupCount:    5,
+		BackupInterval: time.Hour,
+
+		NewUserBoost: 48 * time.Hour,
+
+		MaxNewConversations: DefaultMessagePolicy().MaxNewConversationsPerDay,
+	}
+}
+
+// LoadConfig reads LISTEN_ADDR, DATA_FILE, IMAGE_DIR, MAX_UPLOAD_BYTES,
+// MAX_IMAGE_BYTES, MAX_BODY_BYTES, MULTIPART_MEMORY_BYTES, CORS_ORIGINS, CORS_METHODS, CORS_HEADERS, LOG_LEVEL, SERVE_WEB_CLIENT,
+// RATE_LIMIT_RPM, RATE_LIMIT_BURST, TRUST_PROXY, TLS_CERT_FILE, TLS_KEY_FILE,
+// HTTP_REDIRECT_ADDR, GRPC_ADDR, IMAGE_STORE, IMAGE_URLS, S3_ENDPOINT, S3_REGION,
+// S3_BUCKET, UNIQUE_DISPLAY_NAMES, MESSAGE_REQUESTS, MAX_NEW_CONVERSATIONS,
+// BACKUP_COUNT, BACKUP_INTERVAL, NEW_USER_BOOST, DECISION_LOG_SAMPLE,
+// SIGNUP_REGIONS, LINK_PREVIEW_ALLOW and LINK_PREVIEW_DENY, then applies
+// flags from args. S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are only read
+// from the environment.
+func LoadConfig(args []string) (Config, error) {
+	cfg := DefaultConfig()
+
+	env := func(name, def string) string {
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		return def
+	}
+
+	fs := flag.NewFlagSet("gym-bro-backend", flag.ContinueOnError)
+	listen := fs.String("listen", env("LISTEN_ADDR", cfg.ListenAddr), "TCP address, unix:/path/to.sock or systemd")
+	dataFile := fs.String("data-file", env("DATA_FILE", cfg.DataFile), "path of the storage file")
+	imageDir := fs.String("image-dir", env("IMAGE_DIR", cfg.ImageDir), "directory for uploaded images")
+	maxUpload := fs.String("max-upload-bytes", env("MAX_UPLOAD_BYTES", strconv.FormatInt(cfg.MaxUploadBytes, 10)),
+		"maximum size of a profile upload")
+	maxImage := fs.String("max-image-bytes", env("MAX_IMAGE_BYTES", strconv.FormatInt(cfg.MaxImageBytes, 10)),
+		"maximum size of a profile image")
+	maxBody := fs.String("max-body-bytes", env("MAX_BODY_BYTES", strconv.FormatInt(cfg.MaxBodyBytes, 10)),
+		"maximum size of other request bodies")
+	multipartMemory := fs.String("multipart-memory-bytes",
+		env("MULTIPART_MEMORY_BYTES", strconv.FormatInt(cfg.MultipartMemoryBytes, 10)),
+		"bytes of an upload kept in memory, the rest is buffered on disk")
+	corsOrigins := fs.String("cors-origins", env("CORS_ORIGINS", ""), "comma-separated allowed CORS origins")
+	corsMethods := fs.String("cors-methods", env("CORS_METHODS", strings.Join(cfg.CORSMethods, ",")),
+		"comma-separated allowed CORS methods")
+	corsHeaders := fs.String("cors-headers", env("CORS_HEADERS", strings.Join(cfg.CORSHeaders, ",")),
+		"comma-separated allowed CORS request headers")
+	logLevel := fs.String("log-level", env("LOG_LEVEL", cfg.LogLevel.String()), "debug, info, warn or error")
+	webClient := fs.String("web-client", env("SERVE_WEB_CLIENT", "false"), "serve the embedded web client from /")
+
+	rateLimit := fs.String("rate-limit-rpm", env("RATE_LIMIT_RPM", strconv.Itoa(cfg.RateLimitPerMinute)),
+		"requests per minute per client, 0 to disable")
+	rateBurst := fs.String("rate-limit-burst", env("RATE_LIMIT_BURST", strconv.Itoa(cfg.RateLimitBurst)),
+		"requests a client may burst above the rate")
+	trustProxy := fs.String("trust-proxy", env("TRUST_PROXY", "false"),
+		"take the client IP from the last X-Forwarded-For hop, added by the proxy")
+	tlsCert := fs.String("tls-cert", env("TLS_CERT_FILE", ""), "TLS certificate file; enables HTTPS")
+	tlsKey := fs.String("tls-key", env("TLS_KEY_FILE", ""), "TLS private key file")
+	redirectAddr := fs.String("http-redirect", env("HTTP_REDIRECT_ADDR", ""),
+		"address of a plain HTTP listener that redirects to HTTPS")
+	grpcAddr := fs.String("grpc-addr", env("GRPC_ADDR", ""), "address of the gRPC listener; empty disables it")
+	imageStore := fs.String("image-store", env("IMAGE_STORE", cfg.ImageStore), "local or s3")
+	imageURLs := fs.String("image-urls", env("IMAGE_URLS", cfg.ImageURLs),
+		"proxy to serve images through the server, signed to redirect to the store")
+	s3Endpoint := fs.String("s3-endpoint", env("S3_ENDPOINT", ""), "S3-compatible endpoint URL")
+	s3Region := fs.String("s3-region", env("S3_REGION", ""), "S3 region")
+	s3Bucket := fs.String("s3-bucket", env("S3_BUCKET", ""), "S3 bucket for images")
+	uniqueNames := fs.String("unique-names", env("UNIQUE_DISPLAY_NAMES", "false"),
+		"require display names to be unique per tenant")
+	messageRequests := fs.String("message-requests", env("MESSAGE_REQUESTS", "false"),
+		"make the first message to a match a request the recipient must accept")
+	maxConversations := fs.String("max-new-conversations",
+		env("MAX_NEW_CONVERSATIONS", strconv.Itoa(cfg.MaxNewConversations)),
+		"conversations a user may open a day, 0 for no limit")
+	backupCount := fs.String("backup-count", env("BACKUP_COUNT", strconv.Itoa(cfg.BackupCount)),
+		"number of data file backups to keep, 0 to disable")
+	backupInterval := fs.String("backup-interval", env("BACKUP_INTERVAL", cfg.BackupInterval.String()),
+		"minimum time between data file backups")
+	newUserBoost := fs.String("new-user-boost", env("NEW_USER_BOOST", cfg.NewUserBoost.String()),
+		"how long new profiles are boosted in decks, 0 to disable")
+	decisionSample := fs.String("decision-log-sample", env("DECISION_LOG_SAMPLE", "0"),
+		"fraction of deck responses to keep in the decision log")
+	signupRegions := fs.String("signup-regions", env("SIGNUP_REGIONS", ""),
+		"comma-separated name:lat:lon:radiusKm regions open to sign-ups, empty for everywhere")
+	previewAllow := fs.String("link-preview-allow", env("LINK_PREVIEW_ALLOW", ""),
+		"comma-separated domains chat links are previewed for, empty for any")
+	previewDeny := fs.String("link-preview-deny", env("LINK_PREVIEW_DENY", ""),
+		"comma-separated domains chat links are never previewed for")
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg.ListenAddr = *listen
+	cfg.DataFile = *dataFile
+	cfg.ImageDir = *imageDir
+
+	n, err := strconv.ParseInt(*maxUpload, 10, 64)
+	if err != nil || n <= 0 {
+		return Config{}, fmt.Errorf("invalid max upload size %q", *maxUpload)
+	}
+	cfg.MaxUploadBytes = n
+
+	if n, err = strconv.ParseInt(*maxImage, 10, 64); err != nil || n <= 0 {
+		return Config{}, fmt.Errorf("invalid max image size %q", *maxImage)
+	}
+	cfg.MaxImageBytes = n
+
+	if n, err = strconv.ParseInt(*maxBody, 10, 64); err != nil || n <= 0 {
+		return Config{}, fmt.Errorf("invalid max body size %q", *maxBody)
+	}
+	cfg.MaxBodyBytes = n
+
+	if n, err = strconv.ParseInt(*multipartMemory, 10, 64); err != nil || n <= 0 {
+		return Config{}, fmt.Errorf("invalid multipart memory size %q", *multipartMemory)
+	}
+	cfg.MultipartMemoryBytes = n
+
+	cfg.CORSOrigins = splitList(*corsOrigins)
+	cfg.CORSMethods = splitList(*corsMethods)
+	cfg.CORSHeaders = splitList(*corsHeaders)
+
+	if err := cfg.LogLevel.UnmarshalText([]byte(*logLevel)); err != nil {
+		return Config{}, fmt.Errorf("invalid log level %q", *logLevel)
+	}
+
+	if cfg.ServeWebClient, err = strconv.ParseBool(*webClient); err != nil {
+		return Config{}, fmt.Errorf("invalid web client setting %q", *webClient)
+	}
+
+	if cfg.RateLimitPerMinute, err = strconv.Atoi(*rateLimit); err != nil || cfg.RateLimitPerMinute < 0 {
+		return Config{}, fmt.Errorf("invalid rate limit %q", *rateLimit)
+	}
+	if cfg.RateLimitBurst, err = strconv.Atoi(*rateBurst); err != nil || cfg.RateLimitBurst < 0 {
+		return Config{}, fmt.Errorf("invalid rate limit burst %q", *rateBurst)
+	}
+	if cfg.TrustProxy, err = strconv.ParseBool(*trustProxy); err != nil {
+		return Config{}, fmt.Errorf("invalid trust proxy setting %q", *trustProxy)
+	}
+
+	cfg.TLSCertFile, cfg.TLSKeyFile, cfg.HTTPRedirectAddr = *tlsCert, *tlsKey, *redirectAddr
+	cfg.GRPCAddr = *grpcAddr
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return Config{}, fmt.Errorf("both a TLS certificate and key are required")
+	}
+	if cfg.HTTPRedirectAddr != "" && cfg.TLSCertFile == "" {
+		return Config{}, fmt.Errorf("an HTTPS redirect requires a TLS certificate")
+	}
+
+	cfg.ImageStore, cfg.ImageURLs = *imageStore, *imageURLs
+	cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket = *s3Endpoint, *s3Region, *s3Bucket
+	cfg.S3AccessKeyID, cfg.S3SecretAccessKey = os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY")
+	if cfg.ImageURLs != ImageURLsProxy && cfg.ImageURLs != ImageURLsSigned {
+		return Config{}, fmt.Errorf("invalid image URL mode %q", cfg.ImageURLs)
+	}
+	if cfg.ImageURLs == ImageURLsSigned && cfg.ImageStore != "s3" {
+		return Config{}, fmt.Errorf("signed image URLs require the s3 image store")
+	}
+
+	if cfg.UniqueDisplayNames, err = strconv.ParseBool(*uniqueNames); err != nil {
+		return Config{}, fmt.Errorf("invalid unique names setting %q", *uniqueNames)
+	}
+	if cfg.MessageRequests, err = strconv.ParseBool(*messageRequests); err != nil {
+		return Config{}, fmt.Errorf("invalid message requests setting %q", *messageRequests)
+	}
+	if cfg.MaxNewConversations, err = strconv.Atoi(*maxConversations); err != nil || cfg.MaxNewConversations < 0 {
+		return Config{}, fmt.Errorf("invalid new conversation limit %q", *maxConversations)
+	}
+
+	if cfg.BackupCount, err = strconv.Atoi(*backupCount); err != nil || cfg.BackupCount < 0 {
+		return Config{}, fmt.Errorf("invalid backup count %q", *backupCount)
+	}
+	if cfg.BackupInterval, err = time.ParseDuration(*backupInterval); err != nil || cfg.BackupInterval < 0 {
+		return Config{}, fmt.Errorf("invalid backup interval %q", *backupInterval)
+	}
+	if cfg.NewUserBoost, err = time.ParseDuration(*newUserBoost); err != nil || cfg.NewUserBoost < 0 {
+		return Config{}, fmt.Errorf("invalid new user boost %q", *newUserBoost)
+	}
+	if cfg.DecisionLogSample, err = strconv.ParseFloat(*decisionSample, 64); err != nil ||
+		cfg.DecisionLogSample < 0 || cfg.DecisionLogSample > 1 {
+		return Config{}, fmt.Errorf("invalid decision log sample %q", *decisionSample)
+	}
+	if cfg.SignupRegions, err = ParseRegions(*signupRegions); err != nil {
+		return Config{}, err
+	}
+	cfg.LinkPreviewAllow = splitList(*previewAllow)
+	cfg.LinkPreviewDeny = splitList(*previewDeny)
+
+	return cfg, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
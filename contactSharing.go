@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// sharedContact reports whether uid chose to share their contact in m.
+func (m Match) sharedContact(uid string) bool {
+	return slices.Contains(m.ContactSharedBy, uid)
+}
+
+// contactsRevealed reports whether both sides shared their contact, the only
+// case where Contact is included in the match payload.
+func (m Match) contactsRevealed() bool {
+	return m.sharedContact(m.User1ID) && m.sharedContact(m.User2ID)
+}
+
+// view returns m as seen by viewerID, with partner's contact only revealed
+// once both sides shared theirs.
+func (m Match) view(viewerID string, partner User) MatchView {
+	card := partner.Public()
+	if m.contactsRevealed() {
+		card.Contact = partner.Contact
+	}
+	return MatchView{
+		ID:                     m.ID(),
+		User1ID:                m.User1ID,
+		User2ID:                m.User2ID,
+		CreatedAt:              m.CreatedAt,
+		GoalID:                 m.GoalID,
+		ContactSharedByMe:      m.sharedContact(viewerID),
+		ContactSharedByPartner: m.sharedContact(partner.FirebaseUID),
+		Partner:                card,
+	}
+}
+
+// ShareContact handles POST /api/matches/{matchId}/share-contact?userId=, which
+// shares the user's contact with their match, and DELETE, which withdraws
+// it. The partner is notified when a contact is shared.
+func (c *Controller) ShareContact(w http.ResponseWriter, r *http.Request) {
+	matchID := r.PathValue("matchId")
+	userID := r.URL.Query().Get("userId")
+	i := slices.IndexFunc(c.storage.Matches, func(m Match) bool { return m.ID() == matchID })
+	if i == -1 {
+		writeError(w, http.StatusNotFound, "MATCH_NOT_FOUND", "Match not found")
+		return
+	}
+	match := &c.storage.Matches[i]
+	if !match.Has(userID) {
+		writeError(w, http.StatusForbidden, "NOT_MATCH_PARTICIPANT", "Not a participant of this match")
+		return
+	}
+
+	partner, ok := c.findUser(match.Partner(userID))
+	if !ok {
+		writeError(w, http.StatusNotFound, "MATCH_NOT_FOUND", "Match not found")
+		return
+	}
+
+	changed := false
+	switch {
+	case r.Method == http.MethodDelete && match.sharedContact(userID):
+		match.ContactSharedBy = slices.DeleteFunc(match.ContactSharedBy, func(id string) bool { return id == userID })
+		changed = true
+	case r.Method == http.MethodPost && !match.sharedContact(userID):
+		u, _ := c.findUser(userID)
+		if u.Contact == "" {
+			writeError(w, http.StatusConflict, "CONTACT_REQUIRED", "Add a contact to your profile first")
+			return
+		}
+		match.ContactSharedBy = append(match.ContactSharedBy, userID)
+		changed = true
+
+		body := u.Name + " shared their contact with you"
+		if match.contactsRevealed() {
+			body = u.Name + " shared their contact. You can now see each other's contacts"
+		}
+		c.notifyUser(partner.FirebaseUID, Notification{
+			Title: "Contact shared",
+			Body:  body,
+			Data:  map[string]string{"type": "contact_shared", "matchId": matchID, "userId": userID},
+		})
+	}
+
+	if changed {
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, match.view(userID, partner))
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ContactEmail    = "email"
+	ContactPhone    = "phone"
+	ContactTelegram = "telegram"
+
+	contactCodeTTL      = 15 * time.Minute
+	contactCodeAttempts = 5
+)
+
+var contactFormats = map[string]*regexp.Regexp{
+	ContactEmail:    regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`),
+	ContactPhone:    regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`),
+	ContactTelegram: regexp.MustCompile(`^@?[A-Za-z0-9_]{5,32}$`),
+}
+
+// LinkedContact is a verified contact identifier of a user. Linked contacts
+// are private and stripped from public profiles.
+type LinkedContact struct {
+	Type       string    `json:"type"`
+	Value      string    `json:"value"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+// ContactChange is a pending change of a linked contact, applied once the
+// code sent to the new value is confirmed.
+type ContactChange struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Type      string    `json:"type"`
+	NewValue  string    `json:"newValue"`
+	CodeHash  string    `json:"codeHash"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ContactChangeRequest struct {
+	UserID string `json:"userId"`
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+}
+
+type ContactVerifyRequest struct {
+	UserID   string `json:"userId"`
+	ChangeID string `json:"changeId"`
+	Code     string `json:"code"`
+}
+
+// ContactSender delivers a message to an email address, phone number or
+// Telegram account.
+type ContactSender interface {
+	SendToContact(ctx context.Context, contactType, value, message string) error
+}
+
+// LogContactSender only logs messages; used until a mail/SMS provider is
+// configured.
+type LogContactSender struct{}
+
+func (LogContactSender) SendToContact(_ context.Context, contactType, value, message string) error {
+	slog.Info("Contact message", "type", contactType, "value", value, "message", message)
+	return nil
+}
+
+func (u User) linkedContact(contactType string) (int, bool) {
+	for i, lc := range u.LinkedContacts {
+		if lc.Type == contactType {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func verificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// sendToContact delivers message in the background, moving it to the retry
+// queue when sending fails. A message with expiresAt is dropped rather than
+// retried after it. The caller must hold c.mu.
+func (c *Controller) sendToContact(userID, contactType, value, message string, expiresAt *time.Time) {
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := c.contacts.SendToContact(ctx, contactType, value, message); err != nil {
+			slog.Error("Failed to send contact message, queueing for retry", "type", contactType, "err", err)
+			c.enqueueDelivery(Delivery{
+				Channel:     DeliveryContact,
+				UserID:      userID,
+				Target:      value,
+				ContactType: contactType,
+				Message:     message,
+				ExpiresAt:   expiresAt,
+			}, 1, err)
+		}
+	}()
+}
+
+func (c *Controller) requestContactChange(w http.ResponseWriter, r *http.Request) {
+	var req ContactChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	req.Value = strings.TrimSpace(req.Value)
+	format, ok := contactFormats[req.Type]
+	if !ok || !format.MatchString(req.Value) {
+		writeError(w, http.StatusBadRequest, "INVALID_CONTACT", "Invalid contact")
+		return
+	}
+
+	user, ok := c.findUser(req.UserID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	code, err := verificationCode()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to generate verification code", "err", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		return
+	}
+
+	// A new request replaces any pending change of the same contact type.
+	changes := c.storage.ContactChanges[:0]
+	for _, ch := range c.storage.ContactChanges {
+		if ch.UserID != req.UserID || ch.Type != req.Type {
+			changes = append(changes, ch)
+		}
+	}
+	c.storage.ContactChanges = changes
+
+	now := time.Now()
+	change := ContactChange{
+		ID:        newID(),
+		UserID:    req.UserID,
+		Type:      req.Type,
+		NewValue:  req.Value,
+		CodeHash:  hashToken(code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(contactCodeTTL),
+	}
+	c.storage.ContactChanges = append(c.storage.ContactChanges, change)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	c.sendToContact(user.FirebaseUID, req.Type, req.Value,
+		fmt.Sprintf("Gym Bro verification code: %s", code), &change.ExpiresAt)
+	if i, ok := user.linkedContact(req.Type); ok {
+		c.sendToContact(user.FirebaseUID, req.Type, user.LinkedContacts[i].Value,
+			"A change of your Gym Bro "+req.Type+" was requested. If this wasn't you, contact support.", nil)
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"changeId":  change.ID,
+		"expiresAt": change.ExpiresAt,
+	})
+}
+
+func (c *Controller) verifyContactChange(w http.ResponseWriter, r *http.Request) {
+	var req ContactVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	ci := -1
+	for i, ch := range c.storage.ContactChanges {
+		if ch.ID == req.ChangeID && ch.UserID == req.UserID {
+			ci = i
+			break
+		}
+	}
+	if ci == -1 {
+		writeError(w, http.StatusNotFound, "CONTACT_CHANGE_NOT_FOUND", "Contact change not found")
+		return
+	}
+
+	change := &c.storage.ContactChanges[ci]
+	if time.Now().After(change.ExpiresAt) || change.Attempts >= contactCodeAttempts {
+		c.storage.ContactChanges = append(c.storage.ContactChanges[:ci], c.storage.ContactChanges[ci+1:]...)
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		}
+		writeError(w, http.StatusGone, "VERIFICATION_CODE_EXPIRED", "Verification code expired")
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(req.Code)), []byte(change.CodeHash)) != 1 {
+		change.Attempts++
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "INVALID_VERIFICATION_CODE", "Invalid verification code")
+		return
+	}
+
+	ui := -1
+	for i, u := range c.storage.Users {
+		if u.FirebaseUID == change.UserID {
+			ui = i
+			break
+		}
+	}
+	if ui == -1 {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	user := &c.storage.Users[ui]
+	contact := LinkedContact{Type: change.Type, Value: change.NewValue, VerifiedAt: time.Now()}
+	oldValue := ""
+	if i, ok := user.linkedContact(change.Type); ok {
+		oldValue = user.LinkedContacts[i].Value
+		user.LinkedContacts[i] = contact
+	} else {
+		user.LinkedContacts = append(user.LinkedContacts, contact)
+	}
+	c.storage.ContactChanges = append(c.storage.ContactChanges[:ci], c.storage.ContactChanges[ci+1:]...)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	if oldValue != "" {
+		c.sendToContact(user.FirebaseUID, contact.Type, oldValue,
+			"Your Gym Bro "+contact.Type+" was changed. If this wasn't you, contact support.", nil)
+	}
+
+	writeJSON(w, http.StatusOK, user.LinkedContacts)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const corsMaxAge = 600 // seconds browsers may cache a preflight response
+
+// CORSPolicy lists what cross-origin browser clients may do. An origin of "*"
+// allows any origin.
+type CORSPolicy struct {
+	Origins []string
+	Methods []string
+	Headers []string
+}
+
+func (p CORSPolicy) allowOrigin(origin string) bool {
+	return slices.Contains(p.Origins, "*") || slices.Contains(p.Origins, origin)
+}
+
+// Handler adds CORS headers for allowed origins and answers preflight
+// requests itself. Without configured origins it passes requests through.
+func (p CORSPolicy) Handler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(p.Origins) == 0 {
+			next(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !p.allowOrigin(origin) {
+			if r.Method == http.MethodOptions {
+				writeError(w, http.StatusForbidden, "ORIGIN_NOT_ALLOWED", "Origin not allowed")
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Retry-After, X-Feature")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.Methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.Headers, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	debugCaptureDefault   = time.Hour
+	debugCaptureMax       = 24 * time.Hour
+	debugRecordRetention  = 48 * time.Hour
+	debugMaxBodyBytes     = 16 << 10
+	debugMaxRecordsPerUID = 500
+	debugPurgeInterval    = time.Hour
+)
+
+var (
+	sensitiveHeaders = []string{"Authorization", "Cookie", "X-Admin-Key"}
+	sensitiveFields  = []string{
+		"token", "contact", "phone", "password", "apikey", "api_key",
+		"email", "latitude", "longitude", "emergency", "relation",
+	}
+	// sensitivePaths are routes whose bodies are redacted whole: emergency
+	// contacts are bare lists of names and phone numbers.
+	sensitivePaths = []string{"/safety/contacts/"}
+)
+
+type DebugRecord struct {
+	At              time.Time         `json:"at"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"requestHeaders"`
+	RequestBody     string            `json:"requestBody,omitempty"`
+	Status          int               `json:"status"`
+	ResponseBody    string            `json:"responseBody,omitempty"`
+	DurationMillis  int64             `json:"durationMillis"`
+	ResponseTrimmed bool              `json:"responseTrimmed,omitempty"`
+}
+
+type debugSession struct {
+	expiresAt time.Time
+	records   []DebugRecord
+}
+
+// DebugRecorder captures sanitized request/response pairs for users an admin
+// enabled capture for. Everything is kept in memory and purged after 48h.
+type DebugRecorder struct {
+	mu       sync.Mutex
+	sessions map[string]*debugSession
+}
+
+func NewDebugRecorder() *DebugRecorder {
+	return &DebugRecorder{sessions: make(map[string]*debugSession)}
+}
+
+func (d *DebugRecorder) Enable(uid string, duration time.Duration) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	expiresAt := time.Now().Add(duration)
+	if s, ok := d.sessions[uid]; ok {
+		s.expiresAt = expiresAt
+	} else {
+		d.sessions[uid] = &debugSession{expiresAt: expiresAt}
+	}
+	return expiresAt
+}
+
+func (d *DebugRecorder) Disable(uid string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s, ok := d.sessions[uid]; ok {
+		s.expiresAt = time.Now()
+	}
+}
+
+func (d *DebugRecorder) Records(uid string) []DebugRecord {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.sessions[uid]
+	if !ok {
+		return []DebugRecord{}
+	}
+	return append([]DebugRecord{}, s.records...)
+}
+
+// Purge drops records older than the retention period and sessions that
+// have nothing left to keep.
+func (d *DebugRecorder) Purge() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cutoff := time.Now().Add(-debugRecordRetention)
+	for uid, s := range d.sessions {
+		kept := s.records[:0]
+		for _, rec := range s.records {
+			if rec.At.After(cutoff) {
+				kept = append(kept, rec)
+			}
+		}
+		s.records = kept
+
+		if len(s.records) == 0 && time.Now().After(s.expiresAt) {
+			delete(d.sessions, uid)
+		}
+	}
+}
+
+// activeUIDs returns the UIDs mentioned in the request that are being captured.
+func (d *DebugRecorder) activeUIDs(haystack string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var uids []string
+	now := time.Now()
+	for uid, s := range d.sessions {
+		if now.Before(s.expiresAt) && strings.Contains(haystack, uid) {
+			uids = append(uids, uid)
+		}
+	}
+	return uids
+}
+
+func (d *DebugRecorder) add(uids []string, rec DebugRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, uid := range uids {
+		s, ok := d.sessions[uid]
+		if !ok {
+			continue
+		}
+		s.records = append(s.records, rec)
+		if len(s.records) > debugMaxRecordsPerUID {
+			s.records = s.records[len(s.records)-debugMaxRecordsPerUID:]
+		}
+	}
+}
+
+type captureWriter struct {
+	http.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	trimmed bool
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if room := debugMaxBodyBytes - w.body.Len(); room > 0 {
+		if len(b) > room {
+			w.body.Write(b[:room])
+			w.trimmed = true
+		} else {
+			w.body.Write(b)
+		}
+	} else {
+		w.trimmed = true
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through, so streamed responses such as gRPC frames
+// are not held back while being captured.
+func (w *captureWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Capture records the exchange when the request mentions a captured UID in
+// its URL or body.
+func (d *DebugRecorder) Capture(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		idle := len(d.sessions) == 0
+		d.mu.Unlock()
+		if idle {
+			next(w, r)
+			return
+		}
+
+		multipart := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/")
+		var body []byte
+		if r.Body != nil && !multipart {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, debugMaxBodyBytes))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		}
+
+		haystack := r.URL.String() + "\n" + string(body)
+		if multipart {
+			// Multipart forms carry the UID as a form field; parse lazily
+			// after the handler instead of buffering uploads here.
+			haystack = r.URL.String()
+		}
+
+		start := time.Now()
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next(cw, r)
+
+		if multipart && r.MultipartForm != nil {
+			for _, values := range r.MultipartForm.Value {
+				haystack += "\n" + strings.Join(values, "\n")
+			}
+		}
+
+		uids := d.activeUIDs(haystack)
+		if len(uids) == 0 {
+			return
+		}
+
+		rec := DebugRecord{
+			At:              start,
+			Method:          r.Method,
+			URL:             r.URL.String(),
+			RequestHeaders:  sanitizeHeaders(r.Header),
+			RequestBody:     sanitizeBody(body, r.Header.Get("Content-Type")),
+			Status:          cw.status,
+			ResponseBody:    sanitizeBody(cw.body.Bytes(), cw.Header().Get("Content-Type")),
+			DurationMillis:  time.Since(start).Milliseconds(),
+			ResponseTrimmed: cw.trimmed,
+		}
+		if multipart {
+			rec.RequestBody = "[multipart body omitted]"
+		}
+		for _, p := range sensitivePaths {
+			if strings.Contains(r.URL.Path, p) {
+				rec.RequestBody, rec.ResponseBody = "[redacted]", "[redacted]"
+			}
+		}
+
+		d.add(uids, rec)
+	}
+}
+
+func sanitizeHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		out[k] = strings.Join(v, ", ")
+	}
+	for _, k := range sensitiveHeaders {
+		if _, ok := out[k]; ok {
+			out[k] = "[redacted]"
+		}
+	}
+	return out
+}
+
+// sanitizeBody redacts sensitive fields in JSON and url-encoded bodies.
+// Bodies that cannot be parsed, including JSON cut at debugMaxBodyBytes, are
+// not kept, since nothing in them could be redacted.
+func sanitizeBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		redact(v)
+		out, _ := json.Marshal(v)
+		return string(out)
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			for k := range form {
+				if isSensitiveField(k) {
+					form[k] = []string{"[redacted]"}
+				}
+			}
+			return form.Encode()
+		}
+	}
+	return "[unparsable body omitted]"
+}
+
+func redact(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if isSensitiveField(k) {
+				t[k] = "[redacted]"
+				continue
+			}
+			redact(child)
+		}
+	case []interface{}:
+		for _, child := range t {
+			redact(child)
+		}
+	}
+}
+
+func isSensitiveField(key string) bool {
+	key = strings.ToLower(key)
+	for _, f := range sensitiveFields {
+		if strings.Contains(key, f) {
+			return true
+		}
+	}
+	return false
+}
+
+type EnableDebugCaptureRequest struct {
+	UID             string `json:"uid"`
+	DurationMinutes int    `json:"durationMinutes"`
+}
+
+// AdminDebugCapture handles POST /admin/debug-capture to enable capture.
+func (c *Controller) AdminDebugCapture(w http.ResponseWriter, r *http.Request) {
+	var req EnableDebugCaptureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UID == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	if duration <= 0 {
+		duration = debugCaptureDefault
+	}
+	if duration > debugCaptureMax {
+		duration = debugCaptureMax
+	}
+
+	expiresAt := c.debug.Enable(req.UID, duration)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"uid":       req.UID,
+		"expiresAt": expiresAt,
+	})
+}
+
+// AdminDebugRecords handles GET /admin/debug-capture/{uid}.
+func (c *Controller) AdminDebugRecords(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.debug.Records(r.PathValue("uid")))
+}
+
+// AdminStopDebugCapture handles DELETE /admin/debug-capture/{uid}.
+func (c *Controller) AdminStopDebugCapture(w http.ResponseWriter, r *http.Request) {
+	c.debug.Disable(r.PathValue("uid"))
+	w.WriteHeader(http.StatusNoContent)
+}
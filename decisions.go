@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maxDecisions          = 5000
+	maxDecisionCandidates = 50
+	decisionRetention     = 48 * time.Hour
+	defaultDecisionLimit  = 20
+)
+
+// CandidateDecision explains one candidate's place in a deck.
+type CandidateDecision struct {
+	UserID   string         `json:"userId"`
+	Position int            `json:"position"`
+	Shown    bool           `json:"shown"`
+	Score    float64        `json:"score"`
+	Terms    ScoreBreakdown `json:"terms"`
+	SameGym  bool           `json:"sameGym,omitempty"`
+	Ghost    bool           `json:"ghost,omitempty"`
+}
+
+// DeckDecision is a sampled deck response with the scoring behind it.
+type DeckDecision struct {
+	At         time.Time           `json:"at"`
+	UserID     string              `json:"userId"`
+	Query      string              `json:"query,omitempty"`
+	Eligible   int                 `json:"eligible"`
+	Candidates []CandidateDecision `json:"candidates"`
+}
+
+// DecisionLog keeps a sample of deck decisions in memory so admins can answer
+// "why am I seeing this person?" and compare rankings over time. Decisions
+// are dropped after 48h or once the log is full, oldest first.
+type DecisionLog struct {
+	// SampleRate is the fraction of deck responses logged. Zero disables
+	// the log except for users under debug capture.
+	SampleRate float64
+
+	mu        sync.Mutex
+	decisions []DeckDecision
+}
+
+func NewDecisionLog(sampleRate float64) *DecisionLog {
+	return &DecisionLog{SampleRate: sampleRate}
+}
+
+func (l *DecisionLog) sample() bool {
+	return l.SampleRate > 0 && rand.Float64() < l.SampleRate
+}
+
+func (l *DecisionLog) Add(d DeckDecision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.decisions = append(l.decisions, d)
+	if len(l.decisions) > maxDecisions {
+		l.decisions = l.decisions[len(l.decisions)-maxDecisions:]
+	}
+}
+
+// Find returns the newest decisions for userID, or every user when empty,
+// that include candidateID, or any candidate when empty.
+func (l *DecisionLog) Find(userID, candidateID string, limit int) []DeckDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	found := []DeckDecision{}
+	for i := len(l.decisions) - 1; i >= 0 && len(found) < limit; i-- {
+		d := l.decisions[i]
+		if userID != "" && d.UserID != userID {
+			continue
+		}
+		if candidateID != "" && !d.includes(candidateID) {
+			continue
+		}
+		found = append(found, d)
+	}
+	return found
+}
+
+func (d DeckDecision) includes(uid string) bool {
+	for _, c := range d.Candidates {
+		if c.UserID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// Purge drops decisions older than the retention period.
+func (l *DecisionLog) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-decisionRetention)
+	kept := l.decisions[:0]
+	for _, d := range l.decisions {
+		if d.At.After(cutoff) {
+			kept = append(kept, d)
+		}
+	}
+	l.decisions = kept
+}
+
+// Explain breaks down the ranking of deck, as returned by Candidates, for
+// the decision log.
+func (f *FeedService) Explain(userID string, filter FeedFilter, deck []User) []CandidateDecision {
+	var requester User
+	for _, u := range f.storage.Users {
+		if u.FirebaseUID == userID {
+			requester = u
+			break
+		}
+	}
+
+	var breakdowns map[string]ScoreBreakdown
+	if explainer, ok := f.Ranker.(ScoreExplainer); ok {
+		breakdowns = explainer.Explain(requester, deck)
+	}
+
+	decisions := make([]CandidateDecision, 0, min(len(deck), maxDecisionCandidates))
+	for i, u := range deck[:min(len(deck), maxDecisionCandidates)] {
+		terms := breakdowns[u.FirebaseUID]
+		decisions = append(decisions, CandidateDecision{
+			UserID:   u.FirebaseUID,
+			Position: i,
+			Score:    terms.Total(),
+			Terms:    terms,
+			SameGym: filter.SameGym == SameGymPrefer && requester.HomeGymID != "" &&
+				u.HomeGymID == requester.HomeGymID,
+			Ghost: f.storage.ResponseStats[u.FirebaseUID].IsGhost(),
+		})
+	}
+	return decisions
+}
+
+// logDecision records a sample of deck responses, and every one for users
+// under debug capture. count is the number of candidates shown.
+func (c *Controller) logDecision(ctx context.Context, query, userID string, filter FeedFilter, deck []User, count int) {
+	if !c.decisions.sample() && len(c.debug.activeUIDs(userID)) == 0 {
+		return
+	}
+
+	candidates := c.feed.Explain(userID, filter, deck)
+	for i := range candidates {
+		candidates[i].Shown = i < count
+	}
+
+	c.decisions.Add(DeckDecision{
+		At:         time.Now(),
+		UserID:     userID,
+		Query:      query,
+		Eligible:   len(deck),
+		Candidates: candidates,
+	})
+	slog.DebugContext(ctx, "Logged feed decision", "userId", userID, "eligible", len(deck))
+}
+
+// AdminDecisions handles GET /admin/decisions?userId=&candidateId=&limit=.
+func (c *Controller) AdminDecisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"), defaultDecisionLimit)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_LIMIT", "Invalid limit")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c.decisions.Find(query.Get("userId"), query.Get("candidateId"), limit))
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	maxDeckSuggestions = 3
+	// radiusStepKm is what suggested radii are rounded up to.
+	radiusStepKm = 5
+)
+
+// DeckSuggestion is a filter change that may refill an empty deck.
+// Excluded is how many people the filter alone keeps out of the deck.
+type DeckSuggestion struct {
+	Filter   string `json:"filter"`
+	Value    string `json:"value,omitempty"`
+	Excluded int    `json:"excluded"`
+	Message  string `json:"message"`
+}
+
+// DeckEmpty is the details of a DECK_EMPTY error.
+type DeckEmpty struct {
+	Suggestions []DeckSuggestion `json:"suggestions"`
+}
+
+// Suggestions checks each filter of an exhausted deck on its own and returns
+// changes to the ones that exclude the most people, best first. Users who
+// are out of the deck because they were swiped or blocked never count.
+func (f *FeedService) Suggestions(userID string, filter FeedFilter) []DeckSuggestion {
+	requester, pool := f.unswiped(userID)
+	suggestions := []DeckSuggestion{}
+
+	// excludedBy returns the pool members that fail a filter with only the
+	// criterion set by only.
+	excludedBy := func(only func(*FeedFilter)) []User {
+		single := FeedFilter{TimeFrom: -1, TimeTo: -1}
+		only(&single)
+		var users []User
+		for _, u := range pool {
+			if !single.Matches(requester, u) {
+				users = append(users, u)
+			}
+		}
+		return users
+	}
+
+	if filter.MaxDistanceKm > 0 {
+		// Suggest the smallest radius that reaches anyone.
+		excluded := excludedBy(func(f *FeedFilter) { f.MaxDistanceKm = filter.MaxDistanceKm })
+		nearest := math.Inf(1)
+		for _, u := range excluded {
+			if d, ok := DistanceKm(requester, u); ok && d < nearest {
+				nearest = d
+			}
+		}
+		if !math.IsInf(nearest, 1) {
+			radius := math.Ceil(nearest/radiusStepKm) * radiusStepKm
+			suggestions = append(suggestions, DeckSuggestion{
+				Filter:   "maxDistanceKm",
+				Value:    fmt.Sprint(radius),
+				Excluded: len(excluded),
+				Message:  fmt.Sprintf("Увеличьте радиус до %g км", radius),
+			})
+		}
+	}
+
+	if filter.Day != "" {
+		excluded := excludedBy(func(f *FeedFilter) { f.Day = filter.Day })
+		if day, n := mostCommon(excluded, func(u User) string { return u.Day }); n > 0 {
+			suggestions = append(suggestions, DeckSuggestion{
+				Filter:   "day",
+				Value:    day,
+				Excluded: len(excluded),
+				Message:  "Добавьте " + day + " в расписание",
+			})
+		}
+	}
+
+	if filter.TrainType != "" {
+		excluded := excludedBy(func(f *FeedFilter) { f.TrainType = filter.TrainType })
+		if trainType, n := mostCommon(excluded, func(u User) string { return u.TrainType }); n > 0 {
+			suggestions = append(suggestions, DeckSuggestion{
+				Filter:   "trainType",
+				Value:    trainType,
+				Excluded: len(excluded),
+				Message:  "Включите " + trainType,
+			})
+		}
+	}
+
+	// The remaining filters have a single way to relax them.
+	toggles := []struct {
+		active  bool
+		only    func(*FeedFilter)
+		filter  string
+		value   string
+		message string
+	}{
+		{filter.TimeFrom >= 0 || filter.TimeTo >= 0,
+			func(f *FeedFilter) { f.TimeFrom, f.TimeTo = filter.TimeFrom, filter.TimeTo },
+			"time", "", "Уберите ограничение по времени"},
+		{filter.AdaptiveOnly,
+			func(f *FeedFilter) { f.AdaptiveOnly = true },
+			"adaptive", "", "Показывайте не только адаптивные тренировки"},
+		{filter.SameGym == SameGymOnly,
+			func(f *FeedFilter) { f.SameGym = SameGymOnly },
+			"sameGym", SameGymPrefer, "Показывайте людей из других залов"},
+	}
+	for _, t := range toggles {
+		if !t.active {
+			continue
+		}
+		if n := len(excludedBy(t.only)); n > 0 {
+			suggestions = append(suggestions, DeckSuggestion{
+				Filter:   t.filter,
+				Value:    t.value,
+				Excluded: n,
+				Message:  t.message,
+			})
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Excluded > suggestions[j].Excluded
+	})
+	if len(suggestions) > maxDeckSuggestions {
+		suggestions = suggestions[:maxDeckSuggestions]
+	}
+	return suggestions
+}
+
+// mostCommon returns the most frequent non-empty key of users and its count,
+// breaking ties alphabetically.
+func mostCommon(users []User, key func(User) string) (string, int) {
+	counts := make(map[string]int)
+	for _, u := range users {
+		if k := strings.TrimSpace(key(u)); k != "" {
+			counts[k]++
+		}
+	}
+
+	best, bestCount := "", 0
+	for k, n := range counts {
+		if n > bestCount || (n == bestCount && k < best) {
+			best, bestCount = k, n
+		}
+	}
+	return best, bestCount
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DeliveryPush     = "push"
+	DeliveryContact  = "contact"
+	DeliveryTelegram = "telegram"
+
+	DeliveryPending = "pending"
+	DeliveryDead    = "dead"
+
+	deliveryRetryInterval = 30 * time.Second
+	deliveryBaseBackoff   = time.Minute
+	deliveryMaxBackoff    = 6 * time.Hour
+	deliveryMaxAttempts   = 10
+	maxDeadDeliveries     = 1000
+)
+
+// Delivery is a push notification, contact message or Telegram message that
+// failed to send and waits in the retry queue. After deliveryMaxAttempts it stays as a dead
+// letter until an admin requeues or deletes it.
+type Delivery struct {
+	ID      string `json:"id"`
+	Channel string `json:"channel"`
+	UserID  string `json:"userId,omitempty"`
+
+	// Target is the device token for pushes or the contact value.
+	Target       string        `json:"target"`
+	ContactType  string        `json:"contactType,omitempty"`
+	Notification *Notification `json:"notification,omitempty"`
+	Message      string        `json:"message,omitempty"`
+
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	// ExpiresAt drops deliveries that are useless once late, such as
+	// verification codes.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func deliveryBackoff(attempts int) time.Duration {
+	backoff := deliveryBaseBackoff
+	for i := 1; i < attempts && backoff < deliveryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, deliveryMaxBackoff)
+}
+
+// enqueueDelivery stores a delivery whose first attempts failed. It takes
+// c.mu, so it must only be called from background work.
+func (c *Controller) enqueueDelivery(d Delivery, attempts int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	d.ID = newID()
+	d.Status = DeliveryPending
+	d.Attempts = attempts
+	d.LastError = err.Error()
+	d.CreatedAt = now
+	d.NextAttemptAt = now.Add(deliveryBackoff(attempts))
+	c.storage.Deliveries = append(c.storage.Deliveries, d)
+
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to save data", "err", err)
+	}
+}
+
+func (c *Controller) send(ctx context.Context, d Delivery) error {
+	switch d.Channel {
+	case DeliveryPush:
+		return c.notifier.Send(ctx, d.Target, *d.Notification)
+	case DeliveryTelegram:
+		return c.sendTelegramDelivery(ctx, d)
+	}
+	return c.contacts.SendToContact(ctx, d.ContactType, d.Target, d.Message)
+}
+
+// retryDeliveries sends the due deliveries of the queue. The lock is only
+// held to pick deliveries and record results, not while sending.
+func (c *Controller) retryDeliveries() {
+	c.mu.RLock()
+	now := time.Now()
+	var due []Delivery
+	for _, d := range c.storage.Deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	c.mu.RUnlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	results := make(map[string]error, len(due))
+	for _, d := range due {
+		if d.ExpiresAt != nil && now.After(*d.ExpiresAt) {
+			results[d.ID] = errDeliveryExpired
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		results[d.ID] = c.send(ctx, d)
+		cancel()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var unregistered []Delivery
+	kept := c.storage.Deliveries[:0]
+	for _, d := range c.storage.Deliveries {
+		err, tried := results[d.ID]
+		switch {
+		case !tried || d.Status != DeliveryPending:
+			// Requeued, deleted or dead since it was picked.
+		case err == nil:
+			continue
+		case errors.Is(err, errDeliveryExpired):
+			slog.Info("Dropped expired delivery", "id", d.ID, "channel", d.Channel)
+			continue
+		case errors.Is(err, errUnregistered):
+			unregistered = append(unregistered, d)
+			continue
+		default:
+			d.Attempts++
+			d.LastError = err.Error()
+			d.NextAttemptAt = time.Now().Add(deliveryBackoff(d.Attempts))
+			if d.Attempts >= deliveryMaxAttempts {
+				d.Status = DeliveryDead
+				slog.Error("Delivery moved to dead letters", "id", d.ID, "channel", d.Channel, "err", err)
+			}
+		}
+		kept = append(kept, d)
+	}
+	c.storage.Deliveries = kept
+	c.pruneDeadDeliveries()
+
+	for _, d := range unregistered {
+		if d.Channel == DeliveryTelegram {
+			chatID, _ := strconv.ParseInt(d.Target, 10, 64)
+			c.unlinkTelegramChat(chatID)
+			continue
+		}
+		c.storage.Devices = removeDeviceToken(c.storage.Devices, d.Target)
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to save data", "err", err)
+	}
+}
+
+var errDeliveryExpired = errors.New("delivery expired")
+
+// pruneDeadDeliveries keeps only the newest dead letters. The caller must
+// hold c.mu and save data.
+func (c *Controller) pruneDeadDeliveries() {
+	dead := 0
+	for _, d := range c.storage.Deliveries {
+		if d.Status == DeliveryDead {
+			dead++
+		}
+	}
+
+	kept := c.storage.Deliveries[:0]
+	for _, d := range c.storage.Deliveries {
+		if d.Status == DeliveryDead && dead > maxDeadDeliveries {
+			dead--
+			continue
+		}
+		kept = append(kept, d)
+	}
+	c.storage.Deliveries = kept
+}
+
+func removeDeviceToken(devices []Device, token string) []Device {
+	kept := devices[:0]
+	for _, d := range devices {
+		if d.Token != token {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// AdminDeliveries handles GET /admin/deliveries?status=dead|pending.
+func (c *Controller) AdminDeliveries(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = DeliveryDead
+	}
+
+	deliveries := []Delivery{}
+	for _, d := range c.storage.Deliveries {
+		if d.Status == status {
+			deliveries = append(deliveries, d)
+		}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// AdminRequeueDeliveries handles POST /admin/deliveries, requeueing every
+// dead letter. It isn't POST /admin/deliveries/requeue: that path would
+// overlap the DELETE /admin/deliveries/{id} route.
+func (c *Controller) AdminRequeueDeliveries(w http.ResponseWriter, r *http.Request) {
+	requeued := 0
+	for i, d := range c.storage.Deliveries {
+		if d.Status == DeliveryDead {
+			c.storage.Deliveries[i].requeue()
+			requeued++
+		}
+	}
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"requeued": requeued})
+}
+
+// pathDelivery finds the delivery named by the {id} path value, writing a
+// 404 if there is none.
+func (c *Controller) pathDelivery(w http.ResponseWriter, r *http.Request) (int, bool) {
+	for i, d := range c.storage.Deliveries {
+		if d.ID == r.PathValue("id") {
+			return i, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "Delivery not found")
+	return -1, false
+}
+
+// AdminRequeueDelivery handles POST /admin/deliveries/{id}/requeue.
+func (c *Controller) AdminRequeueDelivery(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	c.storage.Deliveries[i].requeue()
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+	writeJSON(w, http.StatusOK, c.storage.Deliveries[i])
+}
+
+// AdminDeleteDelivery handles DELETE /admin/deliveries/{id}.
+func (c *Controller) AdminDeleteDelivery(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	c.storage.Deliveries = append(c.storage.Deliveries[:i], c.storage.Deliveries[i+1:]...)
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requeue gives a dead letter a fresh set of attempts, starting right away.
+func (d *Delivery) requeue() {
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const digestFlushInterval = 30 * time.Second
+
+// DigestRule batches pushes of one notification type. The first event opens
+// a window and is pushed right away; further events within the window are
+// held back and sent as one summary when it ends.
+type DigestRule struct {
+	Window time.Duration
+	// GroupBy is the data field events are batched by, such as goalId, so
+	// responses to different goals get separate digests. Empty batches every
+	// event of the type together.
+	GroupBy string
+	// Summarize builds the summary of count held back events.
+	Summarize func(count int) Notification
+}
+
+// defaultDigestRules are keyed by the notification's data type.
+var defaultDigestRules = map[string]DigestRule{
+	"match": {
+		Window: 10 * time.Minute,
+		Summarize: func(count int) Notification {
+			return Notification{
+				Title: "Новые мэтчи!",
+				Body:  fmt.Sprintf("Ещё %d %s: пора знакомиться", count, ruPlural(count, "мэтч", "мэтча", "мэтчей")),
+			}
+		},
+	},
+	"goal_response": {
+		Window:  10 * time.Minute,
+		GroupBy: "goalId",
+		Summarize: func(count int) Notification {
+			return Notification{
+				Title: "Отклики на цель",
+				Body:  fmt.Sprintf("Ещё %d %s на твою цель", count, ruPlural(count, "отклик", "отклика", "откликов")),
+			}
+		},
+	},
+	"nearby": {
+		Window:  30 * time.Minute,
+		GroupBy: "gymId",
+		Summarize: func(count int) Notification {
+			return Notification{
+				Title: "Напарники рядом",
+				Body:  fmt.Sprintf("Ещё %d %s сейчас в зале", count, ruPlural(count, "напарник", "напарника", "напарников")),
+			}
+		},
+	},
+}
+
+// ruPlural picks the Russian plural form for n: one (1, 21), few (2-4, 22)
+// or many (5-20, 25).
+func ruPlural(n int, one, few, many string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return one
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return few
+	default:
+		return many
+	}
+}
+
+type digestWindow struct {
+	userID   string
+	kind     string
+	group    string
+	openedAt time.Time
+	held     int
+}
+
+// summary returns the digest of the window's held back events.
+func (w *digestWindow) summary(rule DigestRule) Notification {
+	n := rule.Summarize(w.held)
+	n.Data = map[string]string{
+		"type":   w.kind,
+		"digest": "true",
+		"count":  strconv.Itoa(w.held),
+	}
+	if rule.GroupBy != "" {
+		n.Data[rule.GroupBy] = w.group
+	}
+	return n
+}
+
+// PendingDigest is a summary to push once its window ends.
+type PendingDigest struct {
+	UserID       string
+	Notification Notification
+}
+
+// DigestBatcher coalesces rapid-fire pushes per user, type and group. Held
+// back events live in memory only; a restart drops their summary, while the
+// inbox still has every event.
+type DigestBatcher struct {
+	rules map[string]DigestRule
+
+	mu      sync.Mutex
+	windows map[string]*digestWindow
+}
+
+func NewDigestBatcher(rules map[string]DigestRule) *DigestBatcher {
+	return &DigestBatcher{
+		rules:   rules,
+		windows: make(map[string]*digestWindow),
+	}
+}
+
+// Admit returns the notification to push for n now, if any. Events of types
+// without a rule always go through; others are held back while their
+// window is open.
+func (b *DigestBatcher) Admit(userID string, n Notification, now time.Time) (Notification, bool) {
+	if b == nil {
+		return n, true
+	}
+	kind := n.Data["type"]
+	rule, ok := b.rules[kind]
+	if !ok || rule.Window <= 0 {
+		return n, true
+	}
+	group := ""
+	if rule.GroupBy != "" {
+		group = n.Data[rule.GroupBy]
+	}
+	key := userID + "|" + kind + "|" + group
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w, ok := b.windows[key]
+	if ok && now.Before(w.openedAt.Add(rule.Window)) {
+		w.held++
+		return Notification{}, false
+	}
+
+	b.windows[key] = &digestWindow{userID: userID, kind: kind, group: group, openedAt: now}
+	if ok && w.held > 0 {
+		// The flush job has not caught up with the ended window yet, so
+		// its summary goes out with this event folded in.
+		w.held++
+		return w.summary(rule), true
+	}
+	return n, true
+}
+
+// Due removes the windows that ended by now and returns the summaries of
+// those that held back events.
+func (b *DigestBatcher) Due(now time.Time) []PendingDigest {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var due []PendingDigest
+	for key, w := range b.windows {
+		rule := b.rules[w.kind]
+		if now.Before(w.openedAt.Add(rule.Window)) {
+			continue
+		}
+		delete(b.windows, key)
+		if w.held > 0 {
+			due = append(due, PendingDigest{UserID: w.userID, Notification: w.summary(rule)})
+		}
+	}
+	return due
+}
+
+// flushDigests pushes the summaries of ended digest windows.
+func (c *Controller) flushDigests() {
+	due := c.digests.Due(time.Now())
+	if len(due) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, d := range due {
+		c.push(d.UserID, d.Notification)
+	}
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to save data", "err", err)
+	}
+}
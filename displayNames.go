@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	minDisplayName      = 2
+	maxDisplayName      = 32
+	nameSuggestionCount = 3
+)
+
+var (
+	errNameLength     = fmt.Errorf("name must be %d to %d characters long", minDisplayName, maxDisplayName)
+	errNameCharacters = errors.New("name may only contain Latin or Cyrillic letters, digits, spaces and - _ . '")
+	errNameMixed      = errors.New("name may not mix Latin and Cyrillic letters")
+	errNameProfane    = errors.New("name contains inappropriate language")
+	errNameTaken      = errors.New("name is already taken")
+)
+
+func nameErrorStatus(err error) int {
+	if errors.Is(err, errNameTaken) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// NamePolicy controls which display names profiles may use.
+type NamePolicy struct {
+	// RequireUnique rejects names another profile of the same tenant already
+	// uses, ignoring case.
+	RequireUnique bool
+}
+
+// profaneStems are matched anywhere in the name once separators are dropped
+// and look-alike characters folded, so they are kept to stems that don't
+// occur inside ordinary names. profaneWords only match whole words, since
+// they do, as in "Hashimoto" or "Сукачёв".
+var (
+	profaneStems = []string{
+		"хуй", "хуе", "хуё", "пизд", "ебан", "ёбан", "ебал", "ебло", "бляд", "блят",
+		"мудак", "мудил", "пидор", "пидар", "залуп", "шлюх", "гандон", "дроч",
+		"fuck", "nigger", "faggot",
+	}
+	profaneWords = []string{
+		"бля", "сука", "суки", "сучка",
+		"shit", "cunt", "bitch", "whore", "nigga", "asshole",
+	}
+)
+
+// cyrillicLookalikes and latinLookalikes fold characters commonly swapped in
+// to dodge filters, including digits and symbols.
+var (
+	cyrillicLookalikes = strings.NewReplacer(
+		"a", "а", "b", "в", "c", "с", "e", "е", "h", "н", "k", "к", "m", "м", "o", "о",
+		"p", "р", "t", "т", "x", "х", "y", "у", "0", "о", "3", "з", "@", "а", "6", "б",
+	)
+	latinLookalikes = strings.NewReplacer(
+		"а", "a", "в", "b", "с", "c", "е", "e", "н", "h", "к", "k", "м", "m", "о", "o",
+		"р", "p", "т", "t", "х", "x", "у", "y", "0", "o", "1", "i", "3", "e", "4", "a",
+		"@", "a", "$", "s", "!", "i",
+	)
+)
+
+func isNameSeparator(r rune) bool {
+	return r == ' ' || r == '-' || r == '_' || r == '.' || r == '\''
+}
+
+// normalizeDisplayName trims the name and collapses runs of whitespace.
+func normalizeDisplayName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// validateDisplayName checks the length, characters and language of a
+// normalized name.
+func validateDisplayName(name string) error {
+	length := len([]rune(name))
+	if length < minDisplayName || length > maxDisplayName {
+		return errNameLength
+	}
+
+	var latin, cyrillic bool
+	for _, r := range name {
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic = true
+		case unicode.IsDigit(r) && r < unicode.MaxASCII, isNameSeparator(r):
+		default:
+			return errNameCharacters
+		}
+	}
+	if latin && cyrillic {
+		// Mixed scripts are mostly used to spoof other names or dodge
+		// the profanity filter.
+		return errNameMixed
+	}
+
+	if isProfane(name) {
+		return errNameProfane
+	}
+	return nil
+}
+
+func isProfane(name string) bool {
+	words := strings.FieldsFunc(strings.ToLower(name), isNameSeparator)
+	squashed := strings.Join(words, "")
+
+	for _, stem := range profaneStems {
+		if strings.Contains(cyrillicLookalikes.Replace(squashed), stem) ||
+			strings.Contains(latinLookalikes.Replace(squashed), stem) {
+			return true
+		}
+	}
+
+	// The squashed name counts as a word too, catching "s.h.i.t".
+	for _, w := range append(words, squashed) {
+		asCyrillic, asLatin := cyrillicLookalikes.Replace(w), latinLookalikes.Replace(w)
+		for _, word := range profaneWords {
+			if asCyrillic == word || asLatin == word {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nameTaken reports whether another user of tenant uses name, ignoring case.
+func (c *Controller) nameTaken(name, userID, tenant string) bool {
+	for _, u := range c.storage.Users {
+		if u.FirebaseUID != userID && userTenant(u) == tenant && strings.EqualFold(normalizeDisplayName(u.Name), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDisplayName normalizes name and applies the name policy for userID.
+func (c *Controller) checkDisplayName(name, userID, tenant string) (string, error) {
+	name = normalizeDisplayName(name)
+	if err := validateDisplayName(name); err != nil {
+		return name, err
+	}
+	if c.namePolicy.RequireUnique && c.nameTaken(name, userID, tenant) {
+		return name, errNameTaken
+	}
+	return name, nil
+}
+
+// suggestDisplayNames returns available variants of a taken name.
+func (c *Controller) suggestDisplayNames(name, userID, tenant string) []string {
+	suggestions := []string{}
+	tried := make(map[string]bool)
+	for attempt := 0; attempt < 50 && len(suggestions) < nameSuggestionCount; attempt++ {
+		suffix := strconv.Itoa(rand.IntN(1000))
+		base := []rune(name)
+		if len(base)+1+len(suffix) > maxDisplayName {
+			base = base[:maxDisplayName-1-len(suffix)]
+		}
+
+		candidate := strings.TrimSpace(string(base)) + " " + suffix
+		if tried[candidate] {
+			continue
+		}
+		tried[candidate] = true
+
+		if _, err := c.checkDisplayName(candidate, userID, tenant); err == nil {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions
+}
+
+type DisplayNameCheck struct {
+	Name        string   `json:"name"`
+	Available   bool     `json:"available"`
+	Error       string   `json:"error,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// CheckDisplayName handles GET /api/display-names/check?name=&userId=,
+// reporting whether the name is allowed and suggesting alternatives when
+// it is taken.
+func (c *Controller) CheckDisplayName(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("userId")
+	tenant := tenantID(r)
+	if u, ok := c.findUser(userID); ok {
+		tenant = userTenant(u)
+	}
+
+	name, err := c.checkDisplayName(r.URL.Query().Get("name"), userID, tenant)
+	result := DisplayNameCheck{Name: name, Available: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if errors.Is(err, errNameTaken) {
+		result.Suggestions = c.suggestDisplayNames(name, userID, tenant)
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
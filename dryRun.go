@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const confirmationTTL = 10 * time.Minute
+
+// DestructivePlan lists the records a destructive admin operation would
+// change, keyed by record type.
+type DestructivePlan struct {
+	Operation         string              `json:"operation"`
+	Affected          map[string][]string `json:"affected"`
+	ConfirmationToken string              `json:"confirmationToken,omitempty"`
+	ExpiresAt         *time.Time          `json:"expiresAt,omitempty"`
+}
+
+type pendingConfirmation struct {
+	operation string
+	digest    string
+	expiresAt time.Time
+}
+
+func (p DestructivePlan) digest() string {
+	// Maps marshal with sorted keys, so equal plans hash equally.
+	data, _ := json.Marshal(p.Affected)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// confirmDestructive implements the dry-run flow for destructive admin
+// operations. With ?dryRun=true it responds with the plan and a confirmation
+// token and returns false. Otherwise the request must carry ?confirm= with a
+// token issued for the same operation and an unchanged plan; on success the
+// token is used up and true is returned. The caller must hold c.mu.
+func (c *Controller) confirmDestructive(w http.ResponseWriter, r *http.Request, plan DestructivePlan) bool {
+	plan.Operation = r.Method + " " + r.URL.Path
+	now := time.Now()
+
+	for token, p := range c.confirmations {
+		if now.After(p.expiresAt) {
+			delete(c.confirmations, token)
+		}
+	}
+
+	query := r.URL.Query()
+	if query.Get("dryRun") == "true" {
+		if c.confirmations == nil {
+			c.confirmations = make(map[string]pendingConfirmation)
+		}
+
+		expiresAt := now.Add(confirmationTTL)
+		plan.ConfirmationToken = newID()
+		plan.ExpiresAt = &expiresAt
+		c.confirmations[plan.ConfirmationToken] = pendingConfirmation{
+			operation: plan.Operation,
+			digest:    plan.digest(),
+			expiresAt: expiresAt,
+		}
+
+		writeJSON(w, http.StatusOK, plan)
+		return false
+	}
+
+	token := query.Get("confirm")
+	pending, ok := c.confirmations[token]
+	if token == "" || !ok || pending.operation != plan.Operation {
+		writeError(w, http.StatusPreconditionRequired, "CONFIRMATION_REQUIRED",
+			"Confirmation required: run with dryRun=true and pass its confirmationToken as confirm")
+		return false
+	}
+	delete(c.confirmations, token)
+
+	if pending.digest != plan.digest() {
+		writeError(w, http.StatusConflict, "DRY_RUN_STALE", "Affected records changed since the dry run; run it again")
+		return false
+	}
+	return true
+}
+
+// userErasePlan mirrors what deleteUser removes.
+func (c *Controller) userErasePlan(uid string) DestructivePlan {
+	affected := map[string][]string{"users": {uid}}
+	add := func(kind, id string) {
+		affected[kind] = append(affected[kind], id)
+	}
+
+	if u, ok := c.findUser(uid); ok {
+		for _, url := range u.Photos {
+			if c.imageRemovable(url, uid) {
+				add("imageFiles", url)
+			}
+		}
+	}
+	for _, t := range c.storage.Tombstones {
+		if t.MergedInto == uid {
+			add("tombstones", t.UID)
+		}
+	}
+	for _, p := range c.storage.PendingImages {
+		if p.UserID == uid {
+			add("pendingImages", p.ImageURL)
+		}
+	}
+	for _, h := range c.storage.ImageHashes {
+		if h.UserID == uid {
+			add("imageHashes", h.ImageURL)
+		}
+	}
+	for _, change := range c.storage.ProfileHistory {
+		if change.UserID == uid {
+			add("profileHistory", change.Field+"@"+change.ChangedAt.Format(time.RFC3339Nano))
+		}
+	}
+	for _, t := range c.storage.UserTokens {
+		if t.UserID == uid {
+			add("userTokens", t.ID)
+		}
+	}
+	for _, g := range c.storage.GoalPosts {
+		if g.UserID == uid {
+			add("goalPosts", g.ID)
+		}
+		for _, resp := range g.Responses {
+			if resp.UserID == uid {
+				add("goalResponses", g.ID)
+			}
+		}
+	}
+	for _, s := range c.storage.Sessions {
+		if s.hasUser(uid) {
+			add("sessions", s.ID)
+		}
+	}
+	for _, n := range c.storage.Notifications {
+		if n.UserID == uid {
+			add("notifications", n.ID)
+		}
+	}
+	for _, ch := range c.storage.ContactChanges {
+		if ch.UserID == uid {
+			add("contactChanges", ch.ID)
+		}
+	}
+	for _, d := range c.storage.Deliveries {
+		if d.UserID == uid {
+			add("deliveries", d.ID)
+		}
+	}
+	for _, s := range c.storage.Swipes {
+		if s.SwiperID == uid || s.TargetID == uid {
+			add("swipes", s.SwiperID+"->"+s.TargetID)
+		}
+	}
+
+	matchIDs := make(map[string]bool)
+	for _, m := range c.storage.Matches {
+		if m.Has(uid) {
+			matchIDs[m.ID()] = true
+			add("matches", m.ID())
+		}
+	}
+	for _, m := range c.storage.Messages {
+		if matchIDs[m.MatchID] {
+			add("messages", m.ID)
+		}
+	}
+	for _, d := range c.storage.Devices {
+		if d.UserID == uid {
+			add("devices", hashToken(d.Token)[:12])
+		}
+	}
+	if _, ok := c.storage.EmergencyContacts[uid]; ok {
+		add("emergencyContacts", uid)
+	}
+	if _, ok := c.storage.ResponseStats[uid]; ok {
+		add("responseStats", uid)
+	}
+
+	return DestructivePlan{Affected: affected}
+}
+
+func (c *Controller) imagePurgePlan(u User) DestructivePlan {
+	affected := map[string][]string{"users": {u.FirebaseUID}}
+	if c.imageRemovable(u.ImageURL, u.FirebaseUID) {
+		affected["imageFiles"] = []string{u.ImageURL}
+	}
+	for _, h := range c.storage.ImageHashes {
+		if h.UserID == u.FirebaseUID && h.ImageURL == u.ImageURL {
+			affected["imageHashes"] = append(affected["imageHashes"], h.ImageURL)
+		}
+	}
+	return DestructivePlan{Affected: affected}
+}
+
+func (c *Controller) gymDeletePlan(gymID string) DestructivePlan {
+	affected := map[string][]string{"gyms": {gymID}}
+	for _, u := range c.storage.Users {
+		if u.HomeGymID == gymID {
+			affected["users"] = append(affected["users"], u.FirebaseUID)
+		}
+	}
+	return DestructivePlan{Affected: affected}
+}
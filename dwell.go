@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxDwellMs caps reported dwell times; a card left open for longer
+	// says nothing about the swipe.
+	maxDwellMs = 10 * 60 * 1000
+
+	// fastSwipeMs is the dwell below which a dislike counts as a fast
+	// reject: the card was dismissed without a real look.
+	fastSwipeMs = 1500
+
+	// minFastRejectSamples is how many timed swipes on a training type a
+	// requester needs before their fast-reject rate for it is trusted.
+	minFastRejectSamples = 3
+)
+
+func trainTypeKey(trainType string) string {
+	return strings.ToLower(strings.TrimSpace(trainType))
+}
+
+// fastRejectRates returns, per training type, the share of userID's timed
+// swipes on it that were fast rejects. Types with too few timed swipes are
+// left out.
+func (s *Storage) fastRejectRates(userID string) map[string]float64 {
+	trainTypes := make(map[string]string, len(s.Users))
+	for _, u := range s.Users {
+		trainTypes[u.FirebaseUID] = trainTypeKey(u.TrainType)
+	}
+
+	seen := make(map[string]int)
+	fast := make(map[string]int)
+	for _, swipe := range s.Swipes {
+		if swipe.SwiperID != userID || swipe.DwellMs <= 0 {
+			continue
+		}
+		trainType, ok := trainTypes[swipe.TargetID]
+		if !ok || trainType == "" {
+			continue
+		}
+		seen[trainType]++
+		if !swipe.IsLike && swipe.DwellMs < fastSwipeMs {
+			fast[trainType]++
+		}
+	}
+
+	rates := make(map[string]float64)
+	for trainType, n := range seen {
+		if n >= minFastRejectSamples && fast[trainType] > 0 {
+			rates[trainType] = float64(fast[trainType]) / float64(n)
+		}
+	}
+	return rates
+}
+
+// DwellStats summarizes the dwell times of one period's swipes. Percentiles
+// are in milliseconds over timed swipes only.
+type DwellStats struct {
+	Period         string  `json:"period"`
+	Swipes         int     `json:"swipes"`
+	Timed          int     `json:"timed"`
+	LikeP50Ms      int64   `json:"likeP50Ms"`
+	LikeP90Ms      int64   `json:"likeP90Ms"`
+	DislikeP50Ms   int64   `json:"dislikeP50Ms"`
+	DislikeP90Ms   int64   `json:"dislikeP90Ms"`
+	FastRejects    int     `json:"fastRejects"`
+	FastRejectRate float64 `json:"fastRejectRate"`
+}
+
+// dwellReport groups swipes by the week or month they were made in.
+func (c *Controller) dwellReport(period string) []DwellStats {
+	type bucket struct {
+		stats           DwellStats
+		likes, dislikes []int64
+	}
+	buckets := make(map[string]*bucket)
+
+	for _, s := range c.storage.Swipes {
+		key := cohortKey(s.CreatedAt, period)
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{stats: DwellStats{Period: key}}
+			buckets[key] = b
+		}
+
+		b.stats.Swipes++
+		if s.DwellMs <= 0 {
+			continue
+		}
+		b.stats.Timed++
+		if s.IsLike {
+			b.likes = append(b.likes, s.DwellMs)
+			continue
+		}
+		b.dislikes = append(b.dislikes, s.DwellMs)
+		if s.DwellMs < fastSwipeMs {
+			b.stats.FastRejects++
+		}
+	}
+
+	report := make([]DwellStats, 0, len(buckets))
+	for _, b := range buckets {
+		b.stats.LikeP50Ms, b.stats.LikeP90Ms = percentile(b.likes, 50), percentile(b.likes, 90)
+		b.stats.DislikeP50Ms, b.stats.DislikeP90Ms = percentile(b.dislikes, 50), percentile(b.dislikes, 90)
+		if b.stats.Timed > 0 {
+			b.stats.FastRejectRate = float64(b.stats.FastRejects) / float64(b.stats.Timed)
+		}
+		report = append(report, b.stats)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Period < report[j].Period })
+	return report
+}
+
+// percentile returns the nearest-rank p-th percentile of values, or zero
+// for none. It sorts values in place.
+func percentile(values []int64, p int) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := (p*len(values) + 99) / 100
+	return values[max(rank, 1)-1]
+}
+
+// AdminDwell handles GET /admin/analytics/dwell?period=week|month.
+func (c *Controller) AdminDwell(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "week"
+	}
+	if period != "week" && period != "month" {
+		writeError(w, http.StatusBadRequest, "INVALID_PERIOD", "Invalid period")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"period":      period,
+		"fastSwipeMs": fastSwipeMs,
+		"periods":     c.dwellReport(period),
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// APIError is the body of every error response,
+// {"error": {"code": "USER_NOT_FOUND", "message": "User not found"}}. Code is
+// stable and meant for clients to branch on; Message is for humans and may
+// change. Details carries extra data for some codes, such as the offending
+// fields of VALIDATION_FAILED.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+type errorResponse struct {
+	Error APIError `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorResponse{Error: APIError{Code: code, Message: message}})
+}
+
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details any) {
+	writeJSON(w, status, errorResponse{Error: APIError{Code: code, Message: message, Details: details}})
+}
+
+// apiErrorCodes lists every code the API sends. It becomes the ErrorCode
+// enum of /openapi.json so generated clients can switch on codes; add new
+// codes here.
+var apiErrorCodes = []string{
+	"ACCOUNT_MERGED",
+	"ALREADY_UNDONE",
+	"ANNOUNCEMENT_LIMIT",
+	"AUTOMATION_RULE_NOT_FOUND",
+	"BODY_TOO_LARGE",
+	"CARD_NOT_EDITABLE",
+	"CONFIRMATION_REQUIRED",
+	"CONTACT_CHANGE_NOT_FOUND",
+	"CONTACT_REQUIRED",
+	"CONVERSATION_LIMIT",
+	"DAILY_LIKE_LIMIT",
+	"DECK_EMPTY",
+	"DELIVERY_NOT_FOUND",
+	"DRY_RUN_STALE",
+	"EDIT_WINDOW_PASSED",
+	"ENCRYPTION_NOT_CONFIGURED",
+	"FEATURE_DISABLED",
+	"FEATURE_NOT_FOUND",
+	"FORBIDDEN",
+	"GOAL_ALREADY_RESPONDED",
+	"GOAL_ANSWER_FORBIDDEN",
+	"GOAL_CLOSED",
+	"GOAL_CLOSE_FORBIDDEN",
+	"GOAL_NOT_FOUND",
+	"GOAL_RESPONSE_ANSWERED",
+	"GOAL_RESPONSE_NOT_FOUND",
+	"GOAL_SELF_RESPONSE",
+	"GYM_NOT_FOUND",
+	"HARASSMENT_ALERT_NOT_FOUND",
+	"HARASSMENT_ALERT_REVIEWED",
+	"HARASSMENT_RULE_NOT_FOUND",
+	"IMAGE_LOAD_FAILED",
+	"IMAGE_MIGRATION_NOT_FOUND",
+	"IMAGE_MIGRATION_NOT_RUNNING",
+	"IMAGE_MIGRATION_RUNNING",
+	"IMAGE_MIGRATION_UNAVAILABLE",
+	"IMAGE_NOT_INDEXED",
+	"IMAGE_REQUIRED",
+	"IMAGE_SAVE_FAILED",
+	"IMAGE_TOO_LARGE",
+	"INTERNAL_ERROR",
+	"INVALID_ANNOUNCEMENT",
+	"INVALID_AUTOMATION_RULE",
+	"INVALID_BLOCK_REQUEST",
+	"INVALID_CONTACT",
+	"INVALID_COORDINATES",
+	"INVALID_COUNT",
+	"INVALID_CURSOR",
+	"INVALID_DWELL",
+	"INVALID_EMERGENCY_CONTACT",
+	"INVALID_FEATURE_NAME",
+	"INVALID_FEATURE_RULE",
+	"INVALID_FILTER",
+	"INVALID_GOAL",
+	"INVALID_GYM",
+	"INVALID_HARASSMENT_RULE",
+	"INVALID_LIMIT",
+	"INVALID_MESSAGE",
+	"INVALID_MODERATION_ACTION",
+	"INVALID_MULTIPART_FORM",
+	"INVALID_NAME",
+	"INVALID_OFFSET",
+	"INVALID_PERIOD",
+	"INVALID_PHOTO_ORDER",
+	"INVALID_QUOTA",
+	"INVALID_REPORT",
+	"INVALID_REQUEST_BODY",
+	"INVALID_SECONDARY_TOKEN",
+	"INVALID_SEND_AT",
+	"INVALID_SESSION",
+	"INVALID_STATUS",
+	"INVALID_TENANT_CONFIG",
+	"INVALID_TENANT_ID",
+	"INVALID_VERIFICATION_CODE",
+	"INVALID_WAIT",
+	"INVALID_WINDOW",
+	"LOCATION_REQUIRED",
+	"MAINTENANCE",
+	"MATCH_NOT_FOUND",
+	"MERGE_ACCOUNT_NOT_FOUND",
+	"MESSAGE_DELETED",
+	"MESSAGE_DELETE_FORBIDDEN",
+	"MESSAGE_EDIT_FORBIDDEN",
+	"MESSAGE_NOT_FOUND",
+	"MESSAGE_REQUEST_ANSWERED",
+	"MESSAGE_REQUEST_DECLINED",
+	"MESSAGE_REQUEST_NOT_FOUND",
+	"MESSAGE_REQUEST_PENDING",
+	"MESSAGING_RESTRICTED",
+	"METHOD_NOT_ALLOWED",
+	"MISSING_SCOPE",
+	"NAME_TAKEN",
+	"NOTHING_TO_UNDO",
+	"NOTIFICATION_NOT_FOUND",
+	"NOT_FOUND",
+	"NOT_MATCH_PARTICIPANT",
+	"ORIGIN_NOT_ALLOWED",
+	"PARTNER_NOT_FOUND",
+	"PENDING_IMAGE_NOT_FOUND",
+	"PHOTO_LIMIT_REACHED",
+	"PHOTO_NOT_FOUND",
+	"PHOTO_NOT_INDEXED",
+	"PLAN_NOT_FOUND",
+	"PROFILE_FORBIDDEN",
+	"RATE_LIMITED",
+	"REENCRYPTION_FAILED",
+	"REPORT_ALREADY_MODERATED",
+	"REPORT_NOT_FOUND",
+	"SAME_ACCOUNT",
+	"SCHEDULED_MESSAGE_CANCEL_FORBIDDEN",
+	"SCHEDULED_MESSAGE_NOT_FOUND",
+	"SCHEDULED_MESSAGE_NOT_PENDING",
+	"SESSION_ANSWER_FORBIDDEN",
+	"SESSION_CLOSED",
+	"SESSION_IN_PAST",
+	"SESSION_NOT_FOUND",
+	"STATS_NOT_READY",
+	"STORAGE_ERROR",
+	"SWIPE_SELF_FORBIDDEN",
+	"TELEGRAM_NOT_LINKED",
+	"TELEGRAM_UNAVAILABLE",
+	"TENANT_NOT_FOUND",
+	"TOKEN_NOT_FOUND",
+	"TOKEN_USER_MISMATCH",
+	"TOO_FAR_FROM_GYM",
+	"TOO_MANY_EMERGENCY_CONTACTS",
+	"TRANSLATION_FAILED",
+	"TRANSLATION_LIMIT",
+	"TRANSLATION_UNAVAILABLE",
+	"UNAUTHORIZED",
+	"UNDO_WINDOW_PASSED",
+	"UNKNOWN_SCOPE",
+	"UNSUPPORTED_API_VERSION",
+	"UNSUPPORTED_FORMAT",
+	"UNSUPPORTED_IMAGE",
+	"UPGRADE_REQUIRED",
+	"USER_BLOCKED",
+	"USER_ID_AND_TOKEN_REQUIRED",
+	"USER_ID_REQUIRED",
+	"USER_NOT_FOUND",
+	"USER_TOKEN_REQUIRED",
+	"VALIDATION_FAILED",
+	"VERIFICATION_CODE_EXPIRED",
+}
+
+// errorCodes maps sentinel errors whose message is passed through to
+// clients to their codes.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{errNotParticipant, "NOT_MATCH_PARTICIPANT"},
+	{errInvalidMessage, "INVALID_MESSAGE"},
+	{errConversationLimit, "CONVERSATION_LIMIT"},
+	{errMessagingRestricted, "MESSAGING_RESTRICTED"},
+	{errMessageRequestDenied, "MESSAGE_REQUEST_DECLINED"},
+	{errMessageRequestWait, "MESSAGE_REQUEST_PENDING"},
+	{errNoImage, "IMAGE_REQUIRED"},
+	{errUnsupportedImage, "UNSUPPORTED_IMAGE"},
+	{errImageTooLarge, "IMAGE_TOO_LARGE"},
+	{errImageSave, "IMAGE_SAVE_FAILED"},
+	{errNameLength, "INVALID_NAME"},
+	{errNameCharacters, "INVALID_NAME"},
+	{errNameMixed, "INVALID_NAME"},
+	{errNameProfane, "INVALID_NAME"},
+	{errNameTaken, "NAME_TAKEN"},
+}
+
+// errorCode returns the code of err, or fallback if it has none.
+func errorCode(err error, fallback string) string {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return fallback
+}
+
+// NotFoundAPI answers /api/ paths that match no route.
+func NotFoundAPI(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// imageMaxAge is how long clients may reuse an image without revalidating.
+// Image keys are never reused for different content.
+const imageMaxAge = 24 * time.Hour
+
+// bootID tells ETags of different server runs apart, since the data version
+// restarts from zero.
+var bootID = fmt.Sprint(time.Now().UnixNano())
+
+// dataETag returns a weak ETag for a response computed from storage and the
+// request path and query. It changes whenever data is saved. The caller must hold
+// c.mu.
+func (c *Controller) dataETag(r *http.Request) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%s?%s", bootID, c.dataVersion, r.URL.Path, r.URL.RawQuery)))
+	return `W/"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// imageETag returns a strong ETag for a stored image.
+func imageETag(info ImageInfo) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%d", info.Key, info.Size, info.ModTime.UnixNano())))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// notModified sets etag on the response and answers 304 when the request's
+// If-None-Match already names it. It returns true when it has responded.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
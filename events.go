@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxPollWait    = 25 * time.Second
+	eventRetention = time.Hour
+)
+
+// Event is a notification delivered through the in-app event channels. IDs
+// increase monotonically, also across restarts, and serve as poll cursors.
+type Event struct {
+	ID           int64 `json:"id"`
+	userID       string
+	Notification `json:"notification"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type EventsPage struct {
+	Events []Event `json:"events"`
+	Cursor string  `json:"cursor"`
+}
+
+// EventHub keeps recent events in memory and wakes up waiting pollers when a
+// new one is published.
+type EventHub struct {
+	mu     sync.Mutex
+	lastID int64
+	events []Event
+	wake   chan struct{}
+	closed bool
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		// Start above any ID handed out before a restart.
+		lastID: time.Now().UnixMilli() * 1000,
+		wake:   make(chan struct{}),
+	}
+}
+
+func (h *EventHub) Publish(userID string, n Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.lastID++
+	h.events = append(h.events, Event{ID: h.lastID, userID: userID, Notification: n, CreatedAt: now})
+
+	// Events are appended in time order, so expired ones are a prefix.
+	i := 0
+	for i < len(h.events) && now.Sub(h.events[i].CreatedAt) > eventRetention {
+		i++
+	}
+	h.events = h.events[i:]
+
+	close(h.wake)
+	h.wake = make(chan struct{})
+}
+
+// since returns userID's events after cursor and the channel that is closed on
+// the next publish.
+func (h *EventHub) since(userID string, cursor int64) ([]Event, int64, <-chan struct{}, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := []Event{}
+	for _, e := range h.events {
+		if e.ID > cursor && e.userID == userID {
+			events = append(events, e)
+		}
+	}
+	return events, h.lastID, h.wake, h.closed
+}
+
+// Wait returns userID's events after cursor, waiting up to timeout for one to
+// be published. The returned cursor is the one to pass next time.
+func (h *EventHub) Wait(ctx context.Context, userID string, cursor int64, timeout time.Duration) ([]Event, int64) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		events, last, wake, closed := h.since(userID, cursor)
+		if len(events) > 0 || closed {
+			return events, last
+		}
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			return events, last
+		case <-ctx.Done():
+			return events, last
+		}
+	}
+}
+
+// Close releases all waiting pollers; used on shutdown.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.closed {
+		h.closed = true
+		close(h.wake)
+	}
+}
+
+// PollNotifications handles GET /api/notifications/poll?userId=&cursor=, a
+// long-polling fallback for clients without push. Without a cursor it returns
+// the current cursor immediately. It must not be wrapped in locked, as it
+// waits for up to maxPollWait.
+func (c *Controller) PollNotifications(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userID := query.Get("userId")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "USER_ID_REQUIRED", "User ID is required")
+		return
+	}
+
+	if query.Get("cursor") == "" {
+		_, last, _, _ := c.events.since(userID, 0)
+		writeJSON(w, http.StatusOK, EventsPage{Events: []Event{}, Cursor: strconv.FormatInt(last, 10)})
+		return
+	}
+
+	cursor, err := strconv.ParseInt(query.Get("cursor"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_CURSOR", "Invalid cursor")
+		return
+	}
+
+	wait := maxPollWait
+	if v := query.Get("wait"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			writeError(w, http.StatusBadRequest, "INVALID_WAIT", "Invalid wait")
+			return
+		}
+		wait = min(time.Duration(seconds)*time.Second, maxPollWait)
+	}
+
+	events, last := c.events.Wait(r.Context(), userID, cursor, wait)
+	if len(events) > 0 {
+		last = events[len(events)-1].ID
+	}
+	writeJSON(w, http.StatusOK, EventsPage{Events: events, Cursor: strconv.FormatInt(last, 10)})
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+const (
+	jpegQuality = 90
+
+	// maxImagePixels caps the dimensions of images the server decodes. A
+	// few kilobytes of PNG can declare a 60000x60000 image, and decoding
+	// allocates every pixel up front.
+	maxImagePixels = 50_000_000
+	// maxOrientedPixels is the lower cap for photos whose EXIF orientation
+	// is applied, since the rotated copy is held next to the decoded image.
+	// It still fits a 6000x4000 camera photo.
+	maxOrientedPixels = 24_000_000
+)
+
+// checkImageSize reads the header of the image in data and rejects images
+// with more than maxImagePixels pixels, before anything decodes them.
+func checkImageSize(data []byte) error {
+	return checkImagePixels(data, maxImagePixels)
+}
+
+func checkImagePixels(data []byte, limit int64) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnsupportedImage, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > limit {
+		return fmt.Errorf("%w: the limit is %d pixels", errImageTooLarge, limit)
+	}
+	return nil
+}
+
+// stripMetadata copies the image in src to dst without EXIF, XMP or other
+// metadata, which can carry GPS coordinates and device details. JPEG and PNG
+// files are re-encoded; the EXIF orientation is applied to the pixels first
+// so photos don't end up sideways. WebP can't be decoded by the standard
+// library, so its metadata chunks are removed from the container instead.
+func stripMetadata(contentType string, src io.Reader, dst io.Writer) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	if contentType == "image/jpeg" || contentType == "image/png" {
+		if err := checkImageSize(data); err != nil {
+			return err
+		}
+	}
+
+	switch contentType {
+	case "image/jpeg":
+		orientation := jpegOrientation(data)
+		if orientation > 1 {
+			if err := checkImagePixels(data, maxOrientedPixels); err != nil {
+				return err
+			}
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUnsupportedImage, err)
+		}
+		return jpeg.Encode(dst, applyOrientation(img, orientation), &jpeg.Options{Quality: jpegQuality})
+
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUnsupportedImage, err)
+		}
+		return png.Encode(dst, img)
+
+	case "image/webp":
+		stripped, err := stripWebPMetadata(data)
+		if err != nil {
+			return err
+		}
+		_, err = dst.Write(stripped)
+		return err
+
+	default:
+		return errUnsupportedImage
+	}
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG file, or 1
+// when it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF; {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			// Image data starts at SOS; metadata can only come before it.
+			break
+		}
+
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of a TIFF
+// structure, as embedded in a JPEG APP1 segment.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 0 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation flips and rotates img so it displays upright without its
+// EXIF orientation tag. The pixels are copied in img's own format, so a
+// decoded JPEG isn't expanded to a wider one on the way.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	rect := image.Rect(0, 0, w, h)
+	if orientation >= 5 {
+		// Orientations 5-8 swap the width and height.
+		rect = image.Rect(0, 0, h, w)
+	}
+
+	switch src := img.(type) {
+	case *image.YCbCr:
+		ratio, ok := orientedSubsampleRatio(src.SubsampleRatio, orientation)
+		if !ok || bounds.Min != (image.Point{}) {
+			break
+		}
+		dst := image.NewYCbCr(rect, ratio)
+		orientPixels(dst.Y, dst.YStride, src.Y, src.YStride, w, h, 1, orientation)
+		cw, ch := chromaSize(bounds, src.SubsampleRatio)
+		orientPixels(dst.Cb, dst.CStride, src.Cb, src.CStride, cw, ch, 1, orientation)
+		orientPixels(dst.Cr, dst.CStride, src.Cr, src.CStride, cw, ch, 1, orientation)
+		return dst
+
+	case *image.Gray:
+		dst := image.NewGray(rect)
+		orientPixels(dst.Pix, dst.Stride, src.Pix, src.Stride, w, h, 1, orientation)
+		return dst
+
+	case *image.CMYK:
+		dst := image.NewCMYK(rect)
+		orientPixels(dst.Pix, dst.Stride, src.Pix, src.Stride, w, h, 4, orientation)
+		return dst
+
+	case *image.RGBA:
+		dst := image.NewRGBA(rect)
+		orientPixels(dst.Pix, dst.Stride, src.Pix, src.Stride, w, h, 4, orientation)
+		return dst
+	}
+
+	// Other formats, including 4:1:1 chroma that can't be transposed, go
+	// through RGBA.
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+	return applyOrientation(rgba, orientation)
+}
+
+// orientPixels copies the w x h pixels of src, bpp bytes each, to where
+// orientation moves them in dst.
+func orientPixels(dst []byte, dstStride int, src []byte, srcStride, w, h, bpp, orientation int) {
+	for sy := 0; sy < h; sy++ {
+		row := src[sy*srcStride:]
+		for sx := 0; sx < w; sx++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-sx, sy
+			case 3:
+				dx, dy = w-1-sx, h-1-sy
+			case 4:
+				dx, dy = sx, h-1-sy
+			case 5:
+				dx, dy = sy, sx
+			case 6:
+				dx, dy = h-1-sy, sx
+			case 7:
+				dx, dy = h-1-sy, w-1-sx
+			case 8:
+				dx, dy = sy, w-1-sx
+			}
+			copy(dst[dy*dstStride+dx*bpp:][:bpp], row[sx*bpp:][:bpp])
+		}
+	}
+}
+
+// orientedSubsampleRatio returns the chroma subsampling of a YCbCr image
+// after orientation. Orientations that swap the axes swap the ratio too,
+// which has no equivalent for 4:1:1 and 4:1:0.
+func orientedSubsampleRatio(ratio image.YCbCrSubsampleRatio, orientation int) (image.YCbCrSubsampleRatio, bool) {
+	switch {
+	case orientation < 5:
+		return ratio, true
+	case ratio == image.YCbCrSubsampleRatio444, ratio == image.YCbCrSubsampleRatio420:
+		return ratio, true
+	case ratio == image.YCbCrSubsampleRatio422:
+		return image.YCbCrSubsampleRatio440, true
+	case ratio == image.YCbCrSubsampleRatio440:
+		return image.YCbCrSubsampleRatio422, true
+	}
+	return ratio, false
+}
+
+// chromaSize returns the size of the Cb and Cr planes of a YCbCr image with
+// bounds r, as image.NewYCbCr lays them out.
+func chromaSize(r image.Rectangle, ratio image.YCbCrSubsampleRatio) (w, h int) {
+	w, h = r.Dx(), r.Dy()
+	switch ratio {
+	case image.YCbCrSubsampleRatio422:
+		w = (r.Max.X+1)/2 - r.Min.X/2
+	case image.YCbCrSubsampleRatio420:
+		w = (r.Max.X+1)/2 - r.Min.X/2
+		h = (r.Max.Y+1)/2 - r.Min.Y/2
+	case image.YCbCrSubsampleRatio440:
+		h = (r.Max.Y+1)/2 - r.Min.Y/2
+	case image.YCbCrSubsampleRatio411:
+		w = (r.Max.X+3)/4 - r.Min.X/4
+	case image.YCbCrSubsampleRatio410:
+		w = (r.Max.X+3)/4 - r.Min.X/4
+		h = (r.Max.Y+1)/2 - r.Min.Y/2
+	}
+	return w, h
+}
+
+// WebP extended-format (VP8X) flags announcing metadata chunks.
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+// stripWebPMetadata drops the EXIF and XMP chunks from a WebP RIFF container
+// and clears their flags in the VP8X header.
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errUnsupportedImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("%w: truncated WebP chunk", errUnsupportedImage)
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if pos+8+size > len(data) {
+			return nil, fmt.Errorf("%w: truncated WebP chunk", errUnsupportedImage)
+		}
+		// Chunks are padded to an even size.
+		end := min(pos+8+size+size%2, len(data))
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := bytes.Clone(data[pos:end])
+			if size > 0 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// orientedPoint is where EXIF orientation moves pixel p of a w x h image.
+func orientedPoint(p image.Point, w, h, orientation int) image.Point {
+	switch orientation {
+	case 2:
+		return image.Pt(w-1-p.X, p.Y)
+	case 3:
+		return image.Pt(w-1-p.X, h-1-p.Y)
+	case 4:
+		return image.Pt(p.X, h-1-p.Y)
+	case 5:
+		return image.Pt(p.Y, p.X)
+	case 6:
+		return image.Pt(h-1-p.Y, p.X)
+	case 7:
+		return image.Pt(h-1-p.Y, w-1-p.X)
+	case 8:
+		return image.Pt(p.Y, w-1-p.X)
+	}
+	return p
+}
+
+func TestApplyOrientation(t *testing.T) {
+	const w, h = 8, 4
+	rect := image.Rect(0, 0, w, h)
+
+	gray := image.NewGray(rect)
+	rgba := image.NewRGBA(rect)
+	images := map[string]image.Image{"gray": gray, "rgba": rgba}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray.SetGray(x, y, color.Gray{uint8(x*30 + y)})
+			rgba.SetRGBA(x, y, color.RGBA{uint8(x * 30), uint8(y * 60), uint8(x * y), 255})
+		}
+	}
+	// Chroma varies per block so the subsampled planes are checked too.
+	for name, ratio := range map[string]image.YCbCrSubsampleRatio{
+		"4:4:4": image.YCbCrSubsampleRatio444,
+		"4:2:2": image.YCbCrSubsampleRatio422,
+		"4:2:0": image.YCbCrSubsampleRatio420,
+		"4:4:0": image.YCbCrSubsampleRatio440,
+		"4:1:1": image.YCbCrSubsampleRatio411,
+	} {
+		img := image.NewYCbCr(rect, ratio)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.Y[img.YOffset(x, y)] = uint8(x*30 + y)
+				img.Cb[img.COffset(x, y)] = uint8(60 + x*20 + y*10)
+				img.Cr[img.COffset(x, y)] = uint8(200 - x*10 - y*20)
+			}
+		}
+		images[name] = img
+	}
+
+	for name, img := range images {
+		for orientation := 2; orientation <= 8; orientation++ {
+			got := applyOrientation(img, orientation)
+			// 4:1:1 has no transposed equivalent and falls back to RGBA.
+			if kept := name != "4:1:1" || orientation < 5; kept && fmt.Sprintf("%T", got) != fmt.Sprintf("%T", img) {
+				t.Errorf("%s, orientation %d: got a %T", name, orientation, got)
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					p := orientedPoint(image.Pt(x, y), w, h, orientation)
+					want := color.RGBAModel.Convert(img.At(x, y))
+					if c := color.RGBAModel.Convert(got.At(p.X, p.Y)); c != want {
+						t.Fatalf("%s, orientation %d: pixel (%d,%d) moved to %v is %v, want %v",
+							name, orientation, x, y, p, c, want)
+					}
+				}
+			}
+		}
+	}
+}
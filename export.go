@@ -0,0 +1,262 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserExport is everything stored about one user, for data access requests.
+type UserExport struct {
+	ExportedAt time.Time `json:"exportedAt"`
+	Profile    User      `json:"profile"`
+
+	SwipesSent      []Swipe            `json:"swipesSent"`
+	SwipesReceived  []Swipe            `json:"swipesReceived"`
+	Matches         []Match            `json:"matches"`
+	Chats           []ChatTranscript   `json:"chats"`
+	MessageRequests []MessageRequest   `json:"messageRequests"`
+	Scheduled       []ScheduledMessage `json:"scheduledMessages"`
+	QuickReplies    []string           `json:"quickReplies"`
+
+	EmergencyContacts []EmergencyContact `json:"emergencyContacts"`
+	SharedPlans       []SharedPlan       `json:"sharedPlans"`
+	Blocks            []Block            `json:"blocks"`
+	Reports           []Report           `json:"reports"`
+
+	Goals         []GoalPost          `json:"goals"`
+	Sessions      []Session           `json:"sessions"`
+	CheckIns      []CheckIn           `json:"checkIns"`
+	Devices       []Device            `json:"devices"`
+	Telegram      *TelegramChat       `json:"telegram,omitempty"`
+	Notifications []InboxNotification `json:"notifications"`
+
+	ProfileHistory []ProfileChange `json:"profileHistory"`
+	ContactChanges []ContactChange `json:"contactChanges"`
+	PendingImages  []PendingImage  `json:"pendingImages"`
+	Tokens         []UserToken     `json:"tokens"`
+}
+
+// userExport collects uid's data. Data about other users only appears where
+// it is part of uid's own records, such as chat partners in transcripts;
+// reports filed against uid and blocks of uid are left out to protect the
+// reporter.
+func (c *Controller) userExport(u User) UserExport {
+	uid := u.FirebaseUID
+	export := UserExport{
+		ExportedAt:        time.Now(),
+		Profile:           u,
+		SwipesSent:        []Swipe{},
+		SwipesReceived:    []Swipe{},
+		Matches:           []Match{},
+		Chats:             []ChatTranscript{},
+		MessageRequests:   []MessageRequest{},
+		Scheduled:         []ScheduledMessage{},
+		QuickReplies:      slices.Clone(c.storage.QuickReplies[uid]),
+		EmergencyContacts: slices.Clone(c.storage.EmergencyContacts[uid]),
+		SharedPlans:       []SharedPlan{},
+		Blocks:            []Block{},
+		Reports:           []Report{},
+		Goals:             []GoalPost{},
+		Sessions:          []Session{},
+		CheckIns:          []CheckIn{},
+		Devices:           []Device{},
+		Notifications:     []InboxNotification{},
+		ProfileHistory:    c.profileHistory(uid),
+		ContactChanges:    []ContactChange{},
+		PendingImages:     []PendingImage{},
+		Tokens:            []UserToken{},
+	}
+	if export.QuickReplies == nil {
+		export.QuickReplies = []string{}
+	}
+	if export.EmergencyContacts == nil {
+		export.EmergencyContacts = []EmergencyContact{}
+	}
+
+	for _, s := range c.storage.Swipes {
+		if s.SwiperID == uid {
+			export.SwipesSent = append(export.SwipesSent, s)
+		}
+		if s.TargetID == uid {
+			// How long the swiper looked is their data, not the target's.
+			s.DwellMs = 0
+			export.SwipesReceived = append(export.SwipesReceived, s)
+		}
+	}
+	for _, m := range c.storage.Matches {
+		if m.Has(uid) {
+			export.Matches = append(export.Matches, m)
+			export.Chats = append(export.Chats, c.chatTranscript(m))
+		}
+	}
+	for _, req := range c.storage.MessageRequests {
+		if req.FromID == uid || req.ToID == uid {
+			export.MessageRequests = append(export.MessageRequests, req)
+		}
+	}
+	for _, m := range c.storage.ScheduledMessages {
+		if m.SenderID == uid {
+			export.Scheduled = append(export.Scheduled, m)
+		}
+	}
+	for _, p := range c.storage.SharedPlans {
+		if p.UserID == uid {
+			export.SharedPlans = append(export.SharedPlans, p)
+		}
+	}
+	for _, b := range c.storage.Blocks {
+		if b.BlockerID == uid {
+			export.Blocks = append(export.Blocks, b)
+		}
+	}
+	for _, r := range c.storage.Reports {
+		if r.ReporterID == uid {
+			export.Reports = append(export.Reports, r)
+		}
+	}
+	for _, g := range c.storage.GoalPosts {
+		if g.UserID == uid || slices.ContainsFunc(g.Responses, func(r GoalResponse) bool { return r.UserID == uid }) {
+			export.Goals = append(export.Goals, g)
+		}
+	}
+	for _, s := range c.storage.Sessions {
+		if s.hasUser(uid) {
+			export.Sessions = append(export.Sessions, s)
+		}
+	}
+	for _, ci := range c.storage.CheckIns {
+		if ci.UserID == uid {
+			export.CheckIns = append(export.CheckIns, ci)
+		}
+	}
+	for _, d := range c.storage.Devices {
+		if d.UserID == uid {
+			export.Devices = append(export.Devices, d)
+		}
+	}
+	if i, ok := c.findTelegramChat(uid); ok {
+		chat := c.storage.TelegramChats[i]
+		export.Telegram = &chat
+	}
+	for _, ch := range c.storage.ContactChanges {
+		if ch.UserID == uid {
+			ch.CodeHash = ""
+			export.ContactChanges = append(export.ContactChanges, ch)
+		}
+	}
+	for _, n := range c.storage.Notifications {
+		if n.UserID == uid {
+			export.Notifications = append(export.Notifications, n)
+		}
+	}
+	for _, p := range c.storage.PendingImages {
+		if p.UserID == uid {
+			export.PendingImages = append(export.PendingImages, p)
+		}
+	}
+	for _, t := range c.storage.UserTokens {
+		if t.UserID == uid {
+			export.Tokens = append(export.Tokens, t.UserToken)
+		}
+	}
+
+	return export
+}
+
+// exportImages lists the uploaded images of the export: the profile photos
+// and photos still waiting for moderation.
+func (e UserExport) exportImages() []string {
+	urls := slices.Clone(e.Profile.Photos)
+	for _, p := range e.PendingImages {
+		urls = append(urls, p.ImageURL)
+	}
+
+	var keys []string
+	for _, url := range urls {
+		if strings.HasPrefix(url, "/images/") && url != defaultImageURL {
+			keys = append(keys, imageKey(url))
+		}
+	}
+	return keys
+}
+
+// accountRoute resolves the {uid} of /api/users/{uid}/... routes for h.
+func (c *Controller) accountRoute(h func(http.ResponseWriter, *http.Request, User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid := r.PathValue("uid")
+		u, ok := c.findUser(uid)
+		if !ok {
+			writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+			return
+		}
+		h(w, r, u)
+	}
+}
+
+// exportAccount handles GET /api/users/{uid}/export?format=json|zip. The zip
+// archive holds export.json and the user's photos under photos/.
+func (c *Controller) exportAccount(w http.ResponseWriter, r *http.Request, u User) {
+	uid := u.FirebaseUID
+	export := c.userExport(u)
+	filename := "gymbro-export-" + time.Now().UTC().Format("20060102")
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		writeJSON(w, http.StatusOK, export)
+	case "zip":
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+		if err := c.writeExportZip(r, w, export); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to write data export", "uid", uid, "err", err)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "UNSUPPORTED_FORMAT", "Unsupported format")
+	}
+}
+
+func (c *Controller) writeExportZip(r *http.Request, w io.Writer, export UserExport) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("export.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+
+	for _, key := range export.exportImages() {
+		if err := c.copyExportImage(r, zw, key); err != nil {
+			// The archive is already streaming, so a missing photo is
+			// skipped rather than failing the whole export.
+			slog.WarnContext(r.Context(), "Skipping image in data export", "key", key, "err", err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func (c *Controller) copyExportImage(r *http.Request, zw *zip.Writer, key string) error {
+	src, _, err := c.images.Open(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := zw.Create(path.Join("photos", key))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errInjectedFault = errors.New("injected fault")
+
+// FaultInjector simulates failures for resilience testing. It is configured
+// with the FAULTS environment variable, e.g.
+//
+//	FAULTS="storageWriteFailRate=0.2,imageSaveDelay=2s,notificationDropRate=0.5"
+//
+// A nil injector injects nothing, so production code calls it unconditionally.
+type FaultInjector struct {
+	StorageWriteFailRate float64
+	ImageSaveDelay       time.Duration
+	NotificationDropRate float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func ParseFaults(spec string) (*FaultInjector, error) {
+	f := &FaultInjector{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("fault %q must be key=value", entry)
+		}
+
+		var err error
+		switch key {
+		case "storageWriteFailRate":
+			f.StorageWriteFailRate, err = strconv.ParseFloat(value, 64)
+		case "imageSaveDelay":
+			f.ImageSaveDelay, err = time.ParseDuration(value)
+		case "notificationDropRate":
+			f.NotificationDropRate, err = strconv.ParseFloat(value, 64)
+		default:
+			return nil, fmt.Errorf("unknown fault %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+	}
+
+	return f, nil
+}
+
+func faultsFromEnv() *FaultInjector {
+	spec := os.Getenv("FAULTS")
+	if spec == "" {
+		return nil
+	}
+
+	f, err := ParseFaults(spec)
+	if err != nil {
+		slog.Error("Ignoring invalid FAULTS", "err", err)
+		return nil
+	}
+
+	slog.Warn("Fault injection is enabled, do not run this in production", "faults", spec)
+	return f
+}
+
+func (f *FaultInjector) roll(rate float64) bool {
+	if f == nil || rate <= 0 {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Float64() < rate
+}
+
+// StorageWrite returns an error when a storage write should fail.
+func (f *FaultInjector) StorageWrite() error {
+	if f.roll(f.storageWriteFailRate()) {
+		return fmt.Errorf("%w: storage write", errInjectedFault)
+	}
+	return nil
+}
+
+// SlowImageSave blocks for the configured image save delay.
+func (f *FaultInjector) SlowImageSave() {
+	if f != nil && f.ImageSaveDelay > 0 {
+		time.Sleep(f.ImageSaveDelay)
+	}
+}
+
+func (f *FaultInjector) storageWriteFailRate() float64 {
+	if f == nil {
+		return 0
+	}
+	return f.StorageWriteFailRate
+}
+
+// faultyNotifier drops a share of deliveries with an error so the retry
+// path gets exercised.
+type faultyNotifier struct {
+	Notifier
+	faults *FaultInjector
+}
+
+func (n faultyNotifier) Send(ctx context.Context, token string, notification Notification) error {
+	if n.faults.roll(n.faults.NotificationDropRate) {
+		return fmt.Errorf("%w: notification dropped", errInjectedFault)
+	}
+	return n.Notifier.Send(ctx, token, notification)
+}
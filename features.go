@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// featureHeader carries each flag evaluated for a request, such as
+// "sessions=off; reason=rule 1 (city Moscow)", for debugging rollouts.
+const featureHeader = "X-Feature"
+
+var validFeatureName = regexp.MustCompile(`^[a-z0-9-]{1,40}$`)
+
+// knownFeatures are the features handlers check, with their value when no
+// flag is configured for them.
+var knownFeatures = map[string]bool{
+	"chat":     true,
+	"sessions": true,
+}
+
+// FeatureFlag rolls a feature out to part of the audience. The first rule
+// matching a request decides; otherwise the tenant's enabledFeatures, then
+// Enabled apply.
+type FeatureFlag struct {
+	Name      string        `json:"name"`
+	Enabled   bool          `json:"enabled"`
+	Rules     []FeatureRule `json:"rules,omitempty"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// FeatureRule matches requests meeting all of its set conditions; a rule
+// without conditions matches everyone.
+type FeatureRule struct {
+	Enabled bool `json:"enabled"`
+	// Cities are areas the user's location or home gym must be in.
+	Cities  []Region `json:"cities,omitempty"`
+	Tenants []string `json:"tenants,omitempty"`
+	// MinAppVersion requires an X-App-Version of at least this version.
+	MinAppVersion  string     `json:"minAppVersion,omitempty"`
+	SignedUpAfter  *time.Time `json:"signedUpAfter,omitempty"`
+	SignedUpBefore *time.Time `json:"signedUpBefore,omitempty"`
+}
+
+func (rule FeatureRule) valid() bool {
+	for _, city := range rule.Cities {
+		if strings.TrimSpace(city.Name) == "" || city.RadiusKm <= 0 {
+			return false
+		}
+	}
+	for _, t := range rule.Tenants {
+		if !validTenantID.MatchString(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// FeatureContext is what flags are evaluated against for one request.
+type FeatureContext struct {
+	UserID     string
+	Tenant     string
+	AppVersion string
+	// HasLocation is false for anonymous requests and users without
+	// coordinates or home gym.
+	HasLocation         bool
+	Latitude, Longitude float64
+	SignedUpAt          time.Time
+}
+
+// featureContext builds the context of a request made by userID, which may
+// be empty. The caller must hold c.mu.
+func (c *Controller) featureContext(r *http.Request, userID string) FeatureContext {
+	fc := FeatureContext{
+		UserID:     userID,
+		Tenant:     tenantID(r),
+		AppVersion: r.Header.Get(appVersionHeader),
+	}
+	if u, ok := c.findUser(userID); ok {
+		fc.Tenant = userTenant(u)
+		fc.SignedUpAt = u.CreatedAt
+		fc.Latitude, fc.Longitude, fc.HasLocation = c.signupLocation(u.Latitude, u.Longitude, u.HomeGymID)
+	}
+	return fc
+}
+
+// match reports whether rule applies to fc, and the conditions that made it
+// apply for the debug header.
+func (rule FeatureRule) match(fc FeatureContext) (bool, string) {
+	var why []string
+	if len(rule.Cities) > 0 {
+		if !fc.HasLocation {
+			return false, ""
+		}
+		city := ""
+		for _, r := range rule.Cities {
+			if HaversineKm(fc.Latitude, fc.Longitude, r.Latitude, r.Longitude) <= r.RadiusKm {
+				city = r.Name
+				break
+			}
+		}
+		if city == "" {
+			return false, ""
+		}
+		why = append(why, "city "+city)
+	}
+	if len(rule.Tenants) > 0 {
+		found := false
+		for _, t := range rule.Tenants {
+			found = found || t == fc.Tenant
+		}
+		if !found {
+			return false, ""
+		}
+		why = append(why, "tenant "+fc.Tenant)
+	}
+	if rule.MinAppVersion != "" {
+		if fc.AppVersion == "" || compareVersions(fc.AppVersion, rule.MinAppVersion) < 0 {
+			return false, ""
+		}
+		why = append(why, "app "+fc.AppVersion)
+	}
+	if rule.SignedUpAfter != nil || rule.SignedUpBefore != nil {
+		if fc.SignedUpAt.IsZero() ||
+			rule.SignedUpAfter != nil && !fc.SignedUpAt.After(*rule.SignedUpAfter) ||
+			rule.SignedUpBefore != nil && !fc.SignedUpAt.Before(*rule.SignedUpBefore) {
+			return false, ""
+		}
+		why = append(why, "signup "+fc.SignedUpAt.UTC().Format(time.DateOnly))
+	}
+	return true, strings.Join(why, ", ")
+}
+
+func (c *Controller) findFeatureFlag(name string) (int, bool) {
+	for i, f := range c.storage.FeatureFlags {
+		if f.Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// evaluateFeature returns whether name is on for fc and why. The caller must
+// hold c.mu.
+func (c *Controller) evaluateFeature(name string, fc FeatureContext) (bool, string) {
+	i, ok := c.findFeatureFlag(name)
+	if ok {
+		for j, rule := range c.storage.FeatureFlags[i].Rules {
+			if matched, why := rule.match(fc); matched {
+				reason := fmt.Sprintf("rule %d", j+1)
+				if why != "" {
+					reason += " (" + why + ")"
+				}
+				return rule.Enabled, reason
+			}
+		}
+	}
+	if enabled, set := c.tenantConfig(fc.Tenant).EnabledFeatures[name]; set {
+		return enabled, "tenant " + fc.Tenant
+	}
+	if ok {
+		return c.storage.FeatureFlags[i].Enabled, "flag"
+	}
+	return knownFeatures[name], "default"
+}
+
+// featureEnabled evaluates name for a request by userID and adds the result
+// to the response headers. The caller must hold c.mu.
+func (c *Controller) featureEnabled(w http.ResponseWriter, r *http.Request, name, userID string) bool {
+	enabled, reason := c.evaluateFeature(name, c.featureContext(r, userID))
+	state := "off"
+	if enabled {
+		state = "on"
+	}
+	w.Header().Add(featureHeader, name+"="+state+"; reason="+reason)
+	return enabled
+}
+
+// requireFeature writes a 403 and returns false when name is off for userID.
+// The caller must hold c.mu.
+func (c *Controller) requireFeature(w http.ResponseWriter, r *http.Request, name, userID string) bool {
+	if c.featureEnabled(w, r, name, userID) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, "FEATURE_DISABLED", "Feature "+name+" is not available yet")
+	return false
+}
+
+// featureNames returns the known and configured features, sorted.
+func (c *Controller) featureNames() []string {
+	names := make([]string, 0, len(knownFeatures)+len(c.storage.FeatureFlags))
+	for name := range knownFeatures {
+		names = append(names, name)
+	}
+	for _, f := range c.storage.FeatureFlags {
+		if _, known := knownFeatures[f.Name]; !known {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetFeatures handles GET /api/features?userId=, the features enabled for
+// the user on this tenant and app version.
+func (c *Controller) GetFeatures(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("userId")
+	features := make(map[string]bool)
+	for _, name := range c.featureNames() {
+		features[name] = c.featureEnabled(w, r, name, userID)
+	}
+	writeJSON(w, http.StatusOK, features)
+}
+
+// AdminFeatures handles GET /admin/features.
+func (c *Controller) AdminFeatures(w http.ResponseWriter, r *http.Request) {
+	flags := make([]FeatureFlag, 0, len(knownFeatures)+len(c.storage.FeatureFlags))
+	for _, name := range c.featureNames() {
+		if i, ok := c.findFeatureFlag(name); ok {
+			flags = append(flags, c.storage.FeatureFlags[i])
+		} else {
+			flags = append(flags, FeatureFlag{Name: name, Enabled: knownFeatures[name]})
+		}
+	}
+	writeJSON(w, http.StatusOK, flags)
+}
+
+// pathFeatureName returns the {name} path value, writing a 400 if it isn't a
+// valid feature name.
+func pathFeatureName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.PathValue("name")
+	if !validFeatureName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "INVALID_FEATURE_NAME", "Invalid feature name")
+		return "", false
+	}
+	return name, true
+}
+
+// AdminPutFeature handles PUT /admin/features/{name}, creating or replacing
+// the flag.
+func (c *Controller) AdminPutFeature(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathFeatureName(w, r)
+	if !ok {
+		return
+	}
+
+	var flag FeatureFlag
+	if err := json.NewDecoder(r.Body).Decode(&flag); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	for _, rule := range flag.Rules {
+		if !rule.valid() {
+			writeError(w, http.StatusBadRequest, "INVALID_FEATURE_RULE", "Cities need a name and radius, tenants a valid ID")
+			return
+		}
+	}
+	flag.Name, flag.UpdatedAt = name, time.Now()
+
+	if i, found := c.findFeatureFlag(name); found {
+		c.storage.FeatureFlags[i] = flag
+	} else {
+		c.storage.FeatureFlags = append(c.storage.FeatureFlags, flag)
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, flag)
+}
+
+// AdminDeleteFeature handles DELETE /admin/features/{name}, returning the
+// flag to its default.
+func (c *Controller) AdminDeleteFeature(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathFeatureName(w, r)
+	if !ok {
+		return
+	}
+	i, found := c.findFeatureFlag(name)
+	if !found {
+		writeError(w, http.StatusNotFound, "FEATURE_NOT_FOUND", "Feature flag not found")
+		return
+	}
+	c.storage.FeatureFlags = append(c.storage.FeatureFlags[:i], c.storage.FeatureFlags[i+1:]...)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFeedCount = 10
+	maxFeedCount     = 50
+)
+
+// FeedFilter narrows the candidate deck to compatible training partners.
+type FeedFilter struct {
+	TrainType    string
+	Day          string
+	TimeFrom     int
+	TimeTo       int
+	AdaptiveOnly bool
+
+	// SameGym is SameGymPrefer to rank candidates from the requester's home
+	// gym first, or SameGymOnly to show nobody else.
+	SameGym string
+
+	// MaxDistanceKm excludes candidates farther away, or without a location.
+	// Zero disables the distance filter.
+	MaxDistanceKm float64
+
+	// BioLangs keeps candidates whose bio is in one of these languages.
+	BioLangs []string
+
+	// MinOverlapMinutes turns on overlap mode: only candidates with a slot
+	// overlapping one of the requester's by at least this long are shown,
+	// annotated with the overlapping windows. Zero disables it.
+	MinOverlapMinutes int
+}
+
+func ParseFeedFilter(query url.Values) (FeedFilter, error) {
+	filter := FeedFilter{
+		TrainType:    query.Get("trainType"),
+		Day:          query.Get("day"),
+		TimeFrom:     -1,
+		TimeTo:       -1,
+		AdaptiveOnly: query.Get("adaptive") == "true",
+	}
+
+	switch v := query.Get("sameGym"); v {
+	case "", SameGymPrefer, SameGymOnly:
+		filter.SameGym = v
+	default:
+		return filter, fmt.Errorf("invalid sameGym %q", v)
+	}
+
+	if v := query.Get("bioLang"); v != "" {
+		for _, lang := range strings.Split(v, ",") {
+			lang = strings.ToLower(strings.TrimSpace(lang))
+			if len(lang) != 2 {
+				return filter, fmt.Errorf("invalid bioLang %q", v)
+			}
+			filter.BioLangs = append(filter.BioLangs, lang)
+		}
+	}
+
+	if v := query.Get("minOverlap"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes <= 0 || minutes > 24*60 {
+			return filter, fmt.Errorf("invalid minOverlap %q", v)
+		}
+		filter.MinOverlapMinutes = minutes
+	}
+
+	if v := query.Get("maxDistanceKm"); v != "" {
+		km, err := strconv.ParseFloat(v, 64)
+		if err != nil || km <= 0 {
+			return filter, fmt.Errorf("invalid maxDistanceKm %q", v)
+		}
+		filter.MaxDistanceKm = km
+	}
+
+	if v := query.Get("timeFrom"); v != "" {
+		minutes, err := parseClock(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid timeFrom: %w", err)
+		}
+		filter.TimeFrom = minutes
+	}
+
+	if v := query.Get("timeTo"); v != "" {
+		minutes, err := parseClock(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid timeTo: %w", err)
+		}
+		filter.TimeTo = minutes
+	}
+
+	return filter, nil
+}
+
+// matchesSchedule reports whether one of candidate's slots is on the
+// filtered day and starts within the filtered time range. Profiles without
+// slots are matched on their day and time.
+func (f FeedFilter) matchesSchedule(candidate User) bool {
+	slots := candidate.Availability
+	if len(slots) == 0 {
+		slots = []Slot{{Weekday: candidate.Day, Start: candidate.Time}}
+	}
+
+	for _, s := range slots {
+		if f.Day != "" && !strings.EqualFold(s.Weekday, f.Day) {
+			continue
+		}
+		if f.TimeFrom >= 0 || f.TimeTo >= 0 {
+			minutes, err := parseClock(s.Start)
+			if err != nil {
+				continue
+			}
+			if f.TimeFrom >= 0 && minutes < f.TimeFrom {
+				continue
+			}
+			if f.TimeTo >= 0 && minutes > f.TimeTo {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Matches reports whether candidate passes the filter for requester.
+func (f FeedFilter) Matches(requester, candidate User) bool {
+	if f.TrainType != "" && !strings.EqualFold(candidate.TrainType, f.TrainType) {
+		return false
+	}
+
+	if !f.matchesSchedule(candidate) {
+		return false
+	}
+
+	if len(f.BioLangs) > 0 && !slices.Contains(f.BioLangs, candidate.BioLang) {
+		return false
+	}
+
+	if f.MinOverlapMinutes > 0 &&
+		len(overlapSlots(requester.Availability, candidate.Availability, f.MinOverlapMinutes)) == 0 {
+		return false
+	}
+
+	if f.AdaptiveOnly && !AccessibilityCompatible(requester, candidate) {
+		return false
+	}
+
+	if f.SameGym == SameGymOnly &&
+		(requester.HomeGymID == "" || candidate.HomeGymID != requester.HomeGymID) {
+		return false
+	}
+
+	if f.MaxDistanceKm > 0 {
+		distance, ok := DistanceKm(requester, candidate)
+		if !ok || distance > f.MaxDistanceKm {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FeedService builds candidate decks from storage.
+type FeedService struct {
+	storage *Storage
+
+	// DislikeCooldown is how long a disliked profile stays out of the deck.
+	// Zero keeps dislikes hidden forever.
+	DislikeCooldown time.Duration
+
+	Ranker Ranker
+}
+
+func NewFeedService(storage *Storage, dislikeCooldown, newUserBoost time.Duration) *FeedService {
+	ranker := DefaultCompatibilityRanker()
+	ranker.NewUserWindow = newUserBoost
+	ranker.Exposures = storage.swipesReceived
+	ranker.FastRejects = storage.fastRejectRates
+
+	return &FeedService{
+		storage:         storage,
+		DislikeCooldown: dislikeCooldown,
+		Ranker:          ranker,
+	}
+}
+
+// Candidates returns every user userID has not swiped yet that passes filter,
+// ordered by the ranker, with home-gym mates first when preferred and chronic
+// ghosts moved to the end of the deck.
+func (f *FeedService) Candidates(userID string, filter FeedFilter) []User {
+	requester, pool := f.unswiped(userID)
+
+	var eligible []User
+	for _, user := range pool {
+		if filter.Matches(requester, user) {
+			eligible = append(eligible, user)
+		}
+	}
+
+	if f.Ranker != nil {
+		eligible = f.Ranker.Rank(requester, eligible)
+	}
+
+	var sameGym, candidates, ghosts []User
+	for _, user := range eligible {
+		if f.storage.ResponseStats[user.FirebaseUID].IsGhost() {
+			ghosts = append(ghosts, user)
+			continue
+		}
+
+		if filter.SameGym == SameGymPrefer && requester.HomeGymID != "" &&
+			user.HomeGymID == requester.HomeGymID {
+			sameGym = append(sameGym, user)
+			continue
+		}
+
+		candidates = append(candidates, user)
+	}
+
+	return append(append(sameGym, candidates...), ghosts...)
+}
+
+// unswiped returns userID's profile and every other user they may still see
+// in the deck before filters apply: not swiped yet, or a dislike past its
+// cooldown, and not blocked either way.
+func (f *FeedService) unswiped(userID string) (User, []User) {
+	var requester User
+	for _, u := range f.storage.Users {
+		if u.FirebaseUID == userID {
+			requester = u
+			break
+		}
+	}
+
+	swiped := make(map[string]bool)
+	for _, swipe := range f.storage.Swipes {
+		if swipe.SwiperID != userID {
+			continue
+		}
+		if !swipe.IsLike && f.DislikeCooldown > 0 && time.Since(swipe.CreatedAt) >= f.DislikeCooldown {
+			continue
+		}
+		swiped[swipe.TargetID] = true
+	}
+
+	var pool []User
+	for _, user := range f.storage.Users {
+		if user.FirebaseUID == userID || swiped[user.FirebaseUID] {
+			continue
+		}
+		if f.storage.isBlocked(userID, user.FirebaseUID) {
+			continue
+		}
+		pool = append(pool, user)
+	}
+	return requester, pool
+}
+
+// swipesReceived counts the swipes on each user.
+func (s *Storage) swipesReceived() map[string]int {
+	counts := make(map[string]int)
+	for _, swipe := range s.Swipes {
+		counts[swipe.TargetID]++
+	}
+	return counts
+}
+
+// parseClock converts an "HH:MM" string into minutes since midnight.
+func parseClock(value string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, fmt.Errorf("invalid hours in %q", value)
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid minutes in %q", value)
+	}
+
+	return hours*60 + minutes, nil
+}
+
+// GetFeed returns a deck of up to count candidates in one response so swiping
+// clients can prefetch cards.
+func (c *Controller) GetFeed(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("uid")
+
+	count, err := queryInt(r.URL.Query().Get("count"), defaultFeedCount)
+	if err != nil || count <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_COUNT", "Invalid count")
+		return
+	}
+	if count > maxFeedCount {
+		count = maxFeedCount
+	}
+
+	filter, err := ParseFeedFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c.deck(r.Context(), userID, filter, r.URL.RawQuery, count))
+}
+
+// candidateCard prepares a candidate for viewerID's deck: private fields are
+// stripped and the responsiveness indicator and distance are filled in, as
+// are the overlapping windows in overlap mode.
+func (c *Controller) candidateCard(viewerID string, u User, filter FeedFilter) User {
+	card := u.Public()
+	card.Responsiveness = c.storage.ResponseStats[u.FirebaseUID].Indicator()
+
+	if viewer, ok := c.findUser(viewerID); ok {
+		if distance, ok := DistanceKm(viewer, u); ok {
+			rounded := math.Round(distance*10) / 10
+			card.DistanceKm = &rounded
+		}
+		if filter.MinOverlapMinutes > 0 {
+			card.Overlap = overlapSlots(viewer.Availability, u.Availability, filter.MinOverlapMinutes)
+		}
+	}
+
+	return card
+}
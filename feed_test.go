@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"testing"
+)
+
+func BenchmarkFeed(b *testing.B) {
+	filter, err := ParseFeedFilter(url.Values{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, users := range benchmarkSizes {
+		b.Run(fmt.Sprintf("users=%d", users), func(b *testing.B) {
+			c := newBenchmarkController(b, users)
+			i := 0
+			for b.Loop() {
+				c.deck(context.Background(), benchmarkUID(i%users), filter, "", defaultFeedCount)
+				i++
+			}
+		})
+	}
+}
+
+// BenchmarkFeedWithSwipes mixes deck reads with swipes the way clients do,
+// ten cards shown per swipe, with readers and writers contending for c.mu.
+func BenchmarkFeedWithSwipes(b *testing.B) {
+	filter, err := ParseFeedFilter(url.Values{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, users := range benchmarkSizes {
+		b.Run(fmt.Sprintf("users=%d", users), func(b *testing.B) {
+			c := newBenchmarkController(b, users)
+			b.RunParallel(func(pb *testing.PB) {
+				for n := 0; pb.Next(); n++ {
+					i := rand.IntN(users)
+					if n%10 != 9 {
+						c.mu.RLock()
+						c.deck(context.Background(), benchmarkUID(i), filter, "", defaultFeedCount)
+						c.mu.RUnlock()
+						continue
+					}
+
+					// Dislikes, so the daily like quota never kicks in.
+					req := SwipeRequest{
+						SwiperID: benchmarkUID(i),
+						TargetID: benchmarkUID((i + 1 + rand.IntN(users-1)) % users),
+					}
+					c.mu.Lock()
+					_, err := c.swipe(context.Background(), req)
+					c.mu.Unlock()
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const forgottenIDPrefix = "forgotten_"
+
+// ForgottenUser is what remains of an erased account: a pseudonym that its
+// swipes, matches and messages were moved to, so funnel and usage counts
+// don't change, and the fields those counts are grouped by.
+type ForgottenUser struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenantId,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ForgottenAt time.Time `json:"forgottenAt"`
+}
+
+// ErasureRecord audits an erasure. The subject is only kept as a hash, so
+// support can confirm a UID was erased without the record identifying
+// anyone by itself.
+type ErasureRecord struct {
+	ID          string         `json:"id"`
+	SubjectHash string         `json:"subjectHash"`
+	RequestedBy string         `json:"requestedBy"`
+	At          time.Time      `json:"at"`
+	Affected    map[string]int `json:"affected"`
+}
+
+// forgottenUsers returns the erased accounts as bare users for aggregates.
+func (s *Storage) forgottenUsers() []User {
+	users := make([]User, 0, len(s.ForgottenUsers))
+	for _, f := range s.ForgottenUsers {
+		users = append(users, User{FirebaseUID: f.ID, TenantID: f.TenantID, CreatedAt: f.CreatedAt})
+	}
+	return users
+}
+
+// forgetUser erases uid's personal data. Swipes, matches and messages move to
+// a pseudonym with message contents redacted, so partners' chats and
+// aggregate counts stay intact; everything else is deleted like an account
+// deletion. The caller must hold c.mu and save data.
+func (c *Controller) forgetUser(u User, requestedBy string) ErasureRecord {
+	uid := u.FirebaseUID
+	plan := c.userErasePlan(uid)
+	now := time.Now()
+
+	pseudonym := forgottenIDPrefix + newID()[:16]
+	rekey := func(id string) string {
+		if id == uid {
+			return pseudonym
+		}
+		return id
+	}
+	s := &c.storage
+
+	for i := range s.Swipes {
+		s.Swipes[i].SwiperID = rekey(s.Swipes[i].SwiperID)
+		s.Swipes[i].TargetID = rekey(s.Swipes[i].TargetID)
+	}
+
+	matchIDs := make(map[string]string)
+	for i, m := range s.Matches {
+		if !m.Has(uid) {
+			continue
+		}
+		oldID := m.ID()
+		m.User1ID, m.User2ID = rekey(m.User1ID), rekey(m.User2ID)
+		m.ContactSharedBy = nil
+		if m.User1ID > m.User2ID {
+			m.User1ID, m.User2ID = m.User2ID, m.User1ID
+		}
+		s.Matches[i] = m
+		matchIDs[oldID] = m.ID()
+	}
+
+	// Partners' inbox entries about these matches carry uid and the name.
+	notifications := s.Notifications[:0]
+	for _, n := range s.Notifications {
+		if _, ok := matchIDs[n.Data["matchId"]]; !ok {
+			notifications = append(notifications, n)
+		}
+	}
+	s.Notifications = notifications
+
+	redacted := make(map[string]bool)
+	for i, m := range s.Messages {
+		if newID, ok := matchIDs[m.MatchID]; ok {
+			s.Messages[i].MatchID = newID
+		}
+		if m.SenderID != uid {
+			continue
+		}
+		s.Messages[i].SenderID = pseudonym
+		s.Messages[i].Text = ""
+		s.Messages[i].Payload = nil
+		s.Messages[i].Preview = nil
+		s.Messages[i].Deleted = true
+		if m.DeletedAt == nil {
+			s.Messages[i].DeletedAt = &now
+		}
+		redacted[m.ID] = true
+	}
+
+	edits := s.MessageEdits[:0]
+	for _, e := range s.MessageEdits {
+		if !redacted[e.MessageID] {
+			edits = append(edits, e)
+		}
+	}
+	s.MessageEdits = edits
+
+	scheduled := s.ScheduledMessages[:0]
+	for _, m := range s.ScheduledMessages {
+		if m.SenderID == uid {
+			continue
+		}
+		if newID, ok := matchIDs[m.MatchID]; ok {
+			m.MatchID = newID
+		}
+		scheduled = append(scheduled, m)
+	}
+	s.ScheduledMessages = scheduled
+
+	// Reports stay for moderation, without pointing at the erased account.
+	for i := range s.Reports {
+		s.Reports[i].ReporterID = rekey(s.Reports[i].ReporterID)
+		s.Reports[i].ReportedID = rekey(s.Reports[i].ReportedID)
+	}
+
+	requests := s.MessageRequests[:0]
+	for _, req := range s.MessageRequests {
+		if req.FromID != uid && req.ToID != uid {
+			requests = append(requests, req)
+		}
+	}
+	s.MessageRequests = requests
+
+	blocks := s.Blocks[:0]
+	for _, b := range s.Blocks {
+		if b.BlockerID != uid && b.BlockedID != uid {
+			blocks = append(blocks, b)
+		}
+	}
+	s.Blocks = blocks
+
+	plans := s.SharedPlans[:0]
+	for _, p := range s.SharedPlans {
+		if p.UserID != uid && p.PartnerID != uid {
+			plans = append(plans, p)
+		}
+	}
+	s.SharedPlans = plans
+
+	checkIns := s.CheckIns[:0]
+	for _, ci := range s.CheckIns {
+		if ci.UserID != uid {
+			checkIns = append(checkIns, ci)
+		}
+	}
+	s.CheckIns = checkIns
+
+	alerts := s.NearbyAlerts[:0]
+	for _, a := range s.NearbyAlerts {
+		if a.ToID != uid && a.AboutID != uid {
+			alerts = append(alerts, a)
+		}
+	}
+	s.NearbyAlerts = alerts
+
+	delete(s.QuickReplies, uid)
+	delete(s.SwipeUndos, uid)
+
+	s.ForgottenUsers = append(s.ForgottenUsers, ForgottenUser{
+		ID:          pseudonym,
+		TenantID:    u.TenantID,
+		CreatedAt:   u.CreatedAt,
+		ForgottenAt: now,
+	})
+	c.deleteUser(uid)
+
+	record := ErasureRecord{
+		ID:          newID(),
+		SubjectHash: hashToken(uid),
+		RequestedBy: requestedBy,
+		At:          now,
+		Affected:    make(map[string]int, len(plan.Affected)),
+	}
+	for kind, ids := range plan.Affected {
+		record.Affected[kind] = len(ids)
+	}
+	s.Erasures = append(s.Erasures, record)
+	return record
+}
+
+// forgetAccount handles POST /api/users/{uid}/forget.
+func (c *Controller) forgetAccount(w http.ResponseWriter, r *http.Request, u User) {
+	requestedBy := "user"
+	if token, ok := UserTokenFromContext(r.Context()); ok {
+		requestedBy = "token:" + token.ID
+	}
+	record := c.forgetUser(u, requestedBy)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record)
+}
+
+// AdminErasures handles GET /admin/erasures?uid=, listing the audit records
+// of erased accounts, optionally for one UID.
+func (c *Controller) AdminErasures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	uid := r.URL.Query().Get("uid")
+	records := []ErasureRecord{}
+	for _, e := range c.storage.Erasures {
+		if uid == "" || e.SubjectHash == hashToken(uid) {
+			records = append(records, e)
+		}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// webClient holds the built web client. Replace the contents of web/ with the
+// client build output before compiling.
+//
+//go:embed all:web
+var webClient embed.FS
+
+// WebClientHandler serves the embedded client. Paths that do not match a file
+// fall back to index.html so client-side routes work on reload; API, admin
+// and image paths are never rewritten.
+func WebClientHandler() http.Handler {
+	root, err := fs.Sub(webClient, "web")
+	if err != nil {
+		panic(err)
+	}
+	files := http.FileServer(http.FS(root))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+			return
+		}
+
+		for _, prefix := range []string{"/api/", "/admin/", "/images/"} {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if info, err := fs.Stat(root, name); name == "" || err != nil || info.IsDir() {
+			// index.html must be revalidated so clients pick up new builds.
+			w.Header().Set("Cache-Control", "no-cache")
+			http.ServeFileFS(w, r, root, "index.html")
+			return
+		}
+
+		if strings.HasPrefix(name, "assets/") {
+			// Bundlers put content-hashed files under assets/.
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Funnel stages in the order users pass through them.
+var funnelStages = []string{
+	"profileCreated",
+	"firstSwipe",
+	"firstLike",
+	"firstMatch",
+	"firstMessage",
+	"firstSession",
+}
+
+type FunnelCohort struct {
+	Cohort string         `json:"cohort"`
+	Stages map[string]int `json:"stages"`
+}
+
+func cohortKey(createdAt time.Time, period string) string {
+	if createdAt.IsZero() {
+		return "unknown"
+	}
+	if period == "month" {
+		return createdAt.UTC().Format("2006-01")
+	}
+	year, week := createdAt.UTC().ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// funnel counts, per signup cohort, how many users reached each stage.
+func (c *Controller) funnel(period string) []FunnelCohort {
+	swiped := make(map[string]bool)
+	liked := make(map[string]bool)
+	for _, s := range c.storage.Swipes {
+		swiped[s.SwiperID] = true
+		if s.IsLike {
+			liked[s.SwiperID] = true
+		}
+	}
+
+	matched := make(map[string]bool)
+	for _, m := range c.storage.Matches {
+		matched[m.User1ID] = true
+		matched[m.User2ID] = true
+	}
+
+	messaged := make(map[string]bool)
+	for _, m := range c.storage.Messages {
+		messaged[m.SenderID] = true
+	}
+
+	trained := make(map[string]bool)
+	for _, s := range c.storage.Sessions {
+		if s.Status == SessionAccepted {
+			trained[s.ProposedBy] = true
+			trained[s.ProposedTo] = true
+		}
+	}
+
+	cohorts := make(map[string]*FunnelCohort)
+	for _, u := range append(slices.Clone(c.storage.Users), c.storage.forgottenUsers()...) {
+		key := cohortKey(u.CreatedAt, period)
+		cohort, ok := cohorts[key]
+		if !ok {
+			cohort = &FunnelCohort{Cohort: key, Stages: make(map[string]int)}
+			for _, stage := range funnelStages {
+				cohort.Stages[stage] = 0
+			}
+			cohorts[key] = cohort
+		}
+
+		reached := map[string]bool{
+			"profileCreated": true,
+			"firstSwipe":     swiped[u.FirebaseUID],
+			"firstLike":      liked[u.FirebaseUID],
+			"firstMatch":     matched[u.FirebaseUID],
+			"firstMessage":   messaged[u.FirebaseUID],
+			"firstSession":   trained[u.FirebaseUID],
+		}
+		for stage, ok := range reached {
+			if ok {
+				cohort.Stages[stage]++
+			}
+		}
+	}
+
+	result := make([]FunnelCohort, 0, len(cohorts))
+	for _, cohort := range cohorts {
+		result = append(result, *cohort)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Cohort < result[j].Cohort })
+	return result
+}
+
+// AdminFunnel handles GET /admin/analytics/funnel?period=week|month.
+func (c *Controller) AdminFunnel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "week"
+	}
+	if period != "week" && period != "month" {
+		writeError(w, http.StatusBadRequest, "INVALID_PERIOD", "Invalid period")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"period":  period,
+		"stages":  funnelStages,
+		"cohorts": c.funnel(period),
+	})
+}
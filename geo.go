@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const earthRadiusKm = 6371.0
+
+// HaversineKm returns the great-circle distance between two points.
+func HaversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func (u User) HasLocation() bool {
+	return u.Latitude != nil && u.Longitude != nil
+}
+
+// DistanceKm returns the distance between two users, or false when either of
+// them has no location.
+func DistanceKm(a, b User) (float64, bool) {
+	if !a.HasLocation() || !b.HasLocation() {
+		return 0, false
+	}
+	return HaversineKm(*a.Latitude, *a.Longitude, *b.Latitude, *b.Longitude), true
+}
+
+// formCoordinates reads latitude and longitude from a profile form. Both
+// missing or empty is not an error and yields nil coordinates; profile
+// updates then keep the stored ones unless the fields were sent empty.
+func formCoordinates(r *http.Request) (*float64, *float64, error) {
+	latStr, lonStr := r.FormValue("latitude"), r.FormValue("longitude")
+	if latStr == "" && lonStr == "" {
+		return nil, nil, nil
+	}
+
+	lat, err := strconv.ParseFloat(latStr, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, nil, errors.New("invalid latitude")
+	}
+
+	lon, err := strconv.ParseFloat(lonStr, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, nil, errors.New("invalid longitude")
+	}
+
+	return &lat, &lon, nil
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	GoalOpen   = "open"
+	GoalClosed = "closed"
+	// GoalRemoved is a post taken down by a gym moderator.
+	GoalRemoved = "removed"
+
+	GoalResponsePending  = "pending"
+	GoalResponseAccepted = "accepted"
+	GoalResponseDeclined = "declined"
+
+	maxGoalTitleLength = 120
+)
+
+// GoalPost is a one-off goal a user is looking for a partner for, such as a
+// 10k run on a given date. Goal posts live outside the swipe deck: other
+// users respond to them directly, and accepting a response creates a match
+// scoped to the goal.
+type GoalPost struct {
+	ID          string         `json:"id"`
+	UserID      string         `json:"userId"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Date        string         `json:"date,omitempty"`
+	TrainType   string         `json:"trainType,omitempty"`
+	GymID       string         `json:"gymId,omitempty"`
+	Status      string         `json:"status"`
+	Responses   []GoalResponse `json:"responses,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	ClosedAt    *time.Time     `json:"closedAt,omitempty"`
+	// Moderation is set when a gym moderator removed the post.
+	Moderation *ModerationDecision `json:"moderation,omitempty"`
+}
+
+type GoalResponse struct {
+	UserID      string     `json:"userId"`
+	Message     string     `json:"message,omitempty"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	RespondedAt *time.Time `json:"respondedAt,omitempty"`
+}
+
+type GoalPostRequest struct {
+	UserID      string `json:"userId"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Date        string `json:"date"`
+	TrainType   string `json:"trainType"`
+	GymID       string `json:"gymId"`
+}
+
+type GoalResponseRequest struct {
+	UserID  string `json:"userId"`
+	Message string `json:"message"`
+}
+
+// view returns the goal as seen by viewerID: only its author sees every
+// response, others see just their own.
+func (g GoalPost) view(viewerID string) GoalPost {
+	if g.UserID == viewerID {
+		return g
+	}
+
+	var own []GoalResponse
+	for _, resp := range g.Responses {
+		if resp.UserID == viewerID {
+			own = append(own, resp)
+		}
+	}
+	g.Responses = own
+	return g
+}
+
+func (c *Controller) findGoal(id string) (int, bool) {
+	for i, g := range c.storage.GoalPosts {
+		if g.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Goals handles GET and POST on /api/goals.
+func (c *Controller) Goals(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		userID := r.URL.Query().Get("userId")
+		if userID == "" {
+			writeError(w, http.StatusBadRequest, "USER_ID_REQUIRED", "User ID is required")
+			return
+		}
+		trainType := r.URL.Query().Get("trainType")
+		mine := r.URL.Query().Get("mine") == "true"
+
+		goals := []GoalPost{}
+		for _, g := range c.storage.GoalPosts {
+			if mine {
+				if g.UserID == userID {
+					goals = append(goals, g)
+				}
+				continue
+			}
+
+			if g.UserID == userID || g.Status != GoalOpen || c.storage.isBlocked(userID, g.UserID) {
+				continue
+			}
+			if trainType != "" && g.TrainType != trainType {
+				continue
+			}
+			goals = append(goals, g.view(userID))
+		}
+		writeJSON(w, http.StatusOK, goals)
+
+	case http.MethodPost:
+		var req GoalPostRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+
+		req.Title = strings.TrimSpace(req.Title)
+		if req.UserID == "" || req.Title == "" || len([]rune(req.Title)) > maxGoalTitleLength {
+			writeError(w, http.StatusBadRequest, "INVALID_GOAL", "User ID and a title of up to 120 characters are required")
+			return
+		}
+		if _, ok := c.findUser(req.UserID); !ok {
+			writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+			return
+		}
+		if req.GymID != "" {
+			if _, ok := c.findGym(req.GymID); !ok {
+				writeError(w, http.StatusNotFound, "GYM_NOT_FOUND", "Gym not found")
+				return
+			}
+		}
+
+		goal := GoalPost{
+			ID:          newID(),
+			UserID:      req.UserID,
+			Title:       req.Title,
+			Description: strings.TrimSpace(req.Description),
+			Date:        req.Date,
+			TrainType:   req.TrainType,
+			GymID:       req.GymID,
+			Status:      GoalOpen,
+			CreatedAt:   time.Now(),
+		}
+		c.storage.GoalPosts = append(c.storage.GoalPosts, goal)
+
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, goal)
+
+	}
+}
+
+// Goal handles GET and DELETE (closing the goal) on /api/goals/{id},
+// POST /api/goals/{id}/responses and POST
+// /api/goals/{id}/responses/{responderId}/accept|decline. The acting user is
+// given by ?userId= or the userId of the request body.
+func (c *Controller) Goal(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.findGoal(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "GOAL_NOT_FOUND", "Goal not found")
+		return
+	}
+	goal := &c.storage.GoalPosts[i]
+	userID := r.URL.Query().Get("userId")
+	if goal.Status == GoalRemoved && userID != goal.UserID {
+		writeError(w, http.StatusNotFound, "GOAL_NOT_FOUND", "Goal not found")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet:
+		if userID == "" {
+			writeError(w, http.StatusBadRequest, "USER_ID_REQUIRED", "User ID is required")
+			return
+		}
+		writeJSON(w, http.StatusOK, goal.view(userID))
+		return
+
+	case r.Method == http.MethodDelete:
+		if userID != goal.UserID {
+			writeError(w, http.StatusForbidden, "GOAL_CLOSE_FORBIDDEN", "Only the author can close a goal")
+			return
+		}
+		if goal.Status == GoalOpen {
+			now := time.Now()
+			goal.Status = GoalClosed
+			goal.ClosedAt = &now
+		}
+
+	case r.PathValue("responderId") == "":
+		var req GoalResponseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+		if status, code, msg := c.checkGoalResponse(*goal, req.UserID); status != 0 {
+			writeError(w, status, code, msg)
+			return
+		}
+		userID = req.UserID
+
+		goal.Responses = append(goal.Responses, GoalResponse{
+			UserID:    req.UserID,
+			Message:   strings.TrimSpace(req.Message),
+			Status:    GoalResponsePending,
+			CreatedAt: time.Now(),
+		})
+
+		responder, _ := c.findUser(req.UserID)
+		c.notifyUser(goal.UserID, Notification{
+			Title: "Отклик на цель",
+			Body:  responder.Name + " хочет присоединиться: " + goal.Title,
+			Data: map[string]string{
+				"type":   "goal_response",
+				"goalId": goal.ID,
+			},
+		})
+
+	case r.PathValue("action") == "accept" || r.PathValue("action") == "decline":
+		if userID != goal.UserID {
+			writeError(w, http.StatusForbidden, "GOAL_ANSWER_FORBIDDEN", "Only the author can answer responses")
+			return
+		}
+		if !c.answerGoalResponse(w, goal, r.PathValue("responderId"), r.PathValue("action") == "accept") {
+			return
+		}
+
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+		return
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, goal.view(userID))
+}
+
+// checkGoalResponse returns the error status, code and message for
+// responderID responding to goal, or zero when they may respond.
+func (c *Controller) checkGoalResponse(goal GoalPost, responderID string) (int, string, string) {
+	if _, ok := c.findUser(responderID); !ok {
+		return http.StatusNotFound, "USER_NOT_FOUND", "User not found"
+	}
+	if responderID == goal.UserID {
+		return http.StatusBadRequest, "GOAL_SELF_RESPONSE", "Cannot respond to your own goal"
+	}
+	if goal.Status != GoalOpen {
+		return http.StatusConflict, "GOAL_CLOSED", "Goal is closed"
+	}
+	if c.storage.isBlocked(responderID, goal.UserID) {
+		return http.StatusForbidden, "USER_BLOCKED", "User is blocked"
+	}
+	for _, resp := range goal.Responses {
+		if resp.UserID == responderID {
+			return http.StatusConflict, "GOAL_ALREADY_RESPONDED", "Already responded"
+		}
+	}
+	return 0, "", ""
+}
+
+// answerGoalResponse accepts or declines responderID's response. Accepting
+// closes the goal and matches the pair, reusing an existing match. It writes
+// the error response and returns false on failure.
+func (c *Controller) answerGoalResponse(w http.ResponseWriter, goal *GoalPost, responderID string, accept bool) bool {
+	j := -1
+	for k, resp := range goal.Responses {
+		if resp.UserID == responderID {
+			j = k
+			break
+		}
+	}
+	if j == -1 {
+		writeError(w, http.StatusNotFound, "GOAL_RESPONSE_NOT_FOUND", "Response not found")
+		return false
+	}
+	if goal.Responses[j].Status != GoalResponsePending {
+		writeError(w, http.StatusConflict, "GOAL_RESPONSE_ANSWERED", "Response already answered")
+		return false
+	}
+
+	now := time.Now()
+	resp := &goal.Responses[j]
+	resp.RespondedAt = &now
+	if !accept {
+		resp.Status = GoalResponseDeclined
+		return true
+	}
+
+	if goal.Status != GoalOpen {
+		writeError(w, http.StatusConflict, "GOAL_CLOSED", "Goal is closed")
+		return false
+	}
+	if c.storage.isBlocked(goal.UserID, responderID) {
+		writeError(w, http.StatusForbidden, "USER_BLOCKED", "User is blocked")
+		return false
+	}
+
+	resp.Status = GoalResponseAccepted
+	goal.Status = GoalClosed
+	goal.ClosedAt = &now
+
+	id1, id2 := goal.UserID, responderID
+	if id1 > id2 {
+		id1, id2 = id2, id1
+	}
+	match := Match{User1ID: id1, User2ID: id2}
+	if _, exists := c.findMatch(match.ID()); !exists {
+		match.GoalID = goal.ID
+		match.CreatedAt = now
+		c.storage.Matches = append(c.storage.Matches, match)
+		c.metrics.MatchesCreated.Inc()
+	}
+
+	author, _ := c.findUser(goal.UserID)
+	c.notifyUser(responderID, Notification{
+		Title: "Отклик принят",
+		Body:  author.Name + " ждёт тебя: " + goal.Title,
+		Data: map[string]string{
+			"type":    "goal_accepted",
+			"goalId":  goal.ID,
+			"matchId": match.ID(),
+		},
+	})
+	return true
+}
+
+// forgetGoals removes uid's goal posts and their responses to other goals.
+// The caller must hold c.mu and save data.
+func (c *Controller) forgetGoals(uid string) {
+	goals := c.storage.GoalPosts[:0]
+	for _, g := range c.storage.GoalPosts {
+		if g.UserID == uid {
+			continue
+		}
+
+		responses := g.Responses[:0]
+		for _, resp := range g.Responses {
+			if resp.UserID != uid {
+				responses = append(responses, resp)
+			}
+		}
+		g.Responses = responses
+		goals = append(goals, g)
+	}
+	c.storage.GoalPosts = goals
+}
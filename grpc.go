@@ -0,0 +1,498 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The gRPC API serves the operations of proto/gymbro/v1/gymbro.proto over
+// HTTP/2 on GRPC_ADDR. It uses the same service functions as the REST
+// handlers, and the codec in protobuf.go instead of generated code.
+
+const grpcServicePrefix = "/gymbro.v1.GymBro/"
+
+// gRPC status codes.
+const (
+	grpcOK                 = 0
+	grpcCanceled           = 1
+	grpcUnknown            = 2
+	grpcInvalidArgument    = 3
+	grpcNotFound           = 5
+	grpcPermissionDenied   = 7
+	grpcResourceExhausted  = 8
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+	grpcUnauthenticated    = 16
+)
+
+// grpcCodes maps the HTTP statuses of service errors to gRPC codes.
+var grpcCodes = map[int]int{
+	http.StatusBadRequest:          grpcInvalidArgument,
+	http.StatusUnauthorized:        grpcUnauthenticated,
+	http.StatusForbidden:           grpcPermissionDenied,
+	http.StatusNotFound:            grpcNotFound,
+	http.StatusConflict:            grpcFailedPrecondition,
+	http.StatusGone:                grpcFailedPrecondition,
+	http.StatusTooManyRequests:     grpcResourceExhausted,
+	http.StatusServiceUnavailable:  grpcUnavailable,
+	http.StatusInternalServerError: grpcInternal,
+}
+
+// grpcMethod handles one RPC. Unary methods call send once; streaming ones
+// call it for every message.
+type grpcMethod struct {
+	// scope is the user token scope the method needs, as in apiScopes.
+	scope string
+	// feature, when set, is the feature flag the method is part of, as
+	// handlers check with requireFeature.
+	feature string
+	handle  func(c *Controller, ctx context.Context, req []byte, send func(*protoBuffer) error) error
+}
+
+var grpcMethods = map[string]grpcMethod{
+	"ListProfiles": {handle: (*Controller).grpcListProfiles},
+	"GetProfile":   {handle: (*Controller).grpcGetProfile},
+	"GetFeed":      {handle: (*Controller).grpcGetFeed},
+	"Swipe":        {scope: ScopeSwipe, handle: (*Controller).grpcSwipe},
+	"ListMatches":  {scope: ScopeChat, handle: (*Controller).grpcListMatches},
+	"WatchMatches": {scope: ScopeChat, handle: (*Controller).grpcWatchMatches},
+}
+
+// ServeGRPC handles gRPC calls. It takes c.mu itself, as WatchMatches runs
+// for as long as the client stays connected. It is served behind the same
+// middleware as REST routes; their plain HTTP errors reach gRPC clients as
+// the status codes the gRPC spec maps HTTP statuses to.
+func (c *Controller) ServeGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		writeError(w, http.StatusUnsupportedMediaType, "UNSUPPORTED_FORMAT", "Expected a gRPC request")
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+
+	method, ok := grpcMethods[strings.TrimPrefix(r.URL.Path, grpcServicePrefix)]
+	if !ok {
+		writeGRPCStatus(w, grpcUnimplemented, "Unknown method "+r.URL.Path, "")
+		return
+	}
+
+	userID, err := c.checkGRPCToken(r, method.scope)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	if method.feature != "" {
+		c.mu.RLock()
+		enabled := c.featureEnabled(w, r, method.feature, userID)
+		c.mu.RUnlock()
+		if !enabled {
+			writeGRPCStatus(w, grpcPermissionDenied, "Feature "+method.feature+" is not available yet", "FEATURE_DISABLED")
+			return
+		}
+	}
+
+	req, err := readGRPCMessage(r.Body, c.maxBodyBytes)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeGRPCStatus(w, grpcResourceExhausted, "Request message too large", "BODY_TOO_LARGE")
+			return
+		}
+		writeGRPCStatus(w, grpcInvalidArgument, err.Error(), "INVALID_REQUEST_BODY")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	send := func(m *protoBuffer) error {
+		frame := make([]byte, 5, 5+len(m.b))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(m.b)))
+		if _, err := w.Write(append(frame, m.b...)); err != nil {
+			return err
+		}
+		// Writers that cannot flush just deliver the frames at the end.
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	if err := method.handle(c, r.Context(), req, send); err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	writeGRPCStatus(w, grpcOK, "", "")
+}
+
+// readGRPCMessage reads the single length-prefixed message of a request. The
+// declared length is checked against limit before anything is allocated.
+func readGRPCMessage(body io.Reader, limit int64) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(body, header[:]); err != nil {
+		return nil, fmt.Errorf("reading message header: %w", err)
+	}
+	if header[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if int64(length) > limit {
+		return nil, &http.MaxBytesError{Limit: limit}
+	}
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	return msg, nil
+}
+
+// checkGRPCToken checks the user token of calls that carry one, like
+// requireScope does for REST routes, and returns the token's user.
+func (c *Controller) checkGRPCToken(r *http.Request, scope string) (string, error) {
+	secret, ok := bearerUserToken(r)
+	if !ok {
+		return "", nil
+	}
+
+	c.mu.RLock()
+	token, found := c.findUserToken(secret)
+	c.mu.RUnlock()
+	if !found {
+		return "", serviceError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+	}
+	if scope != "" && !token.Allows(scope) {
+		return "", serviceError(http.StatusForbidden, "MISSING_SCOPE", "Token lacks scope "+scope)
+	}
+	return token.UserID, nil
+}
+
+func writeGRPCError(w http.ResponseWriter, err error) {
+	var e *ServiceError
+	switch {
+	case errors.As(err, &e):
+		code, ok := grpcCodes[e.Status]
+		if !ok {
+			code = grpcUnknown
+		}
+		writeGRPCStatus(w, code, e.Message, e.Code)
+	case errors.Is(err, context.Canceled):
+		writeGRPCStatus(w, grpcCanceled, "Canceled", "")
+	default:
+		writeGRPCStatus(w, grpcInternal, "Internal server error", "INTERNAL_ERROR")
+	}
+}
+
+// writeGRPCStatus ends the call with the given status in the trailers. The
+// REST error code, when there is one, travels as the reason of a
+// google.rpc.ErrorInfo in grpc-status-details-bin.
+func writeGRPCStatus(w http.ResponseWriter, code int, message, reason string) {
+	h := w.Header()
+	h.Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		h.Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(message))
+	}
+	if reason != "" {
+		var status protoBuffer
+		status.int(1, int64(code))
+		status.string(2, message)
+		status.message(3, func(detail *protoBuffer) {
+			detail.string(1, "type.googleapis.com/google.rpc.ErrorInfo")
+			detail.message(2, func(info *protoBuffer) {
+				info.string(1, reason)
+				info.string(2, "gymbro")
+			})
+		})
+		h.Set(http.TrailerPrefix+"Grpc-Status-Details-Bin", base64.RawStdEncoding.EncodeToString(status.b))
+	}
+}
+
+// grpcPercentEncode escapes a grpc-message value as the gRPC spec requires.
+func grpcPercentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if ch := s[i]; ch < 0x20 || ch > 0x7e || ch == '%' {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		} else {
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+func (c *Controller) grpcListProfiles(ctx context.Context, req []byte, send func(*protoBuffer) error) error {
+	var limit, offset int64
+	var filter UsersFilter
+	err := decodeProto(req, func(field int, v protoValue) {
+		switch field {
+		case 1:
+			limit = v.Int()
+		case 2:
+			offset = v.Int()
+		case 3:
+			filter.TrainType = v.String()
+		case 4:
+			filter.Day = v.String()
+		case 5:
+			filter.Time = v.String()
+		case 6:
+			filter.BioLang = v.String()
+		}
+	})
+	if err != nil {
+		return serviceError(http.StatusBadRequest, "INVALID_REQUEST_BODY", err.Error())
+	}
+
+	if limit == 0 {
+		limit = defaultUsersLimit
+	}
+	if limit < 0 {
+		return serviceError(http.StatusBadRequest, "INVALID_LIMIT", "Invalid limit")
+	}
+	if offset < 0 {
+		return serviceError(http.StatusBadRequest, "INVALID_OFFSET", "Invalid offset")
+	}
+
+	c.mu.RLock()
+	page := c.usersPage(filter, int(min(limit, maxUsersLimit)), int(offset))
+	c.mu.RUnlock()
+
+	var resp protoBuffer
+	for _, u := range page.Users {
+		resp.message(1, profileProto(u))
+	}
+	resp.int(2, int64(page.Total))
+	resp.int(3, int64(page.Limit))
+	resp.int(4, int64(page.Offset))
+	return send(&resp)
+}
+
+func (c *Controller) grpcGetProfile(ctx context.Context, req []byte, send func(*protoBuffer) error) error {
+	uid, err := decodeUIDRequest(req)
+	if err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	u, err := c.publicProfile(uid)
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	var resp protoBuffer
+	profileProto(u)(&resp)
+	return send(&resp)
+}
+
+func (c *Controller) grpcGetFeed(ctx context.Context, req []byte, send func(*protoBuffer) error) error {
+	var uid string
+	var count int64
+	var bioLangs []string
+	// The filter goes through the REST query parser so both APIs validate
+	// it alike.
+	query := url.Values{}
+	err := decodeProto(req, func(field int, v protoValue) {
+		set := func(name string) {
+			if s := v.String(); s != "" {
+				query.Set(name, s)
+			}
+		}
+		switch field {
+		case 1:
+			uid = v.String()
+		case 2:
+			count = v.Int()
+		case 3:
+			set("trainType")
+		case 4:
+			set("day")
+		case 5:
+			set("timeFrom")
+		case 6:
+			set("timeTo")
+		case 7:
+			if v.Bool() {
+				query.Set("adaptive", "true")
+			}
+		case 8:
+			set("sameGym")
+		case 9:
+			if km := v.Double(); km != 0 {
+				query.Set("maxDistanceKm", strconv.FormatFloat(km, 'f', -1, 64))
+			}
+		case 10:
+			bioLangs = append(bioLangs, v.String())
+		case 11:
+			if minutes := v.Int(); minutes != 0 {
+				query.Set("minOverlap", strconv.FormatInt(minutes, 10))
+			}
+		}
+	})
+	if err != nil {
+		return serviceError(http.StatusBadRequest, "INVALID_REQUEST_BODY", err.Error())
+	}
+	if len(bioLangs) > 0 {
+		query.Set("bioLang", strings.Join(bioLangs, ","))
+	}
+
+	if count == 0 {
+		count = defaultFeedCount
+	}
+	if count < 0 {
+		return serviceError(http.StatusBadRequest, "INVALID_COUNT", "Invalid count")
+	}
+	filter, err := ParseFeedFilter(query)
+	if err != nil {
+		return serviceError(http.StatusBadRequest, "INVALID_FILTER", err.Error())
+	}
+
+	c.mu.RLock()
+	cards := c.deck(ctx, uid, filter, query.Encode(), int(min(count, maxFeedCount)))
+	c.mu.RUnlock()
+
+	var resp protoBuffer
+	for _, u := range cards {
+		resp.message(1, profileProto(u))
+	}
+	return send(&resp)
+}
+
+func (c *Controller) grpcSwipe(ctx context.Context, req []byte, send func(*protoBuffer) error) error {
+	var swipe SwipeRequest
+	err := decodeProto(req, func(field int, v protoValue) {
+		switch field {
+		case 1:
+			swipe.SwiperID = v.String()
+		case 2:
+			swipe.TargetID = v.String()
+		case 3:
+			swipe.IsLike = v.Bool()
+		case 4:
+			swipe.DwellMs = v.Int()
+		}
+	})
+	if err != nil {
+		return serviceError(http.StatusBadRequest, "INVALID_REQUEST_BODY", err.Error())
+	}
+
+	c.mu.Lock()
+	result, err := c.swipe(ctx, swipe)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var resp protoBuffer
+	resp.bool(1, result.IsMatch)
+	if m := result.Match; m != nil {
+		resp.message(2, func(p *protoBuffer) {
+			p.string(1, m.User1ID)
+			p.string(2, m.User2ID)
+			p.timestamp(3, m.CreatedAt)
+			p.string(4, m.GoalID)
+		})
+	}
+	return send(&resp)
+}
+
+func (c *Controller) grpcListMatches(ctx context.Context, req []byte, send func(*protoBuffer) error) error {
+	uid, err := decodeUIDRequest(req)
+	if err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	views := c.matchViews(uid)
+	c.mu.RUnlock()
+
+	var resp protoBuffer
+	for _, v := range views {
+		resp.message(1, matchViewProto(v))
+	}
+	return send(&resp)
+}
+
+func (c *Controller) grpcWatchMatches(ctx context.Context, req []byte, send func(*protoBuffer) error) error {
+	uid, err := decodeUIDRequest(req)
+	if err != nil {
+		return err
+	}
+
+	return c.watchMatches(ctx, uid, func(v MatchView) error {
+		var msg protoBuffer
+		matchViewProto(v)(&msg)
+		return send(&msg)
+	})
+}
+
+// decodeUIDRequest decodes the requests whose only field is uid = 1.
+func decodeUIDRequest(req []byte) (string, error) {
+	var uid string
+	err := decodeProto(req, func(field int, v protoValue) {
+		if field == 1 {
+			uid = v.String()
+		}
+	})
+	if err != nil {
+		return "", serviceError(http.StatusBadRequest, "INVALID_REQUEST_BODY", err.Error())
+	}
+	return uid, nil
+}
+
+// profileProto encodes u as a gymbro.v1.Profile.
+func profileProto(u User) func(*protoBuffer) {
+	return func(p *protoBuffer) {
+		p.string(1, u.FirebaseUID)
+		p.string(2, u.Name)
+		p.string(3, u.ImageURL)
+		p.strings(4, u.Photos)
+		p.string(5, u.Time)
+		p.string(6, u.Day)
+		p.string(7, u.TextInfo)
+		p.string(8, u.TrainType)
+		p.string(9, u.Contact)
+		p.strings(10, u.AccessibilityNeeds)
+		p.bool(11, u.AdaptiveTraining)
+		p.string(12, u.HomeGymID)
+		p.timestamp(13, u.CreatedAt)
+		p.string(14, u.Responsiveness)
+		p.optionalDouble(15, u.DistanceKm)
+		p.string(16, u.BioLang)
+		for _, s := range u.Availability {
+			p.message(17, slotProto(s))
+		}
+		for _, s := range u.Overlap {
+			p.message(18, slotProto(s))
+		}
+	}
+}
+
+// slotProto encodes s as a gymbro.v1.Slot.
+func slotProto(s Slot) func(*protoBuffer) {
+	return func(p *protoBuffer) {
+		p.string(1, s.Weekday)
+		p.string(2, s.Start)
+		p.string(3, s.End)
+	}
+}
+
+// matchViewProto encodes v as a gymbro.v1.MatchView.
+func matchViewProto(v MatchView) func(*protoBuffer) {
+	return func(p *protoBuffer) {
+		p.string(1, v.ID)
+		p.string(2, v.User1ID)
+		p.string(3, v.User2ID)
+		p.timestamp(4, v.CreatedAt)
+		p.string(5, v.GoalID)
+		p.bool(6, v.ContactSharedByMe)
+		p.bool(7, v.ContactSharedByPartner)
+		p.message(8, profileProto(v.Partner))
+	}
+}
@@ -3,67 +3,347 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultUsersLimit = 50
+	maxUsersLimit     = 200
+
+	defaultDislikeCooldown = 14 * 24 * time.Hour
+
+	defaultImageURL = "/images/default.jpg"
+
+	shutdownTimeout = 15 * time.Second
 )
 
 type User struct {
 	FirebaseUID string `json:"firebaseUid"`
 	Name        string `json:"name"`
 	ImageURL    string `json:"imageUrl"`
-	Time        string `json:"time"`
-	Day         string `json:"day"`
-	TextInfo    string `json:"textInfo"`
-	TrainType   string `json:"trainType"`
-	Contact     string `json:"contact"`
+	// Photos is the ordered gallery; ImageURL mirrors the first photo.
+	Photos    []string `json:"photos,omitempty"`
+	Time      string   `json:"time"`
+	Day       string   `json:"day"`
+	TextInfo  string   `json:"textInfo"`
+	BioLang   string   `json:"bioLang,omitempty"` // ISO 639-1, detected from TextInfo
+	TrainType string   `json:"trainType"`
+	Contact   string   `json:"contact"`
+	// Availability is the weekly schedule; Day and Time mirror its first
+	// slot for old clients.
+	Availability []Slot `json:"availability,omitempty"`
+
+	AccessibilityNeeds []string `json:"accessibilityNeeds,omitempty"`
+	AdaptiveTraining   bool     `json:"adaptiveTraining,omitempty"`
+	ShareAccessibility bool     `json:"shareAccessibility,omitempty"`
+
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	HomeGymID string   `json:"homeGymId,omitempty"`
+
+	NearbyAlerts bool `json:"nearbyAlerts,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	TenantID  string    `json:"tenantId,omitempty"`
+
+	LinkedContacts []LinkedContact `json:"linkedContacts,omitempty"`
+
+	Responsiveness string   `json:"responsiveness,omitempty"`
+	DistanceKm     *float64 `json:"distanceKm,omitempty"`
+	// Overlap is the viewer's shared windows, set on cards in overlap mode.
+	Overlap []Slot `json:"overlap,omitempty"`
 }
 
 type Swipe struct {
-	SwiperID string `json:"swiperId"`
-	TargetID string `json:"targetId"`
-	IsLike   bool   `json:"isLike"`
+	SwiperID  string    `json:"swiperId"`
+	TargetID  string    `json:"targetId"`
+	IsLike    bool      `json:"isLike"`
+	CreatedAt time.Time `json:"createdAt"`
+	// DwellMs is how long the card was on screen before the swipe, as
+	// reported by the client; zero when unknown.
+	DwellMs int64 `json:"dwellMs,omitempty"`
 }
 
 type Match struct {
-	User1ID string `json:"user1Id"`
-	User2ID string `json:"user2Id"`
+	User1ID   string    `json:"user1Id"`
+	User2ID   string    `json:"user2Id"`
+	CreatedAt time.Time `json:"createdAt"`
+	// GoalID is set for matches made through a goal post rather than
+	// swiping.
+	GoalID string `json:"goalId,omitempty"`
+	// ContactSharedBy lists the participants who chose to share their
+	// contact; it is revealed once both did.
+	ContactSharedBy []string `json:"contactSharedBy,omitempty"`
+}
+
+// MatchView is a match as returned to one of its participants, with the
+// counterpart's profile embedded.
+type MatchView struct {
+	ID        string    `json:"id"`
+	User1ID   string    `json:"user1Id"`
+	User2ID   string    `json:"user2Id"`
+	CreatedAt time.Time `json:"createdAt"`
+	GoalID    string    `json:"goalId,omitempty"`
+
+	ContactSharedByMe      bool `json:"contactSharedByMe"`
+	ContactSharedByPartner bool `json:"contactSharedByPartner"`
+	// Partner.Contact is only set once both sides shared their contact.
+	Partner User `json:"partner"`
+}
+
+// ID identifies a match by its ordered pair of user IDs.
+func (m Match) ID() string {
+	id1, id2 := m.User1ID, m.User2ID
+	if id1 > id2 {
+		id1, id2 = id2, id1
+	}
+	return id1 + ":" + id2
+}
+
+func (m Match) Has(userID string) bool {
+	return m.User1ID == userID || m.User2ID == userID
+}
+
+func (m Match) Partner(userID string) string {
+	if m.User1ID == userID {
+		return m.User2ID
+	}
+	return m.User1ID
 }
 
 type SwipeRequest struct {
 	SwiperID string `json:"swiperId"`
 	TargetID string `json:"targetId"`
 	IsLike   bool   `json:"isLike"`
+	// DwellMs is the time the card was shown before the swipe.
+	DwellMs int64 `json:"dwellMs,omitempty"`
+}
+
+type UsersPage struct {
+	Users  []User `json:"users"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
 }
 
 type Storage struct {
 	Users   []User  `json:"users"`
 	Swipes  []Swipe `json:"swipes"`
 	Matches []Match `json:"matches"`
+
+	// SwipeUndos is when each user last undid a swipe; only swipes made
+	// after that can be undone.
+	SwipeUndos map[string]time.Time `json:"swipeUndos,omitempty"`
+
+	Tombstones     []AccountTombstone `json:"tombstones,omitempty"`
+	ForgottenUsers []ForgottenUser    `json:"forgottenUsers,omitempty"`
+	Erasures       []ErasureRecord    `json:"erasures,omitempty"`
+
+	ResponseStats map[string]ResponseStats `json:"responseStats,omitempty"`
+
+	EmergencyContacts map[string][]EmergencyContact `json:"emergencyContacts,omitempty"`
+	SharedPlans       []SharedPlan                  `json:"sharedPlans,omitempty"`
+
+	MessageRequests []MessageRequest `json:"messageRequests,omitempty"`
+	Messages        []Message        `json:"messages,omitempty"`
+	MessageEdits    []MessageEdit    `json:"messageEdits,omitempty"`
+
+	Blocks  []Block  `json:"blocks,omitempty"`
+	Reports []Report `json:"reports,omitempty"`
+
+	QuickReplies map[string][]string `json:"quickReplies,omitempty"`
+
+	ScheduledMessages []ScheduledMessage `json:"scheduledMessages,omitempty"`
+
+	Devices    []Device   `json:"devices,omitempty"`
+	Deliveries []Delivery `json:"deliveries,omitempty"`
+
+	TelegramChats     []TelegramChat     `json:"telegramChats,omitempty"`
+	TelegramLinkCodes []TelegramLinkCode `json:"telegramLinkCodes,omitempty"`
+
+	Maintenance *MaintenanceState `json:"maintenance,omitempty"`
+
+	Gyms []Gym `json:"gyms,omitempty"`
+
+	GoalPosts []GoalPost `json:"goalPosts,omitempty"`
+	Sessions  []Session  `json:"sessions,omitempty"`
+
+	VersionPolicy VersionPolicy `json:"versionPolicy"`
+
+	CheckIns     []CheckIn     `json:"checkIns,omitempty"`
+	NearbyAlerts []NearbyAlert `json:"nearbyAlerts,omitempty"`
+
+	GymTokens     []storedGymToken  `json:"gymTokens,omitempty"`
+	Announcements []GymAnnouncement `json:"announcements,omitempty"`
+
+	ImageHashes    []ImageHash         `json:"imageHashes,omitempty"`
+	PendingImages  []PendingImage      `json:"pendingImages,omitempty"`
+	ProfileHistory []ProfileChange     `json:"profileHistory,omitempty"`
+	UserTokens     []storedUserToken   `json:"userTokens,omitempty"`
+	ContactChanges []ContactChange     `json:"contactChanges,omitempty"`
+	Notifications  []InboxNotification `json:"notifications,omitempty"`
+
+	Waitlist []WaitlistEntry `json:"waitlist,omitempty"`
+
+	Tenants           []TenantConfig            `json:"tenants,omitempty"`
+	TenantQuotas      map[string]TenantQuota    `json:"tenantQuotas,omitempty"`
+	NotificationUsage map[string]map[string]int `json:"notificationUsage,omitempty"`
+	FeatureFlags      []FeatureFlag             `json:"featureFlags,omitempty"`
+
+	HarassmentRules  []HarassmentRule  `json:"harassmentRules,omitempty"`
+	HarassmentAlerts []HarassmentAlert `json:"harassmentAlerts,omitempty"`
+
+	AutomationRules []AutomationRule `json:"automationRules,omitempty"`
+	AutomationRuns  []AutomationRun  `json:"automationRuns,omitempty"`
+
+	ImageMigration *ImageMigration `json:"imageMigration,omitempty"`
 }
 
 type Controller struct {
-	storage  Storage
-	dataFile string
-	imageDir string
+	// mu guards storage. Handlers are wrapped with locked; background jobs
+	// take the lock themselves.
+	mu sync.RWMutex
+
+	storage        Storage
+	storageLoaded  bool
+	dataFile       string
+	journal        *Journal
+	backupCount    int
+	backupInterval time.Duration
+	lastBackup     time.Time
+	images         ImageStore
+	imageURLs      string
+	maxImageBytes  int64
+	// maxBodyBytes caps request messages of handlers outside limitBody,
+	// such as gRPC calls.
+	maxBodyBytes int64
+	// migrationTarget is the S3 bucket local images can be migrated to;
+	// migrationCancel is set while the migration runs.
+	migrationTarget ImageStore
+	migrationCancel context.CancelFunc
+	migrationDone   chan struct{}
+	// dataVersion counts successful saves, for ETags of responses computed
+	// from storage.
+	dataVersion uint64
+	// multipartMemory is how much of an upload is kept in memory while
+	// parsing; the rest goes to temporary files.
+	multipartMemory int64
+	feed            *FeedService
+
+	// regions are where sign-ups are open; empty accepts them everywhere.
+	regions []Region
+
+	messagePolicy MessagePolicy
+	namePolicy    NamePolicy
+	previews      *LinkPreviewer
+	undoWindow    time.Duration
+	translations  *TranslationService
+	notifier      Notifier
+	digests       *DigestBatcher
+	contacts      ContactSender
+	telegram      *TelegramBot
+	moderator     ImageModerator
+	events        *EventHub
+
+	keyring           *Keyring
+	storageKeyVersion int
+
+	debug     *DebugRecorder
+	decisions *DecisionLog
+	versions  versionStats
+
+	dailyLikeLimit int
+
+	// confirmations holds dry-run tokens of destructive admin operations.
+	confirmations map[string]pendingConfirmation
+
+	faults  *FaultInjector
+	metrics *Metrics
+	limiter *RateLimiter
+
+	// publicStats is refreshed by a background job and served without
+	// taking c.mu, behind its own per-IP limiter.
+	publicStats   publicStatsCache
+	publicLimiter *RateLimiter
+
+	// background tracks work started by handlers that outlives the request,
+	// such as push deliveries, so shutdown can wait for it.
+	background sync.WaitGroup
 }
 
-func NewController(dataFile, imageDir string) *Controller {
+func NewController(cfg Config) *Controller {
 	c := &Controller{
-		dataFile: dataFile,
-		imageDir: imageDir,
+		dataFile:        cfg.DataFile,
+		backupCount:     cfg.BackupCount,
+		backupInterval:  cfg.BackupInterval,
+		imageURLs:       cfg.ImageURLs,
+		maxImageBytes:   cfg.MaxImageBytes,
+		maxBodyBytes:    cfg.MaxBodyBytes,
+		multipartMemory: cfg.MultipartMemoryBytes,
+		regions:         cfg.SignupRegions,
+
+		messagePolicy: MessagePolicy{RequireAcceptance: cfg.MessageRequests, MaxNewConversationsPerDay: cfg.MaxNewConversations},
+		namePolicy:    NamePolicy{RequireUnique: cfg.UniqueDisplayNames},
+		previews:      NewLinkPreviewer(cfg.LinkPreviewAllow, cfg.LinkPreviewDeny),
+		undoWindow:    defaultUndoWindow,
+		translations:  NewTranslationService(translatorFromEnv(), defaultDailyTranslations),
+		notifier:      notifierFromEnv(),
+		digests:       NewDigestBatcher(defaultDigestRules),
+		contacts:      LogContactSender{},
+		telegram:      telegramFromEnv(),
+		moderator:     moderatorFromEnv(),
+		events:        NewEventHub(),
+		debug:         NewDebugRecorder(),
+		decisions:     NewDecisionLog(cfg.DecisionLogSample),
+
+		dailyLikeLimit: defaultDailyLikeLimit,
+		faults:         faultsFromEnv(),
+		metrics:        NewMetrics(),
+		limiter:        NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst, cfg.TrustProxy),
+		publicLimiter:  NewIPRateLimiter(publicStatsPerMinute, publicStatsBurst, cfg.TrustProxy),
+	}
+	if c.faults != nil && c.faults.NotificationDropRate > 0 {
+		c.notifier = faultyNotifier{Notifier: c.notifier, faults: c.faults}
 	}
+	c.limiter.WithIdentity(c.tokenUser)
+	c.feed = NewFeedService(&c.storage, defaultDislikeCooldown, cfg.NewUserBoost)
 
-	if err := os.MkdirAll(imageDir, 0755); err != nil {
-		log.Printf("Failed to create image directory: %v", err)
+	keyring, err := keyringFromEnv()
+	if err != nil {
+		slog.Error("Failed to load storage keys", "err", err)
+		os.Exit(1)
 	}
+	c.keyring = keyring
 
-	if err := c.loadData(); err != nil {
-		log.Printf("Failed to load data, using defaults: %v", err)
+	images, err := imageStoreFromConfig(cfg)
+	if err != nil {
+		slog.Error("Failed to set up image storage", "err", err)
+		os.Exit(1)
+	}
+	c.images = images
+	c.migrationTarget = imageMigrationTarget(cfg)
+
+	if err := c.loadData(); err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, errCorruptStorage) {
+		slog.Error("Failed to load data", "err", err)
+		os.Exit(1)
+	} else if err != nil {
+		slog.Warn("Failed to load data, using defaults", "err", err)
 
 		c.storage = Storage{
 			Users: []User{
@@ -91,95 +371,313 @@ func NewController(dataFile, imageDir string) *Controller {
 		}
 	}
 
+	c.storage.migratePhotos()
+	c.storage.migrateBioLanguages()
+	c.storage.migrateAvailability()
+
+	if err := c.openJournal(); err != nil {
+		slog.Error("Failed to open swipe journal", "err", err)
+		os.Exit(1)
+	}
+	c.notifyOpenedRegions()
+	c.resumeImageMigration()
+
 	return c
 }
 
+// loadData reads the data file, falling back to the newest backup that
+// parses when it is missing or corrupt. A corrupt data file is moved aside
+// before the server starts over, so it is never overwritten. Any other
+// failure, such as a missing storage key, is returned as is: the caller must
+// not start over on defaults then, or the next save would replace the data.
 func (c *Controller) loadData() error {
-	data, err := os.ReadFile(c.dataFile)
-	if err != nil {
-		return fmt.Errorf("reading data file: %w", err)
+	storage, err := c.readStorageFile(c.dataFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, errCorruptStorage) {
+		return err
 	}
+	if err != nil {
+		for _, path := range c.backups() {
+			backup, backupErr := c.readStorageFile(path)
+			if backupErr != nil {
+				slog.Warn("Skipping unreadable backup", "path", path, "err", backupErr)
+				continue
+			}
 
-	if err := json.Unmarshal(data, &c.storage); err != nil {
-		return fmt.Errorf("unmarshaling data: %w", err)
+			slog.Warn("Recovered data from backup", "path", path, "err", err)
+			storage, err = backup, nil
+			break
+		}
+		quarantineFile(c.dataFile)
+	}
+	if err != nil {
+		return err
 	}
 
+	c.storage = storage
+	c.storageLoaded = true
 	return nil
 }
 
 func (c *Controller) saveData() error {
+	start := time.Now()
+	defer func() {
+		c.metrics.StorageSave.Observe("", time.Since(start).Seconds())
+	}()
+
 	data, err := json.MarshalIndent(c.storage, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling data: %w", err)
 	}
 
-	if err := os.WriteFile(c.dataFile, data, 0644); err != nil {
+	data, err = c.encodeStorage(data)
+	if err != nil {
+		return fmt.Errorf("encrypting data: %w", err)
+	}
+
+	if err := c.faults.StorageWrite(); err != nil {
+		return fmt.Errorf("writing data file: %w", err)
+	}
+
+	c.rotateBackup()
+	if err := writeFileAtomic(c.dataFile, data, 0644); err != nil {
 		return fmt.Errorf("writing data file: %w", err)
 	}
+	c.dataVersion++
+
+	// The snapshot now holds every journaled swipe.
+	if c.journal != nil {
+		if err := c.journal.Reset(); err != nil {
+			return fmt.Errorf("resetting journal: %w", err)
+		}
+	}
 
 	return nil
 }
 
+// heldLockKey marks a request context whose handler holds c.mu; the value
+// reports whether it is the write lock.
+type heldLockKey struct{}
+
+// locked serializes handlers that write storage; reads share a read lock.
+func (c *Controller) locked(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		write := r.Method != http.MethodGet && r.Method != http.MethodHead
+		if write {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+		} else {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), heldLockKey{}, write)))
+	}
+}
+
+// unlocked runs fn with c.mu released when ctx belongs to a handler wrapped
+// in locked, so outbound calls do not stall every other request. Storage can
+// change meanwhile: whatever the caller looked up before must be looked up
+// again afterwards.
+func (c *Controller) unlocked(ctx context.Context, fn func()) {
+	write, ok := ctx.Value(heldLockKey{}).(bool)
+	switch {
+	case !ok:
+	case write:
+		c.mu.Unlock()
+		defer c.mu.Lock()
+	default:
+		c.mu.RUnlock()
+		defer c.mu.RLock()
+	}
+	fn()
+}
+
+// RegisterJobs adds the controller's background jobs to s.
+func (c *Controller) RegisterJobs(s *Scheduler) {
+	s.Every("scheduled-messages", scheduledMessagesInterval, c.deliverScheduledMessages)
+	s.Every("debug-capture-purge", debugPurgeInterval, c.debug.Purge)
+	s.Every("decision-log-purge", debugPurgeInterval, c.decisions.Purge)
+	s.Every("link-preview-purge", linkPreviewPurgeInterval, c.previews.Purge)
+	s.Every("rate-limit-purge", rateLimitPurgeInterval, c.limiter.Purge)
+	s.Every("public-rate-limit-purge", rateLimitPurgeInterval, c.publicLimiter.Purge)
+	s.Every("public-stats", publicStatsInterval, c.refreshPublicStats)
+	s.Every("orphan-images", orphanSweepInterval, c.sweepOrphanImages)
+	s.Every("journal-compaction", journalCompactInterval, c.compactJournal)
+	s.Every("delivery-retries", deliveryRetryInterval, c.retryDeliveries)
+	s.Every("notification-digests", digestFlushInterval, c.flushDigests)
+	s.Every("automations", automationInterval, c.runAutomations)
+}
+
+func (c *Controller) findUser(uid string) (User, bool) {
+	for _, u := range c.storage.Users {
+		if u.FirebaseUID == uid {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generating id: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode response", "err", err)
+	}
+}
+
+func queryInt(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
 func (c *Controller) AddProfile(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !c.parseUpload(w, r) {
 		return
 	}
 
-	if err := r.ParseMultipartForm(10 << 30); err != nil {
-		http.Error(w, "Failed to parse multipart form", http.StatusBadRequest)
+	if err := validateProfileForm(r); err != nil {
+		writeValidationError(w, err)
 		return
 	}
-
 	firebaseUID := r.FormValue("firebaseUid")
-	if firebaseUID == "" {
-		http.Error(w, "Firebase UID is required", http.StatusBadRequest)
+
+	// Moderation releases the lock, so the image is stored and checked
+	// before anything is read from storage.
+	imageURL, err := c.saveUpload(r)
+	if err != nil && !errors.Is(err, errNoImage) {
+		if uploadErrorStatus(err) == http.StatusInternalServerError {
+			slog.ErrorContext(r.Context(), "Failed to save image", "err", err)
+		}
+		writeError(w, uploadErrorStatus(err), errorCode(err, "INTERNAL_ERROR"), err.Error())
 		return
 	}
+	imageUpdated := err == nil
+	var moderation ModerationResult
+	if imageUpdated {
+		moderation = c.moderateUpload(r.Context(), imageURL)
+	}
 
-	var user User
-	var imageUpdated bool
+	if t, ok := c.findTombstone(firebaseUID); ok {
+		writeErrorDetails(w, http.StatusGone, "ACCOUNT_MERGED", "Account was merged into "+t.MergedInto,
+			map[string]string{"mergedInto": t.MergedInto})
+		return
+	}
 
-	if file, handler, err := r.FormFile("image"); err == nil {
-		defer file.Close()
+	tenant := tenantID(r)
+	if u, ok := c.findUser(firebaseUID); ok {
+		tenant = userTenant(u)
+	}
+	name, err := c.checkDisplayName(r.FormValue("name"), firebaseUID, tenant)
+	if err != nil {
+		writeError(w, nameErrorStatus(err), errorCode(err, "INVALID_NAME"), err.Error())
+		return
+	}
 
-		imagePath := filepath.Join(c.imageDir, handler.Filename)
-		dst, err := os.Create(imagePath)
-		if err != nil {
-			http.Error(w, "Failed to save image", http.StatusInternalServerError)
-			return
-		}
-		defer dst.Close()
+	if _, ok := c.findUser(firebaseUID); !ok && !c.checkSignupRegion(w, r, firebaseUID, name) {
+		return
+	}
 
-		if _, err := io.Copy(dst, file); err != nil {
-			http.Error(w, "Failed to save image", http.StatusInternalServerError)
-			return
-		}
+	var user User
 
-		user.ImageURL = "/images/" + handler.Filename
-		imageUpdated = true
+	imagePending := false
+	if imageUpdated {
+		// A new primary photo supersedes one still awaiting review.
+		c.discardPendingImages(firebaseUID, true)
+		imagePending = c.holdForModeration(firebaseUID, imageURL, true, moderation)
 	}
 
 	user.FirebaseUID = firebaseUID
-	user.Name = r.FormValue("name")
+	user.Name = name
 	user.Time = r.FormValue("time")
 	user.Day = r.FormValue("day")
+	if existing, ok := c.findUser(firebaseUID); ok {
+		user.setAvailability(formAvailability(r, &existing))
+	} else {
+		user.setAvailability(formAvailability(r, nil))
+	}
 	user.TextInfo = r.FormValue("textInfo")
+	user.BioLang = detectLanguage(user.TextInfo)
 	user.TrainType = r.FormValue("trainType")
 	user.Contact = r.FormValue("contact")
+	user.AccessibilityNeeds = formList(r, "accessibilityNeeds")
+	user.AdaptiveTraining = formBool(r, "adaptiveTraining")
+	user.ShareAccessibility = formBool(r, "shareAccessibility")
+
+	lat, lon, err := formCoordinates(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_COORDINATES", err.Error())
+		return
+	}
+	user.Latitude, user.Longitude = lat, lon
+
+	user.NearbyAlerts = formBool(r, "nearbyAlerts")
+
+	user.HomeGymID = r.FormValue("homeGymId")
+	if _, ok := c.findGym(user.HomeGymID); user.HomeGymID != "" && !ok {
+		writeError(w, http.StatusBadRequest, "GYM_NOT_FOUND", "Gym not found")
+		return
+	}
 
 	found := false
 	for i, u := range c.storage.Users {
 		if u.FirebaseUID == firebaseUID {
+			before := u
 			if imageUpdated {
-				c.storage.Users[i].ImageURL = user.ImageURL
+				// An image sent with the profile replaces the primary photo.
+				// One held for moderation only takes its place on approval.
+				photos := slices.Clone(before.Photos)
+				switch {
+				case imagePending && len(photos) > 0:
+					photos = photos[1:]
+				case imagePending:
+				case len(photos) == 0:
+					photos = []string{imageURL}
+				default:
+					photos[0] = imageURL
+				}
+				c.storage.Users[i].setPhotos(photos)
+				if before.ImageURL != defaultImageURL {
+					c.removeImage(before.ImageURL, firebaseUID)
+					c.unindexImage(firebaseUID, before.ImageURL)
+				}
 			}
 			c.storage.Users[i].Name = user.Name
 			c.storage.Users[i].Time = user.Time
 			c.storage.Users[i].Day = user.Day
+			c.storage.Users[i].Availability = user.Availability
 			c.storage.Users[i].TextInfo = user.TextInfo
+			c.storage.Users[i].BioLang = user.BioLang
 			c.storage.Users[i].TrainType = user.TrainType
 			c.storage.Users[i].Contact = user.Contact
+			if formHas(r, "accessibilityNeeds") {
+				c.storage.Users[i].AccessibilityNeeds = user.AccessibilityNeeds
+			}
+			if formHas(r, "adaptiveTraining") {
+				c.storage.Users[i].AdaptiveTraining = user.AdaptiveTraining
+			}
+			if formHas(r, "shareAccessibility") {
+				c.storage.Users[i].ShareAccessibility = user.ShareAccessibility
+			}
+			if formHas(r, "latitude", "longitude") {
+				c.storage.Users[i].Latitude = user.Latitude
+				c.storage.Users[i].Longitude = user.Longitude
+			}
+			if formHas(r, "homeGymId") {
+				c.storage.Users[i].HomeGymID = user.HomeGymID
+			}
+			if formHas(r, "nearbyAlerts") {
+				c.storage.Users[i].NearbyAlerts = user.NearbyAlerts
+			}
+			c.recordProfileChanges(before, c.storage.Users[i], firebaseUID)
 			user = c.storage.Users[i]
 			found = true
 			break
@@ -187,223 +685,450 @@ func (c *Controller) AddProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !found {
-		if !imageUpdated {
-			user.ImageURL = "/images/default.jpg"
+		if imageUpdated && !imagePending {
+			user.setPhotos([]string{imageURL})
+		} else {
+			user.setPhotos(nil)
 		}
+		user.CreatedAt = time.Now()
+		user.TenantID = tenant
 		c.storage.Users = append(c.storage.Users, user)
+		c.metrics.ProfilesCreated.Inc()
+	}
+
+	if imageUpdated && !imagePending {
+		c.indexImage(firebaseUID, imageURL)
 	}
 
 	if err := c.saveData(); err != nil {
-		log.Printf("Failed to save data: %v", err)
-		http.Error(w, "Failed to save data", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
 		return
 	}
 
+	if imagePending {
+		w.Header().Set("X-Image-Status", "pending")
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
 
+// GetUsers handles GET /api/users. Version 1 answers with the array of all
+// matching users unless limit or offset is given; paged responses are a
+// UsersPage. Responses carry an ETag that changes with every save, so
+// clients can revalidate with If-None-Match.
 func (c *Controller) GetUsers(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	encoder := json.NewEncoder(w)
-	encoder.SetEscapeHTML(false)
-	err := encoder.Encode(c.storage.Users)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if notModified(w, r, c.dataETag(r)) {
 		return
 	}
-}
 
-func (c *Controller) GetNextUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	query := r.URL.Query()
+
+	limit, err := queryInt(query.Get("limit"), defaultUsersLimit)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_LIMIT", "Invalid limit")
 		return
 	}
+	if limit > maxUsersLimit {
+		limit = maxUsersLimit
+	}
 
-	userIDStr := r.URL.Path[len("/api/next-user/"):]
-	if userIDStr == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_OFFSET", "Invalid offset")
 		return
 	}
 
-	userID := userIDStr
-
-	for _, user := range c.storage.Users {
-		if user.FirebaseUID == userID {
-			continue
-		}
-
-		swiped := false
-		for _, swipe := range c.storage.Swipes {
-			if swipe.SwiperID == userID && swipe.TargetID == user.FirebaseUID {
-				swiped = true
-				break
-			}
-		}
-
-		if !swiped {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			json.NewEncoder(w).Encode(user)
-			return
-		}
+	filter := UsersFilter{
+		TrainType: query.Get("trainType"),
+		Day:       query.Get("day"),
+		Time:      query.Get("time"),
+		BioLang:   query.Get("bioLang"),
+	}
+	var body any
+	if APIVersion(r.Context()) >= 2 || query.Has("limit") || query.Has("offset") {
+		body = c.usersPage(filter, limit, offset)
+	} else {
+		body = c.usersPage(filter, len(c.storage.Users), 0).Users
 	}
 
-	http.Error(w, "No users available", http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
 }
 
-func (c *Controller) GetMatches(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (c *Controller) GetNextUser(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.PathValue("uid")
+
+	filter, err := ParseFeedFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
 		return
 	}
 
-	userIDStr := r.URL.Path[len("/api/matches/"):]
-	if userIDStr == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	if cards := c.deck(r.Context(), userIDStr, filter, r.URL.RawQuery, 1); len(cards) > 0 {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(cards[0])
 		return
 	}
 
-	var userMatches []Match
-	for _, match := range c.storage.Matches {
-		if match.User1ID == userIDStr || match.User2ID == userIDStr {
-			userMatches = append(userMatches, match)
+	writeErrorDetails(w, http.StatusNotFound, "DECK_EMPTY", "No users available",
+		DeckEmpty{Suggestions: c.feed.Suggestions(userIDStr, filter)})
+}
+
+func (c *Controller) findMatch(id string) (Match, bool) {
+	for _, m := range c.storage.Matches {
+		if m.ID() == id {
+			return m, true
 		}
 	}
+	return Match{}, false
+}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	json.NewEncoder(w).Encode(userMatches)
+func (c *Controller) GetMatches(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.matchViews(r.PathValue("uid")))
 }
 
 func (c *Controller) Swipe(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req SwipeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
 		return
 	}
 
-	var swiperExists, targetExists bool
-	for _, user := range c.storage.Users {
-		if user.FirebaseUID == req.SwiperID {
-			swiperExists = true
-		}
-		if user.FirebaseUID == req.TargetID {
-			targetExists = true
-		}
+	result, err := c.swipe(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
 	}
+	writeJSON(w, http.StatusOK, result)
+}
 
-	if !swiperExists || !targetExists {
-		http.Error(w, "User not found", http.StatusNotFound)
+func main() {
+	// "openapi" prints the API description for client generators without
+	// starting the server.
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		os.Stdout.Write(openAPIDocument())
 		return
 	}
 
-	swipeExists := false
-	for _, swipe := range c.storage.Swipes {
-		if swipe.SwiperID == req.SwiperID && swipe.TargetID == req.TargetID {
-			swipeExists = true
-			break
-		}
+	cfg, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
+	setupLogging(cfg.LogLevel)
 
-	if req.IsLike && !swipeExists {
-		c.storage.Swipes = append(c.storage.Swipes, Swipe{
-			SwiperID: req.SwiperID,
-			TargetID: req.TargetID,
-			IsLike:   req.IsLike,
-		})
+	if err := os.MkdirAll(filepath.Dir(cfg.DataFile), 0755); err != nil {
+		slog.Error("Failed to create data directory", "err", err)
+		os.Exit(1)
 	}
 
-	isMatch := false
-	if req.IsLike {
-		for _, swipe := range c.storage.Swipes {
-			if swipe.SwiperID == req.TargetID &&
-				swipe.TargetID == req.SwiperID &&
-				swipe.IsLike {
-				isMatch = true
-				break
-			}
+	controller := NewController(cfg)
+
+	http.HandleFunc("/images/", controller.ServeImage)
+	http.HandleFunc("GET /images/thumb/{size}/{name}", controller.Thumbnail)
+
+	cors := CORSPolicy{Origins: cfg.CORSOrigins, Methods: cfg.CORSMethods, Headers: cfg.CORSHeaders}
+
+	// Client routes are served on their legacy /api/ path and under each
+	// /api/v{N}/. Long-running handlers take c.mu themselves.
+	preflight := cors.Handler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	stream := NewRouter(http.DefaultServeMux, preflight).With(
+		controller.metrics.InstrumentRoutes, cors.Handler, controller.limiter.Handler,
+		controller.debug.Capture, controller.maintenanceGuard, controller.versionGate)
+	api := stream.With(controller.locked)
+	// Bodies are capped at MaxBodyBytes except on these routes.
+	bodyLimits := map[string]int64{
+		"POST /api/profiles":              cfg.MaxUploadBytes,
+		"POST /api/profiles/{uid}/photos": cfg.MaxUploadBytes,
+		"POST /api/swipe":                 swipeBodyBytes,
+		"POST /api/swipe/undo":            swipeBodyBytes,
+	}
+	handleAPI := func(method, path string, h http.HandlerFunc) {
+		limit, ok := bodyLimits[method+" "+path]
+		if !ok {
+			limit = cfg.MaxBodyBytes
 		}
+		api.HandleVersioned(method, path, h, limitBody(limit), func(next http.HandlerFunc) http.HandlerFunc {
+			return controller.requireScope(routeScope(path), next)
+		})
 	}
 
-	if isMatch {
-		id1, id2 := req.SwiperID, req.TargetID
-		if id1 > id2 {
-			id1, id2 = id2, id1
-		}
+	handleAPI("GET", "/api/users", controller.GetUsers)
+	handleAPI("GET", "/api/users/{uid}/export", controller.accountRoute(controller.exportAccount))
+	handleAPI("POST", "/api/users/{uid}/forget", controller.accountRoute(controller.forgetAccount))
+	handleAPI("GET", "/api/users/{uid}/sessions.ics", controller.accountRoute(controller.sessionCalendarFeed))
+	handleAPI("GET", "/api/next-user/{uid}", controller.GetNextUser)
+	handleAPI("GET", "/api/feed/{uid}", controller.GetFeed)
+	handleAPI("POST", "/api/swipe", controller.Swipe)
+	handleAPI("POST", "/api/swipe/undo", controller.UndoSwipe)
+	handleAPI("GET", "/api/display-names/check", controller.CheckDisplayName)
+	handleAPI("POST", "/api/account/merge", controller.MergeAccount)
+
+	handleAPI("GET", "/api/matches/{uid}", controller.GetMatches)
+	handleAPI("GET", "/api/matches/{matchId}/export", controller.ExportChat)
+	handleAPI("GET", "/api/matches/{matchId}/quick-replies", controller.QuickReplies)
+	handleAPI("GET", "/api/matches/{matchId}/messages", controller.MatchMessages)
+	handleAPI("POST", "/api/matches/{matchId}/messages", controller.MatchMessages)
+	handleAPI("GET", "/api/matches/{matchId}/scheduled-messages", controller.ScheduledMessages)
+	handleAPI("POST", "/api/matches/{matchId}/scheduled-messages", controller.ScheduledMessages)
+	handleAPI("POST", "/api/matches/{matchId}/share-contact", controller.ShareContact)
+	handleAPI("POST", "/api/matches/{matchId}/sessions", controller.ProposeSession)
+	handleAPI("GET", "/api/sessions/{uid}", controller.UserSessions)
+	handleAPI("POST", "/api/sessions/{id}/{action}", controller.RespondSession)
+	handleAPI("DELETE", "/api/matches/{matchId}/share-contact", controller.ShareContact)
+	handleAPI("PATCH", "/api/messages/{id}", controller.editMessage)
+	handleAPI("DELETE", "/api/messages/{id}", controller.deleteMessage)
+	handleAPI("POST", "/api/messages/{id}/translate", controller.TranslateMessage)
+	handleAPI("GET", "/api/message-requests/{uid}", controller.GetMessageRequests)
+	handleAPI("POST", "/api/message-requests/{id}/{action}", controller.RespondMessageRequest)
+	handleAPI("DELETE", "/api/scheduled-messages/{id}", controller.CancelScheduledMessage)
+
+	handleAPI("GET", "/api/goals", controller.Goals)
+	handleAPI("POST", "/api/goals", controller.Goals)
+	handleAPI("GET", "/api/goals/{id}", controller.Goal)
+	handleAPI("DELETE", "/api/goals/{id}", controller.Goal)
+	handleAPI("POST", "/api/goals/{id}/responses", controller.Goal)
+	handleAPI("POST", "/api/goals/{id}/responses/{responderId}/{action}", controller.Goal)
+
+	handleAPI("POST", "/api/profiles", controller.AddProfile)
+	handleAPI("GET", "/api/profiles/{uid}/history", ownProfile(controller.ProfileHistory))
+	handleAPI("GET", "/api/profiles/{uid}/onboarding", ownProfile(controller.Onboarding))
+	handleAPI("POST", "/api/profiles/{uid}/photos", ownProfile(controller.ProfilePhotos))
+	handleAPI("PUT", "/api/profiles/{uid}/photos", ownProfile(controller.ProfilePhotos))
+	handleAPI("DELETE", "/api/profiles/{uid}/photos/{name}", ownProfile(controller.ProfilePhotos))
+	handleAPI("POST", "/api/devices", controller.RegisterDevice)
+	handleAPI("GET", "/api/telegram/link", controller.TelegramLink)
+	handleAPI("POST", "/api/telegram/link", controller.TelegramLink)
+	handleAPI("DELETE", "/api/telegram/link", controller.TelegramLink)
+	handleAPI("POST", "/api/contacts/change", controller.requestContactChange)
+	handleAPI("POST", "/api/contacts/verify", controller.verifyContactChange)
+
+	handleAPI("GET", "/api/safety/contacts/{uid}", controller.EmergencyContacts)
+	handleAPI("PUT", "/api/safety/contacts/{uid}", controller.EmergencyContacts)
+	handleAPI("POST", "/api/safety/share", controller.SharePlan)
+	handleAPI("GET", "/api/safety/plans/{token}", controller.GetSharedPlan)
+	handleAPI("POST", "/api/block", controller.BlockUser)
+	handleAPI("POST", "/api/report", controller.ReportUser)
+	handleAPI("POST", "/api/safety/block", controller.BlockUser)
+	handleAPI("POST", "/api/safety/report", controller.ReportUser)
+	handleAPI("POST", "/api/photo-reports", controller.ReportStolenPhoto)
+
+	handleAPI("GET", "/api/gyms", controller.Gyms)
+	handleAPI("GET", "/api/gyms/{id}", controller.GetGym)
+	handleAPI("POST", "/api/checkins", controller.CheckIn)
+	handleAPI("GET", "/api/tenant/config", controller.GetTenantConfig)
+	handleAPI("GET", "/api/features", controller.GetFeatures)
+
+	handleAPI("GET", "/api/notifications", controller.Inbox)
+	handleAPI("POST", "/api/notifications/read-all", controller.ReadAllNotifications)
+	handleAPI("POST", "/api/notifications/{id}/read", controller.ReadNotification)
+	stream.HandleVersioned("GET", "/api/notifications/poll", controller.PollNotifications)
+
+	// Partner routes authenticate with gym tokens and skip client middleware.
+	partner := NewRouter(http.DefaultServeMux, preflight).With(
+		controller.metrics.InstrumentRoutes, cors.Handler, limitBody(cfg.MaxBodyBytes), controller.locked)
+	partner.HandleVersioned("GET", "/api/version-policy", controller.GetVersionPolicy)
+	partner.HandleVersioned("GET", "/api/partner/stats", controller.PartnerStats)
+	partner.HandleVersioned("GET", "/api/partner/announcements", controller.PartnerAnnouncements)
+	partner.HandleVersioned("POST", "/api/partner/announcements", controller.PartnerAnnouncements)
+	partner.HandleVersioned("GET", "/api/partner/moderation/reports", controller.PartnerModerationReports)
+	partner.HandleVersioned("POST", "/api/partner/moderation/reports/{id}", controller.PartnerModerateReport)
+	partner.HandleVersioned("GET", "/api/partner/moderation/goals", controller.PartnerModerationGoals)
+	partner.HandleVersioned("POST", "/api/partner/moderation/goals/{id}/remove", controller.PartnerRemoveGoal)
+
+	public := NewRouter(http.DefaultServeMux, preflight).With(
+		controller.metrics.InstrumentRoutes, cors.Handler, controller.publicLimiter.Handler)
+	public.HandleVersioned("GET", "/api/public/stats", controller.PublicStats)
+
+	// Telegram posts bot updates with the webhook secret instead of a token.
+	http.HandleFunc("POST /api/telegram/webhook", controller.metrics.InstrumentRoutes(
+		limitBody(cfg.MaxBodyBytes)(controller.locked(controller.TelegramWebhook))))
+
+	http.HandleFunc("/metrics", controller.locked(controller.ServeMetrics))
+	http.HandleFunc("/healthz", controller.Healthz)
+	http.HandleFunc("/readyz", controller.Readyz)
+	http.HandleFunc("/api/", NotFoundAPI)
+	http.HandleFunc("/openapi.json", cors.Handler(OpenAPI))
+	http.HandleFunc("/docs", APIDocs)
+	http.HandleFunc("/docs/", APIDocs)
+
+	handleAdmin := func(pattern string, h http.HandlerFunc) {
+		http.HandleFunc(pattern, controller.metrics.InstrumentRoutes(limitBody(cfg.MaxBodyBytes)(controller.locked(controller.requireAdmin(h)))))
+	}
+	handleAdmin("/admin/swipes", controller.AdminSwipes)
+	handleAdmin("/admin/matches", controller.AdminMatches)
+	handleAdmin("/admin/reports", controller.AdminReports)
+	handleAdmin("/admin/analytics/funnel", controller.AdminFunnel)
+	handleAdmin("/admin/analytics/dwell", controller.AdminDwell)
+	handleAdmin("/admin/capacity", controller.AdminCapacity)
+	handleAdmin("/admin/images/migration", controller.AdminImageMigration)
+	handleAdmin("/admin/quick-replies", controller.AdminQuickReplies)
+	handleAdmin("/admin/storage/encryption", controller.AdminStorageEncryption)
+	handleAdmin("/admin/maintenance", controller.AdminMaintenance)
+	handleAdmin("/admin/version-policy", controller.AdminVersionPolicy)
+	handleAdmin("/admin/decisions", controller.AdminDecisions)
+	handleAdmin("/admin/waitlist", controller.AdminWaitlist)
+	handleAdmin("/admin/erasures", controller.AdminErasures)
+
+	admin := NewRouter(http.DefaultServeMux, nil).With(
+		controller.metrics.InstrumentRoutes, limitBody(cfg.MaxBodyBytes), controller.locked, controller.requireAdmin)
+	admin.Handle("GET", "/admin/users", controller.AdminUsers)
+	admin.Handle("DELETE", "/admin/users/{uid}", controller.AdminDeleteUser)
+	admin.Handle("DELETE", "/admin/users/{uid}/image", controller.AdminDeleteUserImage)
+	admin.Handle("GET", "/admin/users/{uid}/history", controller.AdminUserHistory)
+	admin.Handle("POST", "/admin/users/{uid}/merge", controller.AdminMergeUser)
+	admin.Handle("GET", "/admin/users/{uid}/tokens", controller.AdminUserTokens)
+	admin.Handle("POST", "/admin/users/{uid}/tokens", controller.AdminCreateUserToken)
+	admin.Handle("DELETE", "/admin/users/{uid}/tokens/{tokenId}", controller.AdminRevokeUserToken)
+	admin.Handle("POST", "/admin/debug-capture", controller.AdminDebugCapture)
+	admin.Handle("GET", "/admin/debug-capture/{uid}", controller.AdminDebugRecords)
+	admin.Handle("DELETE", "/admin/debug-capture/{uid}", controller.AdminStopDebugCapture)
+	admin.Handle("GET", "/admin/deliveries", controller.AdminDeliveries)
+	admin.Handle("POST", "/admin/deliveries", controller.AdminRequeueDeliveries)
+	admin.Handle("POST", "/admin/deliveries/{id}/requeue", controller.AdminRequeueDelivery)
+	admin.Handle("DELETE", "/admin/deliveries/{id}", controller.AdminDeleteDelivery)
+	admin.Handle("GET", "/admin/images/similar", controller.AdminSimilarImages)
+	admin.Handle("GET", "/admin/images/duplicates", controller.AdminDuplicateImages)
+	admin.Handle("POST", "/admin/images/reindex", controller.AdminReindexImages)
+	admin.Handle("GET", "/admin/images/pending", controller.AdminPendingImages)
+	admin.Handle("POST", "/admin/images/pending/{id}/{action}", controller.AdminReviewPendingImage)
+	admin.Handle("GET", "/admin/profile-changes/suspicious", controller.AdminProfileChanges)
+	admin.Handle("GET", "/admin/tenants", controller.AdminTenants)
+	admin.Handle("PUT", "/admin/tenants/{id}", controller.AdminPutTenant)
+	admin.Handle("DELETE", "/admin/tenants/{id}", controller.AdminDeleteTenant)
+	admin.Handle("GET", "/admin/usage", controller.AdminUsage)
+	admin.Handle("PUT", "/admin/usage/{tenant}/quota", controller.AdminTenantQuota)
+	admin.Handle("GET", "/admin/features", controller.AdminFeatures)
+	admin.Handle("PUT", "/admin/features/{name}", controller.AdminPutFeature)
+	admin.Handle("DELETE", "/admin/features/{name}", controller.AdminDeleteFeature)
+	admin.Handle("GET", "/admin/harassment/rules", controller.AdminHarassmentRules)
+	admin.Handle("POST", "/admin/harassment/rules", controller.AdminCreateHarassmentRule)
+	admin.Handle("DELETE", "/admin/harassment/rules/{id}", controller.AdminDeleteHarassmentRule)
+	admin.Handle("GET", "/admin/harassment/alerts", controller.AdminHarassmentAlerts)
+	admin.Handle("POST", "/admin/harassment/alerts/{id}/{action}", controller.AdminReviewHarassmentAlert)
+	admin.Handle("GET", "/admin/automations", controller.AdminAutomations)
+	admin.Handle("POST", "/admin/automations", controller.AdminCreateAutomation)
+	admin.Handle("PUT", "/admin/automations/{id}", controller.AdminUpdateAutomation)
+	admin.Handle("DELETE", "/admin/automations/{id}", controller.AdminDeleteAutomation)
+	admin.Handle("POST", "/admin/gyms", controller.AdminCreateGym)
+	admin.Handle("PUT", "/admin/gyms/{id}", controller.AdminUpdateGym)
+	admin.Handle("DELETE", "/admin/gyms/{id}", controller.AdminDeleteGym)
+	admin.Handle("GET", "/admin/gyms/{id}/tokens", controller.AdminGymTokens)
+	admin.Handle("POST", "/admin/gyms/{id}/tokens", controller.AdminCreateGymToken)
+	admin.Handle("DELETE", "/admin/gyms/{id}/tokens/{tokenId}", controller.AdminRevokeGymToken)
+
+	if cfg.ServeWebClient {
+		http.Handle("/", WebClientHandler())
+	}
 
-		matchExists := false
-		for _, match := range c.storage.Matches {
-			if (match.User1ID == id1 && match.User2ID == id2) ||
-				(match.User1ID == id2 && match.User2ID == id1) {
-				matchExists = true
-				break
-			}
-		}
+	controller.sweepOrphanImages()
+	controller.refreshPublicStats()
 
-		if !matchExists {
-			c.storage.Matches = append(c.storage.Matches, Match{
-				User1ID: id1,
-				User2ID: id2,
-			})
+	scheduler := NewScheduler()
+	controller.RegisterJobs(scheduler)
+	scheduler.Start()
 
-			if err := c.saveData(); err != nil {
-				log.Printf("Failed to save data: %v", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-		}
+	ln, err := Listen(cfg.ListenAddr)
+	if err != nil {
+		slog.Error("Failed to listen", "addr", cfg.ListenAddr, "err", err)
+		os.Exit(1)
 	}
 
-	if err := c.saveData(); err != nil {
-		log.Printf("Failed to save data: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	response := map[string]interface{}{
-		"success": true,
-		"isMatch": isMatch,
+	server := &http.Server{Handler: requestLogger(http.DefaultServeMux)}
+	server.RegisterOnShutdown(controller.events.Close)
+	serveErr := make(chan error, 3)
+	go func() {
+		if cfg.TLSCertFile != "" {
+			server.TLSConfig = newTLSConfig()
+			serveErr <- server.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+			return
+		}
+		serveErr <- server.Serve(ln)
+	}()
+
+	var redirect *http.Server
+	if cfg.HTTPRedirectAddr != "" {
+		redirect = &http.Server{Addr: cfg.HTTPRedirectAddr, Handler: HTTPSRedirectHandler(ln.Addr())}
+		go func() {
+			serveErr <- redirect.ListenAndServe()
+		}()
 	}
 
-	if isMatch {
-		var lastMatch Match
-		for _, match := range c.storage.Matches {
-			if (match.User1ID == req.SwiperID && match.User2ID == req.TargetID) ||
-				(match.User1ID == req.TargetID && match.User2ID == req.SwiperID) {
-				lastMatch = match
-			}
+	// gRPC needs HTTP/2; without TLS it is spoken in cleartext (h2c).
+	var grpcServer *http.Server
+	if cfg.GRPCAddr != "" {
+		grpcLn, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			slog.Error("Failed to listen", "addr", cfg.GRPCAddr, "err", err)
+			os.Exit(1)
 		}
-		response["match"] = lastMatch
-	} else {
-		response["match"] = nil
-	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Failed to encode response: %v", err)
+		var protocols http.Protocols
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(cfg.TLSCertFile == "")
+		// Calls run behind the same middleware as the REST routes.
+		grpcMux := http.NewServeMux()
+		NewRouter(grpcMux, nil).With(
+			controller.metrics.InstrumentRoutes, controller.limiter.Handler, controller.debug.Capture,
+			controller.maintenanceGuard, controller.versionGate, limitBody(cfg.MaxBodyBytes),
+		).Handle("POST", grpcServicePrefix+"{method}", controller.ServeGRPC)
+		grpcServer = &http.Server{
+			Handler:   grpcMux,
+			Protocols: &protocols,
+		}
+		grpcServer.RegisterOnShutdown(controller.events.Close)
+		go func() {
+			if cfg.TLSCertFile != "" {
+				grpcServer.TLSConfig = newTLSConfig()
+				serveErr <- grpcServer.ServeTLS(grpcLn, cfg.TLSCertFile, cfg.TLSKeyFile)
+				return
+			}
+			serveErr <- grpcServer.Serve(grpcLn)
+		}()
+		slog.Info("gRPC server starting", "addr", grpcLn.Addr().String())
 	}
-}
 
-func main() {
-	if err := os.MkdirAll("data", 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+	slog.Info("Server starting", "addr", ln.Addr().String(), "tls", cfg.TLSCertFile != "")
+	select {
+	case err := <-serveErr:
+		slog.Error("Server stopped", "err", err)
+		os.Exit(1)
+	case <-ctx.Done():
 	}
 
-	controller := NewController("data/storage.json", "data/images")
-
-	http.Handle("/images/", http.StripPrefix("/images/",
-		http.FileServer(http.Dir(controller.imageDir))))
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	http.HandleFunc("/api/users", controller.GetUsers)
-	http.HandleFunc("/api/next-user/", controller.GetNextUser)
-	http.HandleFunc("/api/swipe", controller.Swipe)
-	http.HandleFunc("/api/matches/", controller.GetMatches)
-	http.HandleFunc("/api/profiles", controller.AddProfile)
-
-	log.Println("Server starting on :8080...")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if redirect != nil {
+		redirect.Shutdown(shutdownCtx)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to drain requests", "err", err)
+	}
+	if grpcServer != nil {
+		// Watch streams end once the event hub is closed.
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to drain gRPC calls", "err", err)
+		}
+	}
+	scheduler.Stop()
+	if err := controller.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down cleanly", "err", err)
+		os.Exit(1)
+	}
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// ScopeModerate lets a gym's own staff moderate content scoped to the
+	// gym without global admin access: they can dismiss or escalate reports
+	// involving their members and take down goal posts at the gym, but
+	// never ban or erase accounts.
+	ScopeModerate = "moderation"
+
+	ReportDismissed = "dismissed"
+	ReportEscalated = "escalated"
+
+	maxModerationNote = 500
+)
+
+// ModerationDecision records what a gym moderator did and through which
+// token, so global admins can audit it.
+type ModerationDecision struct {
+	GymID     string    `json:"gymId"`
+	TokenID   string    `json:"tokenId"`
+	Action    string    `json:"action"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ModerationRequest struct {
+	Action string `json:"action"`
+	Note   string `json:"note"`
+}
+
+// moderationToken returns the request's gym token if it carries the
+// moderation scope, writing the error response otherwise.
+func (c *Controller) moderationToken(w http.ResponseWriter, r *http.Request) (GymToken, bool) {
+	token, ok := c.gymTokenFromRequest(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+		return GymToken{}, false
+	}
+	if !token.HasScope(ScopeModerate) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden")
+		return GymToken{}, false
+	}
+	return token, true
+}
+
+func decodeModerationRequest(w http.ResponseWriter, r *http.Request) (ModerationRequest, bool) {
+	var req ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return req, false
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if len([]rune(req.Note)) > maxModerationNote {
+		writeError(w, http.StatusBadRequest, "INVALID_MODERATION_ACTION", "Note is too long")
+		return req, false
+	}
+	return req, true
+}
+
+// reportInGym reports whether a gym's moderators may see report: the
+// reporter or the reported user is a member, or it was filed from one of
+// the gym's goal posts. The caller must hold c.mu.
+func (c *Controller) reportInGym(report Report, gymID string) bool {
+	for _, uid := range []string{report.ReporterID, report.ReportedID} {
+		if u, ok := c.findUser(uid); ok && u.HomeGymID == gymID {
+			return true
+		}
+	}
+	if report.Context == "goal" {
+		if i, ok := c.findGoal(report.ContextID); ok && c.storage.GoalPosts[i].GymID == gymID {
+			return true
+		}
+	}
+	return false
+}
+
+// PartnerModerationReports handles GET /api/partner/moderation/reports for
+// gym tokens with the moderation scope. ?status=open leaves out reports that
+// have already been dismissed or escalated.
+func (c *Controller) PartnerModerationReports(w http.ResponseWriter, r *http.Request) {
+	token, ok := c.moderationToken(w, r)
+	if !ok {
+		return
+	}
+	openOnly := r.URL.Query().Get("status") == "open"
+
+	reports := []Report{}
+	for _, report := range c.storage.Reports {
+		if openOnly && report.Status != "" {
+			continue
+		}
+		if c.reportInGym(report, token.GymID) {
+			reports = append(reports, report)
+		}
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+// PartnerModerateReport handles POST /api/partner/moderation/reports/{id}
+// with {"action": "dismiss"|"escalate", "note": "..."}. Escalated reports
+// stay in /admin/reports for the global admins to act on; a report decided
+// by a global admin or another gym is not changed.
+func (c *Controller) PartnerModerateReport(w http.ResponseWriter, r *http.Request) {
+	token, ok := c.moderationToken(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeModerationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var status string
+	switch req.Action {
+	case "dismiss":
+		status = ReportDismissed
+	case "escalate":
+		status = ReportEscalated
+	default:
+		writeError(w, http.StatusBadRequest, "INVALID_MODERATION_ACTION", "Action must be dismiss or escalate")
+		return
+	}
+
+	var report *Report
+	for i := range c.storage.Reports {
+		if c.storage.Reports[i].ID == r.PathValue("id") {
+			report = &c.storage.Reports[i]
+			break
+		}
+	}
+	// Reports outside the gym are indistinguishable from missing ones.
+	if report == nil || !c.reportInGym(*report, token.GymID) {
+		writeError(w, http.StatusNotFound, "REPORT_NOT_FOUND", "Report not found")
+		return
+	}
+	if report.Status != "" {
+		writeError(w, http.StatusConflict, "REPORT_ALREADY_MODERATED", "Report has already been moderated")
+		return
+	}
+
+	report.Status = status
+	report.Moderation = &ModerationDecision{
+		GymID:     token.GymID,
+		TokenID:   token.ID,
+		Action:    req.Action,
+		Note:      req.Note,
+		CreatedAt: time.Now(),
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Gym moderated report", "gymId", token.GymID, "tokenId", token.ID, "reportId", report.ID, "action", req.Action)
+	writeJSON(w, http.StatusOK, report)
+}
+
+// PartnerModerationGoals handles GET /api/partner/moderation/goals, the goal
+// posts at the token's gym, responses included.
+func (c *Controller) PartnerModerationGoals(w http.ResponseWriter, r *http.Request) {
+	token, ok := c.moderationToken(w, r)
+	if !ok {
+		return
+	}
+
+	goals := []GoalPost{}
+	for _, g := range c.storage.GoalPosts {
+		if g.GymID == token.GymID {
+			goals = append(goals, g)
+		}
+	}
+	writeJSON(w, http.StatusOK, goals)
+}
+
+// PartnerRemoveGoal handles POST /api/partner/moderation/goals/{id}/remove
+// with an optional {"note": "..."}. The post is taken out of every listing
+// and can no longer be responded to; its author keeps seeing it with the
+// removed status.
+func (c *Controller) PartnerRemoveGoal(w http.ResponseWriter, r *http.Request) {
+	token, ok := c.moderationToken(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeModerationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	i, ok := c.findGoal(r.PathValue("id"))
+	if !ok || c.storage.GoalPosts[i].GymID != token.GymID {
+		writeError(w, http.StatusNotFound, "GOAL_NOT_FOUND", "Goal not found")
+		return
+	}
+	goal := &c.storage.GoalPosts[i]
+	if goal.Status == GoalRemoved {
+		writeJSON(w, http.StatusOK, goal)
+		return
+	}
+
+	now := time.Now()
+	goal.Status = GoalRemoved
+	if goal.ClosedAt == nil {
+		goal.ClosedAt = &now
+	}
+	goal.Moderation = &ModerationDecision{
+		GymID:     token.GymID,
+		TokenID:   token.ID,
+		Action:    "remove",
+		Note:      req.Note,
+		CreatedAt: now,
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Gym removed goal post", "gymId", token.GymID, "tokenId", token.ID, "goalId", goal.ID)
+	writeJSON(w, http.StatusOK, goal)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeStatsRead = "stats:read"
+
+	partnerStatsWindow = 30 * 24 * time.Hour
+)
+
+// GymToken is a read-only API token a partner gym uses to pull anonymized
+// stats. Only the SHA-256 hash of the token is stored.
+type GymToken struct {
+	ID        string     `json:"id"`
+	GymID     string     `json:"gymId"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// storedGymToken is how tokens are persisted, including the hash.
+type storedGymToken struct {
+	GymToken
+	Hash string `json:"hash"`
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t GymToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminGymTokens handles GET /admin/gyms/{id}/tokens.
+func (c *Controller) AdminGymTokens(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathGym(w, r)
+	if !ok {
+		return
+	}
+
+	tokens := []GymToken{}
+	for _, t := range c.storage.GymTokens {
+		if t.GymID == c.storage.Gyms[i].ID {
+			tokens = append(tokens, t.GymToken)
+		}
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// AdminCreateGymToken handles POST /admin/gyms/{id}/tokens with an optional
+// {"scopes": [...]} body.
+func (c *Controller) AdminCreateGymToken(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathGym(w, r)
+	if !ok {
+		return
+	}
+
+	// The body is optional; tokens default to reading stats.
+	var req struct {
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{ScopeStatsRead}
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(gymTokenScopes, scope) {
+			writeError(w, http.StatusBadRequest, "UNKNOWN_SCOPE", "Unknown scope "+scope)
+			return
+		}
+	}
+
+	secret := "gym_" + newID()
+	token := GymToken{
+		ID:        newID(),
+		GymID:     c.storage.Gyms[i].ID,
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now(),
+	}
+	c.storage.GymTokens = append(c.storage.GymTokens, storedGymToken{
+		GymToken: token,
+		Hash:     hashToken(secret),
+	})
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	// The secret is only ever returned here.
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":  secret,
+		"detail": token,
+	})
+}
+
+// AdminRevokeGymToken handles DELETE /admin/gyms/{id}/tokens/{tokenId}.
+func (c *Controller) AdminRevokeGymToken(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathGym(w, r)
+	if !ok {
+		return
+	}
+
+	for j, t := range c.storage.GymTokens {
+		if t.GymID != c.storage.Gyms[i].ID || t.ID != r.PathValue("tokenId") {
+			continue
+		}
+
+		now := time.Now()
+		c.storage.GymTokens[j].RevokedAt = &now
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeError(w, http.StatusNotFound, "TOKEN_NOT_FOUND", "Token not found")
+}
+
+// gymTokenFromRequest resolves the bearer token of a partner request.
+func (c *Controller) gymTokenFromRequest(r *http.Request) (GymToken, bool) {
+	secret := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if secret == "" {
+		return GymToken{}, false
+	}
+
+	hash := hashToken(secret)
+	for _, t := range c.storage.GymTokens {
+		if t.Hash == hash && t.RevokedAt == nil {
+			return t.GymToken, true
+		}
+	}
+	return GymToken{}, false
+}
+
+type PartnerStats struct {
+	GymID          string         `json:"gymId"`
+	Since          time.Time      `json:"since"`
+	Members        int            `json:"members"`
+	CheckIns       int            `json:"checkIns"`
+	UniqueVisitors int            `json:"uniqueVisitors"`
+	CheckInsByDay  map[string]int `json:"checkInsByDay"`
+}
+
+// PartnerStats handles GET /api/partner/stats for gym partner tokens. Only
+// aggregate counts are returned, never user identifiers.
+func (c *Controller) PartnerStats(w http.ResponseWriter, r *http.Request) {
+	token, ok := c.gymTokenFromRequest(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+		return
+	}
+	if !token.HasScope(ScopeStatsRead) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden")
+		return
+	}
+
+	since := time.Now().Add(-partnerStatsWindow)
+	stats := PartnerStats{
+		GymID:         token.GymID,
+		Since:         since,
+		CheckInsByDay: make(map[string]int),
+	}
+
+	for _, u := range c.storage.Users {
+		if u.HomeGymID == token.GymID {
+			stats.Members++
+		}
+	}
+
+	visitors := make(map[string]bool)
+	for _, ci := range c.storage.CheckIns {
+		if ci.GymID != token.GymID || ci.At.Before(since) {
+			continue
+		}
+		stats.CheckIns++
+		visitors[ci.UserID] = true
+		stats.CheckInsByDay[ci.At.UTC().Format("2006-01-02")]++
+	}
+	stats.UniqueVisitors = len(visitors)
+
+	writeJSON(w, http.StatusOK, stats)
+}
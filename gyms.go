@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	SameGymPrefer = "prefer"
+	SameGymOnly   = "only"
+)
+
+type Gym struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type GymRequest struct {
+	Name      string  `json:"name"`
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (req GymRequest) valid() bool {
+	return strings.TrimSpace(req.Name) != "" &&
+		req.Latitude >= -90 && req.Latitude <= 90 &&
+		req.Longitude >= -180 && req.Longitude <= 180
+}
+
+func (c *Controller) findGym(id string) (int, bool) {
+	for i, g := range c.storage.Gyms {
+		if g.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// GetGym handles GET /api/gyms/{id}.
+func (c *Controller) GetGym(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.findGym(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "GYM_NOT_FOUND", "Gym not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, c.storage.Gyms[i])
+}
+
+// Gyms handles GET /api/gyms.
+func (c *Controller) Gyms(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("q"))
+	gyms := []Gym{}
+	for _, g := range c.storage.Gyms {
+		if query == "" || strings.Contains(strings.ToLower(g.Name), query) ||
+			strings.Contains(strings.ToLower(g.Address), query) {
+			gyms = append(gyms, g)
+		}
+	}
+	writeJSON(w, http.StatusOK, gyms)
+}
+
+// AdminCreateGym handles POST /admin/gyms.
+func (c *Controller) AdminCreateGym(w http.ResponseWriter, r *http.Request) {
+	var req GymRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.valid() {
+		writeError(w, http.StatusBadRequest, "INVALID_GYM", "Invalid gym")
+		return
+	}
+
+	gym := Gym{
+		ID:        newID(),
+		Name:      strings.TrimSpace(req.Name),
+		Address:   strings.TrimSpace(req.Address),
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		CreatedAt: time.Now(),
+	}
+	c.storage.Gyms = append(c.storage.Gyms, gym)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, gym)
+}
+
+// pathGym finds the gym named by the {id} path value, writing a 404 if
+// there is none.
+func (c *Controller) pathGym(w http.ResponseWriter, r *http.Request) (int, bool) {
+	i, ok := c.findGym(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "GYM_NOT_FOUND", "Gym not found")
+	}
+	return i, ok
+}
+
+// AdminUpdateGym handles PUT /admin/gyms/{id}.
+func (c *Controller) AdminUpdateGym(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathGym(w, r)
+	if !ok {
+		return
+	}
+
+	var req GymRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.valid() {
+		writeError(w, http.StatusBadRequest, "INVALID_GYM", "Invalid gym")
+		return
+	}
+
+	gym := &c.storage.Gyms[i]
+	gym.Name = strings.TrimSpace(req.Name)
+	gym.Address = strings.TrimSpace(req.Address)
+	gym.Latitude = req.Latitude
+	gym.Longitude = req.Longitude
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminDeleteGym handles DELETE /admin/gyms/{id}. Users who had it as their
+// home gym are left without one.
+func (c *Controller) AdminDeleteGym(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathGym(w, r)
+	if !ok {
+		return
+	}
+
+	id := c.storage.Gyms[i].ID
+	if !c.confirmDestructive(w, r, c.gymDeletePlan(id)) {
+		return
+	}
+	c.storage.Gyms = append(c.storage.Gyms[:i], c.storage.Gyms[i+1:]...)
+	for j := range c.storage.Users {
+		if c.storage.Users[j].HomeGymID == id {
+			c.storage.Users[j].HomeGymID = ""
+		}
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
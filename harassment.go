@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	HarassmentAlertOpen      = "open"
+	HarassmentAlertDismissed = "dismissed"
+	HarassmentAlertConfirmed = "confirmed"
+
+	// harassmentContextMessages is how many earlier messages of the chat
+	// are kept with an alert.
+	harassmentContextMessages = 5
+
+	// harassmentRestrictThreshold is how many open alerts against a user
+	// restrict their messaging until a moderator reviews them.
+	harassmentRestrictThreshold = 3
+	// harassmentRestrictedInterval is how often a restricted user may send
+	// a message.
+	harassmentRestrictedInterval = 10 * time.Minute
+
+	maxHarassmentPatternLength = 200
+)
+
+var errMessagingRestricted = errors.New("messaging is limited while reports about you are reviewed")
+
+// HarassmentRule is an entry of the alert list: a keyword matched anywhere
+// in the text, ignoring case and look-alike characters, or a regular
+// expression matched ignoring case.
+type HarassmentRule struct {
+	ID        string    `json:"id"`
+	Pattern   string    `json:"pattern"`
+	Regex     bool      `json:"regex,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type HarassmentRuleRequest struct {
+	Pattern string `json:"pattern"`
+	Regex   bool   `json:"regex"`
+}
+
+// HarassmentAlert flags a chat message or report matching the alert list
+// for moderator review. UserID is the suspected offender: the sender of the
+// message or the reported user.
+type HarassmentAlert struct {
+	ID       string `json:"id"`
+	UserID   string `json:"userId"`
+	Source   string `json:"source"` // "message" or "report"
+	SourceID string `json:"sourceId"`
+	MatchID  string `json:"matchId,omitempty"`
+	Text     string `json:"text"`
+	// Matched are the patterns of the rules that matched.
+	Matched []string `json:"matched"`
+	// Context is the chat leading up to the message, oldest first.
+	Context    []Message  `json:"context,omitempty"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+}
+
+// HarassmentAlertView is an alert in the moderation queue.
+type HarassmentAlertView struct {
+	HarassmentAlert
+	// Priority grows with the offender's open alerts; reports count once
+	// more, since a person has already complained.
+	Priority   int  `json:"priority"`
+	Restricted bool `json:"restricted"`
+}
+
+func (rule HarassmentRule) compile() (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + rule.Pattern)
+}
+
+// harassmentMatches returns the patterns of the rules text matches. The
+// caller must hold c.mu.
+func (c *Controller) harassmentMatches(text string) []string {
+	lower := strings.ToLower(text)
+	folded := []string{lower, cyrillicLookalikes.Replace(lower), latinLookalikes.Replace(lower)}
+
+	var matched []string
+	for _, rule := range c.storage.HarassmentRules {
+		if rule.Regex {
+			re, err := rule.compile()
+			if err == nil && re.MatchString(text) {
+				matched = append(matched, rule.Pattern)
+			}
+			continue
+		}
+		keyword := strings.ToLower(rule.Pattern)
+		if slices.ContainsFunc(folded, func(s string) bool { return strings.Contains(s, keyword) }) {
+			matched = append(matched, rule.Pattern)
+		}
+	}
+	return matched
+}
+
+// chatContext returns up to harassmentContextMessages messages of matchID
+// sent before the message with id, or the newest ones when id is empty.
+func (c *Controller) chatContext(matchID, id string) []Message {
+	var history []Message
+	for _, m := range c.storage.Messages {
+		if m.MatchID != matchID {
+			continue
+		}
+		if m.ID == id {
+			break
+		}
+		history = append(history, m)
+	}
+	return slices.Clone(history[max(len(history)-harassmentContextMessages, 0):])
+}
+
+// flagMessage adds an alert when m matches the alert list. The caller must
+// hold c.mu and save data.
+func (c *Controller) flagMessage(m Message) {
+	matched := c.harassmentMatches(m.Text)
+	if len(matched) == 0 {
+		return
+	}
+	c.addHarassmentAlert(HarassmentAlert{
+		UserID:   m.SenderID,
+		Source:   "message",
+		SourceID: m.ID,
+		MatchID:  m.MatchID,
+		Text:     m.Text,
+		Matched:  matched,
+		Context:  c.chatContext(m.MatchID, m.ID),
+	})
+}
+
+// flagReport adds an alert when the reason or details of report match the
+// alert list, with the latest messages between the two users. The caller
+// must hold c.mu and save data.
+func (c *Controller) flagReport(report Report) {
+	text := strings.TrimSpace(report.Reason + "\n" + report.Details)
+	matched := c.harassmentMatches(text)
+	if len(matched) == 0 {
+		return
+	}
+	matchID := Match{User1ID: report.ReporterID, User2ID: report.ReportedID}.ID()
+	c.addHarassmentAlert(HarassmentAlert{
+		UserID:   report.ReportedID,
+		Source:   "report",
+		SourceID: report.ID,
+		MatchID:  matchID,
+		Text:     text,
+		Matched:  matched,
+		Context:  c.chatContext(matchID, ""),
+	})
+}
+
+func (c *Controller) addHarassmentAlert(alert HarassmentAlert) {
+	alert.ID = newID()
+	alert.Status = HarassmentAlertOpen
+	alert.CreatedAt = time.Now()
+	c.storage.HarassmentAlerts = append(c.storage.HarassmentAlerts, alert)
+
+	slog.Warn("Harassment alert", "alertId", alert.ID, "userId", alert.UserID, "source", alert.Source)
+	if c.harassmentRestricted(alert.UserID) {
+		slog.Warn("Messaging restricted pending review", "userId", alert.UserID)
+	}
+}
+
+func (c *Controller) openHarassmentAlerts(uid string) int {
+	n := 0
+	for _, a := range c.storage.HarassmentAlerts {
+		if a.UserID == uid && a.Status == HarassmentAlertOpen {
+			n++
+		}
+	}
+	return n
+}
+
+// harassmentRestricted reports whether uid has enough open alerts to be
+// rate-limited. Reviewing the alerts lifts the restriction.
+func (c *Controller) harassmentRestricted(uid string) bool {
+	return c.openHarassmentAlerts(uid) >= harassmentRestrictThreshold
+}
+
+// checkRestrictedSender returns errMessagingRestricted when senderID is
+// restricted and has sent a message within harassmentRestrictedInterval.
+func (c *Controller) checkRestrictedSender(senderID string, now time.Time) error {
+	if !c.harassmentRestricted(senderID) {
+		return nil
+	}
+	for i := len(c.storage.Messages) - 1; i >= 0; i-- {
+		m := c.storage.Messages[i]
+		if now.Sub(m.CreatedAt) >= harassmentRestrictedInterval {
+			break
+		}
+		if m.SenderID == senderID {
+			return errMessagingRestricted
+		}
+	}
+	return nil
+}
+
+// forgetHarassmentAlerts removes the alerts about uid. The caller must hold
+// c.mu and save data.
+func (c *Controller) forgetHarassmentAlerts(uid string) {
+	alerts := c.storage.HarassmentAlerts[:0]
+	for _, a := range c.storage.HarassmentAlerts {
+		if a.UserID != uid {
+			alerts = append(alerts, a)
+		}
+	}
+	c.storage.HarassmentAlerts = alerts
+}
+
+// harassmentQueue returns the alerts with the given status, or all when it
+// is empty, highest priority first and oldest first within a priority.
+func (c *Controller) harassmentQueue(status string) []HarassmentAlertView {
+	queue := []HarassmentAlertView{}
+	for _, a := range c.storage.HarassmentAlerts {
+		if status != "" && a.Status != status {
+			continue
+		}
+		view := HarassmentAlertView{
+			HarassmentAlert: a,
+			Priority:        c.openHarassmentAlerts(a.UserID),
+			Restricted:      c.harassmentRestricted(a.UserID),
+		}
+		if a.Source == "report" {
+			view.Priority++
+		}
+		queue = append(queue, view)
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		if queue[i].Priority != queue[j].Priority {
+			return queue[i].Priority > queue[j].Priority
+		}
+		return queue[i].CreatedAt.Before(queue[j].CreatedAt)
+	})
+	return queue
+}
+
+// AdminHarassmentRules handles GET /admin/harassment/rules.
+func (c *Controller) AdminHarassmentRules(w http.ResponseWriter, r *http.Request) {
+	rules := c.storage.HarassmentRules
+	if rules == nil {
+		rules = []HarassmentRule{}
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// AdminCreateHarassmentRule handles POST /admin/harassment/rules.
+func (c *Controller) AdminCreateHarassmentRule(w http.ResponseWriter, r *http.Request) {
+	var req HarassmentRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	rule := HarassmentRule{ID: newID(), Pattern: strings.TrimSpace(req.Pattern), Regex: req.Regex, CreatedAt: time.Now()}
+	if rule.Pattern == "" || len(rule.Pattern) > maxHarassmentPatternLength {
+		writeError(w, http.StatusBadRequest, "INVALID_HARASSMENT_RULE", "Pattern must be 1 to 200 characters")
+		return
+	}
+	if _, err := rule.compile(); rule.Regex && err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_HARASSMENT_RULE", "Invalid regular expression: "+err.Error())
+		return
+	}
+	c.storage.HarassmentRules = append(c.storage.HarassmentRules, rule)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// AdminDeleteHarassmentRule handles DELETE /admin/harassment/rules/{id}.
+func (c *Controller) AdminDeleteHarassmentRule(w http.ResponseWriter, r *http.Request) {
+	i := slices.IndexFunc(c.storage.HarassmentRules, func(rule HarassmentRule) bool { return rule.ID == r.PathValue("id") })
+	if i == -1 {
+		writeError(w, http.StatusNotFound, "HARASSMENT_RULE_NOT_FOUND", "Rule not found")
+		return
+	}
+	c.storage.HarassmentRules = slices.Delete(c.storage.HarassmentRules, i, i+1)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminHarassmentAlerts handles GET
+// /admin/harassment/alerts?status=open|dismissed|confirmed|all, the priority
+// queue.
+func (c *Controller) AdminHarassmentAlerts(w http.ResponseWriter, r *http.Request) {
+	filter := r.URL.Query().Get("status")
+	switch filter {
+	case "":
+		filter = HarassmentAlertOpen
+	case "all":
+		filter = ""
+	case HarassmentAlertOpen, HarassmentAlertDismissed, HarassmentAlertConfirmed:
+	default:
+		writeError(w, http.StatusBadRequest, "INVALID_STATUS", "Invalid status")
+		return
+	}
+	writeJSON(w, http.StatusOK, c.harassmentQueue(filter))
+}
+
+// AdminReviewHarassmentAlert handles POST
+// /admin/harassment/alerts/{id}/{action}, where action is dismiss or confirm.
+func (c *Controller) AdminReviewHarassmentAlert(w http.ResponseWriter, r *http.Request) {
+	var review string
+	switch r.PathValue("action") {
+	case "dismiss":
+		review = HarassmentAlertDismissed
+	case "confirm":
+		review = HarassmentAlertConfirmed
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+		return
+	}
+	i := slices.IndexFunc(c.storage.HarassmentAlerts, func(a HarassmentAlert) bool { return a.ID == r.PathValue("id") })
+	if i == -1 {
+		writeError(w, http.StatusNotFound, "HARASSMENT_ALERT_NOT_FOUND", "Alert not found")
+		return
+	}
+	alert := &c.storage.HarassmentAlerts[i]
+	if alert.Status != HarassmentAlertOpen {
+		writeError(w, http.StatusConflict, "HARASSMENT_ALERT_REVIEWED", "Alert was already reviewed")
+		return
+	}
+	now := time.Now()
+	alert.Status, alert.ReviewedAt = review, &now
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, *alert)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type ProbeResult struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz reports that the process is up. It does not touch storage so it
+// stays cheap enough for a liveness probe.
+func (c *Controller) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ProbeResult{Status: "ok"})
+}
+
+// Readyz reports whether the server can serve traffic: the data directory
+// must be writable and the image store reachable.
+func (c *Controller) Readyz(w http.ResponseWriter, r *http.Request) {
+	result := ProbeResult{Status: "ready", Checks: map[string]string{}}
+	status := http.StatusOK
+
+	checks := map[string]func() error{
+		"storage": c.checkStorageWritable,
+		"images":  c.checkImageStore,
+	}
+	for name, check := range checks {
+		if err := check(); err != nil {
+			result.Checks[name] = err.Error()
+			result.Status = "not ready"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		result.Checks[name] = "ok"
+	}
+
+	writeJSON(w, status, result)
+}
+
+func (c *Controller) checkStorageWritable() error {
+	f, err := os.CreateTemp(filepath.Dir(c.dataFile), ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("data directory is not writable: %v", err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+func (c *Controller) checkImageStore() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.images.Check(ctx)
+}
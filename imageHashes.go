@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"math"
+	"math/bits"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	phashSize = 32
+	// similarImageDistance is the largest Hamming distance between two
+	// perceptual hashes that still counts as the same photo.
+	similarImageDistance = 10
+)
+
+// ImageHash indexes the perceptual hash of a profile image. Hash is the
+// 64-bit DCT hash in hex.
+type ImageHash struct {
+	ImageURL  string    `json:"imageUrl"`
+	UserID    string    `json:"userId"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SimilarImage struct {
+	ImageURL string `json:"imageUrl"`
+	UserID   string `json:"userId"`
+	Distance int    `json:"distance"`
+}
+
+type DuplicateImagePair struct {
+	A        ImageHash `json:"a"`
+	B        ImageHash `json:"b"`
+	Distance int       `json:"distance"`
+}
+
+type PhotoReportRequest struct {
+	ReporterID string `json:"reporterId"`
+}
+
+// PerceptualHash computes a DCT-based perceptual hash: the image is reduced to
+// 32x32 grayscale, transformed with a 2D DCT, and each of the 63 lowest
+// non-DC frequencies sets a bit when it is above their median.
+func PerceptualHash(img image.Image) uint64 {
+	var pixels [phashSize][phashSize]float64
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	for y := 0; y < phashSize; y++ {
+		for x := 0; x < phashSize; x++ {
+			// Average the block of source pixels that maps onto (x, y).
+			x0, x1 := bounds.Min.X+x*w/phashSize, bounds.Min.X+(x+1)*w/phashSize
+			y0, y1 := bounds.Min.Y+y*h/phashSize, bounds.Min.Y+(y+1)*h/phashSize
+			if x1 == x0 {
+				x1++
+			}
+			if y1 == y0 {
+				y1++
+			}
+
+			var sum float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			pixels[y][x] = sum / float64((x1-x0)*(y1-y0))
+		}
+	}
+
+	coeffs := dct2D(pixels)
+
+	values := make([]float64, 0, 63)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			if u == 0 && v == 0 {
+				continue
+			}
+			values = append(values, coeffs[v][u])
+		}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, value := range values {
+		if value > median {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+func dct1D(in [phashSize]float64) [phashSize]float64 {
+	var out [phashSize]float64
+	for k := 0; k < phashSize; k++ {
+		var sum float64
+		for n := 0; n < phashSize; n++ {
+			sum += in[n] * math.Cos(math.Pi/phashSize*(float64(n)+0.5)*float64(k))
+		}
+		out[k] = sum
+	}
+	return out
+}
+
+func dct2D(pixels [phashSize][phashSize]float64) [phashSize][phashSize]float64 {
+	var rows [phashSize][phashSize]float64
+	for y := range pixels {
+		rows[y] = dct1D(pixels[y])
+	}
+
+	var out [phashSize][phashSize]float64
+	for x := 0; x < phashSize; x++ {
+		var column [phashSize]float64
+		for y := 0; y < phashSize; y++ {
+			column[y] = rows[y][x]
+		}
+		column = dct1D(column)
+		for y := 0; y < phashSize; y++ {
+			out[y][x] = column[y]
+		}
+	}
+	return out
+}
+
+func hashDistance(a, b string) (int, bool) {
+	x, errA := strconv.ParseUint(a, 16, 64)
+	y, errB := strconv.ParseUint(b, 16, 64)
+	if errA != nil || errB != nil {
+		return 0, false
+	}
+	return bits.OnesCount64(x ^ y), true
+}
+
+func (c *Controller) hashImageFile(imageURL string) (string, error) {
+	f, _, err := c.images.Open(context.Background(), imageKey(imageURL))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	if err := checkImageSize(data); err != nil {
+		return "", err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%016x", PerceptualHash(img)), nil
+}
+
+// indexImage hashes one of userID's photos and adds it to the index. Formats
+// the decoder does not support are skipped. The caller must hold c.mu and
+// save data.
+func (c *Controller) indexImage(userID, imageURL string) {
+	c.unindexImage(userID, imageURL)
+	if imageURL == "" || imageURL == defaultImageURL || !strings.HasPrefix(imageURL, "/images/") {
+		return
+	}
+
+	hash, err := c.hashImageFile(imageURL)
+	if err != nil {
+		slog.Warn("Failed to hash image", "imageUrl", imageURL, "err", err)
+		return
+	}
+
+	entry := ImageHash{ImageURL: imageURL, UserID: userID, Hash: hash, CreatedAt: time.Now()}
+	c.storage.ImageHashes = append(c.storage.ImageHashes, entry)
+
+	if similar := c.similarImages(hash, userID); len(similar) > 0 {
+		slog.Warn("Uploaded photo is used by other accounts",
+			"userId", userID, "imageUrl", imageURL, "matches", len(similar))
+	}
+}
+
+func (c *Controller) unindexImage(userID, imageURL string) {
+	hashes := c.storage.ImageHashes[:0]
+	for _, h := range c.storage.ImageHashes {
+		if h.UserID != userID || h.ImageURL != imageURL {
+			hashes = append(hashes, h)
+		}
+	}
+	c.storage.ImageHashes = hashes
+}
+
+func (c *Controller) unindexImages(userID string) {
+	hashes := c.storage.ImageHashes[:0]
+	for _, h := range c.storage.ImageHashes {
+		if h.UserID != userID {
+			hashes = append(hashes, h)
+		}
+	}
+	c.storage.ImageHashes = hashes
+}
+
+// similarImages returns indexed images of other accounts that look like hash,
+// closest first.
+func (c *Controller) similarImages(hash, excludeUserID string) []SimilarImage {
+	similar := []SimilarImage{}
+	for _, h := range c.storage.ImageHashes {
+		if h.UserID == excludeUserID {
+			continue
+		}
+		if d, ok := hashDistance(hash, h.Hash); ok && d <= similarImageDistance {
+			similar = append(similar, SimilarImage{ImageURL: h.ImageURL, UserID: h.UserID, Distance: d})
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool { return similar[i].Distance < similar[j].Distance })
+	return similar
+}
+
+func (c *Controller) findImageHash(userID, imageURL string) (ImageHash, bool) {
+	for _, h := range c.storage.ImageHashes {
+		if (userID != "" && h.UserID == userID) || (imageURL != "" && h.ImageURL == imageURL) {
+			return h, true
+		}
+	}
+	return ImageHash{}, false
+}
+
+// AdminSimilarImages handles GET /admin/images/similar?userId=|imageUrl=.
+func (c *Controller) AdminSimilarImages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	h, ok := c.findImageHash(query.Get("userId"), query.Get("imageUrl"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "IMAGE_NOT_INDEXED", "Image not indexed")
+		return
+	}
+	writeJSON(w, http.StatusOK, c.similarImages(h.Hash, h.UserID))
+}
+
+// AdminDuplicateImages handles GET /admin/images/duplicates.
+func (c *Controller) AdminDuplicateImages(w http.ResponseWriter, r *http.Request) {
+	pairs := []DuplicateImagePair{}
+	hashes := c.storage.ImageHashes
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if hashes[i].UserID == hashes[j].UserID {
+				continue
+			}
+			if d, ok := hashDistance(hashes[i].Hash, hashes[j].Hash); ok && d <= similarImageDistance {
+				pairs = append(pairs, DuplicateImagePair{A: hashes[i], B: hashes[j], Distance: d})
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, pairs)
+}
+
+// AdminReindexImages handles POST /admin/images/reindex.
+func (c *Controller) AdminReindexImages(w http.ResponseWriter, r *http.Request) {
+	c.storage.ImageHashes = nil
+	for _, u := range c.storage.Users {
+		for _, url := range u.Photos {
+			c.indexImage(u.FirebaseUID, url)
+		}
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"indexed": len(c.storage.ImageHashes)})
+}
+
+// ReportStolenPhoto handles POST /api/photo-reports: the reporter says their
+// photos are used by someone else, and every account using a photo similar
+// to one of theirs is reported for moderation.
+func (c *Controller) ReportStolenPhoto(w http.ResponseWriter, r *http.Request) {
+	var req PhotoReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	var own []ImageHash
+	for _, h := range c.storage.ImageHashes {
+		if h.UserID == req.ReporterID {
+			own = append(own, h)
+		}
+	}
+	if req.ReporterID == "" || len(own) == 0 {
+		writeError(w, http.StatusNotFound, "PHOTO_NOT_INDEXED", "Reporter has no indexed photo")
+		return
+	}
+
+	reports := []Report{}
+	reported := make(map[string]bool)
+	for _, h := range own {
+		for _, s := range c.similarImages(h.Hash, req.ReporterID) {
+			if reported[s.ImageURL] {
+				continue
+			}
+			reported[s.ImageURL] = true
+
+			report := Report{
+				ID:         newID(),
+				ReporterID: req.ReporterID,
+				ReportedID: s.UserID,
+				Reason:     "stolen_photo",
+				Details:    fmt.Sprintf("Photo %s matches %s (distance %d)", s.ImageURL, h.ImageURL, s.Distance),
+				Context:    "photo",
+				ContextID:  s.ImageURL,
+				CreatedAt:  time.Now(),
+			}
+			c.storage.Reports = append(c.storage.Reports, report)
+			reports = append(reports, report)
+		}
+	}
+
+	if len(reports) > 0 {
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, reports)
+}
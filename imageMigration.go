@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	ImageMigrationRunning   = "running"
+	ImageMigrationPaused    = "paused"
+	ImageMigrationCompleted = "completed"
+	ImageMigrationFailed    = "failed"
+
+	// imageMigrationSaveEvery is how many copied images are recorded between
+	// saves, bounding the work redone after a crash.
+	imageMigrationSaveEvery = 50
+)
+
+// ImageMigration is the progress of copying images from the local disk to
+// the S3 bucket. Verified keys are kept with their checksum, so a paused or
+// interrupted migration resumes where it stopped and running it again later
+// only copies new images.
+type ImageMigration struct {
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"startedAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// Total is the number of images, thumbnails and variants found by the
+	// latest pass.
+	Total int    `json:"total"`
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+	// Copied maps every verified key to its SHA-256 checksum.
+	Copied map[string]string `json:"copied,omitempty"`
+	// Failed maps keys that could not be copied to the reason.
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// ImageMigrationStatus is an ImageMigration as the admin API shows it.
+type ImageMigrationStatus struct {
+	Status      string            `json:"status"`
+	StartedAt   time.Time         `json:"startedAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
+	Total       int               `json:"total"`
+	Copied      int               `json:"copied"`
+	Bytes       int64             `json:"bytes"`
+	Error       string            `json:"error,omitempty"`
+	Failed      map[string]string `json:"failed,omitempty"`
+}
+
+func (m *ImageMigration) status() ImageMigrationStatus {
+	return ImageMigrationStatus{
+		Status:      m.Status,
+		StartedAt:   m.StartedAt,
+		UpdatedAt:   m.UpdatedAt,
+		CompletedAt: m.CompletedAt,
+		Total:       m.Total,
+		Copied:      len(m.Copied),
+		Bytes:       m.Bytes,
+		Error:       m.Error,
+		Failed:      m.Failed,
+	}
+}
+
+// imageMigrationTarget returns the bucket to migrate local images to, or nil
+// when images are not kept locally or no bucket is configured.
+func imageMigrationTarget(cfg Config) ImageStore {
+	if cfg.ImageStore != "" && cfg.ImageStore != "local" || cfg.S3Bucket == "" {
+		return nil
+	}
+	target, err := NewS3ImageStore(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKeyID, cfg.S3SecretAccessKey)
+	if err != nil {
+		slog.Warn("Image migration is unavailable", "err", err)
+		return nil
+	}
+	return target
+}
+
+// startImageMigration runs the migration in the background. The caller must
+// hold c.mu for writing.
+func (c *Controller) startImageMigration() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.migrationCancel, c.migrationDone = cancel, done
+
+	go func() {
+		defer close(done)
+		c.runImageMigration(ctx)
+
+		c.mu.Lock()
+		c.migrationCancel = nil
+		c.mu.Unlock()
+	}()
+}
+
+// stopImageMigration interrupts a running migration and waits for it to
+// record its progress. Its status stays running, so it resumes on the next
+// start. The caller must not hold c.mu.
+func (c *Controller) stopImageMigration() {
+	c.mu.Lock()
+	cancel, done := c.migrationCancel, c.migrationDone
+	c.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// resumeImageMigration restarts a migration the last shutdown interrupted.
+func (c *Controller) resumeImageMigration() {
+	m := c.storage.ImageMigration
+	if m == nil || m.Status != ImageMigrationRunning {
+		return
+	}
+	if c.migrationTarget == nil {
+		slog.Warn("Not resuming image migration: no target bucket is configured")
+		return
+	}
+	slog.Info("Resuming image migration", "copied", len(m.Copied))
+	c.startImageMigration()
+}
+
+func (c *Controller) runImageMigration(ctx context.Context) {
+	images, err := c.images.List(ctx)
+	if err != nil {
+		c.finishImageMigration(ctx, fmt.Errorf("listing images: %w", err))
+		return
+	}
+
+	c.mu.Lock()
+	m := c.storage.ImageMigration
+	m.Total = len(images)
+	if m.Copied == nil {
+		m.Copied = make(map[string]string)
+	}
+	if m.Failed == nil {
+		m.Failed = make(map[string]string)
+	}
+	copied := make(map[string]bool, len(m.Copied))
+	for key := range m.Copied {
+		copied[key] = true
+	}
+	c.mu.Unlock()
+
+	unsaved := 0
+	for _, img := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		if copied[img.Key] {
+			continue
+		}
+
+		sum, err := c.copyImage(ctx, img.Key)
+		if ctx.Err() != nil {
+			break
+		}
+
+		c.mu.Lock()
+		if err != nil {
+			slog.Error("Failed to migrate image", "key", img.Key, "err", err)
+			m.Failed[img.Key] = err.Error()
+		} else {
+			delete(m.Failed, img.Key)
+			m.Copied[img.Key] = sum
+			m.Bytes += img.Size
+		}
+		m.UpdatedAt = time.Now()
+		if unsaved++; unsaved >= imageMigrationSaveEvery {
+			if err := c.saveData(); err != nil {
+				slog.Error("Failed to save data", "err", err)
+			}
+			unsaved = 0
+		}
+		c.mu.Unlock()
+	}
+
+	c.finishImageMigration(ctx, nil)
+}
+
+// finishImageMigration records the outcome of a pass. An interrupted pass
+// keeps its status.
+func (c *Controller) finishImageMigration(ctx context.Context, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := c.storage.ImageMigration
+	now := time.Now()
+	m.UpdatedAt = now
+	switch {
+	case ctx.Err() != nil:
+	case err != nil:
+		m.Status, m.Error = ImageMigrationFailed, err.Error()
+	case len(m.Failed) > 0:
+		m.Status, m.Error = ImageMigrationFailed, fmt.Sprintf("%d images failed to copy", len(m.Failed))
+	default:
+		m.Status, m.Error = ImageMigrationCompleted, ""
+		m.CompletedAt = &now
+		slog.Info("Image migration completed", "images", len(m.Copied), "bytes", m.Bytes)
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to save data", "err", err)
+	}
+}
+
+// copyImage copies key to the migration target and reads it back to verify
+// the checksum, which it returns.
+func (c *Controller) copyImage(ctx context.Context, key string) (string, error) {
+	data, err := readImage(ctx, c.images, key)
+	if err != nil {
+		return "", fmt.Errorf("reading source: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	if err := c.migrationTarget.Put(ctx, key, data, http.DetectContentType(data)); err != nil {
+		return "", fmt.Errorf("writing target: %w", err)
+	}
+
+	copied, err := readImage(ctx, c.migrationTarget, key)
+	if err != nil {
+		return "", fmt.Errorf("reading target: %w", err)
+	}
+	copiedSum := sha256.Sum256(copied)
+	if hex.EncodeToString(copiedSum[:]) != checksum {
+		return "", fmt.Errorf("checksum mismatch")
+	}
+	return checksum, nil
+}
+
+func readImage(ctx context.Context, store ImageStore, key string) ([]byte, error) {
+	rc, _, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// AdminImageMigration handles /admin/images/migration: GET shows progress,
+// POST starts or resumes copying local images to the S3 bucket and DELETE
+// pauses it. Image URLs are /images/{key} whichever store serves them, so
+// once it has completed, restarting with IMAGE_STORE=s3 switches over.
+func (c *Controller) AdminImageMigration(w http.ResponseWriter, r *http.Request) {
+	m := c.storage.ImageMigration
+
+	switch r.Method {
+	case http.MethodGet:
+		if m == nil {
+			writeError(w, http.StatusNotFound, "IMAGE_MIGRATION_NOT_FOUND", "No image migration has been started")
+			return
+		}
+		writeJSON(w, http.StatusOK, m.status())
+
+	case http.MethodPost:
+		if c.migrationTarget == nil {
+			writeError(w, http.StatusConflict, "IMAGE_MIGRATION_UNAVAILABLE", "Image migration needs the local image store and an S3 bucket")
+			return
+		}
+		if c.migrationCancel != nil {
+			writeError(w, http.StatusConflict, "IMAGE_MIGRATION_RUNNING", "Image migration is already running")
+			return
+		}
+
+		now := time.Now()
+		if m == nil {
+			m = &ImageMigration{StartedAt: now}
+			c.storage.ImageMigration = m
+		}
+		// Failed keys are retried.
+		m.Status, m.Error, m.CompletedAt, m.UpdatedAt = ImageMigrationRunning, "", nil, now
+		m.Failed = nil
+
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+		c.startImageMigration()
+		writeJSON(w, http.StatusAccepted, m.status())
+
+	case http.MethodDelete:
+		if m == nil || c.migrationCancel == nil {
+			writeError(w, http.StatusConflict, "IMAGE_MIGRATION_NOT_RUNNING", "Image migration is not running")
+			return
+		}
+		m.Status, m.UpdatedAt = ImageMigrationPaused, time.Now()
+		c.migrationCancel()
+
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+		writeJSON(w, http.StatusOK, m.status())
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+	}
+}
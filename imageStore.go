@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ImageURLsProxy  = "proxy"
+	ImageURLsSigned = "signed"
+
+	signedImageURLTTL = 15 * time.Minute
+)
+
+var errImageNotFound = errors.New("image not found")
+
+// ImageInfo describes a stored image. Keys are slash-separated, e.g.
+// "abc.jpg" for an upload and "thumb/128/abc.jpg" for its thumbnail.
+type ImageInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// ImageStore keeps uploaded images and their thumbnails. Open, Stat and
+// Delete of a missing key return errImageNotFound, except Delete which
+// treats it as success.
+type ImageStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, ImageInfo, error)
+	Stat(ctx context.Context, key string) (ImageInfo, error)
+	Delete(ctx context.Context, key string) error
+	// List returns every stored key, including thumbnails.
+	List(ctx context.Context) ([]ImageInfo, error)
+	// Check reports whether the store is reachable, for readiness probes.
+	Check(ctx context.Context) error
+}
+
+// ImageURLSigner is implemented by stores that can hand out time-limited
+// URLs, letting clients download images without going through the server.
+type ImageURLSigner interface {
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+func imageStoreFromConfig(cfg Config) (ImageStore, error) {
+	switch cfg.ImageStore {
+	case "", "local":
+		return NewLocalImageStore(cfg.ImageDir)
+	case "s3":
+		return NewS3ImageStore(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKeyID, cfg.S3SecretAccessKey)
+	default:
+		return nil, fmt.Errorf("unknown image store %q", cfg.ImageStore)
+	}
+}
+
+// LocalImageStore keeps images in a directory on local disk. It only works
+// while a single instance serves the images.
+type LocalImageStore struct {
+	dir string
+}
+
+func NewLocalImageStore(dir string) (*LocalImageStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create image directory: %w", err)
+	}
+	return &LocalImageStore{dir: dir}, nil
+}
+
+func (s *LocalImageStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(cleanImageKey(key)))
+}
+
+func (s *LocalImageStore) Put(_ context.Context, key string, data []byte, _ string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *LocalImageStore) Open(_ context.Context, key string) (io.ReadCloser, ImageInfo, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ImageInfo{}, errImageNotFound
+	}
+	if err != nil {
+		return nil, ImageInfo{}, err
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, ImageInfo{}, errImageNotFound
+	}
+	return f, ImageInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *LocalImageStore) Stat(_ context.Context, key string) (ImageInfo, error) {
+	info, err := os.Stat(s.path(key))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.IsDir()) {
+		return ImageInfo{}, errImageNotFound
+	}
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	return ImageInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *LocalImageStore) Delete(_ context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalImageStore) List(_ context.Context) ([]ImageInfo, error) {
+	var images []ImageInfo
+	err := filepath.WalkDir(s.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		images = append(images, ImageInfo{Key: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return images, err
+}
+
+func (s *LocalImageStore) Check(context.Context) error {
+	info, err := os.Stat(s.dir)
+	if err != nil {
+		return fmt.Errorf("image directory is missing: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("image directory is not a directory")
+	}
+	return nil
+}
+
+// cleanImageKey keeps keys inside the store, dropping any ".." elements.
+func cleanImageKey(key string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+key)), "/")
+}
+
+// imageKey returns the store key of an /images/ URL.
+func imageKey(imageURL string) string {
+	return filepath.Base(imageURL)
+}
+
+// ServeImage handles GET /images/{name}. Images are proxied through the
+// server, or with signed image URLs enabled, clients are redirected to a
+// short-lived URL on the store. Either way a smaller format is picked when
+// the client accepts one we can encode.
+func (c *Controller) ServeImage(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/images/")
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	c.serveImage(w, r, name)
+}
+
+func (c *Controller) serveImage(w http.ResponseWriter, r *http.Request, key string) {
+	key = c.negotiateImage(w, r, key)
+
+	if signer, ok := c.images.(ImageURLSigner); ok && c.imageURLs == ImageURLsSigned {
+		url, err := signer.SignedURL(key, signedImageURLTTL)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "IMAGE_LOAD_FAILED", "Failed to load image")
+			return
+		}
+		// Let clients reuse the redirect for part of the URL's lifetime.
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(signedImageURLTTL.Seconds())/2))
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	rc, info, err := c.images.Open(r.Context(), key)
+	if errors.Is(err, errImageNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "IMAGE_LOAD_FAILED", "Failed to load image")
+		return
+	}
+	defer rc.Close()
+
+	// ServeContent answers If-None-Match and If-Modified-Since with 304.
+	w.Header().Set("ETag", imageETag(info))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(imageMaxAge.Seconds())))
+
+	content, ok := rc.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "IMAGE_LOAD_FAILED", "Failed to load image")
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	http.ServeContent(w, r, key, info.ModTime, content)
+}
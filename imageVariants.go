@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ImageEncoder encodes images into a format that can be offered to clients
+// in place of the original, such as WebP or AVIF.
+type ImageEncoder interface {
+	ContentType() string
+	Extension() string
+	// Improves reports whether a variant of the image stored under key is
+	// expected to be smaller than the original.
+	Improves(key string) bool
+	Encode(w io.Writer, img image.Image) error
+}
+
+// imageVariantEncoders lists the encoders used for content negotiation, most
+// preferred first. There is no AVIF encoder yet.
+var imageVariantEncoders = []ImageEncoder{webpEncoder{}}
+
+// acceptsMediaType reports whether the Accept header explicitly lists
+// mediaType with a non-zero quality. Wildcards don't count: clients sending
+// */* may not support newer formats.
+func acceptsMediaType(accept, mediaType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || typ != mediaType {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err != nil || v <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// variantKey keeps the variant's extension last so it is served with the
+// right content type.
+func variantKey(enc ImageEncoder, key string) string {
+	return "variants/" + key + enc.Extension()
+}
+
+// negotiateImage returns the key of the best variant of key the client
+// accepts, converting and storing it on first use. It falls back to key when
+// no encoder matches, none would shrink the image or conversion fails.
+func (c *Controller) negotiateImage(w http.ResponseWriter, r *http.Request, key string) string {
+	if len(imageVariantEncoders) == 0 {
+		return key
+	}
+	w.Header().Add("Vary", "Accept")
+
+	accept := r.Header.Get("Accept")
+	for _, enc := range imageVariantEncoders {
+		if !enc.Improves(key) || !acceptsMediaType(accept, enc.ContentType()) {
+			continue
+		}
+
+		variant := variantKey(enc, key)
+		size, err := c.variantSize(r.Context(), key, variant, enc)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Failed to convert image", "key", key, "type", enc.ContentType(), "err", err)
+			return key
+		}
+		// A variant that came out larger is kept, so it is not converted
+		// again, but never served.
+		if orig, err := c.images.Stat(r.Context(), key); err == nil && size < orig.Size {
+			return variant
+		}
+	}
+	return key
+}
+
+// variantSize returns the size of the stored variant, converting key first
+// if there is none yet.
+func (c *Controller) variantSize(ctx context.Context, key, variant string, enc ImageEncoder) (int64, error) {
+	if info, err := c.images.Stat(ctx, variant); err == nil {
+		return info.Size, nil
+	}
+	return c.convertImage(ctx, key, variant, enc)
+}
+
+func (c *Controller) convertImage(ctx context.Context, key, variant string, enc ImageEncoder) (int64, error) {
+	rc, _, err := c.images.Open(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkImageSize(data); err != nil {
+		return 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, img); err != nil {
+		return 0, err
+	}
+	return int64(buf.Len()), c.images.Put(ctx, variant, buf.Bytes(), enc.ContentType())
+}
+
+// removeImageVariants deletes the converted variants of name and of its
+// thumbnails.
+func (c *Controller) removeImageVariants(name string) {
+	keys := []string{imageKey(name)}
+	for _, size := range thumbnailSizes {
+		keys = append(keys, thumbnailKey(size, name))
+	}
+
+	for _, enc := range imageVariantEncoders {
+		for _, key := range keys {
+			key = variantKey(enc, key)
+			if err := c.images.Delete(context.Background(), key); err != nil {
+				slog.Error("Failed to remove image variant", "key", key, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultInboxLimit = 20
+	maxInboxLimit     = 100
+	// maxInboxSize caps stored notifications per user; older ones are dropped.
+	maxInboxSize = 200
+)
+
+// InboxNotification is a notification kept in the user's in-app inbox.
+type InboxNotification struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	Notification
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+type InboxPage struct {
+	Notifications []InboxNotification `json:"notifications"`
+	Unread        int                 `json:"unread"`
+	NextCursor    string              `json:"nextCursor,omitempty"`
+}
+
+// storeNotification adds n to userID's inbox. The caller must hold c.mu and
+// save data.
+func (c *Controller) storeNotification(userID string, n Notification) {
+	c.storage.Notifications = append(c.storage.Notifications, InboxNotification{
+		ID:           newID(),
+		UserID:       userID,
+		Notification: n,
+		CreatedAt:    time.Now(),
+	})
+
+	count := 0
+	for _, stored := range c.storage.Notifications {
+		if stored.UserID == userID {
+			count++
+		}
+	}
+	if count <= maxInboxSize {
+		return
+	}
+
+	// Notifications are stored oldest first, so drop from the front.
+	drop := count - maxInboxSize
+	kept := c.storage.Notifications[:0]
+	for _, stored := range c.storage.Notifications {
+		if stored.UserID == userID && drop > 0 {
+			drop--
+			continue
+		}
+		kept = append(kept, stored)
+	}
+	c.storage.Notifications = kept
+}
+
+// Inbox handles GET /api/notifications?userId=&limit=&cursor=, newest first.
+func (c *Controller) Inbox(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "USER_ID_REQUIRED", "User ID is required")
+		return
+	}
+	c.listInbox(w, r, userID)
+}
+
+// ReadAllNotifications handles POST /api/notifications/read-all?userId=.
+func (c *Controller) ReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "USER_ID_REQUIRED", "User ID is required")
+		return
+	}
+
+	now := time.Now()
+	for i, n := range c.storage.Notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			c.storage.Notifications[i].ReadAt = &now
+		}
+	}
+	c.saveInbox(w, r)
+}
+
+// ReadNotification handles POST /api/notifications/{id}/read?userId=.
+func (c *Controller) ReadNotification(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "USER_ID_REQUIRED", "User ID is required")
+		return
+	}
+
+	found := false
+	for i, n := range c.storage.Notifications {
+		if n.ID == r.PathValue("id") && n.UserID == userID {
+			if n.ReadAt == nil {
+				now := time.Now()
+				c.storage.Notifications[i].ReadAt = &now
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "NOTIFICATION_NOT_FOUND", "Notification not found")
+		return
+	}
+	c.saveInbox(w, r)
+}
+
+func (c *Controller) saveInbox(w http.ResponseWriter, r *http.Request) {
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Controller) listInbox(w http.ResponseWriter, r *http.Request, userID string) {
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"), defaultInboxLimit)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_LIMIT", "Invalid limit")
+		return
+	}
+	limit = min(limit, maxInboxLimit)
+
+	page := InboxPage{Notifications: []InboxNotification{}}
+	cursor := query.Get("cursor")
+	collecting := cursor == ""
+
+	for i := len(c.storage.Notifications) - 1; i >= 0; i-- {
+		n := c.storage.Notifications[i]
+		if n.UserID != userID {
+			continue
+		}
+		if n.ReadAt == nil {
+			page.Unread++
+		}
+
+		if !collecting {
+			collecting = n.ID == cursor
+			continue
+		}
+		if len(page.Notifications) < limit {
+			page.Notifications = append(page.Notifications, n)
+		} else if page.NextCursor == "" {
+			page.NextCursor = page.Notifications[len(page.Notifications)-1].ID
+		}
+	}
+
+	if !collecting {
+		writeError(w, http.StatusBadRequest, "INVALID_CURSOR", "Invalid cursor")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const journalCompactInterval = 5 * time.Minute
+
+// JournalEntry is one line of the swipe journal: the swipe as stored after
+// the request and, when it made a match, the new match.
+type JournalEntry struct {
+	Swipe Swipe  `json:"swipe"`
+	Match *Match `json:"match,omitempty"`
+}
+
+// Journal is an append-only log of swipes written next to the data file.
+// Swipes are the most frequent write, so instead of rewriting the whole
+// snapshot they are appended and synced here, and folded into the snapshot
+// by the next full save.
+type Journal struct {
+	f       *os.File
+	entries int
+}
+
+func (c *Controller) journalPath() string {
+	return c.dataFile + ".journal"
+}
+
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{f: f}, nil
+}
+
+// Append writes line and syncs it to disk.
+func (j *Journal) Append(line []byte) error {
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.entries++
+	return nil
+}
+
+// Reset empties the journal once its entries are part of a snapshot.
+func (j *Journal) Reset() error {
+	if j.entries == 0 {
+		return nil
+	}
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.entries = 0
+	return nil
+}
+
+func (j *Journal) Len() int {
+	return j.entries
+}
+
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// journalSwipe records a swipe and the match it made. The caller must hold
+// c.mu.
+func (c *Controller) journalSwipe(swipe Swipe, match *Match) error {
+	line, err := json.Marshal(JournalEntry{Swipe: swipe, Match: match})
+	if err != nil {
+		return fmt.Errorf("marshaling journal entry: %w", err)
+	}
+
+	sealed, err := c.encodeStorage(line)
+	if err != nil {
+		return fmt.Errorf("encrypting journal entry: %w", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, sealed); err != nil {
+		return fmt.Errorf("encrypting journal entry: %w", err)
+	}
+
+	if err := c.faults.StorageWrite(); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	if err := c.journal.Append(compact.Bytes()); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	return nil
+}
+
+// replayJournal applies the journal on top of the loaded snapshot. Entries
+// are upserts, so replaying ones the snapshot already contains, after a
+// crash between a save and the journal reset, is harmless. A torn last line
+// from a crash mid-append is skipped.
+func (c *Controller) replayJournal(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line, err := c.decodeStorage(scanner.Bytes())
+		if err != nil {
+			return replayed, fmt.Errorf("decrypting journal entry: %w", err)
+		}
+
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			slog.Warn("Skipping unreadable journal entry", "err", err)
+			continue
+		}
+		c.storage.applySwipe(entry.Swipe)
+		if entry.Match != nil {
+			c.storage.applyMatch(*entry.Match)
+		}
+		replayed++
+	}
+	return replayed, scanner.Err()
+}
+
+func (s *Storage) applySwipe(swipe Swipe) {
+	for i, existing := range s.Swipes {
+		if existing.SwiperID == swipe.SwiperID && existing.TargetID == swipe.TargetID {
+			s.Swipes[i] = swipe
+			return
+		}
+	}
+	s.Swipes = append(s.Swipes, swipe)
+}
+
+func (s *Storage) applyMatch(match Match) {
+	for _, existing := range s.Matches {
+		if existing.Has(match.User1ID) && existing.Has(match.User2ID) {
+			return
+		}
+	}
+	s.Matches = append(s.Matches, match)
+}
+
+// openJournal replays the journal left by the previous run into storage and
+// opens it for appending. A journal that can't be applied, because the
+// snapshot was lost or it can't be decrypted, is set aside rather than
+// replayed onto the wrong data.
+func (c *Controller) openJournal() error {
+	path := c.journalPath()
+
+	replayed := 0
+	if c.storageLoaded {
+		var err error
+		if replayed, err = c.replayJournal(path); err != nil {
+			slog.Error("Failed to replay swipe journal", "err", err)
+			quarantineFile(path)
+		}
+	} else if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		slog.Warn("Setting aside swipe journal without a snapshot", "path", path)
+		quarantineFile(path)
+	}
+
+	journal, err := OpenJournal(path)
+	if err != nil {
+		return err
+	}
+	c.journal = journal
+	if !c.storageLoaded {
+		// Journaled swipes need a snapshot to be replayed onto, so write the
+		// defaults out before accepting any.
+		return c.saveData()
+	}
+	if replayed == 0 {
+		// Drop unreadable leftovers.
+		return journal.f.Truncate(0)
+	}
+
+	// Fold the replayed entries into the snapshot right away so the journal
+	// starts empty.
+	slog.Info("Replayed swipe journal", "entries", replayed)
+	c.journal.entries = replayed
+	return c.saveData()
+}
+
+// compactJournal writes a full snapshot when swipes have been journaled
+// since the last save, which also empties the journal.
+func (c *Controller) compactJournal() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal.Len() == 0 {
+		return
+	}
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to compact swipe journal", "err", err)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Keyring holds every known storage key by version. New writes always use
+// the active key; older versions are kept so existing data can still be
+// decrypted and lazily re-encrypted on the next save.
+type Keyring struct {
+	keys   map[int][]byte
+	active int
+}
+
+// encryptedEnvelope is the on-disk format of an encrypted storage file.
+type encryptedEnvelope struct {
+	Encrypted  bool   `json:"encrypted"`
+	KeyVersion int    `json:"keyVersion"`
+	Nonce      string `json:"nonce"`
+	Data       string `json:"data"`
+}
+
+// ParseKeyring parses "version:base64key" pairs separated by commas. The
+// active key is activeVersion, or the highest version when it is zero.
+func ParseKeyring(spec string, activeVersion int) (*Keyring, error) {
+	k := &Keyring{keys: make(map[int][]byte)}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		versionStr, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("key entry %q must be version:base64key", entry)
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid key version %q", versionStr)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key version %d must be 32 bytes of base64", version)
+		}
+
+		k.keys[version] = key
+		if version > k.active {
+			k.active = version
+		}
+	}
+
+	if len(k.keys) == 0 {
+		return nil, errors.New("no storage keys configured")
+	}
+
+	if activeVersion != 0 {
+		if _, ok := k.keys[activeVersion]; !ok {
+			return nil, fmt.Errorf("active key version %d is not configured", activeVersion)
+		}
+		k.active = activeVersion
+	}
+
+	return k, nil
+}
+
+// keyringFromEnv reads STORAGE_KEYS and STORAGE_ACTIVE_KEY. It returns nil
+// when storage encryption is disabled.
+func keyringFromEnv() (*Keyring, error) {
+	spec := os.Getenv("STORAGE_KEYS")
+	if spec == "" {
+		return nil, nil
+	}
+
+	active := 0
+	if v := os.Getenv("STORAGE_ACTIVE_KEY"); v != "" {
+		var err error
+		if active, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid STORAGE_ACTIVE_KEY: %w", err)
+		}
+	}
+
+	return ParseKeyring(spec, active)
+}
+
+func (k *Keyring) Active() int {
+	return k.active
+}
+
+func (k *Keyring) aead(version int) (cipher.AEAD, error) {
+	key, ok := k.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown key version %d", version)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	aead, err := k.aead(k.active)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	return json.MarshalIndent(encryptedEnvelope{
+		Encrypted:  true,
+		KeyVersion: k.active,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Data:       base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, nil)),
+	}, "", "  ")
+}
+
+// Open decrypts an envelope produced by Seal with any known key version.
+func (k *Keyring) Open(env encryptedEnvelope) ([]byte, error) {
+	aead, err := k.aead(env.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("decoding nonce: %w", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding data: %w", err)
+	}
+
+	return aead.Open(nil, nonce, data, nil)
+}
+
+// decodeStorage returns the plaintext storage JSON, decrypting it when the
+// file is an encrypted envelope.
+func (c *Controller) decodeStorage(data []byte) ([]byte, error) {
+	var env encryptedEnvelope
+	if err := json.Unmarshal(data, &env); err != nil || !env.Encrypted {
+		return data, nil
+	}
+
+	if c.keyring == nil {
+		return nil, errors.New("data file is encrypted but no storage keys are configured")
+	}
+
+	c.storageKeyVersion = env.KeyVersion
+	return c.keyring.Open(env)
+}
+
+func (c *Controller) encodeStorage(plaintext []byte) ([]byte, error) {
+	if c.keyring == nil {
+		return plaintext, nil
+	}
+
+	sealed, err := c.keyring.Seal(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	c.storageKeyVersion = c.keyring.Active()
+	return sealed, nil
+}
+
+// reencryptBackups rewrites the backups of the data file that are not yet
+// sealed with the active key and returns how many it rewrote, so retiring
+// an old key does not make them unreadable.
+func (c *Controller) reencryptBackups() (int, error) {
+	rewritten := 0
+	for _, path := range c.backups() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return rewritten, fmt.Errorf("reading backup: %w", err)
+		}
+
+		plaintext := data
+		var env encryptedEnvelope
+		if json.Unmarshal(data, &env) == nil && env.Encrypted {
+			if env.KeyVersion == c.keyring.Active() {
+				continue
+			}
+			if plaintext, err = c.keyring.Open(env); err != nil {
+				return rewritten, fmt.Errorf("decrypting backup %s: %w", filepath.Base(path), err)
+			}
+		}
+
+		sealed, err := c.keyring.Seal(plaintext)
+		if err != nil {
+			return rewritten, fmt.Errorf("encrypting backup: %w", err)
+		}
+		if err := writeFileAtomic(path, sealed, 0644); err != nil {
+			return rewritten, fmt.Errorf("writing backup: %w", err)
+		}
+		rewritten++
+	}
+	return rewritten, nil
+}
+
+// AdminStorageEncryption reports the key version of the data file on GET. On
+// POST it re-encrypts the data file and its backups with the active key; the
+// save also folds in and empties the swipe journal, so afterwards no file
+// needs an older key.
+func (c *Controller) AdminStorageEncryption(w http.ResponseWriter, r *http.Request) {
+	if c.keyring == nil {
+		writeError(w, http.StatusConflict, "ENCRYPTION_NOT_CONFIGURED", "Storage encryption is not configured")
+		return
+	}
+
+	result := map[string]int{}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "REENCRYPTION_FAILED", "Failed to re-encrypt data")
+			return
+		}
+		// Backups are rewritten after the save, which may have just
+		// taken one with the old key.
+		n, err := c.reencryptBackups()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to re-encrypt backups", "err", err)
+			writeError(w, http.StatusInternalServerError, "REENCRYPTION_FAILED", "Failed to re-encrypt backups")
+			return
+		}
+		result["reencryptedBackups"] = n
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	result["activeKeyVersion"] = c.keyring.Active()
+	result["storageKeyVersion"] = c.storageKeyVersion
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minLanguageLetters is how many letters a text needs before its language
+// is guessed at all.
+const minLanguageLetters = 4
+
+// languageScripts maps scripts used by a single language to its ISO 639-1
+// code. Cyrillic and Latin are shared and handled separately.
+var languageScripts = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// cyrillicLetters are letters that only occur in one Cyrillic language.
+// Belarusian and Kazakh are listed before Ukrainian, which shares their і.
+var cyrillicLetters = []struct {
+	letters string
+	lang    string
+}{
+	{"ў", "be"},
+	{"қңғүұөәһ", "kk"},
+	{"їєґі", "uk"},
+	{"ђјљњћџ", "sr"},
+}
+
+// latinWords are frequent words, including a few training ones, of the
+// Latin-script languages bios are written in.
+var latinWords = map[string][]string{
+	"en": {"the", "and", "i", "i'm", "my", "for", "to", "with", "in", "looking", "partner", "love", "workout", "training", "gym", "morning", "evening", "weekend"},
+	"de": {"und", "ich", "der", "die", "das", "mit", "für", "suche", "nicht", "ein", "eine", "gerne", "training", "morgens", "abends"},
+	"es": {"el", "la", "y", "de", "que", "con", "para", "busco", "compañero", "entrenar", "gimnasio", "mañana", "tarde", "los", "las"},
+	"fr": {"le", "la", "et", "je", "de", "avec", "pour", "cherche", "partenaire", "salle", "matin", "soir", "les", "des", "un", "une"},
+	"it": {"il", "e", "di", "con", "per", "cerco", "allenamento", "palestra", "mattina", "sera", "sono", "che", "gli", "una"},
+	"pt": {"o", "e", "de", "com", "para", "procuro", "treino", "academia", "manhã", "noite", "que", "eu", "os", "uma"},
+	"tr": {"ve", "bir", "ben", "için", "ile", "arıyorum", "spor", "salonu", "sabah", "akşam", "antrenman"},
+	"pl": {"i", "w", "z", "na", "szukam", "siłownia", "trening", "rano", "wieczorem", "się", "jestem"},
+}
+
+// latinLetters are letters that point to a single Latin-script language.
+var latinLetters = map[string]string{
+	"ß": "de", "ä": "de",
+	"ñ": "es",
+	"ç": "fr", "è": "fr", "ê": "fr",
+	"ã": "pt", "õ": "pt",
+	"ğ": "tr", "ş": "tr", "ı": "tr",
+	"ą": "pl", "ę": "pl", "ł": "pl", "ś": "pl", "ż": "pl",
+}
+
+// detectLanguage guesses the ISO 639-1 language of a short text such as a
+// bio, or returns "" when it cannot tell. Scripts used by one language
+// decide on their own; Cyrillic is told apart by its distinctive letters
+// and Latin by frequent words.
+func detectLanguage(text string) string {
+	text = strings.ToLower(text)
+
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["cyrillic"]++
+		case unicode.Is(unicode.Latin, r):
+			counts["latin"]++
+		default:
+			for _, s := range languageScripts {
+				if unicode.Is(s.script, r) {
+					counts[s.lang]++
+					break
+				}
+			}
+		}
+	}
+	if letters < minLanguageLetters {
+		return ""
+	}
+
+	script, best := "", 0
+	for s, n := range counts {
+		if n > best || n == best && s < script {
+			script, best = s, n
+		}
+	}
+	// Japanese mixes kana into Han text.
+	if script == "zh" && counts["ja"] > 0 {
+		script = "ja"
+	}
+
+	switch script {
+	case "cyrillic":
+		return detectCyrillic(text)
+	case "latin":
+		return detectLatin(text)
+	}
+	return script
+}
+
+func detectCyrillic(text string) string {
+	for _, l := range cyrillicLetters {
+		if strings.ContainsAny(text, l.letters) {
+			return l.lang
+		}
+	}
+	return "ru"
+}
+
+func detectLatin(text string) string {
+	scores := make(map[string]int)
+	for letter, lang := range latinLetters {
+		if strings.Contains(text, letter) {
+			scores[lang] += 2
+		}
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, word := range words {
+		for lang, common := range latinWords {
+			for _, w := range common {
+				if word == w {
+					scores[lang]++
+					break
+				}
+			}
+		}
+	}
+
+	lang, best := "", 0
+	for l, n := range scores {
+		if n > best || n == best && l < lang {
+			lang, best = l, n
+		}
+	}
+	return lang
+}
+
+// migrateBioLanguages detects the bio language of profiles saved before it
+// was stored.
+func (s *Storage) migrateBioLanguages() {
+	for i, u := range s.Users {
+		if u.BioLang == "" && u.TextInfo != "" {
+			s.Users[i].BioLang = detectLanguage(u.TextInfo)
+		}
+	}
+}
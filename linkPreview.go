@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	linkPreviewTimeout  = 5 * time.Second
+	linkPreviewMaxBytes = 512 << 10
+	linkPreviewCacheTTL = 6 * time.Hour
+	// linkPreviewCacheSize caps the cache; once full, the oldest preview
+	// makes room for a new one.
+	linkPreviewCacheSize     = 1000
+	linkPreviewPurgeInterval = time.Hour
+)
+
+var (
+	urlPattern     = regexp.MustCompile(`https?://[^\s<>"]+`)
+	metaTagPattern = regexp.MustCompile(`(?is)<meta\s+[^>]*>`)
+	metaAttrRegexp = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("([^"]*)"|'([^']*)')`)
+	titlePattern   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+	errLinkNotAllowed = errors.New("link domain is not allowed")
+
+	// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+	// net.IP.IsPrivate leaves out but is just as internal.
+	sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+)
+
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+	SiteName    string `json:"siteName,omitempty"`
+}
+
+type cachedPreview struct {
+	preview   *LinkPreview
+	fetchedAt time.Time
+}
+
+// LinkPreviewer fetches OpenGraph metadata for links posted in chat. Requests
+// to private, loopback, link-local and carrier-grade NAT addresses are
+// refused at dial time so users cannot probe the internal network through
+// the server.
+type LinkPreviewer struct {
+	AllowDomains []string
+	DenyDomains  []string
+
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedPreview
+}
+
+func NewLinkPreviewer(allow, deny []string) *LinkPreviewer {
+	dialer := &net.Dialer{
+		Timeout: linkPreviewTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("refusing to connect to %s", host)
+			}
+			return nil
+		},
+	}
+
+	p := &LinkPreviewer{
+		AllowDomains: allow,
+		DenyDomains:  deny,
+		cache:        make(map[string]cachedPreview),
+	}
+	p.client = &http.Client{
+		Timeout:   linkPreviewTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("too many redirects")
+			}
+			if !p.domainAllowed(req.URL.Hostname()) {
+				return errLinkNotAllowed
+			}
+			return nil
+		},
+	}
+
+	return p
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+func domainMatches(host, domain string) bool {
+	host = strings.ToLower(host)
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func (p *LinkPreviewer) domainAllowed(host string) bool {
+	for _, d := range p.DenyDomains {
+		if domainMatches(host, d) {
+			return false
+		}
+	}
+
+	if len(p.AllowDomains) == 0 {
+		return true
+	}
+
+	for _, d := range p.AllowDomains {
+		if domainMatches(host, d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FirstURL returns the first http(s) link found in text.
+func FirstURL(text string) string {
+	return urlPattern.FindString(text)
+}
+
+// Preview returns the cached preview for rawURL or fetches it.
+func (p *LinkPreviewer) Preview(ctx context.Context, rawURL string) (*LinkPreview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid link %q", rawURL)
+	}
+
+	if !p.domainAllowed(u.Hostname()) {
+		return nil, errLinkNotAllowed
+	}
+
+	p.mu.Lock()
+	cached, ok := p.cache[rawURL]
+	p.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < linkPreviewCacheTTL {
+		return cached.preview, nil
+	}
+
+	preview, err := p.fetch(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if _, ok := p.cache[rawURL]; !ok && len(p.cache) >= linkPreviewCacheSize {
+		p.evictOldest()
+	}
+	p.cache[rawURL] = cachedPreview{preview: preview, fetchedAt: time.Now()}
+	p.mu.Unlock()
+
+	return preview, nil
+}
+
+// evictOldest drops the preview fetched longest ago. The caller must hold
+// p.mu.
+func (p *LinkPreviewer) evictOldest() {
+	var oldest string
+	for link, cached := range p.cache {
+		if oldest == "" || cached.fetchedAt.Before(p.cache[oldest].fetchedAt) {
+			oldest = link
+		}
+	}
+	delete(p.cache, oldest)
+}
+
+// Purge drops previews older than the cache TTL.
+func (p *LinkPreviewer) Purge() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for link, cached := range p.cache {
+		if time.Since(cached.fetchedAt) >= linkPreviewCacheTTL {
+			delete(p.cache, link)
+		}
+	}
+}
+
+func (p *LinkPreviewer) fetch(ctx context.Context, rawURL string) (*LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	req.Header.Set("User-Agent", "GymBroLinkPreview/1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching link: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching link: status %d", resp.StatusCode)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return &LinkPreview{URL: rawURL}, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, linkPreviewMaxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading link body: %w", err)
+	}
+
+	return parseOpenGraph(rawURL, string(body)), nil
+}
+
+func parseOpenGraph(rawURL, page string) *LinkPreview {
+	preview := &LinkPreview{URL: rawURL}
+
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		var key, content string
+		for _, attr := range metaAttrRegexp.FindAllStringSubmatch(tag, -1) {
+			value := attr[3] + attr[4]
+			switch strings.ToLower(attr[1]) {
+			case "property", "name":
+				key = strings.ToLower(value)
+			case "content":
+				content = html.UnescapeString(value)
+			}
+		}
+
+		switch key {
+		case "og:title":
+			preview.Title = content
+		case "og:description":
+			preview.Description = content
+		case "og:image":
+			preview.ImageURL = content
+		case "og:site_name":
+			preview.SiteName = content
+		case "description":
+			if preview.Description == "" {
+				preview.Description = content
+			}
+		}
+	}
+
+	if preview.Title == "" {
+		if m := titlePattern.FindStringSubmatch(page); m != nil {
+			preview.Title = strings.TrimSpace(html.UnescapeString(m[1]))
+		}
+	}
+
+	return preview
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemdListenFDsStart is the first file descriptor passed by systemd socket
+// activation.
+const systemdListenFDsStart = 3
+
+// Listen opens the listener for addr. Besides TCP addresses it accepts
+// "unix:/path/to.sock" for a Unix domain socket and "systemd" for the first
+// socket inherited through systemd socket activation.
+func Listen(addr string) (net.Listener, error) {
+	switch {
+	case addr == "systemd":
+		return systemdListener()
+
+	case strings.HasPrefix(addr, "unix:"):
+		path := strings.TrimPrefix(addr, "unix:")
+		// A socket left behind by a previous run would make Listen fail.
+		if info, err := os.Stat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		// Let a reverse proxy running as another user in our group connect.
+		if err := os.Chmod(path, 0660); err != nil {
+			ln.Close()
+			return nil, err
+		}
+		return ln, nil
+
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets passed by systemd")
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.New("no sockets passed by systemd")
+	}
+	if n > 1 {
+		return nil, fmt.Errorf("expected one socket from systemd, got %d", n)
+	}
+
+	f := os.NewFile(systemdListenFDsStart, "systemd-socket")
+	defer f.Close()
+	return net.FileListener(f)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var defaultMaintenanceMessages = map[string]string{
+	"ru": "Идут технические работы. Скоро вернёмся!",
+	"en": "We're down for maintenance. Back soon!",
+}
+
+type MaintenanceState struct {
+	Enabled  bool              `json:"enabled"`
+	Messages map[string]string `json:"messages,omitempty"`
+	EndsAt   *time.Time        `json:"endsAt,omitempty"`
+}
+
+type maintenanceDetails struct {
+	EstimatedEnd *time.Time `json:"estimatedEnd,omitempty"`
+}
+
+// message picks the message for the first supported language in the
+// Accept-Language header, falling back to Russian.
+func (m MaintenanceState) message(acceptLanguage string) string {
+	messages := m.Messages
+	if len(messages) == 0 {
+		messages = defaultMaintenanceMessages
+	}
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if msg, ok := messages[lang]; ok {
+			return msg
+		}
+	}
+
+	if msg, ok := messages["ru"]; ok {
+		return msg
+	}
+	return defaultMaintenanceMessages["ru"]
+}
+
+// maintenanceGuard answers user-facing requests with 503 while maintenance
+// mode is on. Admin and health endpoints are registered without it.
+func (c *Controller) maintenanceGuard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.mu.RLock()
+		state := c.storage.Maintenance
+		c.mu.RUnlock()
+
+		if state == nil || !state.Enabled {
+			next(w, r)
+			return
+		}
+
+		if state.EndsAt != nil {
+			if wait := time.Until(*state.EndsAt); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			}
+		}
+
+		writeErrorDetails(w, http.StatusServiceUnavailable, "MAINTENANCE",
+			state.message(r.Header.Get("Accept-Language")), maintenanceDetails{EstimatedEnd: state.EndsAt})
+	}
+}
+
+func (c *Controller) AdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		state := MaintenanceState{}
+		if c.storage.Maintenance != nil {
+			state = *c.storage.Maintenance
+		}
+		writeJSON(w, http.StatusOK, state)
+
+	case http.MethodPut:
+		var state MaintenanceState
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+
+		c.storage.Maintenance = &state
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Maintenance mode changed", "enabled", state.Enabled)
+		writeJSON(w, http.StatusOK, state)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+	}
+}
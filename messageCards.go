@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	MessageTypeText            = "text"
+	MessageTypeSessionProposal = "session_proposal"
+	MessageTypeWorkoutSummary  = "workout_summary"
+	MessageTypeLocation        = "location"
+)
+
+type SessionProposalCard struct {
+	Gym       string `json:"gym"`
+	Date      string `json:"date"`
+	Time      string `json:"time"`
+	TrainType string `json:"trainType,omitempty"`
+}
+
+type ExerciseSummary struct {
+	Name     string  `json:"name"`
+	Sets     int     `json:"sets,omitempty"`
+	Reps     int     `json:"reps,omitempty"`
+	WeightKg float64 `json:"weightKg,omitempty"`
+}
+
+type WorkoutSummaryCard struct {
+	DurationMinutes int               `json:"durationMinutes"`
+	Calories        int               `json:"calories,omitempty"`
+	Exercises       []ExerciseSummary `json:"exercises,omitempty"`
+}
+
+type LocationCard struct {
+	Name      string  `json:"name"`
+	Address   string  `json:"address,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (p SessionProposalCard) validate() error {
+	if strings.TrimSpace(p.Gym) == "" {
+		return errors.New("gym is required")
+	}
+	if _, err := time.Parse("2006-01-02", p.Date); err != nil {
+		return errors.New("date must be YYYY-MM-DD")
+	}
+	if _, err := parseClock(p.Time); err != nil {
+		return errors.New("time must be HH:MM")
+	}
+	return nil
+}
+
+func (p WorkoutSummaryCard) validate() error {
+	if p.DurationMinutes <= 0 || p.DurationMinutes > 24*60 {
+		return errors.New("durationMinutes is out of range")
+	}
+	if p.Calories < 0 {
+		return errors.New("calories must not be negative")
+	}
+	for _, e := range p.Exercises {
+		if strings.TrimSpace(e.Name) == "" {
+			return errors.New("exercise name is required")
+		}
+		if e.Sets < 0 || e.Reps < 0 || e.WeightKg < 0 {
+			return fmt.Errorf("exercise %q has negative values", e.Name)
+		}
+	}
+	return nil
+}
+
+func (p LocationCard) validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name is required")
+	}
+	if p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180 {
+		return errors.New("coordinates are out of range")
+	}
+	return nil
+}
+
+// normalizeCard validates a card payload of the given message type and
+// returns it re-encoded without unknown fields.
+func normalizeCard(messageType string, payload json.RawMessage) (json.RawMessage, error) {
+	if len(payload) == 0 {
+		return nil, errors.New("payload is required")
+	}
+
+	decode := func(v interface{ validate() error }) (json.RawMessage, error) {
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	}
+
+	switch messageType {
+	case MessageTypeSessionProposal:
+		return decode(&SessionProposalCard{})
+	case MessageTypeWorkoutSummary:
+		return decode(&WorkoutSummaryCard{})
+	case MessageTypeLocation:
+		return decode(&LocationCard{})
+	default:
+		return nil, fmt.Errorf("unknown message type %q", messageType)
+	}
+}
+
+// cardSummary renders a one-line description of a card for plain-text
+// transcripts and notifications.
+func cardSummary(m Message) string {
+	switch m.Type {
+	case MessageTypeSessionProposal:
+		var p SessionProposalCard
+		json.Unmarshal(m.Payload, &p)
+		return fmt.Sprintf("[session proposal: %s %s, %s]", p.Date, p.Time, p.Gym)
+	case MessageTypeWorkoutSummary:
+		var p WorkoutSummaryCard
+		json.Unmarshal(m.Payload, &p)
+		return fmt.Sprintf("[workout: %d min, %d exercises]", p.DurationMinutes, len(p.Exercises))
+	case MessageTypeLocation:
+		var p LocationCard
+		json.Unmarshal(m.Payload, &p)
+		return fmt.Sprintf("[location: %s]", p.Name)
+	default:
+		return ""
+	}
+}
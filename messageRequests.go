@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	MessageRequestPending  = "pending"
+	MessageRequestAccepted = "accepted"
+	MessageRequestDeclined = "declined"
+)
+
+// MessagePolicy controls how new conversations between matches are opened.
+type MessagePolicy struct {
+	// RequireAcceptance puts the first message of a conversation into a
+	// request the recipient has to accept before the sender can continue.
+	RequireAcceptance bool
+	// MaxNewConversationsPerDay caps how many conversations a user may open
+	// in 24 hours. Zero disables the limit.
+	MaxNewConversationsPerDay int
+}
+
+func DefaultMessagePolicy() MessagePolicy {
+	return MessagePolicy{
+		RequireAcceptance:         false,
+		MaxNewConversationsPerDay: 20,
+	}
+}
+
+type MessageRequest struct {
+	ID          string     `json:"id"`
+	FromID      string     `json:"fromId"`
+	ToID        string     `json:"toId"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	RespondedAt *time.Time `json:"respondedAt,omitempty"`
+}
+
+func (c *Controller) findMessageRequest(fromID, toID string) (int, bool) {
+	for i, req := range c.storage.MessageRequests {
+		if req.FromID == fromID && req.ToID == toID {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// canStartConversation reports whether userID is still below the daily limit
+// of new conversations.
+func (c *Controller) canStartConversation(userID string) bool {
+	if c.messagePolicy.MaxNewConversationsPerDay <= 0 {
+		return true
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	started := 0
+	for _, req := range c.storage.MessageRequests {
+		if req.FromID == userID && req.CreatedAt.After(since) {
+			started++
+		}
+	}
+
+	return started < c.messagePolicy.MaxNewConversationsPerDay
+}
+
+// openConversation records that fromID started a conversation with toID.
+// Without acceptance mode the request is accepted immediately so it only
+// counts towards the daily limit.
+func (c *Controller) openConversation(fromID, toID string) MessageRequest {
+	req := MessageRequest{
+		ID:        newID(),
+		FromID:    fromID,
+		ToID:      toID,
+		Status:    MessageRequestPending,
+		CreatedAt: time.Now(),
+	}
+	if !c.messagePolicy.RequireAcceptance {
+		req.Status = MessageRequestAccepted
+	}
+
+	c.storage.MessageRequests = append(c.storage.MessageRequests, req)
+	return req
+}
+
+func (c *Controller) GetMessageRequests(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("uid")
+
+	pending := []MessageRequest{}
+	for _, req := range c.storage.MessageRequests {
+		if req.ToID == userID && req.Status == MessageRequestPending {
+			pending = append(pending, req)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, pending)
+}
+
+// RespondMessageRequest handles POST /api/message-requests/{id}/accept and
+// POST /api/message-requests/{id}/decline.
+func (c *Controller) RespondMessageRequest(w http.ResponseWriter, r *http.Request) {
+	var status string
+	switch r.PathValue("action") {
+	case "accept":
+		status = MessageRequestAccepted
+	case "decline":
+		status = MessageRequestDeclined
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+		return
+	}
+
+	for i, req := range c.storage.MessageRequests {
+		if req.ID != r.PathValue("id") {
+			continue
+		}
+
+		if req.Status != MessageRequestPending {
+			writeError(w, http.StatusConflict, "MESSAGE_REQUEST_ANSWERED", "Message request already answered")
+			return
+		}
+
+		now := time.Now()
+		c.storage.MessageRequests[i].Status = status
+		c.storage.MessageRequests[i].RespondedAt = &now
+
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, c.storage.MessageRequests[i])
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "MESSAGE_REQUEST_NOT_FOUND", "Message request not found")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestController(tb testing.TB, cfg Config) *Controller {
+	tb.Helper()
+	dir := tb.TempDir()
+	cfg.DataFile = filepath.Join(dir, "storage.json")
+	cfg.ImageDir = filepath.Join(dir, "images")
+	return NewController(cfg)
+}
+
+func TestMessageRequestsRequireAcceptance(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MessageRequests = true
+	c := newTestController(t, cfg)
+
+	const kot, dog = "firebase_uid_AAAAACAT", "firebase_uid_AAAADOG"
+	match := Match{User1ID: kot, User2ID: dog, CreatedAt: time.Now()}
+	c.storage.Matches = append(c.storage.Matches, match)
+
+	send := func(senderID string) error {
+		_, err := c.postMessage(match, SendMessageRequest{SenderID: senderID, Text: "hi"}, nil)
+		return err
+	}
+
+	if err := send(kot); err != nil {
+		t.Fatalf("first message: %v", err)
+	}
+	i, ok := c.findMessageRequest(kot, dog)
+	if !ok || c.storage.MessageRequests[i].Status != MessageRequestPending {
+		t.Fatalf("request after first message = %+v, want pending", c.storage.MessageRequests)
+	}
+
+	if err := send(kot); !errors.Is(err, errMessageRequestWait) {
+		t.Fatalf("second message before acceptance: err = %v, want %v", err, errMessageRequestWait)
+	}
+
+	if err := send(dog); err != nil {
+		t.Fatalf("reply: %v", err)
+	}
+	if status := c.storage.MessageRequests[i].Status; status != MessageRequestAccepted {
+		t.Fatalf("request after reply = %s, want %s", status, MessageRequestAccepted)
+	}
+	if err := send(kot); err != nil {
+		t.Fatalf("message after acceptance: %v", err)
+	}
+}
+
+func TestMaxNewConversations(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxNewConversations = 1
+	c := newTestController(t, cfg)
+
+	const kot = "firebase_uid_AAAAACAT"
+	var matches []Match
+	for _, uid := range []string{"firebase_uid_AAAADOG", "u3"} {
+		if _, ok := c.findUser(uid); !ok {
+			c.storage.Users = append(c.storage.Users, User{FirebaseUID: uid, Name: uid})
+		}
+		m := Match{User1ID: kot, User2ID: uid, CreatedAt: time.Now()}
+		c.storage.Matches = append(c.storage.Matches, m)
+		matches = append(matches, m)
+	}
+
+	if _, err := c.postMessage(matches[0], SendMessageRequest{SenderID: kot, Text: "hi"}, nil); err != nil {
+		t.Fatalf("first conversation: %v", err)
+	}
+	_, err := c.postMessage(matches[1], SendMessageRequest{SenderID: kot, Text: "hi"}, nil)
+	if !errors.Is(err, errConversationLimit) {
+		t.Fatalf("second conversation: err = %v, want %v", err, errConversationLimit)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const usagePeriodLayout = "2006-01"
+
+// TenantQuota holds soft limits for a tenant. Exceeding them is reported
+// but never blocks users. Zero means unlimited.
+type TenantQuota struct {
+	ActiveUsers   int   `json:"activeUsers,omitempty"`
+	StorageBytes  int64 `json:"storageBytes,omitempty"`
+	Notifications int   `json:"notifications,omitempty"`
+}
+
+type TenantUsage struct {
+	TenantID      string       `json:"tenantId"`
+	Period        string       `json:"period"`
+	ActiveUsers   int          `json:"activeUsers"`
+	StorageBytes  int64        `json:"storageBytes"`
+	Notifications int          `json:"notifications"`
+	Quota         *TenantQuota `json:"quota,omitempty"`
+	OverQuota     []string     `json:"overQuota,omitempty"`
+}
+
+func userTenant(u User) string {
+	if u.TenantID == "" {
+		return defaultTenantID
+	}
+	return u.TenantID
+}
+
+// meterNotification counts a push sent to userID's tenant in the current
+// month. The caller must hold c.mu and save data.
+func (c *Controller) meterNotification(userID string) {
+	tenant := defaultTenantID
+	if u, ok := c.findUser(userID); ok {
+		tenant = userTenant(u)
+	}
+
+	if c.storage.NotificationUsage == nil {
+		c.storage.NotificationUsage = make(map[string]map[string]int)
+	}
+	if c.storage.NotificationUsage[tenant] == nil {
+		c.storage.NotificationUsage[tenant] = make(map[string]int)
+	}
+	c.storage.NotificationUsage[tenant][time.Now().UTC().Format(usagePeriodLayout)]++
+}
+
+// usage computes each tenant's usage for period. Active users are users that
+// swiped or sent a message during the period; storage is the current size of
+// the tenant's profile images.
+func (c *Controller) usage(period time.Time) []TenantUsage {
+	start := period
+	end := start.AddDate(0, 1, 0)
+	key := start.Format(usagePeriodLayout)
+	inPeriod := func(t time.Time) bool {
+		return !t.Before(start) && t.Before(end)
+	}
+
+	active := make(map[string]bool)
+	for _, s := range c.storage.Swipes {
+		if inPeriod(s.CreatedAt) {
+			active[s.SwiperID] = true
+		}
+	}
+	for _, m := range c.storage.Messages {
+		if inPeriod(m.CreatedAt) {
+			active[m.SenderID] = true
+		}
+	}
+
+	byTenant := make(map[string]*TenantUsage)
+	get := func(tenant string) *TenantUsage {
+		if byTenant[tenant] == nil {
+			byTenant[tenant] = &TenantUsage{TenantID: tenant, Period: key}
+		}
+		return byTenant[tenant]
+	}
+	for _, t := range c.storage.Tenants {
+		get(t.ID)
+	}
+
+	counted := make(map[string]bool)
+	for _, u := range append(slices.Clone(c.storage.Users), c.storage.forgottenUsers()...) {
+		usage := get(userTenant(u))
+		if active[u.FirebaseUID] {
+			usage.ActiveUsers++
+		}
+
+		for _, url := range u.Photos {
+			if !strings.HasPrefix(url, "/images/") || counted[url] {
+				continue
+			}
+			counted[url] = true
+			if info, err := c.images.Stat(context.Background(), imageKey(url)); err == nil {
+				usage.StorageBytes += info.Size
+			}
+		}
+	}
+
+	for tenant, periods := range c.storage.NotificationUsage {
+		if n := periods[key]; n > 0 {
+			get(tenant).Notifications = n
+		}
+	}
+
+	result := make([]TenantUsage, 0, len(byTenant))
+	for tenant, usage := range byTenant {
+		if quota, ok := c.storage.TenantQuotas[tenant]; ok {
+			usage.Quota = &quota
+			if quota.ActiveUsers > 0 && usage.ActiveUsers > quota.ActiveUsers {
+				usage.OverQuota = append(usage.OverQuota, "activeUsers")
+			}
+			if quota.StorageBytes > 0 && usage.StorageBytes > quota.StorageBytes {
+				usage.OverQuota = append(usage.OverQuota, "storageBytes")
+			}
+			if quota.Notifications > 0 && usage.Notifications > quota.Notifications {
+				usage.OverQuota = append(usage.OverQuota, "notifications")
+			}
+		}
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].TenantID < result[j].TenantID })
+	return result
+}
+
+// AdminUsage handles GET /admin/usage?period=YYYY-MM.
+func (c *Controller) AdminUsage(w http.ResponseWriter, r *http.Request) {
+	period := time.Now().UTC()
+	if p := r.URL.Query().Get("period"); p != "" {
+		var err error
+		if period, err = time.Parse(usagePeriodLayout, p); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_PERIOD", "Invalid period")
+			return
+		}
+	}
+	period = time.Date(period.Year(), period.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	writeJSON(w, http.StatusOK, c.usage(period))
+}
+
+// AdminTenantQuota handles PUT /admin/usage/{tenant}/quota.
+func (c *Controller) AdminTenantQuota(w http.ResponseWriter, r *http.Request) {
+	tenant := r.PathValue("tenant")
+	if !validTenantID.MatchString(tenant) {
+		writeError(w, http.StatusBadRequest, "INVALID_TENANT_ID", "Invalid tenant ID")
+		return
+	}
+
+	var quota TenantQuota
+	if err := json.NewDecoder(r.Body).Decode(&quota); err != nil ||
+		quota.ActiveUsers < 0 || quota.StorageBytes < 0 || quota.Notifications < 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_QUOTA", "Invalid quota")
+		return
+	}
+
+	if c.storage.TenantQuotas == nil {
+		c.storage.TenantQuotas = make(map[string]TenantQuota)
+	}
+	c.storage.TenantQuotas[tenant] = quota
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, quota)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.value.Load())
+}
+
+// Gauge is a value that is set rather than incremented, such as a count
+// recomputed on every scrape.
+type Gauge struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+func (g *Gauge) Set(v int64) {
+	g.value.Store(v)
+}
+
+func (g *Gauge) write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, g.value.Load())
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a histogram partitioned by a single label.
+type HistogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+func (h *HistogramVec) Observe(labelValue string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.series == nil {
+		h.series = make(map[string]*histogram)
+	}
+	s, ok := h.series[labelValue]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = s
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Mean returns the average of the values observed for labelValue, or zero.
+func (h *HistogramVec) Mean(labelValue string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[labelValue]
+	if !ok || s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+func (h *HistogramVec) write(b *strings.Builder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+
+	labels := make([]string, 0, len(h.series))
+	for l := range h.series {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	for _, l := range labels {
+		s := h.series[l]
+		prefix := ""
+		if h.label != "" {
+			prefix = fmt.Sprintf("%s=%q,", h.label, l)
+		}
+		for i, upper := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket{%sle=\"%g\"} %d\n", h.name, prefix, upper, s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%sle=\"+Inf\"} %d\n", h.name, prefix, s.count)
+
+		suffix := ""
+		if h.label != "" {
+			suffix = fmt.Sprintf("{%s=%q}", h.label, l)
+		}
+		fmt.Fprintf(b, "%s_sum%s %g\n%s_count%s %d\n", h.name, suffix, s.sum, h.name, suffix, s.count)
+	}
+}
+
+// Metrics is the set of metrics exposed on /metrics in the Prometheus text
+// format.
+type Metrics struct {
+	Swipes          Counter
+	Likes           Counter
+	MatchesCreated  Counter
+	ProfilesCreated Counter
+
+	MessagesSent      Counter
+	SessionsScheduled Counter
+	// DeckRequests and DeckEmpty give the deck emptiness rate as
+	// rate(gymbro_deck_empty_total) / rate(gymbro_deck_requests_total).
+	DeckRequests Counter
+	DeckEmpty    Counter
+	// NotificationsCoalesced counts pushes held back for a digest.
+	NotificationsCoalesced Counter
+
+	DailyActiveUsers Gauge
+
+	HandlerLatency HistogramVec
+	StorageSave    HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Swipes:          Counter{name: "gymbro_swipes_total", help: "Swipes received."},
+		Likes:           Counter{name: "gymbro_likes_total", help: "Swipes that were likes."},
+		MatchesCreated:  Counter{name: "gymbro_matches_created_total", help: "Matches created."},
+		ProfilesCreated: Counter{name: "gymbro_profiles_created_total", help: "Profiles created."},
+
+		MessagesSent:      Counter{name: "gymbro_messages_sent_total", help: "Chat messages sent, including scheduled ones."},
+		SessionsScheduled: Counter{name: "gymbro_sessions_scheduled_total", help: "Session proposals sent in chats."},
+		DeckRequests:      Counter{name: "gymbro_deck_requests_total", help: "Next-user and feed requests."},
+		DeckEmpty:         Counter{name: "gymbro_deck_empty_total", help: "Next-user and feed requests with no candidates."},
+		NotificationsCoalesced: Counter{
+			name: "gymbro_notifications_coalesced_total",
+			help: "Pushes held back and sent as part of a digest.",
+		},
+
+		DailyActiveUsers: Gauge{name: "gymbro_daily_active_users", help: "Users who swiped, sent a message or checked in within the last 24 hours."},
+		HandlerLatency: HistogramVec{
+			name:    "gymbro_http_request_duration_seconds",
+			help:    "Handler latency by route.",
+			label:   "route",
+			buckets: defaultLatencyBuckets,
+		},
+		StorageSave: HistogramVec{
+			name:    "gymbro_storage_save_duration_seconds",
+			help:    "Time spent persisting storage.",
+			buckets: defaultLatencyBuckets,
+		},
+	}
+}
+
+// InstrumentRoutes records the latency of h under the route pattern the
+// request matched.
+func (m *Metrics) InstrumentRoutes(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		h(w, r)
+		m.HandlerLatency.Observe(routeLabel(r), time.Since(start).Seconds())
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	for _, c := range []*Counter{
+		&m.Swipes, &m.Likes, &m.MatchesCreated, &m.ProfilesCreated,
+		&m.MessagesSent, &m.SessionsScheduled, &m.DeckRequests, &m.DeckEmpty,
+		&m.NotificationsCoalesced,
+	} {
+		c.write(&b)
+	}
+	m.DailyActiveUsers.write(&b)
+	m.HandlerLatency.write(&b)
+	m.StorageSave.write(&b)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
+
+// countDeck records a deck request that found n candidates.
+func (c *Controller) countDeck(n int) {
+	c.metrics.DeckRequests.Inc()
+	if n == 0 {
+		c.metrics.DeckEmpty.Inc()
+	}
+}
+
+// dailyActiveUsers counts users who swiped, sent a message or checked in
+// within the last 24 hours. The caller must hold c.mu.
+func (c *Controller) dailyActiveUsers() int {
+	since := time.Now().Add(-24 * time.Hour)
+	active := make(map[string]bool)
+	for _, s := range c.storage.Swipes {
+		if s.CreatedAt.After(since) {
+			active[s.SwiperID] = true
+		}
+	}
+	for _, m := range c.storage.Messages {
+		if m.CreatedAt.After(since) {
+			active[m.SenderID] = true
+		}
+	}
+	for _, ci := range c.storage.CheckIns {
+		if ci.At.After(since) {
+			active[ci.UserID] = true
+		}
+	}
+	return len(active)
+}
+
+// ServeMetrics handles GET /metrics, refreshing the gauges computed from
+// storage before writing every metric.
+func (c *Controller) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	c.metrics.DailyActiveUsers.Set(int64(c.dailyActiveUsers()))
+	c.metrics.ServeHTTP(w, r)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultModerationThreshold = 0.8
+	moderationTimeout          = 10 * time.Second
+
+	// moderationFailedLabel marks images held because the provider could
+	// not be reached; they are reviewed rather than published unchecked.
+	moderationFailedLabel = "moderation_failed"
+)
+
+type ModerationResult struct {
+	Flagged bool     `json:"flagged"`
+	Score   float64  `json:"score"`
+	Labels  []string `json:"labels,omitempty"`
+}
+
+// ImageModerator screens uploaded images before they are published.
+type ImageModerator interface {
+	Moderate(ctx context.Context, data []byte, contentType string) (ModerationResult, error)
+}
+
+// HTTPImageModerator sends the image to an NSFW-detection service, which
+// answers with {"nsfw": 0.0-1.0, "labels": [...]}. Images scoring at or above
+// Threshold are flagged.
+type HTTPImageModerator struct {
+	Endpoint  string
+	APIKey    string
+	Threshold float64
+	Client    *http.Client
+}
+
+func (m *HTTPImageModerator) Moderate(ctx context.Context, data []byte, contentType string) (ModerationResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return ModerationResult{}, fmt.Errorf("building moderation request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if m.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.APIKey)
+	}
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return ModerationResult{}, fmt.Errorf("calling moderation provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ModerationResult{}, fmt.Errorf("moderation provider returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		NSFW   float64  `json:"nsfw"`
+		Labels []string `json:"labels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ModerationResult{}, fmt.Errorf("decoding moderation response: %w", err)
+	}
+
+	return ModerationResult{
+		Flagged: result.NSFW >= m.Threshold,
+		Score:   result.NSFW,
+		Labels:  result.Labels,
+	}, nil
+}
+
+// moderatorFromEnv returns the configured provider or nil when image
+// moderation is disabled.
+func moderatorFromEnv() ImageModerator {
+	endpoint := os.Getenv("MODERATION_URL")
+	if endpoint == "" {
+		return nil
+	}
+
+	threshold := defaultModerationThreshold
+	if v := os.Getenv("MODERATION_THRESHOLD"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 || t > 1 {
+			slog.Warn("Ignoring invalid MODERATION_THRESHOLD", "value", v)
+		} else {
+			threshold = t
+		}
+	}
+
+	return &HTTPImageModerator{
+		Endpoint:  endpoint,
+		APIKey:    os.Getenv("MODERATION_API_KEY"),
+		Threshold: threshold,
+		Client:    &http.Client{Timeout: moderationTimeout},
+	}
+}
+
+// PendingImage is an uploaded photo held back from the profile until an
+// admin approves it. Primary images go to the front of the gallery on
+// approval, others to the end.
+type PendingImage struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	ImageURL  string    `json:"imageUrl"`
+	Primary   bool      `json:"primary"`
+	Score     float64   `json:"score"`
+	Labels    []string  `json:"labels,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// moderateUpload runs the moderator on a stored upload. The moderator is a
+// remote service, so it runs with c.mu released and the caller must look
+// storage up again afterwards. Failures flag the image for review.
+func (c *Controller) moderateUpload(ctx context.Context, imageURL string) ModerationResult {
+	if c.moderator == nil {
+		return ModerationResult{}
+	}
+
+	var (
+		result ModerationResult
+		err    error
+	)
+	c.unlocked(ctx, func() { result, err = c.moderateStoredImage(ctx, imageURL) })
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to moderate image", "imageUrl", imageURL, "err", err)
+		result = ModerationResult{Flagged: true, Labels: []string{moderationFailedLabel}}
+	}
+	return result
+}
+
+// holdForModeration adds a flagged upload to the pending images and reports
+// whether it did. The caller must hold c.mu and save data.
+func (c *Controller) holdForModeration(userID, imageURL string, primary bool, result ModerationResult) bool {
+	if !result.Flagged {
+		return false
+	}
+
+	c.storage.PendingImages = append(c.storage.PendingImages, PendingImage{
+		ID:        newID(),
+		UserID:    userID,
+		ImageURL:  imageURL,
+		Primary:   primary,
+		Score:     result.Score,
+		Labels:    result.Labels,
+		CreatedAt: time.Now(),
+	})
+	return true
+}
+
+func (c *Controller) moderateStoredImage(ctx context.Context, imageURL string) (ModerationResult, error) {
+	rc, _, err := c.images.Open(ctx, imageKey(imageURL))
+	if err != nil {
+		return ModerationResult{}, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return ModerationResult{}, err
+	}
+	return c.moderator.Moderate(ctx, data, http.DetectContentType(data))
+}
+
+// discardPendingImages drops uid's pending images and their files; with
+// primaryOnly just the pending primary photo, which a new upload replaces.
+// The caller must hold c.mu and save data.
+func (c *Controller) discardPendingImages(uid string, primaryOnly bool) {
+	pending := c.storage.PendingImages[:0]
+	for _, p := range c.storage.PendingImages {
+		if p.UserID == uid && (p.Primary || !primaryOnly) {
+			c.removeImage(p.ImageURL, uid)
+			continue
+		}
+		pending = append(pending, p)
+	}
+	c.storage.PendingImages = pending
+}
+
+func (c *Controller) pendingImageCount(uid string) int {
+	n := 0
+	for _, p := range c.storage.PendingImages {
+		if p.UserID == uid {
+			n++
+		}
+	}
+	return n
+}
+
+// AdminPendingImages handles GET /admin/images/pending.
+func (c *Controller) AdminPendingImages(w http.ResponseWriter, r *http.Request) {
+	pending := c.storage.PendingImages
+	if pending == nil {
+		pending = []PendingImage{}
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+// AdminReviewPendingImage handles POST /admin/images/pending/{id}/{action},
+// where action is approve or reject.
+func (c *Controller) AdminReviewPendingImage(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	if action != "approve" && action != "reject" {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+		return
+	}
+
+	j := slices.IndexFunc(c.storage.PendingImages, func(p PendingImage) bool { return p.ID == r.PathValue("id") })
+	if j == -1 {
+		writeError(w, http.StatusNotFound, "PENDING_IMAGE_NOT_FOUND", "Pending image not found")
+		return
+	}
+	p := c.storage.PendingImages[j]
+
+	if action == "approve" {
+		i, ok := c.findUserIndex(p.UserID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+			return
+		}
+		before := c.storage.Users[i]
+		if len(before.Photos) >= maxPhotos {
+			writeError(w, http.StatusConflict, "PHOTO_LIMIT_REACHED", "Photo limit reached")
+			return
+		}
+
+		at := len(before.Photos)
+		if p.Primary {
+			at = 0
+		}
+		c.storage.Users[i].setPhotos(slices.Insert(slices.Clone(before.Photos), at, p.ImageURL))
+		c.recordProfileChanges(before, c.storage.Users[i], changedByAdmin)
+		c.indexImage(p.UserID, p.ImageURL)
+	} else {
+		c.removeImage(p.ImageURL, p.UserID)
+		c.notifyUser(p.UserID, Notification{
+			Title: "Фото отклонено",
+			Body:  "Фото не прошло модерацию и не будет показано в профиле",
+			Data:  map[string]string{"type": "photo_rejected"},
+		})
+	}
+	c.storage.PendingImages = slices.Delete(c.storage.PendingImages, j, j+1)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	notifyAttempts    = 4
+	notifyBaseBackoff = 500 * time.Millisecond
+	notifyTimeout     = time.Minute
+)
+
+type Notification struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Notifier delivers a notification to a single device.
+type Notifier interface {
+	Send(ctx context.Context, deviceToken string, n Notification) error
+}
+
+// LogNotifier only logs notifications; used when FCM is not configured.
+type LogNotifier struct{}
+
+func (LogNotifier) Send(_ context.Context, deviceToken string, n Notification) error {
+	slog.Info("Notification", "token", deviceToken, "title", n.Title, "body", n.Body)
+	return nil
+}
+
+func notifierFromEnv() Notifier {
+	credentials := os.Getenv("FCM_CREDENTIALS_FILE")
+	if credentials == "" {
+		return LogNotifier{}
+	}
+
+	notifier, err := NewFCMNotifier(credentials)
+	if err != nil {
+		slog.Error("Failed to set up FCM, falling back to logging notifier", "err", err)
+		return LogNotifier{}
+	}
+	return notifier
+}
+
+type Device struct {
+	UserID    string    `json:"userId"`
+	Token     string    `json:"token"`
+	Platform  string    `json:"platform,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type RegisterDeviceRequest struct {
+	UserID   string `json:"userId"`
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+func (c *Controller) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	var req RegisterDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	if req.UserID == "" || req.Token == "" {
+		writeError(w, http.StatusBadRequest, "USER_ID_AND_TOKEN_REQUIRED", "User ID and token are required")
+		return
+	}
+
+	if _, ok := c.findUser(req.UserID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	device := Device{
+		UserID:    req.UserID,
+		Token:     req.Token,
+		Platform:  req.Platform,
+		UpdatedAt: time.Now(),
+	}
+
+	// A token belongs to one device, so it moves with the signed-in user.
+	found := false
+	for i, d := range c.storage.Devices {
+		if d.Token == req.Token {
+			c.storage.Devices[i] = device
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.storage.Devices = append(c.storage.Devices, device)
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, device)
+}
+
+// notifyUser stores n in userID's inbox, publishes it to their event
+// channels and pushes it to all of their devices in the background. Pushes
+// of rapid-fire types are coalesced into digests. The caller must hold c.mu
+// and save data.
+func (c *Controller) notifyUser(userID string, n Notification) {
+	c.storeNotification(userID, n)
+	c.events.Publish(userID, n)
+
+	n, ok := c.digests.Admit(userID, n, time.Now())
+	if !ok {
+		c.metrics.NotificationsCoalesced.Inc()
+		return
+	}
+	c.push(userID, n)
+}
+
+// push sends n to all of userID's devices and, for the types worth it, their
+// linked Telegram chat in the background. The caller must hold c.mu and save
+// data.
+func (c *Controller) push(userID string, n Notification) {
+	var tokens []string
+	for _, d := range c.storage.Devices {
+		if d.UserID == userID {
+			tokens = append(tokens, d.Token)
+		}
+	}
+
+	for _, token := range tokens {
+		c.meterNotification(userID)
+		c.background.Add(1)
+		go func() {
+			defer c.background.Done()
+			c.deliver(userID, token, n)
+		}()
+	}
+	c.pushTelegram(userID, n)
+}
+
+// deliver pushes n to a device, moving it to the retry queue when the
+// immediate retries fail.
+func (c *Controller) deliver(userID, token string, n Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	err := sendWithRetry(ctx, c.notifier, token, n)
+	if errors.Is(err, errUnregistered) {
+		c.removeDevice(token)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to deliver notification, queueing for retry", "token", token, "err", err)
+		c.enqueueDelivery(Delivery{
+			Channel:      DeliveryPush,
+			UserID:       userID,
+			Target:       token,
+			Notification: &n,
+		}, notifyAttempts, err)
+	}
+}
+
+func sendWithRetry(ctx context.Context, notifier Notifier, token string, n Notification) error {
+	backoff := notifyBaseBackoff
+	var err error
+	for attempt := 1; attempt <= notifyAttempts; attempt++ {
+		err = notifier.Send(ctx, token, n)
+		if err == nil || errors.Is(err, errUnregistered) {
+			return err
+		}
+
+		if attempt == notifyAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+func (c *Controller) removeDevice(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.storage.Devices = removeDeviceToken(c.storage.Devices, token)
+
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to save data", "err", err)
+	}
+}
+
+func (c *Controller) notifyMatch(match Match) {
+	for _, id := range []string{match.User1ID, match.User2ID} {
+		partner, _ := c.findUser(match.Partner(id))
+		c.notifyUser(id, Notification{
+			Title: "Новый мэтч!",
+			Body:  partner.Name + " тоже хочет тренироваться с тобой",
+			Data: map[string]string{
+				"type":    "match",
+				"matchId": match.ID(),
+			},
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import "net/http"
+
+const (
+	StepDone    = "done"
+	StepPending = "pending"
+	StepTodo    = "todo"
+)
+
+// OnboardingStep is one item of the onboarding checklist. Fields lists the
+// profile fields that complete it, so the app knows which form to show.
+type OnboardingStep struct {
+	ID       string   `json:"id"`
+	Title    string   `json:"title"`
+	Fields   []string `json:"fields"`
+	Required bool     `json:"required"`
+	// Status is "done", "todo" or "pending" while the step is waiting on
+	// the backend, such as a photo held for moderation.
+	Status string `json:"status"`
+}
+
+// OnboardingChecklist is the onboarding state of a profile. Complete is set
+// once every required step is done.
+type OnboardingChecklist struct {
+	UserID   string           `json:"userId"`
+	Steps    []OnboardingStep `json:"steps"`
+	Done     int              `json:"done"`
+	Total    int              `json:"total"`
+	Complete bool             `json:"complete"`
+	NextStep string           `json:"nextStep,omitempty"`
+	Percent  int              `json:"percent"`
+}
+
+func stepStatus(done bool) string {
+	if done {
+		return StepDone
+	}
+	return StepTodo
+}
+
+// onboardingChecklist derives the checklist from u's profile. The steps
+// follow what the feed and matching rely on: a real photo, availability for
+// day and time scoring, a bio and a training type.
+func (c *Controller) onboardingChecklist(u User) OnboardingChecklist {
+	photo := stepStatus(u.ImageURL != "" && u.ImageURL != defaultImageURL)
+	if photo == StepTodo && c.pendingImageCount(u.FirebaseUID) > 0 {
+		photo = StepPending
+	}
+
+	steps := []OnboardingStep{
+		{ID: "photo", Title: "Add a photo", Fields: []string{"image"}, Required: true, Status: photo},
+		{ID: "availability", Title: "Set when you train", Fields: []string{"day", "time"}, Required: true,
+			Status: stepStatus(u.Day != "" && u.Time != "")},
+		{ID: "bio", Title: "Write a bio", Fields: []string{"textInfo"}, Required: true,
+			Status: stepStatus(u.TextInfo != "")},
+		{ID: "preferences", Title: "Choose your training type", Fields: []string{"trainType"}, Required: true,
+			Status: stepStatus(u.TrainType != "")},
+		{ID: "location", Title: "Add your gym or location", Fields: []string{"homeGymId", "latitude", "longitude"},
+			Status: stepStatus(u.HomeGymID != "" || (u.Latitude != nil && u.Longitude != nil))},
+	}
+
+	checklist := OnboardingChecklist{UserID: u.FirebaseUID, Steps: steps, Total: len(steps), Complete: true}
+	for _, s := range steps {
+		if s.Status == StepDone {
+			checklist.Done++
+			continue
+		}
+		if s.Required {
+			checklist.Complete = false
+		}
+		if checklist.NextStep == "" && s.Status == StepTodo {
+			checklist.NextStep = s.ID
+		}
+	}
+	checklist.Percent = checklist.Done * 100 / checklist.Total
+	return checklist
+}
+
+// Onboarding handles GET /api/profiles/{uid}/onboarding.
+func (c *Controller) Onboarding(w http.ResponseWriter, r *http.Request) {
+	u, ok := c.findUser(r.PathValue("uid"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, c.onboardingChecklist(u))
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiParam is a path, query or form parameter of a documented operation.
+type apiParam struct {
+	Name        string
+	In          string // "path", "query" or "formData"
+	Type        string // "string", "integer", "boolean", "number" or "file"
+	Required    bool
+	Description string
+}
+
+// apiOperation documents one method on one route for /openapi.json. Body and
+// Response are example values whose types are turned into schemas.
+type apiOperation struct {
+	// ID is the operationId, which client generators turn into method names.
+	// Keep it stable once published.
+	ID       string
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Params   []apiParam
+	Body     any
+	Status   int
+	Response any
+}
+
+// swipeResult documents the response of POST /api/swipe.
+type swipeResult struct {
+	Success bool   `json:"success"`
+	IsMatch bool   `json:"isMatch"`
+	Match   *Match `json:"match"`
+}
+
+func pathParam(name, description string) apiParam {
+	return apiParam{Name: name, In: "path", Type: "string", Required: true, Description: description}
+}
+
+func queryParam(name, typ, description string) apiParam {
+	return apiParam{Name: name, In: "query", Type: typ, Description: description}
+}
+
+func formParam(name, typ, description string) apiParam {
+	return apiParam{Name: name, In: "formData", Type: typ, Description: description}
+}
+
+var userIDQuery = apiParam{Name: "userId", In: "query", Type: "string", Required: true, Description: "Acting user"}
+
+// apiOperations lists the documented client API. Keep it next to route
+// changes in main; admin and partner routes are not part of it.
+var apiOperations = []apiOperation{
+	{ID: "listUsers", Method: "GET", Path: "/api/users", Tag: "profiles", Summary: "List public profiles",
+		Params: []apiParam{
+			queryParam("limit", "integer", "Page size"),
+			queryParam("offset", "integer", "Page offset"),
+			queryParam("trainType", "string", ""),
+			queryParam("day", "string", ""),
+			queryParam("time", "string", "HH:MM"),
+			queryParam("bioLang", "string", "ISO 639-1 language of the bio"),
+		},
+		Response: UsersPage{}},
+	{ID: "upsertProfile", Method: "POST", Path: "/api/profiles", Tag: "profiles", Summary: "Create or update a profile",
+		Params: []apiParam{
+			{Name: "firebaseUid", In: "formData", Type: "string", Required: true},
+			{Name: "name", In: "formData", Type: "string", Required: true, Description: "2-32 letters, digits, spaces and - _ . '"},
+			formParam("time", "string", "Preferred training time, HH:MM"),
+			formParam("day", "string", "Preferred training day"),
+			formParam("availability", "string", "JSON array of {weekday, start, end} slots; overrides time and day"),
+			formParam("textInfo", "string", "Bio"),
+			formParam("trainType", "string", "Training type"),
+			formParam("contact", "string", "Contact shown to matches"),
+			formParam("homeGymId", "string", "Home gym"),
+			formParam("latitude", "number", ""),
+			formParam("longitude", "number", ""),
+			formParam("accessibilityNeeds", "string", "Comma-separated list"),
+			formParam("adaptiveTraining", "boolean", ""),
+			formParam("shareAccessibility", "boolean", ""),
+			formParam("image", "file", "Primary photo"),
+		},
+		Response: User{}},
+	{ID: "uploadPhoto", Method: "POST", Path: "/api/profiles/{uid}/photos", Tag: "profiles", Summary: "Upload a gallery photo",
+		Params:   []apiParam{pathParam("uid", ""), userIDQuery, {Name: "image", In: "formData", Type: "file", Required: true}},
+		Response: User{}},
+	{ID: "reorderPhotos", Method: "PUT", Path: "/api/profiles/{uid}/photos", Tag: "profiles", Summary: "Reorder gallery photos",
+		Params: []apiParam{pathParam("uid", ""), userIDQuery}, Body: ReorderPhotosRequest{}, Response: User{}},
+	{ID: "deletePhoto", Method: "DELETE", Path: "/api/profiles/{uid}/photos/{name}", Tag: "profiles", Summary: "Delete a gallery photo",
+		Params: []apiParam{pathParam("uid", ""), pathParam("name", "Image file name"), userIDQuery}, Response: User{}},
+	{ID: "getProfileHistory", Method: "GET", Path: "/api/profiles/{uid}/history", Tag: "profiles", Summary: "Profile change history",
+		Params: []apiParam{pathParam("uid", ""), userIDQuery}, Response: []ProfileChange{}},
+	{ID: "getOnboarding", Method: "GET", Path: "/api/profiles/{uid}/onboarding", Tag: "profiles", Summary: "Onboarding checklist",
+		Params: []apiParam{pathParam("uid", ""), userIDQuery}, Response: OnboardingChecklist{}},
+	{ID: "checkDisplayName", Method: "GET", Path: "/api/display-names/check", Tag: "profiles", Summary: "Check a display name",
+		Params:   []apiParam{{Name: "name", In: "query", Type: "string", Required: true}, queryParam("userId", "string", "")},
+		Response: DisplayNameCheck{}},
+	{ID: "exportUser", Method: "GET", Path: "/api/users/{uid}/export", Tag: "account", Summary: "Export all data stored about the user",
+		Params:   []apiParam{pathParam("uid", ""), queryParam("format", "string", "json (default) or zip with photos")},
+		Response: UserExport{}},
+	{ID: "forgetUser", Method: "POST", Path: "/api/users/{uid}/forget", Tag: "account", Summary: "Erase the user's personal data",
+		Params: []apiParam{pathParam("uid", "")}, Response: ErasureRecord{}},
+	{ID: "mergeAccount", Method: "POST", Path: "/api/account/merge", Tag: "account", Summary: "Merge a second account into the signed-in one",
+		Body: MergeAccountRequest{}, Response: User{}},
+	{ID: "changeContact", Method: "POST", Path: "/api/contacts/change", Tag: "account", Summary: "Send a verification code to a new contact",
+		Body: ContactChangeRequest{}, Status: http.StatusAccepted},
+	{ID: "verifyContact", Method: "POST", Path: "/api/contacts/verify", Tag: "account", Summary: "Verify a contact change",
+		Body: ContactVerifyRequest{}, Response: []LinkedContact{}},
+	{ID: "registerDevice", Method: "POST", Path: "/api/devices", Tag: "account", Summary: "Register a push device",
+		Body: RegisterDeviceRequest{}, Response: Device{}},
+	{ID: "linkTelegram", Method: "POST", Path: "/api/telegram/link", Tag: "account", Summary: "Start linking a Telegram chat for notifications",
+		Body: TelegramLinkRequest{}, Status: http.StatusCreated, Response: TelegramLinkResponse{}},
+	{ID: "getTelegramLink", Method: "GET", Path: "/api/telegram/link", Tag: "account", Summary: "Linked Telegram chat",
+		Params: []apiParam{userIDQuery}, Response: TelegramChat{}},
+	{ID: "unlinkTelegram", Method: "DELETE", Path: "/api/telegram/link", Tag: "account", Summary: "Stop Telegram notifications",
+		Params: []apiParam{userIDQuery}, Status: http.StatusNoContent},
+
+	{ID: "getFeed", Method: "GET", Path: "/api/feed/{uid}", Tag: "swiping", Summary: "Deck of candidates",
+		Params: []apiParam{
+			pathParam("uid", "Requesting user"),
+			queryParam("count", "integer", "Deck size, up to 50"),
+			queryParam("trainType", "string", ""),
+			queryParam("day", "string", ""),
+			queryParam("timeFrom", "string", "HH:MM"),
+			queryParam("timeTo", "string", "HH:MM"),
+			queryParam("adaptive", "boolean", "Only accessibility-compatible partners"),
+			queryParam("sameGym", "string", "prefer or only"),
+			queryParam("maxDistanceKm", "number", ""),
+			queryParam("bioLang", "string", "Comma-separated ISO 639-1 languages of the bio"),
+			queryParam("minOverlap", "integer", "Overlap mode: minutes of shared availability, cards list the overlapping windows"),
+		},
+		Response: []User{}},
+	{ID: "getNextUser", Method: "GET", Path: "/api/next-user/{uid}", Tag: "swiping", Summary: "Next single candidate",
+		Params: []apiParam{pathParam("uid", "Requesting user")}, Response: User{}},
+	{ID: "swipe", Method: "POST", Path: "/api/swipe", Tag: "swiping", Summary: "Like or dislike a candidate",
+		Body: SwipeRequest{}, Response: swipeResult{}},
+	{ID: "undoSwipe", Method: "POST", Path: "/api/swipe/undo", Tag: "swiping", Summary: "Undo the last swipe",
+		Body: UndoSwipeRequest{}},
+	{ID: "listGoals", Method: "GET", Path: "/api/goals", Tag: "swiping", Summary: "List open workout goals",
+		Params:   []apiParam{queryParam("userId", "string", ""), queryParam("trainType", "string", ""), queryParam("mine", "boolean", "")},
+		Response: []GoalPost{}},
+	{ID: "createGoal", Method: "POST", Path: "/api/goals", Tag: "swiping", Summary: "Post a workout goal",
+		Body: GoalPostRequest{}, Status: http.StatusCreated, Response: GoalPost{}},
+	{ID: "getGoal", Method: "GET", Path: "/api/goals/{id}", Tag: "swiping", Summary: "Get a goal",
+		Params: []apiParam{pathParam("id", ""), queryParam("userId", "string", "")}, Response: GoalPost{}},
+	{ID: "respondToGoal", Method: "POST", Path: "/api/goals/{id}/responses", Tag: "swiping", Summary: "Respond to a goal",
+		Params: []apiParam{pathParam("id", "")}, Body: GoalResponseRequest{}, Response: GoalPost{}},
+
+	{ID: "listMatches", Method: "GET", Path: "/api/matches/{uid}", Tag: "chat", Summary: "List matches",
+		Params: []apiParam{pathParam("uid", "")}, Response: []MatchView{}},
+	{ID: "listMessages", Method: "GET", Path: "/api/matches/{matchId}/messages", Tag: "chat", Summary: "Chat history, newest page first",
+		Params:   []apiParam{pathParam("matchId", ""), userIDQuery, queryParam("limit", "integer", ""), queryParam("cursor", "string", "")},
+		Response: MessagesPage{}},
+	{ID: "sendMessage", Method: "POST", Path: "/api/matches/{matchId}/messages", Tag: "chat", Summary: "Send a message",
+		Params: []apiParam{pathParam("matchId", "")}, Body: SendMessageRequest{}, Status: http.StatusCreated, Response: Message{}},
+	{ID: "proposeSession", Method: "POST", Path: "/api/matches/{matchId}/sessions", Tag: "chat", Summary: "Propose a workout session to a match",
+		Params: []apiParam{pathParam("matchId", "")}, Body: SessionRequest{}, Status: http.StatusCreated, Response: Session{}},
+	{ID: "listSessions", Method: "GET", Path: "/api/sessions/{uid}", Tag: "chat", Summary: "Upcoming workout sessions",
+		Params:   []apiParam{pathParam("uid", ""), queryParam("all", "boolean", "Include past, declined and cancelled sessions")},
+		Response: []Session{}},
+	{ID: "sessionCalendar", Method: "GET", Path: "/api/users/{uid}/sessions.ics", Tag: "chat", Summary: "iCalendar feed of accepted sessions",
+		Params: []apiParam{pathParam("uid", "")}},
+	{ID: "respondSession", Method: "POST", Path: "/api/sessions/{id}/{action}", Tag: "chat", Summary: "Accept, decline, reschedule or cancel a session",
+		Params: []apiParam{pathParam("id", ""), pathParam("action", "accept, decline, reschedule or cancel")},
+		Body:   SessionRequest{}, Response: Session{}},
+	{ID: "exportChat", Method: "GET", Path: "/api/matches/{matchId}/export", Tag: "chat", Summary: "Export a chat transcript",
+		Params:   []apiParam{pathParam("matchId", ""), userIDQuery, queryParam("format", "string", "json or text")},
+		Response: ChatTranscript{}},
+	{ID: "scheduleMessage", Method: "POST", Path: "/api/matches/{matchId}/scheduled-messages", Tag: "chat", Summary: "Schedule a message",
+		Params: []apiParam{pathParam("matchId", "")}, Body: ScheduleMessageRequest{}, Status: http.StatusCreated, Response: ScheduledMessage{}},
+	{ID: "shareContact", Method: "POST", Path: "/api/matches/{matchId}/share-contact", Tag: "chat", Summary: "Share my contact with the match",
+		Params: []apiParam{pathParam("matchId", ""), userIDQuery}, Response: MatchView{}},
+	{ID: "unshareContact", Method: "DELETE", Path: "/api/matches/{matchId}/share-contact", Tag: "chat", Summary: "Stop sharing my contact",
+		Params: []apiParam{pathParam("matchId", ""), userIDQuery}, Response: MatchView{}},
+	{ID: "editMessage", Method: "PATCH", Path: "/api/messages/{id}", Tag: "chat", Summary: "Edit a message",
+		Params: []apiParam{pathParam("id", "")}, Body: EditMessageRequest{}, Response: Message{}},
+	{ID: "deleteMessage", Method: "DELETE", Path: "/api/messages/{id}", Tag: "chat", Summary: "Delete a message",
+		Params: []apiParam{pathParam("id", ""), userIDQuery}, Response: Message{}},
+	{ID: "translateMessage", Method: "POST", Path: "/api/messages/{id}/translate", Tag: "chat", Summary: "Translate a message",
+		Params: []apiParam{pathParam("id", "")}, Body: TranslateMessageRequest{}},
+	{ID: "listMessageRequests", Method: "GET", Path: "/api/message-requests/{uid}", Tag: "chat", Summary: "Pending message requests",
+		Params: []apiParam{pathParam("uid", "")}, Response: []MessageRequest{}},
+	{ID: "acceptMessageRequest", Method: "POST", Path: "/api/message-requests/{id}/accept", Tag: "chat", Summary: "Accept a message request",
+		Params: []apiParam{pathParam("id", "")}, Response: MessageRequest{}},
+	{ID: "declineMessageRequest", Method: "POST", Path: "/api/message-requests/{id}/decline", Tag: "chat", Summary: "Decline a message request",
+		Params: []apiParam{pathParam("id", "")}, Response: MessageRequest{}},
+	{ID: "cancelScheduledMessage", Method: "DELETE", Path: "/api/scheduled-messages/{id}", Tag: "chat", Summary: "Cancel a scheduled message",
+		Params: []apiParam{pathParam("id", ""), userIDQuery}, Response: ScheduledMessage{}},
+
+	{ID: "listNotifications", Method: "GET", Path: "/api/notifications", Tag: "notifications", Summary: "Notification inbox, newest first",
+		Params:   []apiParam{userIDQuery, queryParam("limit", "integer", ""), queryParam("cursor", "string", "")},
+		Response: InboxPage{}},
+	{ID: "markNotificationRead", Method: "POST", Path: "/api/notifications/{id}/read", Tag: "notifications", Summary: "Mark a notification read",
+		Params: []apiParam{pathParam("id", ""), userIDQuery}},
+	{ID: "markAllNotificationsRead", Method: "POST", Path: "/api/notifications/read-all", Tag: "notifications", Summary: "Mark all notifications read",
+		Params: []apiParam{userIDQuery}},
+	{ID: "pollEvents", Method: "GET", Path: "/api/notifications/poll", Tag: "notifications", Summary: "Long-poll for new events",
+		Params: []apiParam{userIDQuery, queryParam("cursor", "string", "")}, Response: EventsPage{}},
+
+	{ID: "searchGyms", Method: "GET", Path: "/api/gyms", Tag: "gyms", Summary: "Search gyms",
+		Params: []apiParam{queryParam("q", "string", "Name or address")}, Response: []Gym{}},
+	{ID: "checkIn", Method: "POST", Path: "/api/checkins", Tag: "gyms", Summary: "Check in at a gym",
+		Body: CheckInRequest{}, Status: http.StatusCreated, Response: CheckIn{}},
+
+	{ID: "blockUser", Method: "POST", Path: "/api/block", Tag: "safety", Summary: "Block a user", Body: BlockRequest{}},
+	{ID: "reportUser", Method: "POST", Path: "/api/report", Tag: "safety", Summary: "Report a user",
+		Body: ReportRequest{}, Status: http.StatusCreated, Response: Report{}},
+	{ID: "reportPhoto", Method: "POST", Path: "/api/photo-reports", Tag: "safety", Summary: "Report a stolen photo",
+		Body: PhotoReportRequest{}, Status: http.StatusCreated, Response: []Report{}},
+	{ID: "listEmergencyContacts", Method: "GET", Path: "/api/safety/contacts/{uid}", Tag: "safety", Summary: "Emergency contacts",
+		Params: []apiParam{pathParam("uid", "")}, Response: []EmergencyContact{}},
+	{ID: "sharePlan", Method: "POST", Path: "/api/safety/share", Tag: "safety", Summary: "Share a workout plan with emergency contacts",
+		Body: SharePlanRequest{}, Status: http.StatusCreated},
+	{ID: "getSharedPlan", Method: "GET", Path: "/api/safety/plans/{token}", Tag: "safety", Summary: "View a shared plan",
+		Params: []apiParam{pathParam("token", "")}, Response: SharedPlanView{}},
+	{ID: "getTenantConfig", Method: "GET", Path: "/api/tenant/config", Tag: "account", Summary: "Branding and settings of the tenant",
+		Response: TenantConfig{}},
+	{ID: "getPublicStats", Method: "GET", Path: "/api/public/stats", Tag: "public", Summary: "Headline stats for the marketing site",
+		Response: PublicStats{}},
+}
+
+// openAPISpec builds an OpenAPI 3 document from apiOperations, with schemas
+// derived from the Go types and their json tags.
+func openAPISpec() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]map[string]any{}
+
+	for _, op := range apiOperations {
+		operation := map[string]any{
+			"operationId": op.ID,
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+		}
+
+		var params []map[string]any
+		form := map[string]any{}
+		var formRequired []string
+		for _, p := range op.Params {
+			if p.In == "formData" {
+				schema := map[string]any{"type": p.Type}
+				if p.Type == "file" {
+					schema = map[string]any{"type": "string", "format": "binary"}
+				}
+				if p.Description != "" {
+					schema["description"] = p.Description
+				}
+				form[p.Name] = schema
+				if p.Required {
+					formRequired = append(formRequired, p.Name)
+				}
+				continue
+			}
+
+			param := map[string]any{
+				"name":     p.Name,
+				"in":       p.In,
+				"required": p.Required,
+				"schema":   map[string]any{"type": p.Type},
+			}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			params = append(params, param)
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		switch {
+		case len(form) > 0:
+			schema := map[string]any{"type": "object", "properties": form}
+			if len(formRequired) > 0 {
+				schema["required"] = formRequired
+			}
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"multipart/form-data": map[string]any{"schema": schema}},
+			}
+		case op.Body != nil:
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{"application/json": map[string]any{
+					"schema": jsonSchema(reflect.TypeOf(op.Body), schemas),
+				}},
+			}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := map[string]any{"description": http.StatusText(status)}
+		if op.Response != nil {
+			response["content"] = map[string]any{"application/json": map[string]any{
+				"schema": jsonSchema(reflect.TypeOf(op.Response), schemas),
+			}}
+		}
+		operation["responses"] = map[string]any{
+			strconv.Itoa(status): response,
+			"default": map[string]any{
+				"description": "Error",
+				"content": map[string]any{"application/json": map[string]any{
+					"schema": jsonSchema(reflect.TypeOf(errorResponse{}), schemas),
+				}},
+			},
+		}
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+
+	schemas["ErrorCode"] = map[string]any{
+		"type":        "string",
+		"enum":        apiErrorCodes,
+		"description": "Values of APIError.code. New codes may be added, so clients should tolerate unknown ones.",
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Gym Bro API",
+			"version":     "1",
+			"description": "Every /api/ path is also served under /api/v1/.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"userToken": map[string]any{"type": "http", "scheme": "bearer", "description": "Optional gbu_ user token"},
+			},
+		},
+	}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// jsonSchema returns the schema of t as encoding/json would marshal it.
+// Named structs are added to schemas once and referenced.
+func jsonSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawType:
+		return map[string]any{"type": "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := jsonSchema(t.Elem(), schemas)
+		if _, ref := schema["$ref"]; ref {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem(), schemas)}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Struct:
+	default:
+		return map[string]any{}
+	}
+
+	name := t.Name()
+	if name == "" {
+		return structSchema(t, schemas)
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if _, ok := schemas[name]; !ok {
+		// Reserve the name first so recursive types terminate.
+		schemas[name] = map[string]any{}
+		schemas[name] = structSchema(t, schemas)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	addStructFields(t, schemas, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func addStructFields(t reflect.Type, schemas, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructFields(ft, schemas, properties, required)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = jsonSchema(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+)
+
+// openAPIDocument returns the rendered API description. It is also what
+// "gymBroServer openapi" prints for client generators.
+func openAPIDocument() []byte {
+	openAPIOnce.Do(func() {
+		var err error
+		if openAPIJSON, err = json.MarshalIndent(openAPISpec(), "", "  "); err != nil {
+			panic(err)
+		}
+	})
+	return openAPIJSON
+}
+
+// OpenAPI serves the API description at /openapi.json.
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(openAPIDocument())
+}
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at /openapi.json.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Gym Bro API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// APIDocs serves Swagger UI at /docs.
+func APIDocs(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/docs" && r.URL.Path != "/docs/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405Z"
+
+// writeFileAtomic replaces path with data so that readers and crashes only
+// ever see the old or the new contents: it writes a temporary file in the
+// same directory, syncs it and renames it over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+func (c *Controller) backupDir() string {
+	return filepath.Join(filepath.Dir(c.dataFile), "backups")
+}
+
+// backups returns the backup files of the data file, newest first.
+func (c *Controller) backups() []string {
+	base := filepath.Base(c.dataFile)
+	ext := filepath.Ext(base)
+	matches, _ := filepath.Glob(filepath.Join(c.backupDir(), strings.TrimSuffix(base, ext)+"-*"+ext))
+	slices.Sort(matches)
+	slices.Reverse(matches)
+	return matches
+}
+
+// rotateBackup keeps the current data file as a timestamped backup when the
+// last one is older than the backup interval, and prunes all but the newest
+// backups. It runs before every save, so backups capture the state from
+// right before a write.
+func (c *Controller) rotateBackup() {
+	if c.backupCount <= 0 || time.Since(c.lastBackup) < c.backupInterval {
+		return
+	}
+	if _, err := os.Stat(c.dataFile); err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.backupDir(), 0755); err != nil {
+		slog.Error("Failed to create backup directory", "err", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	base := filepath.Base(c.dataFile)
+	ext := filepath.Ext(base)
+	path := filepath.Join(c.backupDir(), strings.TrimSuffix(base, ext)+"-"+now.Format(backupTimeFormat)+ext)
+
+	// The data file is only ever replaced by rename, so a hard link keeps
+	// its current contents without copying them.
+	if err := os.Link(c.dataFile, path); err != nil && !errors.Is(err, os.ErrExist) {
+		if err := copyFile(c.dataFile, path); err != nil {
+			slog.Error("Failed to back up data file", "path", path, "err", err)
+			return
+		}
+	}
+	c.lastBackup = now
+
+	backups := c.backups()
+	for _, old := range backups[min(c.backupCount, len(backups)):] {
+		if err := os.Remove(old); err != nil {
+			slog.Error("Failed to remove old backup", "path", old, "err", err)
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (c *Controller) readStorageFile(path string) (Storage, error) {
+	var storage Storage
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return storage, fmt.Errorf("reading data file: %w", err)
+	}
+
+	data, err = c.decodeStorage(data)
+	if err != nil {
+		return storage, fmt.Errorf("decrypting data: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &storage); err != nil {
+		return storage, fmt.Errorf("unmarshaling data: %w: %w", errCorruptStorage, err)
+	}
+	return storage, nil
+}
+
+// errCorruptStorage means a data file was read but does not parse. Only
+// such files are quarantined; decryption and I/O errors leave the file in
+// place, since it is likely fine and the environment is not.
+var errCorruptStorage = errors.New("data file is corrupt")
+
+// quarantineFile moves an unreadable data or journal file aside so the next
+// save doesn't overwrite what may still be recoverable by hand.
+func quarantineFile(path string) {
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+
+	moved := path + ".corrupt-" + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(path, moved); err != nil {
+		slog.Error("Failed to move aside unreadable file", "path", path, "err", err)
+		return
+	}
+	slog.Warn("Moved aside unreadable file", "path", moved)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchmarkSizes are the user counts the storage benchmarks run at. Only
+// the JSON file is measured: there is no SQLite or Postgres backend to
+// compare it with, and benchmarks for one should reuse these sizes and
+// seedStorage.
+var benchmarkSizes = []int{1_000, 10_000}
+
+// newBenchmarkController returns a controller on a temporary data file,
+// filled by seedStorage and saved once.
+func newBenchmarkController(b *testing.B, users int) *Controller {
+	b.Helper()
+	cfg := DefaultConfig()
+	cfg.BackupCount = 0
+	c := newTestController(b, cfg)
+	seedStorage(c, users, 20)
+	if err := c.saveData(); err != nil {
+		b.Fatal(err)
+	}
+	return c
+}
+
+// seedStorage replaces c's storage with users profiles around one city.
+// Every user has swiped on the next swipesPerUser users, a third of them
+// likes, and has one match with a short chat.
+func seedStorage(c *Controller, users, swipesPerUser int) {
+	now := time.Now()
+	c.storage.Users = make([]User, 0, users)
+	for i := range users {
+		lat, lon := 55.70+float64(i%100)/1000, 37.55+float64(i/100%100)/1000
+		c.storage.Users = append(c.storage.Users, User{
+			FirebaseUID: benchmarkUID(i),
+			Name:        fmt.Sprintf("User %d", i),
+			ImageURL:    defaultImageURL,
+			Time:        "18:00",
+			Day:         "Понедельник",
+			TextInfo:    "Тренируюсь три раза в неделю, ищу напарника на жим и становую.",
+			TrainType:   "Силовые",
+			Latitude:    &lat,
+			Longitude:   &lon,
+			CreatedAt:   now.Add(-time.Duration(i) * time.Minute),
+		})
+	}
+
+	c.storage.Swipes = make([]Swipe, 0, users*swipesPerUser)
+	for i := range users {
+		for j := 1; j <= swipesPerUser; j++ {
+			c.storage.Swipes = append(c.storage.Swipes, Swipe{
+				SwiperID:  benchmarkUID(i),
+				TargetID:  benchmarkUID((i + j) % users),
+				IsLike:    j%3 == 0,
+				CreatedAt: now.Add(-time.Duration(j) * time.Hour),
+			})
+		}
+	}
+
+	c.storage.Matches = make([]Match, 0, users/2)
+	c.storage.Messages = make([]Message, 0, users*5/2)
+	for i := 0; i+1 < users; i += 2 {
+		match := Match{User1ID: benchmarkUID(i), User2ID: benchmarkUID(i + 1), CreatedAt: now.Add(-time.Hour)}
+		c.storage.Matches = append(c.storage.Matches, match)
+		for k := range 5 {
+			c.storage.Messages = append(c.storage.Messages, Message{
+				ID:        newID(),
+				MatchID:   match.ID(),
+				SenderID:  []string{match.User1ID, match.User2ID}[k%2],
+				Text:      "Привет! В четверг вечером в зале?",
+				CreatedAt: now.Add(time.Duration(k-5) * time.Minute),
+			})
+		}
+	}
+}
+
+func benchmarkUID(i int) string {
+	return fmt.Sprintf("bench_uid_%06d", i)
+}
+
+func BenchmarkSaveData(b *testing.B) {
+	for _, users := range benchmarkSizes {
+		b.Run(fmt.Sprintf("users=%d", users), func(b *testing.B) {
+			c := newBenchmarkController(b, users)
+			for b.Loop() {
+				if err := c.saveData(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLoadData(b *testing.B) {
+	for _, users := range benchmarkSizes {
+		b.Run(fmt.Sprintf("users=%d", users), func(b *testing.B) {
+			c := newBenchmarkController(b, users)
+			for b.Loop() {
+				if _, err := c.readStorageFile(c.dataFile); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkJournalSwipe(b *testing.B) {
+	c := newBenchmarkController(b, benchmarkSizes[0])
+	swipe := Swipe{SwiperID: benchmarkUID(0), TargetID: benchmarkUID(1), CreatedAt: time.Now()}
+	for b.Loop() {
+		if err := c.journalSwipe(swipe, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
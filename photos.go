@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+const maxPhotos = 6
+
+type ReorderPhotosRequest struct {
+	Photos []string `json:"photos"`
+}
+
+// setPhotos replaces the gallery and keeps ImageURL, which older clients
+// read, pointing at the primary photo.
+func (u *User) setPhotos(photos []string) {
+	if len(photos) == 0 {
+		u.Photos = nil
+		u.ImageURL = defaultImageURL
+		return
+	}
+	u.Photos = photos
+	u.ImageURL = photos[0]
+}
+
+// migratePhotos moves profiles created before galleries to a one-photo
+// gallery.
+func (s *Storage) migratePhotos() {
+	for i, u := range s.Users {
+		if len(u.Photos) == 0 && u.ImageURL != "" && u.ImageURL != defaultImageURL {
+			s.Users[i].setPhotos([]string{u.ImageURL})
+		}
+	}
+}
+
+func (c *Controller) findUserIndex(uid string) (int, bool) {
+	for i, u := range c.storage.Users {
+		if u.FirebaseUID == uid {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// ownProfile only lets requests whose ?userId= is the {uid} of the path
+// through, for routes where users manage their own profile.
+func ownProfile(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("userId") != r.PathValue("uid") {
+			writeError(w, http.StatusForbidden, "PROFILE_FORBIDDEN", "Users can only manage their own profile")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ProfilePhotos handles POST (upload) and PUT (reorder) on
+// /api/profiles/{uid}/photos and DELETE on /api/profiles/{uid}/photos/{name}.
+// The first photo is the primary one, also returned as imageUrl. Uploads
+// flagged by image moderation are left out until an admin approves them.
+func (c *Controller) ProfilePhotos(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	i, ok := c.findUserIndex(uid)
+	if !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+	before := c.storage.Users[i]
+	photos := slices.Clone(before.Photos)
+
+	switch {
+	case r.Method == http.MethodPost:
+		if len(photos)+c.pendingImageCount(uid) >= maxPhotos {
+			writeError(w, http.StatusConflict, "PHOTO_LIMIT_REACHED", "Photo limit reached")
+			return
+		}
+
+		if !c.parseUpload(w, r) {
+			return
+		}
+
+		url, err := c.saveUpload(r)
+		if err != nil {
+			if uploadErrorStatus(err) == http.StatusInternalServerError {
+				slog.ErrorContext(r.Context(), "Failed to save image", "err", err)
+			}
+			writeError(w, uploadErrorStatus(err), errorCode(err, "INTERNAL_ERROR"), err.Error())
+			return
+		}
+
+		moderation := c.moderateUpload(r.Context(), url)
+		// The profile may have changed while moderation ran unlocked.
+		if i, ok = c.findUserIndex(uid); !ok {
+			writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+			return
+		}
+		before = c.storage.Users[i]
+		photos = slices.Clone(before.Photos)
+		if len(photos)+c.pendingImageCount(uid) >= maxPhotos {
+			writeError(w, http.StatusConflict, "PHOTO_LIMIT_REACHED", "Photo limit reached")
+			return
+		}
+
+		if c.holdForModeration(uid, url, false, moderation) {
+			w.Header().Set("X-Image-Status", "pending")
+		} else {
+			photos = append(photos, url)
+			c.indexImage(uid, url)
+		}
+
+	case r.Method == http.MethodPut:
+		var req ReorderPhotosRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+
+		sortedOld, sortedNew := slices.Clone(photos), slices.Clone(req.Photos)
+		slices.Sort(sortedOld)
+		slices.Sort(sortedNew)
+		if !slices.Equal(sortedOld, sortedNew) {
+			writeError(w, http.StatusBadRequest, "INVALID_PHOTO_ORDER", "Photos must be a reordering of the current photos")
+			return
+		}
+		photos = req.Photos
+
+	case r.Method == http.MethodDelete:
+		url := "/images/" + r.PathValue("name")
+		j := slices.Index(photos, url)
+		if j == -1 {
+			writeError(w, http.StatusNotFound, "PHOTO_NOT_FOUND", "Photo not found")
+			return
+		}
+
+		photos = slices.Delete(photos, j, j+1)
+		c.removeImage(url, uid)
+		c.unindexImage(uid, url)
+	}
+
+	c.storage.Users[i].setPhotos(photos)
+	c.recordProfileChanges(before, c.storage.Users[i], uid)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c.storage.Users[i])
+}
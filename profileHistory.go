@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	changedByAdmin = "admin"
+
+	// rapidChangeWindow and rapidChangeCount flag profiles that are edited
+	// many times in a short period.
+	rapidChangeWindow = 24 * time.Hour
+	rapidChangeCount  = 5
+)
+
+// ProfileChange records one field of a profile edit. ChangedBy is the user's
+// own ID or "admin".
+type ProfileChange struct {
+	UserID    string    `json:"userId"`
+	ChangedBy string    `json:"changedBy"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"oldValue"`
+	NewValue  string    `json:"newValue"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+type SuspiciousProfile struct {
+	UserID  string          `json:"userId"`
+	Reasons []string        `json:"reasons"`
+	Changes []ProfileChange `json:"changes"`
+}
+
+func profileFields(u User) map[string]string {
+	return map[string]string{
+		"name":         u.Name,
+		"imageUrl":     u.ImageURL,
+		"photos":       strings.Join(u.Photos, " "),
+		"time":         u.Time,
+		"day":          u.Day,
+		"availability": u.availabilityString(),
+		"textInfo":     u.TextInfo,
+		"trainType":    u.TrainType,
+		"contact":      u.Contact,
+		"homeGymId":    u.HomeGymID,
+	}
+}
+
+// recordProfileChanges appends a history entry for every field that differs
+// between before and after. The caller must hold c.mu and save data.
+func (c *Controller) recordProfileChanges(before, after User, changedBy string) {
+	now := time.Now()
+	old, updated := profileFields(before), profileFields(after)
+
+	fields := make([]string, 0, len(old))
+	for field := range old {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if old[field] == updated[field] {
+			continue
+		}
+		c.storage.ProfileHistory = append(c.storage.ProfileHistory, ProfileChange{
+			UserID:    after.FirebaseUID,
+			ChangedBy: changedBy,
+			Field:     field,
+			OldValue:  old[field],
+			NewValue:  updated[field],
+			ChangedAt: now,
+		})
+	}
+}
+
+func (c *Controller) profileHistory(uid string) []ProfileChange {
+	changes := []ProfileChange{}
+	for _, change := range c.storage.ProfileHistory {
+		if change.UserID == uid {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func (c *Controller) forgetProfileHistory(uid string) {
+	history := c.storage.ProfileHistory[:0]
+	for _, change := range c.storage.ProfileHistory {
+		if change.UserID != uid {
+			history = append(history, change)
+		}
+	}
+	c.storage.ProfileHistory = history
+}
+
+// ProfileHistory handles GET /api/profiles/{uid}/history; users can only
+// view their own history.
+func (c *Controller) ProfileHistory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.profileHistory(r.PathValue("uid")))
+}
+
+// suspiciousProfiles flags photo changes made after the user already had a
+// match, a common bait-and-switch, and bursts of edits within
+// rapidChangeWindow.
+func (c *Controller) suspiciousProfiles() []SuspiciousProfile {
+	firstMatch := make(map[string]time.Time)
+	for _, m := range c.storage.Matches {
+		for _, uid := range []string{m.User1ID, m.User2ID} {
+			if t, ok := firstMatch[uid]; !ok || m.CreatedAt.Before(t) {
+				firstMatch[uid] = m.CreatedAt
+			}
+		}
+	}
+
+	byUser := make(map[string][]ProfileChange)
+	for _, change := range c.storage.ProfileHistory {
+		if change.ChangedBy != changedByAdmin {
+			byUser[change.UserID] = append(byUser[change.UserID], change)
+		}
+	}
+
+	result := []SuspiciousProfile{}
+	for uid, changes := range byUser {
+		var reasons []string
+
+		if matchedAt, ok := firstMatch[uid]; ok {
+			for _, change := range changes {
+				if change.Field == "imageUrl" && change.ChangedAt.After(matchedAt) {
+					reasons = append(reasons, "photo_changed_after_match")
+					break
+				}
+			}
+		}
+
+		// History is appended in time order, so a window of
+		// rapidChangeCount consecutive entries is enough.
+		for i := rapidChangeCount - 1; i < len(changes); i++ {
+			if changes[i].ChangedAt.Sub(changes[i-rapidChangeCount+1].ChangedAt) <= rapidChangeWindow {
+				reasons = append(reasons, "rapid_changes")
+				break
+			}
+		}
+
+		if len(reasons) > 0 {
+			result = append(result, SuspiciousProfile{UserID: uid, Reasons: reasons, Changes: changes})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
+}
+
+// AdminProfileChanges handles GET /admin/profile-changes/suspicious.
+func (c *Controller) AdminProfileChanges(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.suspiciousProfiles())
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// A minimal protobuf wire format codec for the gRPC API. It only knows the
+// scalar types proto/gymbro/v1/gymbro.proto uses.
+
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("truncated protobuf message")
+
+// protoBuffer encodes a message. Like proto3, scalar fields with their zero
+// value are left out.
+type protoBuffer struct {
+	b []byte
+}
+
+func (p *protoBuffer) tag(field, wire int) {
+	p.b = binary.AppendUvarint(p.b, uint64(field)<<3|uint64(wire))
+}
+
+func (p *protoBuffer) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	p.tag(field, protoVarint)
+	p.b = binary.AppendUvarint(p.b, v)
+}
+
+func (p *protoBuffer) int(field int, v int64) {
+	p.uint(field, uint64(v))
+}
+
+func (p *protoBuffer) bool(field int, v bool) {
+	if v {
+		p.uint(field, 1)
+	}
+}
+
+func (p *protoBuffer) bytes(field int, b []byte) {
+	p.tag(field, protoBytes)
+	p.b = binary.AppendUvarint(p.b, uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *protoBuffer) string(field int, s string) {
+	if s != "" {
+		p.bytes(field, []byte(s))
+	}
+}
+
+func (p *protoBuffer) strings(field int, values []string) {
+	for _, s := range values {
+		p.bytes(field, []byte(s))
+	}
+}
+
+// optionalDouble encodes an optional double, which is sent even when zero.
+func (p *protoBuffer) optionalDouble(field int, v *float64) {
+	if v == nil {
+		return
+	}
+	p.tag(field, protoFixed64)
+	p.b = binary.LittleEndian.AppendUint64(p.b, math.Float64bits(*v))
+}
+
+// message encodes a nested message; it is sent even when empty.
+func (p *protoBuffer) message(field int, encode func(*protoBuffer)) {
+	var m protoBuffer
+	encode(&m)
+	p.bytes(field, m.b)
+}
+
+// timestamp encodes a google.protobuf.Timestamp, leaving zero times out.
+func (p *protoBuffer) timestamp(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	p.message(field, func(m *protoBuffer) {
+		m.int(1, t.Unix())
+		m.int(2, int64(t.Nanosecond()))
+	})
+}
+
+// protoValue is one decoded field. n holds varint and fixed values, b the
+// contents of length-delimited ones.
+type protoValue struct {
+	wire int
+	n    uint64
+	b    []byte
+}
+
+func (v protoValue) String() string { return string(v.b) }
+func (v protoValue) Int() int64     { return int64(v.n) }
+func (v protoValue) Bool() bool     { return v.n != 0 }
+
+func (v protoValue) Double() float64 {
+	if v.wire != protoFixed64 {
+		return 0
+	}
+	return math.Float64frombits(v.n)
+}
+
+// decodeProto calls fn with each field of a message in order.
+func decodeProto(data []byte, fn func(field int, v protoValue)) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+
+		v := protoValue{wire: int(key & 7)}
+		switch v.wire {
+		case protoVarint:
+			if v.n, n = binary.Uvarint(data); n <= 0 {
+				return errProtoTruncated
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return errProtoTruncated
+			}
+			v.n, data = binary.LittleEndian.Uint64(data), data[8:]
+		case protoFixed32:
+			if len(data) < 4 {
+				return errProtoTruncated
+			}
+			v.n, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errProtoTruncated
+			}
+			v.b, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return errors.New("unsupported protobuf wire type")
+		}
+		fn(int(key>>3), v)
+	}
+	return nil
+}
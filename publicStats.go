@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	publicStatsInterval = 15 * time.Minute
+	publicStatsMaxAge   = 5 * time.Minute
+
+	publicStatsPerMinute = 30
+	publicStatsBurst     = 10
+)
+
+// PublicStats are the headline numbers shown on the marketing site.
+type PublicStats struct {
+	TotalUsers  int `json:"totalUsers"`
+	MatchesMade int `json:"matchesMade"`
+	// SessionsCompletedThisMonth counts session proposals sent in chats for
+	// a date earlier this month.
+	SessionsCompletedThisMonth int `json:"sessionsCompletedThisMonth"`
+	// CitiesCovered counts sign-up regions with at least one profile.
+	CitiesCovered int       `json:"citiesCovered"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// publicStatsCache holds the last computed stats; it is nil until the first
+// refresh.
+type publicStatsCache struct {
+	stats atomic.Pointer[PublicStats]
+}
+
+// computePublicStats counts the public stats. The caller must hold c.mu.
+func (c *Controller) computePublicStats(now time.Time) PublicStats {
+	stats := PublicStats{
+		TotalUsers:  len(c.storage.Users),
+		MatchesMade: len(c.storage.Matches),
+		UpdatedAt:   now,
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, m := range c.storage.Messages {
+		if m.Type != MessageTypeSessionProposal || m.DeletedAt != nil {
+			continue
+		}
+		var card SessionProposalCard
+		if json.Unmarshal(m.Payload, &card) != nil {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", card.Date)
+		if err == nil && !date.Before(monthStart) && date.Before(now) {
+			stats.SessionsCompletedThisMonth++
+		}
+	}
+
+	covered := make(map[string]bool)
+	for _, u := range c.storage.Users {
+		lat, lon, ok := c.signupLocation(u.Latitude, u.Longitude, u.HomeGymID)
+		if !ok {
+			continue
+		}
+		if region, _, in := c.regionFor(lat, lon); in {
+			covered[region.Name] = true
+		}
+	}
+	stats.CitiesCovered = len(covered)
+
+	return stats
+}
+
+// refreshPublicStats recomputes the stats served on /api/public/stats.
+func (c *Controller) refreshPublicStats() {
+	c.mu.RLock()
+	stats := c.computePublicStats(time.Now().UTC())
+	c.mu.RUnlock()
+
+	c.publicStats.stats.Store(&stats)
+}
+
+// PublicStats handles GET /api/public/stats. It serves the stats of the last
+// background refresh and never touches storage, so it is safe to expose
+// without authentication.
+func (c *Controller) PublicStats(w http.ResponseWriter, r *http.Request) {
+	stats := c.publicStats.stats.Load()
+	if stats == nil {
+		writeError(w, http.StatusServiceUnavailable, "STATS_NOT_READY", "Stats are not computed yet")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(publicStatsMaxAge.Seconds())))
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const defaultQuickReplyLang = "ru"
+
+// defaultQuickReplies are used for a language until an admin overrides them.
+// Templates may reference the partner's {day}, {time} and {trainType}.
+var defaultQuickReplies = map[string][]string{
+	"ru": {
+		"Привет! Потренируемся вместе?",
+		"{day} {time} подходит?",
+		"Какой зал?",
+		"Тоже люблю {trainType}!",
+	},
+	"en": {
+		"Hi! Want to train together?",
+		"Does {day} {time} work for you?",
+		"Which gym?",
+		"I'm into {trainType} too!",
+	},
+}
+
+func (c *Controller) quickReplyTemplates(lang string) []string {
+	if templates, ok := c.storage.QuickReplies[lang]; ok {
+		return templates
+	}
+	if templates, ok := defaultQuickReplies[lang]; ok {
+		return templates
+	}
+	if templates, ok := c.storage.QuickReplies[defaultQuickReplyLang]; ok {
+		return templates
+	}
+	return defaultQuickReplies[defaultQuickReplyLang]
+}
+
+func renderQuickReplies(templates []string, partner User) []string {
+	replacer := strings.NewReplacer(
+		"{day}", partner.Day,
+		"{time}", partner.Time,
+		"{trainType}", strings.ToLower(partner.TrainType),
+	)
+
+	replies := make([]string, 0, len(templates))
+	for _, t := range templates {
+		// Skip suggestions the partner profile has no data for.
+		if (strings.Contains(t, "{day}") && partner.Day == "") ||
+			(strings.Contains(t, "{time}") && partner.Time == "") ||
+			(strings.Contains(t, "{trainType}") && partner.TrainType == "") {
+			continue
+		}
+		replies = append(replies, replacer.Replace(t))
+	}
+	return replies
+}
+
+// QuickReplies handles GET /api/matches/{matchId}/quick-replies?userId=...&lang=...
+func (c *Controller) QuickReplies(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("userId")
+	match, ok := c.findMatch(r.PathValue("matchId"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "MATCH_NOT_FOUND", "Match not found")
+		return
+	}
+	if !match.Has(userID) {
+		writeError(w, http.StatusForbidden, "NOT_MATCH_PARTICIPANT", "Not a participant of this match")
+		return
+	}
+
+	partner, _ := c.findUser(match.Partner(userID))
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = defaultQuickReplyLang
+	}
+
+	writeJSON(w, http.StatusOK, renderQuickReplies(c.quickReplyTemplates(lang), partner))
+}
+
+// AdminQuickReplies lets admins read and replace the per-language templates.
+func (c *Controller) AdminQuickReplies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		templates := make(map[string][]string)
+		for lang, t := range defaultQuickReplies {
+			templates[lang] = t
+		}
+		for lang, t := range c.storage.QuickReplies {
+			templates[lang] = t
+		}
+		writeJSON(w, http.StatusOK, templates)
+
+	case http.MethodPut:
+		var templates map[string][]string
+		if err := json.NewDecoder(r.Body).Decode(&templates); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+
+		c.storage.QuickReplies = templates
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+		writeJSON(w, http.StatusOK, templates)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ranker orders feed candidates for a requester, best first.
+type Ranker interface {
+	Rank(requester User, candidates []User) []User
+}
+
+// ScoreExplainer is implemented by rankers that can break their scores down
+// for the decision log.
+type ScoreExplainer interface {
+	Explain(requester User, candidates []User) map[string]ScoreBreakdown
+}
+
+// CompatibilityRanker scores candidates by training type, schedule and
+// distance. Candidates with equal scores keep their storage order.
+type CompatibilityRanker struct {
+	TrainTypeWeight float64
+	DayWeight       float64
+	TimeWeight      float64
+	DistanceWeight  float64
+
+	// TimeWindowMinutes is the start-time difference at which the time
+	// score drops to zero.
+	TimeWindowMinutes float64
+	// DistanceRangeKm is the distance at which the distance score drops to
+	// zero.
+	DistanceRangeKm float64
+
+	// NewUserWeight boosts profiles created within NewUserWindow, fading
+	// out linearly over the window, so newcomers get early matches. A
+	// boosted profile stops being boosted once it has been swiped
+	// NewUserMaxExposures times, and at most NewUserMaxPerDeck candidates
+	// per deck get the boost. A zero window disables it.
+	NewUserWeight       float64
+	NewUserWindow       time.Duration
+	NewUserMaxExposures int
+	NewUserMaxPerDeck   int
+
+	// Exposures returns how many times each user has been swiped.
+	Exposures func() map[string]int
+
+	// FastRejectWeight penalizes candidates of a training type the
+	// requester keeps dismissing within seconds, scaled by the share of
+	// such cards they fast-rejected.
+	FastRejectWeight float64
+	// FastRejects returns the requester's fast-reject rate per training
+	// type.
+	FastRejects func(userID string) map[string]float64
+}
+
+func DefaultCompatibilityRanker() CompatibilityRanker {
+	return CompatibilityRanker{
+		TrainTypeWeight:   3,
+		DayWeight:         2,
+		TimeWeight:        2,
+		DistanceWeight:    2,
+		TimeWindowMinutes: 180,
+		DistanceRangeKm:   20,
+
+		NewUserWeight:       3,
+		NewUserWindow:       48 * time.Hour,
+		NewUserMaxExposures: 100,
+		NewUserMaxPerDeck:   3,
+
+		FastRejectWeight: 2,
+	}
+}
+
+// ScoreBreakdown is a candidate's score split into its terms.
+type ScoreBreakdown struct {
+	TrainType float64 `json:"trainType"`
+	Day       float64 `json:"day"`
+	Time      float64 `json:"time"`
+	Distance  float64 `json:"distance"`
+	NewUser   float64 `json:"newUser"`
+	// FastReject is zero or negative.
+	FastReject float64 `json:"fastReject"`
+}
+
+func (b ScoreBreakdown) Total() float64 {
+	return b.TrainType + b.Day + b.Time + b.Distance + b.NewUser + b.FastReject
+}
+
+func (r CompatibilityRanker) Score(requester, candidate User) float64 {
+	return r.Breakdown(requester, candidate).Total()
+}
+
+// Breakdown scores candidate for requester without the new user boost,
+// which depends on the rest of the deck.
+func (r CompatibilityRanker) Breakdown(requester, candidate User) ScoreBreakdown {
+	var b ScoreBreakdown
+
+	if requester.TrainType != "" && strings.EqualFold(requester.TrainType, candidate.TrainType) {
+		b.TrainType = r.TrainTypeWeight
+	}
+
+	if requester.Day != "" && strings.EqualFold(requester.Day, candidate.Day) {
+		b.Day = r.DayWeight
+	}
+
+	if a, err := parseClock(requester.Time); err == nil {
+		if c, err := parseClock(candidate.Time); err == nil {
+			diff := math.Abs(float64(a - c))
+			b.Time = r.TimeWeight * math.Max(0, 1-diff/r.TimeWindowMinutes)
+		}
+	}
+
+	if distance, ok := DistanceKm(requester, candidate); ok {
+		b.Distance = r.DistanceWeight * math.Max(0, 1-distance/r.DistanceRangeKm)
+	}
+
+	return b
+}
+
+// newUserBoost is the boost term for candidate at now, ignoring exposure
+// caps.
+func (r CompatibilityRanker) newUserBoost(candidate User, now time.Time) float64 {
+	if r.NewUserWindow <= 0 || candidate.CreatedAt.IsZero() {
+		return 0
+	}
+
+	age := now.Sub(candidate.CreatedAt)
+	if age < 0 || age >= r.NewUserWindow {
+		return 0
+	}
+	return r.NewUserWeight * (1 - float64(age)/float64(r.NewUserWindow))
+}
+
+// Explain returns the score breakdown of every candidate as Rank sees it.
+func (r CompatibilityRanker) Explain(requester User, candidates []User) map[string]ScoreBreakdown {
+	var fastRejects map[string]float64
+	if r.FastRejects != nil && r.FastRejectWeight > 0 {
+		fastRejects = r.FastRejects(requester.FirebaseUID)
+	}
+
+	breakdowns := make(map[string]ScoreBreakdown, len(candidates))
+	for _, c := range candidates {
+		b := r.Breakdown(requester, c)
+		b.FastReject = -r.FastRejectWeight * fastRejects[trainTypeKey(c.TrainType)]
+		breakdowns[c.FirebaseUID] = b
+	}
+	r.boostNewUsers(candidates, breakdowns)
+	return breakdowns
+}
+
+func (r CompatibilityRanker) Rank(requester User, candidates []User) []User {
+	breakdowns := r.Explain(requester, candidates)
+
+	ranked := append([]User(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return breakdowns[ranked[i].FirebaseUID].Total() > breakdowns[ranked[j].FirebaseUID].Total()
+	})
+	return ranked
+}
+
+// boostNewUsers adds the new user boost to breakdowns, for the best scoring
+// newcomers first and up to the per-deck cap.
+func (r CompatibilityRanker) boostNewUsers(candidates []User, breakdowns map[string]ScoreBreakdown) {
+	now := time.Now()
+
+	var boosted []User
+	for _, c := range candidates {
+		if r.newUserBoost(c, now) > 0 {
+			boosted = append(boosted, c)
+		}
+	}
+	if len(boosted) == 0 {
+		return
+	}
+
+	var exposures map[string]int
+	if r.Exposures != nil && r.NewUserMaxExposures > 0 {
+		exposures = r.Exposures()
+	}
+
+	sort.SliceStable(boosted, func(i, j int) bool {
+		return breakdowns[boosted[i].FirebaseUID].Total() > breakdowns[boosted[j].FirebaseUID].Total()
+	})
+
+	applied := 0
+	for _, c := range boosted {
+		if r.NewUserMaxPerDeck > 0 && applied >= r.NewUserMaxPerDeck {
+			break
+		}
+		if exposures != nil && exposures[c.FirebaseUID] >= r.NewUserMaxExposures {
+			continue
+		}
+		b := breakdowns[c.FirebaseUID]
+		b.NewUser = r.newUserBoost(c, now)
+		breakdowns[c.FirebaseUID] = b
+		applied++
+	}
+}
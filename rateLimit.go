@@ -0,0 +1,145 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rateLimitPurgeInterval = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a token-bucket limiter keyed by user ID for authenticated
+// requests and by client IP otherwise. A nil limiter allows everything.
+type RateLimiter struct {
+	rate       float64 // tokens per second
+	burst      float64
+	trustProxy bool
+	// byIP ignores the caller's identity, for public endpoints.
+	byIP bool
+	// identify returns the user a request is authenticated as. The userId
+	// parameter is never used: clients could pick a fresh one per request.
+	identify func(*http.Request) (string, bool)
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewRateLimiter(perMinute, burst int, trustProxy bool) *RateLimiter {
+	if perMinute <= 0 || burst <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		rate:       float64(perMinute) / 60,
+		burst:      float64(burst),
+		trustProxy: trustProxy,
+		buckets:    make(map[string]*bucket),
+	}
+}
+
+// NewIPRateLimiter returns a limiter keyed by client IP only.
+func NewIPRateLimiter(perMinute, burst int, trustProxy bool) *RateLimiter {
+	l := NewRateLimiter(perMinute, burst, trustProxy)
+	if l != nil {
+		l.byIP = true
+	}
+	return l
+}
+
+// take removes a token from key's bucket. When the bucket is empty it
+// returns how long until the next token is available.
+func (l *RateLimiter) take(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// WithIdentity keys authenticated requests by the user identify returns.
+func (l *RateLimiter) WithIdentity(identify func(*http.Request) (string, bool)) *RateLimiter {
+	if l != nil {
+		l.identify = identify
+	}
+	return l
+}
+
+// clientIP returns the address of the client. Behind a trusted proxy that is
+// the rightmost X-Forwarded-For entry, the one the proxy appended; entries
+// to its left come from the client and can be forged.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// key identifies the client: the authenticated user, or the client IP.
+func (l *RateLimiter) key(r *http.Request) string {
+	if !l.byIP && l.identify != nil {
+		if uid, ok := l.identify(r); ok {
+			return "uid:" + uid
+		}
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+func (l *RateLimiter) Handler(next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.take(l.key(r), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Too many requests")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Purge drops buckets that have refilled completely; they are equivalent to
+// a new bucket.
+func (l *RateLimiter) Purge() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if time.Since(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
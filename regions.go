@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Region is a city where sign-ups are open: a center and a radius around it.
+type Region struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	RadiusKm  float64 `json:"radiusKm"`
+}
+
+// WaitlistEntry is a sign-up from outside the open regions. It is notified
+// once a region covering it opens and removed when the person signs up.
+type WaitlistEntry struct {
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Contact   string    `json:"contact,omitempty"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	TenantID  string    `json:"tenantId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Nearest is the closest open region, to see where demand is.
+	Nearest    string     `json:"nearest,omitempty"`
+	DistanceKm float64    `json:"distanceKm,omitempty"`
+	NotifiedAt *time.Time `json:"notifiedAt,omitempty"`
+}
+
+// ParseRegions reads a comma-separated list of name:latitude:longitude:radiusKm.
+func ParseRegions(value string) ([]Region, error) {
+	var regions []Region
+	for _, item := range splitList(value) {
+		fields := strings.Split(item, ":")
+		if len(fields) != 4 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid region %q", item)
+		}
+
+		var coords [3]float64
+		for i, f := range fields[1:] {
+			n, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid region %q", item)
+			}
+			coords[i] = n
+		}
+		r := Region{Name: fields[0], Latitude: coords[0], Longitude: coords[1], RadiusKm: coords[2]}
+		if r.Latitude < -90 || r.Latitude > 90 || r.Longitude < -180 || r.Longitude > 180 || r.RadiusKm <= 0 {
+			return nil, fmt.Errorf("invalid region %q", item)
+		}
+		regions = append(regions, r)
+	}
+	return regions, nil
+}
+
+// regionFor returns the open region containing the point or, when none
+// does, the nearest one and false.
+func (c *Controller) regionFor(lat, lon float64) (Region, float64, bool) {
+	var nearest Region
+	best := math.Inf(1)
+	for _, r := range c.regions {
+		d := HaversineKm(lat, lon, r.Latitude, r.Longitude)
+		if d <= r.RadiusKm {
+			return r, d, true
+		}
+		if d < best {
+			nearest, best = r, d
+		}
+	}
+	return nearest, best, false
+}
+
+// signupLocation is where a new profile is, from its coordinates or else its
+// home gym.
+func (c *Controller) signupLocation(lat, lon *float64, homeGymID string) (float64, float64, bool) {
+	if lat != nil && lon != nil {
+		return *lat, *lon, true
+	}
+	if i, ok := c.findGym(homeGymID); ok {
+		return c.storage.Gyms[i].Latitude, c.storage.Gyms[i].Longitude, true
+	}
+	return 0, 0, false
+}
+
+// checkSignupRegion decides whether a new profile may sign up. Sign-ups
+// outside every open region are put on the waitlist and answered with 202;
+// it returns false when it has written the response. The caller must hold
+// c.mu.
+func (c *Controller) checkSignupRegion(w http.ResponseWriter, r *http.Request, uid, name string) bool {
+	if len(c.regions) == 0 {
+		return true
+	}
+
+	lat, lon, err := formCoordinates(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_COORDINATES", err.Error())
+		return false
+	}
+	latitude, longitude, ok := c.signupLocation(lat, lon, r.FormValue("homeGymId"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "LOCATION_REQUIRED", "Location is required to sign up")
+		return false
+	}
+
+	region, distance, open := c.regionFor(latitude, longitude)
+	if open {
+		c.removeFromWaitlist(uid)
+		return true
+	}
+
+	entry := WaitlistEntry{
+		UserID:     uid,
+		Name:       name,
+		Contact:    r.FormValue("contact"),
+		Latitude:   latitude,
+		Longitude:  longitude,
+		TenantID:   tenantID(r),
+		CreatedAt:  time.Now(),
+		Nearest:    region.Name,
+		DistanceKm: math.Round(distance),
+	}
+	if i, ok := c.waitlistIndex(uid); ok {
+		entry.CreatedAt = c.storage.Waitlist[i].CreatedAt
+		c.storage.Waitlist[i] = entry
+	} else {
+		c.storage.Waitlist = append(c.storage.Waitlist, entry)
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return false
+	}
+
+	slog.InfoContext(r.Context(), "Sign-up waitlisted", "nearest", region.Name, "distanceKm", entry.DistanceKm)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"waitlisted": true,
+		"entry":      entry,
+	})
+	return false
+}
+
+func (c *Controller) waitlistIndex(uid string) (int, bool) {
+	for i, e := range c.storage.Waitlist {
+		if e.UserID == uid {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// removeFromWaitlist drops uid's entry once they could sign up. The caller
+// must hold c.mu and save data.
+func (c *Controller) removeFromWaitlist(uid string) {
+	if i, ok := c.waitlistIndex(uid); ok {
+		c.storage.Waitlist = append(c.storage.Waitlist[:i], c.storage.Waitlist[i+1:]...)
+	}
+}
+
+// notifyOpenedRegions tells waitlisted people that a region covering them
+// has opened. Regions only change with the configuration, so this runs once
+// at startup; entries stay until the person signs up, and are only notified
+// once.
+func (c *Controller) notifyOpenedRegions() {
+	if len(c.regions) == 0 || len(c.storage.Waitlist) == 0 {
+		return
+	}
+
+	now := time.Now()
+	notified := 0
+	for i, e := range c.storage.Waitlist {
+		region, _, open := c.regionFor(e.Latitude, e.Longitude)
+		if !open || e.NotifiedAt != nil {
+			continue
+		}
+
+		message := "Gym Bro is now open in " + region.Name + "! Open the app to finish signing up."
+		for contactType, format := range contactFormats {
+			if format.MatchString(e.Contact) {
+				c.sendToContact(e.UserID, contactType, e.Contact, message, nil)
+				break
+			}
+		}
+		c.notifyUser(e.UserID, Notification{
+			Title: "Gym Bro is open near you",
+			Body:  message,
+			Data:  map[string]string{"type": "region_opened", "region": region.Name},
+		})
+
+		c.storage.Waitlist[i].NotifiedAt = &now
+		notified++
+	}
+	if notified == 0 {
+		return
+	}
+
+	slog.Info("Notified waitlisted sign-ups of opened regions", "count", notified)
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to save data", "err", err)
+	}
+}
+
+// AdminWaitlist handles GET /admin/waitlist, listing waitlisted sign-ups
+// with counts per nearest open region.
+func (c *Controller) AdminWaitlist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	byRegion := make(map[string]int)
+	for _, e := range c.storage.Waitlist {
+		byRegion[e.Nearest]++
+	}
+
+	entries := c.storage.Waitlist
+	if entries == nil {
+		entries = []WaitlistEntry{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"regions":  c.regions,
+		"byRegion": byRegion,
+		"entries":  entries,
+	})
+}
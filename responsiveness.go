@@ -0,0 +1,75 @@
+package main
+
+import "time"
+
+const (
+	minResponseSamples = 3
+	ghostSamples       = 5
+	ghostResponseRate  = 0.2
+)
+
+// ResponseStats tracks how a user reacts to the first message of a new
+// conversation.
+type ResponseStats struct {
+	FirstMessagesReceived int   `json:"firstMessagesReceived"`
+	Responded             int   `json:"responded"`
+	TotalResponseSeconds  int64 `json:"totalResponseSeconds"`
+}
+
+func (s ResponseStats) ResponseRate() float64 {
+	if s.FirstMessagesReceived == 0 {
+		return 0
+	}
+	return float64(s.Responded) / float64(s.FirstMessagesReceived)
+}
+
+func (s ResponseStats) AverageResponseTime() time.Duration {
+	if s.Responded == 0 {
+		return 0
+	}
+	return time.Duration(s.TotalResponseSeconds/int64(s.Responded)) * time.Second
+}
+
+// Indicator returns a coarse responsiveness label for candidate cards, or an
+// empty string while there is not enough data.
+func (s ResponseStats) Indicator() string {
+	if s.FirstMessagesReceived < minResponseSamples {
+		return ""
+	}
+
+	rate := s.ResponseRate()
+	switch {
+	case rate >= 0.7 && s.AverageResponseTime() <= 6*time.Hour:
+		return "high"
+	case rate >= 0.4:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// IsGhost reports whether the user chronically ignores first messages.
+func (s ResponseStats) IsGhost() bool {
+	return s.FirstMessagesReceived >= ghostSamples && s.ResponseRate() < ghostResponseRate
+}
+
+func (c *Controller) recordFirstMessage(recipientID string) {
+	if c.storage.ResponseStats == nil {
+		c.storage.ResponseStats = make(map[string]ResponseStats)
+	}
+
+	stats := c.storage.ResponseStats[recipientID]
+	stats.FirstMessagesReceived++
+	c.storage.ResponseStats[recipientID] = stats
+}
+
+func (c *Controller) recordFirstResponse(responderID string, delay time.Duration) {
+	if c.storage.ResponseStats == nil {
+		c.storage.ResponseStats = make(map[string]ResponseStats)
+	}
+
+	stats := c.storage.ResponseStats[responderID]
+	stats.Responded++
+	stats.TotalResponseSeconds += int64(delay / time.Second)
+	c.storage.ResponseStats[responderID] = stats
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Middleware wraps a handler, for example to take c.mu or check a token
+// scope.
+type Middleware func(http.HandlerFunc) http.HandlerFunc
+
+// Router registers handlers per method and path on a ServeMux. Paths use
+// ServeMux wildcards such as /api/feed/{uid}, which handlers read with
+// r.PathValue. Other methods get a 405 error with an Allow header. Every
+// route runs the router's middleware, outermost first, then its own.
+type Router struct {
+	mux        *http.ServeMux
+	middleware []Middleware
+
+	// preflight answers OPTIONS on every registered path: CORS preflights
+	// don't use the method of the route they are for.
+	preflight http.HandlerFunc
+	// methods lists the methods registered on each path.
+	methods map[string][]string
+}
+
+func NewRouter(mux *http.ServeMux, preflight http.HandlerFunc) *Router {
+	return &Router{mux: mux, preflight: preflight, methods: make(map[string][]string)}
+}
+
+// With returns a router sharing rt's mux that runs mw after rt's middleware.
+func (rt *Router) With(mw ...Middleware) *Router {
+	return &Router{
+		mux:        rt.mux,
+		middleware: append(slices.Clone(rt.middleware), mw...),
+		preflight:  rt.preflight,
+		methods:    rt.methods,
+	}
+}
+
+// Handle registers h for method on path, wrapped in the router's middleware
+// and then mw.
+func (rt *Router) Handle(method, path string, h http.HandlerFunc, mw ...Middleware) {
+	chain := append(slices.Clone(rt.middleware), mw...)
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i](h)
+	}
+	rt.mux.HandleFunc(method+" "+path, h)
+
+	if _, ok := rt.methods[path]; !ok {
+		rt.mux.HandleFunc(path, rt.unmatched(path))
+	}
+	rt.methods[path] = append(rt.methods[path], method)
+}
+
+// unmatched answers requests to path whose method has no route.
+func (rt *Router) unmatched(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions && rt.preflight != nil {
+			rt.preflight(w, r)
+			return
+		}
+
+		allow := slices.Clone(rt.methods[path])
+		if slices.Contains(allow, http.MethodGet) {
+			allow = append(allow, http.MethodHead)
+		}
+		if rt.preflight != nil {
+			allow = append(allow, http.MethodOptions)
+		}
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+	}
+}
+
+var versionSegment = regexp.MustCompile(`^/api/v[0-9]+/`)
+
+// routeLabel names the route r matched for metrics: its path without the
+// method, with versioned paths counted under their legacy /api/ path.
+func routeLabel(r *http.Request) string {
+	_, path, found := strings.Cut(r.Pattern, " ")
+	if !found {
+		path = r.Pattern
+	}
+	return versionSegment.ReplaceAllString(path, "/api/")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	maxEmergencyContacts = 3
+	sharedPlanTTL        = 24 * time.Hour
+)
+
+type EmergencyContact struct {
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+	Relation string `json:"relation,omitempty"`
+}
+
+// SharedPlan is a session plan a user shares with their trusted contacts
+// before meeting a new partner.
+type SharedPlan struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"userId"`
+	PartnerID string    `json:"partnerId"`
+	Time      string    `json:"time"`
+	Gym       string    `json:"gym"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type SharePlanRequest struct {
+	UserID    string `json:"userId"`
+	PartnerID string `json:"partnerId"`
+	Time      string `json:"time"`
+	Gym       string `json:"gym"`
+}
+
+type SharedPlanView struct {
+	Partner   User      `json:"partner"`
+	Time      string    `json:"time"`
+	Gym       string    `json:"gym"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (c *Controller) EmergencyContacts(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("uid")
+
+	if _, ok := c.findUser(userID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		contacts := c.storage.EmergencyContacts[userID]
+		if contacts == nil {
+			contacts = []EmergencyContact{}
+		}
+		writeJSON(w, http.StatusOK, contacts)
+
+	case http.MethodPut:
+		var contacts []EmergencyContact
+		if err := json.NewDecoder(r.Body).Decode(&contacts); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+
+		if len(contacts) > maxEmergencyContacts {
+			writeError(w, http.StatusBadRequest, "TOO_MANY_EMERGENCY_CONTACTS", "Too many emergency contacts")
+			return
+		}
+
+		for _, contact := range contacts {
+			if contact.Name == "" || contact.Phone == "" {
+				writeError(w, http.StatusBadRequest, "INVALID_EMERGENCY_CONTACT", "Contact name and phone are required")
+				return
+			}
+		}
+
+		if c.storage.EmergencyContacts == nil {
+			c.storage.EmergencyContacts = make(map[string][]EmergencyContact)
+		}
+		c.storage.EmergencyContacts[userID] = contacts
+
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, contacts)
+
+	}
+}
+
+// SharePlan creates a time-limited link describing an upcoming session. The
+// client forwards the link to the returned trusted contacts.
+func (c *Controller) SharePlan(w http.ResponseWriter, r *http.Request) {
+	var req SharePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	if _, ok := c.findUser(req.UserID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+	if _, ok := c.findUser(req.PartnerID); !ok {
+		writeError(w, http.StatusNotFound, "PARTNER_NOT_FOUND", "Partner not found")
+		return
+	}
+
+	now := time.Now()
+	plan := SharedPlan{
+		Token:     newID(),
+		UserID:    req.UserID,
+		PartnerID: req.PartnerID,
+		Time:      req.Time,
+		Gym:       req.Gym,
+		CreatedAt: now,
+		ExpiresAt: now.Add(sharedPlanTTL),
+	}
+
+	active := c.storage.SharedPlans[:0]
+	for _, p := range c.storage.SharedPlans {
+		if p.ExpiresAt.After(now) {
+			active = append(active, p)
+		}
+	}
+	c.storage.SharedPlans = append(active, plan)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"link":      "/api/safety/plans/" + plan.Token,
+		"expiresAt": plan.ExpiresAt,
+		"contacts":  c.storage.EmergencyContacts[req.UserID],
+	})
+}
+
+func (c *Controller) GetSharedPlan(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	for _, plan := range c.storage.SharedPlans {
+		if plan.Token != token || time.Now().After(plan.ExpiresAt) {
+			continue
+		}
+
+		partner, _ := c.findUser(plan.PartnerID)
+		writeJSON(w, http.StatusOK, SharedPlanView{
+			Partner:   partner.Public(),
+			Time:      plan.Time,
+			Gym:       plan.Gym,
+			ExpiresAt: plan.ExpiresAt,
+		})
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "PLAN_NOT_FOUND", "Plan not found")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ScheduledPending   = "pending"
+	ScheduledSent      = "sent"
+	ScheduledCancelled = "cancelled"
+	ScheduledFailed    = "failed"
+
+	scheduledMessagesInterval = 30 * time.Second
+	maxScheduleAhead          = 30 * 24 * time.Hour
+)
+
+type ScheduledMessage struct {
+	ID        string    `json:"id"`
+	MatchID   string    `json:"matchId"`
+	SenderID  string    `json:"senderId"`
+	Text      string    `json:"text"`
+	SendAt    time.Time `json:"sendAt"`
+	Status    string    `json:"status"`
+	MessageID string    `json:"messageId,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type ScheduleMessageRequest struct {
+	SenderID string    `json:"senderId"`
+	Text     string    `json:"text"`
+	SendAt   time.Time `json:"sendAt"`
+}
+
+// ScheduledMessages handles GET and POST on
+// /api/matches/{matchId}/scheduled-messages.
+func (c *Controller) ScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	match, ok := c.findMatch(r.PathValue("matchId"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "MATCH_NOT_FOUND", "Match not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		userID := r.URL.Query().Get("userId")
+		if !match.Has(userID) {
+			writeError(w, http.StatusForbidden, "NOT_MATCH_PARTICIPANT", "Not a participant of this match")
+			return
+		}
+
+		scheduled := []ScheduledMessage{}
+		for _, s := range c.storage.ScheduledMessages {
+			if s.MatchID == match.ID() && s.SenderID == userID {
+				scheduled = append(scheduled, s)
+			}
+		}
+		writeJSON(w, http.StatusOK, scheduled)
+
+	case http.MethodPost:
+		var req ScheduleMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+
+		if !match.Has(req.SenderID) {
+			writeError(w, http.StatusForbidden, "NOT_MATCH_PARTICIPANT", "Not a participant of this match")
+			return
+		}
+
+		req.Text = strings.TrimSpace(req.Text)
+		if req.Text == "" || len([]rune(req.Text)) > maxMessageLength {
+			writeError(w, http.StatusBadRequest, "INVALID_MESSAGE", "Invalid message text")
+			return
+		}
+
+		now := time.Now()
+		if !req.SendAt.After(now) || req.SendAt.Sub(now) > maxScheduleAhead {
+			writeError(w, http.StatusBadRequest, "INVALID_SEND_AT", "sendAt must be in the future and within 30 days")
+			return
+		}
+
+		scheduled := ScheduledMessage{
+			ID:       newID(),
+			MatchID:  match.ID(),
+			SenderID: req.SenderID,
+			Text:     req.Text,
+			SendAt:   req.SendAt,
+			Status:   ScheduledPending,
+		}
+		c.storage.ScheduledMessages = append(c.storage.ScheduledMessages, scheduled)
+
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, scheduled)
+
+	}
+}
+
+// CancelScheduledMessage handles DELETE /api/scheduled-messages/{id}?userId=...
+func (c *Controller) CancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	userID := r.URL.Query().Get("userId")
+
+	for i, s := range c.storage.ScheduledMessages {
+		if s.ID != id {
+			continue
+		}
+
+		if s.SenderID != userID {
+			writeError(w, http.StatusForbidden, "SCHEDULED_MESSAGE_CANCEL_FORBIDDEN", "Only the sender can cancel a scheduled message")
+			return
+		}
+		if s.Status != ScheduledPending {
+			writeError(w, http.StatusConflict, "SCHEDULED_MESSAGE_NOT_PENDING", "Scheduled message was already "+s.Status)
+			return
+		}
+
+		c.storage.ScheduledMessages[i].Status = ScheduledCancelled
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, c.storage.ScheduledMessages[i])
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "SCHEDULED_MESSAGE_NOT_FOUND", "Scheduled message not found")
+}
+
+// deliverScheduledMessages posts every pending message whose time has come.
+// Link previews are fetched before taking the lock.
+func (c *Controller) deliverScheduledMessages() {
+	now := time.Now()
+	var due []ScheduledMessage
+	c.mu.RLock()
+	for _, s := range c.storage.ScheduledMessages {
+		if s.Status == ScheduledPending && !s.SendAt.After(now) {
+			due = append(due, s)
+		}
+	}
+	c.mu.RUnlock()
+	if len(due) == 0 {
+		return
+	}
+
+	previews := make(map[string]*LinkPreview, len(due))
+	for _, s := range due {
+		previews[s.ID] = c.linkPreview(context.Background(), s.Text)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delivered := 0
+	for i, s := range c.storage.ScheduledMessages {
+		// Messages cancelled meanwhile are skipped, new ones wait for the
+		// next run.
+		preview, ok := previews[s.ID]
+		if !ok || s.Status != ScheduledPending {
+			continue
+		}
+
+		match, ok := c.findMatch(s.MatchID)
+		if !ok {
+			c.storage.ScheduledMessages[i].Status = ScheduledFailed
+			c.storage.ScheduledMessages[i].Error = "match no longer exists"
+			delivered++
+			continue
+		}
+
+		message, err := c.postMessage(match, SendMessageRequest{
+			SenderID: s.SenderID,
+			Text:     s.Text,
+		}, preview)
+		if err != nil {
+			c.storage.ScheduledMessages[i].Status = ScheduledFailed
+			c.storage.ScheduledMessages[i].Error = err.Error()
+		} else {
+			c.storage.ScheduledMessages[i].Status = ScheduledSent
+			c.storage.ScheduledMessages[i].MessageID = message.ID
+		}
+		delivered++
+	}
+
+	if delivered == 0 {
+		return
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.Error("Failed to save data", "err", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type job struct {
+	name     string
+	interval time.Duration
+	fn       func()
+}
+
+// Scheduler runs background jobs at fixed intervals until stopped.
+type Scheduler struct {
+	jobs []job
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{stop: make(chan struct{})}
+}
+
+// Every registers fn to run every interval once the scheduler is started.
+func (s *Scheduler) Every(name string, interval time.Duration, fn func()) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.run(j)
+	}
+}
+
+// Stop signals all jobs to exit and waits for running ones to finish.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.runOnce(j)
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(j job) {
+	defer func() {
+		if err := recover(); err != nil {
+			slog.Error("Job panicked", "job", j.name, "err", err)
+		}
+	}()
+	j.fn()
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The functions in this file are the operations shared by the HTTP handlers
+// and the gRPC server. They take and return plain values; failures are
+// *ServiceError so each transport can render them its own way. Unless noted
+// otherwise the caller must hold c.mu.
+
+// ServiceError is a failed operation with the status and code of its HTTP
+// error response.
+type ServiceError struct {
+	Status  int
+	Code    string
+	Message string
+	Details any
+	// RetryAfter is sent as Retry-After when set.
+	RetryAfter time.Duration
+}
+
+func (e *ServiceError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func serviceError(status int, code, message string) *ServiceError {
+	return &ServiceError{Status: status, Code: code, Message: message}
+}
+
+// writeServiceError writes err as an error envelope. Errors other than
+// *ServiceError are logged and reported as internal errors.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	e, ok := err.(*ServiceError)
+	if !ok {
+		slog.ErrorContext(r.Context(), "Request failed", "err", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		return
+	}
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds())+1))
+	}
+	writeErrorDetails(w, e.Status, e.Code, e.Message, e.Details)
+}
+
+// UsersFilter narrows /api/users to exact trainType, day, time and bio
+// language values.
+type UsersFilter struct {
+	TrainType string
+	Day       string
+	Time      string
+	BioLang   string
+}
+
+// usersPage returns a page of public profiles. limit and offset must already
+// be validated.
+func (c *Controller) usersPage(filter UsersFilter, limit, offset int) UsersPage {
+	var filtered []User
+	for _, u := range c.storage.Users {
+		if filter.TrainType != "" && u.TrainType != filter.TrainType {
+			continue
+		}
+		if filter.Day != "" && u.Day != filter.Day {
+			continue
+		}
+		if filter.Time != "" && u.Time != filter.Time {
+			continue
+		}
+		if filter.BioLang != "" && u.BioLang != filter.BioLang {
+			continue
+		}
+		filtered = append(filtered, u)
+	}
+
+	page := UsersPage{
+		Users:  []User{},
+		Total:  len(filtered),
+		Limit:  limit,
+		Offset: offset,
+	}
+	for i := offset; i < len(filtered) && i < offset+limit; i++ {
+		page.Users = append(page.Users, filtered[i].Public())
+	}
+	return page
+}
+
+// publicProfile returns the public part of uid's profile.
+func (c *Controller) publicProfile(uid string) (User, error) {
+	u, ok := c.findUser(uid)
+	if !ok {
+		return User{}, serviceError(http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+	}
+	return u.Public(), nil
+}
+
+// deck returns up to count cards from userID's deck and logs the decision
+// behind it. query is the raw query filter was parsed from.
+func (c *Controller) deck(ctx context.Context, userID string, filter FeedFilter, query string, count int) []User {
+	candidates := c.feed.Candidates(userID, filter)
+	c.countDeck(len(candidates))
+	c.logDecision(ctx, query, userID, filter, candidates, count)
+
+	shown := candidates
+	if len(shown) > count {
+		shown = shown[:count]
+	}
+	cards := make([]User, 0, len(shown))
+	for _, u := range shown {
+		cards = append(cards, c.candidateCard(userID, u, filter))
+	}
+	return cards
+}
+
+// swipe records req and creates the match when the like is mutual. The
+// caller must hold c.mu for writing.
+func (c *Controller) swipe(ctx context.Context, req SwipeRequest) (swipeResult, error) {
+	if req.SwiperID == req.TargetID {
+		return swipeResult{}, serviceError(http.StatusForbidden, "SWIPE_SELF_FORBIDDEN", "Users cannot swipe on themselves")
+	}
+	if req.DwellMs < 0 {
+		return swipeResult{}, serviceError(http.StatusBadRequest, "INVALID_DWELL", "dwellMs must not be negative")
+	}
+
+	var swiperExists, targetExists bool
+	for _, user := range c.storage.Users {
+		if user.FirebaseUID == req.SwiperID {
+			swiperExists = true
+		}
+		if user.FirebaseUID == req.TargetID {
+			targetExists = true
+		}
+	}
+	if !swiperExists || !targetExists {
+		return swipeResult{}, serviceError(http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+	}
+
+	if c.storage.isBlocked(req.SwiperID, req.TargetID) {
+		return swipeResult{}, serviceError(http.StatusForbidden, "USER_BLOCKED", "User is blocked")
+	}
+
+	if req.IsLike {
+		if err := c.likeQuotaError(req.SwiperID); err != nil {
+			return swipeResult{}, err
+		}
+	}
+
+	swipe := Swipe{
+		SwiperID:  req.SwiperID,
+		TargetID:  req.TargetID,
+		IsLike:    req.IsLike,
+		CreatedAt: time.Now(),
+		DwellMs:   min(req.DwellMs, maxDwellMs),
+	}
+	swipeIndex := -1
+	for i, existing := range c.storage.Swipes {
+		if existing.SwiperID == req.SwiperID && existing.TargetID == req.TargetID {
+			// A resurfaced dislike can be swiped again.
+			if existing.IsLike {
+				swipe = existing
+			}
+			swipeIndex = i
+			break
+		}
+	}
+
+	isMatch := false
+	if req.IsLike {
+		for _, swipe := range c.storage.Swipes {
+			if swipe.SwiperID == req.TargetID &&
+				swipe.TargetID == req.SwiperID &&
+				swipe.IsLike {
+				isMatch = true
+				break
+			}
+		}
+	}
+
+	var newMatch *Match
+	if isMatch {
+		id1, id2 := req.SwiperID, req.TargetID
+		if id1 > id2 {
+			id1, id2 = id2, id1
+		}
+
+		matchExists := false
+		for _, match := range c.storage.Matches {
+			if (match.User1ID == id1 && match.User2ID == id2) ||
+				(match.User1ID == id2 && match.User2ID == id1) {
+				matchExists = true
+				break
+			}
+		}
+
+		if !matchExists {
+			newMatch = &Match{
+				User1ID:   id1,
+				User2ID:   id2,
+				CreatedAt: time.Now(),
+			}
+		}
+	}
+
+	// Swipes go to the journal instead of rewriting the snapshot; the
+	// compaction job folds them in. The journal is written first, so a
+	// failed write leaves memory untouched and nobody is told of a match
+	// that would be lost on restart.
+	if err := c.journalSwipe(swipe, newMatch); err != nil {
+		slog.ErrorContext(ctx, "Failed to save data", "err", err)
+		return swipeResult{}, serviceError(http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+	}
+
+	c.metrics.Swipes.Inc()
+	if req.IsLike {
+		c.metrics.Likes.Inc()
+	}
+	if swipeIndex == -1 {
+		c.storage.Swipes = append(c.storage.Swipes, swipe)
+	} else {
+		c.storage.Swipes[swipeIndex] = swipe
+	}
+	if newMatch != nil {
+		c.storage.Matches = append(c.storage.Matches, *newMatch)
+		c.metrics.MatchesCreated.Inc()
+		c.notifyMatch(*newMatch)
+	}
+
+	result := swipeResult{Success: true, IsMatch: isMatch}
+	if isMatch {
+		for _, match := range c.storage.Matches {
+			if (match.User1ID == req.SwiperID && match.User2ID == req.TargetID) ||
+				(match.User1ID == req.TargetID && match.User2ID == req.SwiperID) {
+				result.Match = &match
+			}
+		}
+	}
+	return result, nil
+}
+
+// matchViews returns userID's matches as they see them.
+func (c *Controller) matchViews(userID string) []MatchView {
+	views := []MatchView{}
+	for _, match := range c.storage.Matches {
+		if !match.Has(userID) {
+			continue
+		}
+
+		// Partners of erased accounts no longer exist.
+		partner, ok := c.findUser(match.Partner(userID))
+		if !ok {
+			continue
+		}
+		views = append(views, match.view(userID, partner))
+	}
+	return views
+}
+
+// watchMatches calls send with each of userID's current matches and then
+// with every new one, until ctx is done, send fails or the server shuts
+// down. The caller must not hold c.mu.
+func (c *Controller) watchMatches(ctx context.Context, userID string, send func(MatchView) error) error {
+	// Take the cursor first so a match made while listing is sent twice
+	// rather than never.
+	_, cursor, _, _ := c.events.since(userID, 0)
+
+	c.mu.RLock()
+	current := c.matchViews(userID)
+	c.mu.RUnlock()
+	for _, v := range current {
+		if err := send(v); err != nil {
+			return err
+		}
+	}
+
+	for {
+		events, last, wake, closed := c.events.since(userID, cursor)
+		cursor = last
+
+		for _, e := range events {
+			if e.Data["type"] != "match" {
+				continue
+			}
+			c.mu.RLock()
+			view, ok := c.matchView(userID, e.Data["matchId"])
+			c.mu.RUnlock()
+			if !ok {
+				continue
+			}
+			if err := send(view); err != nil {
+				return err
+			}
+		}
+
+		if closed {
+			return nil
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// matchView returns match id as userID sees it.
+func (c *Controller) matchView(userID, id string) (MatchView, bool) {
+	match, ok := c.findMatch(id)
+	if !ok || !match.Has(userID) {
+		return MatchView{}, false
+	}
+	partner, ok := c.findUser(match.Partner(userID))
+	if !ok {
+		return MatchView{}, false
+	}
+	return match.view(userID, partner), true
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// sessionCalendarMinutes is how long a session lasts in calendars, since
+	// sessions only have a start.
+	sessionCalendarMinutes = 60
+
+	icsTimeLayout = "20060102T150405Z"
+	// icsLineOctets is the longest content line RFC 5545 allows before it
+	// has to be folded.
+	icsLineOctets = 75
+)
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// icsWriter builds an iCalendar document with CRLF line endings and long
+// lines folded.
+type icsWriter struct {
+	b strings.Builder
+}
+
+func (w *icsWriter) line(name, value string) {
+	line := name + ":" + value
+	for len(line) > icsLineOctets {
+		// Fold on a rune boundary; continuation lines start with a space.
+		cut := icsLineOctets
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.b.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+	w.b.WriteString(line + "\r\n")
+}
+
+func (w *icsWriter) text(name, value string) {
+	w.line(name, icsEscaper.Replace(value))
+}
+
+// sessionCalendar returns uid's accepted sessions as an iCalendar feed.
+func (c *Controller) sessionCalendar(u User) string {
+	now := time.Now().UTC().Format(icsTimeLayout)
+
+	var w icsWriter
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//GymBro//Sessions//RU")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	w.text("X-WR-CALNAME", "GymBro: тренировки "+u.Name)
+	w.line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	w.line("X-PUBLISHED-TTL", "PT1H")
+
+	for _, s := range c.storage.Sessions {
+		if s.Status != SessionAccepted || !s.hasUser(u.FirebaseUID) {
+			continue
+		}
+		startsAt := s.StartsAt()
+		if startsAt.IsZero() {
+			continue
+		}
+
+		partnerID := s.ProposedBy
+		if partnerID == u.FirebaseUID {
+			partnerID = s.ProposedTo
+		}
+		partner, _ := c.findUser(partnerID)
+
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", s.ID+"@gymbro")
+		w.line("DTSTAMP", now)
+		w.line("LAST-MODIFIED", s.UpdatedAt.UTC().Format(icsTimeLayout))
+		w.line("DTSTART", startsAt.UTC().Format(icsTimeLayout))
+		w.line("DTEND", startsAt.Add(sessionCalendarMinutes*time.Minute).UTC().Format(icsTimeLayout))
+		// Each reschedule is a new revision of the event.
+		w.line("SEQUENCE", fmt.Sprint(s.Reschedules))
+		w.line("STATUS", "CONFIRMED")
+		summary := "Тренировка с " + partner.Name
+		if s.TrainType != "" {
+			summary += ": " + s.TrainType
+		}
+		w.text("SUMMARY", summary)
+		if i, ok := c.findGym(s.GymID); ok {
+			gym := c.storage.Gyms[i]
+			location := gym.Name
+			if gym.Address != "" {
+				location += ", " + gym.Address
+			}
+			w.text("LOCATION", location)
+			w.line("GEO", fmt.Sprintf("%f;%f", gym.Latitude, gym.Longitude))
+		}
+		w.line("END", "VEVENT")
+	}
+
+	w.line("END", "VCALENDAR")
+	return w.b.String()
+}
+
+// sessionCalendarFeed handles GET /api/users/{uid}/sessions.ics, a feed of
+// the user's accepted sessions calendar apps can subscribe to.
+func (c *Controller) sessionCalendarFeed(w http.ResponseWriter, r *http.Request, u User) {
+	if !c.requireFeature(w, r, "sessions", u.FirebaseUID) {
+		return
+	}
+	if notModified(w, r, c.dataETag(r)) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="gymbro-sessions.ics"`)
+	fmt.Fprint(w, c.sessionCalendar(u))
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SessionProposed  = "proposed"
+	SessionAccepted  = "accepted"
+	SessionDeclined  = "declined"
+	SessionCancelled = "cancelled"
+
+	sessionDateLayout = "2006-01-02"
+)
+
+// Session is a workout one matched user proposes to the other. The partner
+// accepts or declines it, and either of them may reschedule it, which turns
+// it back into a proposal the other one has to answer.
+type Session struct {
+	ID      string `json:"id"`
+	MatchID string `json:"matchId"`
+	// ProposedBy made the current proposal and ProposedTo has to answer it.
+	ProposedBy string `json:"proposedBy"`
+	ProposedTo string `json:"proposedTo"`
+	GymID      string `json:"gymId,omitempty"`
+	// Date is YYYY-MM-DD and Time HH:MM in the server's time zone.
+	Date        string     `json:"date"`
+	Time        string     `json:"time"`
+	TrainType   string     `json:"trainType,omitempty"`
+	Status      string     `json:"status"`
+	Reschedules int        `json:"reschedules,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	RespondedAt *time.Time `json:"respondedAt,omitempty"`
+}
+
+// SessionRequest proposes or reschedules a session. When rescheduling, empty
+// fields keep their current value.
+type SessionRequest struct {
+	UserID    string `json:"userId"`
+	GymID     string `json:"gymId"`
+	Date      string `json:"date"`
+	Time      string `json:"time"`
+	TrainType string `json:"trainType"`
+}
+
+// StartsAt returns when the session begins, or the zero time when its date
+// or time is invalid.
+func (s Session) StartsAt() time.Time {
+	day, err := time.ParseInLocation(sessionDateLayout, s.Date, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	minutes, err := parseClock(s.Time)
+	if err != nil {
+		return time.Time{}
+	}
+	return day.Add(time.Duration(minutes) * time.Minute)
+}
+
+func (s Session) hasUser(uid string) bool {
+	return s.ProposedBy == uid || s.ProposedTo == uid
+}
+
+func (c *Controller) findSession(id string) (int, bool) {
+	for i, s := range c.storage.Sessions {
+		if s.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// checkSessionRequest returns the error status, code and message for req,
+// or zero when the gym exists and the session starts in the future.
+func (c *Controller) checkSessionRequest(req SessionRequest) (int, string, string) {
+	if req.GymID != "" {
+		if _, ok := c.findGym(req.GymID); !ok {
+			return http.StatusNotFound, "GYM_NOT_FOUND", "Gym not found"
+		}
+	}
+	startsAt := Session{Date: req.Date, Time: req.Time}.StartsAt()
+	if startsAt.IsZero() {
+		return http.StatusBadRequest, "INVALID_SESSION", "Date must be YYYY-MM-DD and time HH:MM"
+	}
+	if !startsAt.After(time.Now()) {
+		return http.StatusBadRequest, "SESSION_IN_PAST", "Session must start in the future"
+	}
+	return 0, "", ""
+}
+
+// ProposeSession handles POST /api/matches/{matchId}/sessions.
+func (c *Controller) ProposeSession(w http.ResponseWriter, r *http.Request) {
+	var req SessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	if !c.requireFeature(w, r, "sessions", req.UserID) {
+		return
+	}
+
+	match, ok := c.findMatch(r.PathValue("matchId"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "MATCH_NOT_FOUND", "Match not found")
+		return
+	}
+	var partnerID string
+	switch req.UserID {
+	case match.User1ID:
+		partnerID = match.User2ID
+	case match.User2ID:
+		partnerID = match.User1ID
+	default:
+		writeError(w, http.StatusForbidden, "NOT_MATCH_PARTICIPANT", "User is not part of this match")
+		return
+	}
+	if c.storage.isBlocked(req.UserID, partnerID) {
+		writeError(w, http.StatusForbidden, "USER_BLOCKED", "User is blocked")
+		return
+	}
+	if status, code, msg := c.checkSessionRequest(req); status != 0 {
+		writeError(w, status, code, msg)
+		return
+	}
+
+	now := time.Now()
+	session := Session{
+		ID:         newID(),
+		MatchID:    match.ID(),
+		ProposedBy: req.UserID,
+		ProposedTo: partnerID,
+		GymID:      req.GymID,
+		Date:       req.Date,
+		Time:       req.Time,
+		TrainType:  strings.TrimSpace(req.TrainType),
+		Status:     SessionProposed,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	c.storage.Sessions = append(c.storage.Sessions, session)
+
+	proposer, _ := c.findUser(req.UserID)
+	c.notifyUser(partnerID, Notification{
+		Title: "Приглашение на тренировку",
+		Body:  proposer.Name + " зовёт тренироваться " + session.Date + " в " + session.Time,
+		Data: map[string]string{
+			"type":      "session",
+			"sessionId": session.ID,
+			"matchId":   session.MatchID,
+			"status":    session.Status,
+		},
+	})
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, session)
+}
+
+// UserSessions handles GET /api/sessions/{uid}: the user's proposed and
+// accepted sessions that have not started yet, soonest first. With
+// ?all=true declined, cancelled and past sessions are included.
+func (c *Controller) UserSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("uid")
+	if !c.requireFeature(w, r, "sessions", userID) {
+		return
+	}
+	all := r.URL.Query().Get("all") == "true"
+	now := time.Now()
+
+	sessions := []Session{}
+	for _, s := range c.storage.Sessions {
+		if !s.hasUser(userID) {
+			continue
+		}
+		upcoming := (s.Status == SessionProposed || s.Status == SessionAccepted) && s.StartsAt().After(now)
+		if all || upcoming {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].StartsAt().Before(sessions[j].StartsAt())
+	})
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// RespondSession handles POST /api/sessions/{id}/accept|decline|reschedule|cancel.
+// Only the user a proposal is addressed to may accept or decline it; either
+// participant may reschedule or cancel a proposed or accepted session.
+func (c *Controller) RespondSession(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	if action != "accept" && action != "decline" && action != "reschedule" && action != "cancel" {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+		return
+	}
+
+	var req SessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	if !c.requireFeature(w, r, "sessions", req.UserID) {
+		return
+	}
+
+	i, ok := c.findSession(r.PathValue("id"))
+	if !ok || !c.storage.Sessions[i].hasUser(req.UserID) {
+		writeError(w, http.StatusNotFound, "SESSION_NOT_FOUND", "Session not found")
+		return
+	}
+	session := &c.storage.Sessions[i]
+	if session.Status != SessionProposed && session.Status != SessionAccepted {
+		writeError(w, http.StatusConflict, "SESSION_CLOSED", "Session was declined or cancelled")
+		return
+	}
+
+	now := time.Now()
+	partnerID := session.ProposedTo
+	if req.UserID == session.ProposedTo {
+		partnerID = session.ProposedBy
+	}
+	user, _ := c.findUser(req.UserID)
+	var n Notification
+
+	switch action {
+	case "accept", "decline":
+		if session.Status != SessionProposed || req.UserID != session.ProposedTo {
+			writeError(w, http.StatusForbidden, "SESSION_ANSWER_FORBIDDEN", "Only the invited partner can answer a proposal")
+			return
+		}
+		session.RespondedAt = &now
+		if action == "accept" {
+			session.Status = SessionAccepted
+			n = Notification{Title: "Тренировка согласована", Body: user.Name + " придёт " + session.Date + " в " + session.Time}
+		} else {
+			session.Status = SessionDeclined
+			n = Notification{Title: "Тренировка отклонена", Body: user.Name + " не сможет " + session.Date + " в " + session.Time}
+		}
+
+	case "reschedule":
+		if c.storage.isBlocked(req.UserID, partnerID) {
+			writeError(w, http.StatusForbidden, "USER_BLOCKED", "User is blocked")
+			return
+		}
+		if req.GymID == "" {
+			req.GymID = session.GymID
+		}
+		if req.Date == "" {
+			req.Date = session.Date
+		}
+		if req.Time == "" {
+			req.Time = session.Time
+		}
+		if status, code, msg := c.checkSessionRequest(req); status != 0 {
+			writeError(w, status, code, msg)
+			return
+		}
+		session.GymID, session.Date, session.Time = req.GymID, req.Date, req.Time
+		if t := strings.TrimSpace(req.TrainType); t != "" {
+			session.TrainType = t
+		}
+		session.ProposedBy, session.ProposedTo = req.UserID, partnerID
+		session.Status = SessionProposed
+		session.RespondedAt = nil
+		session.Reschedules++
+		n = Notification{Title: "Тренировка перенесена", Body: user.Name + " предлагает " + session.Date + " в " + session.Time}
+
+	case "cancel":
+		session.Status = SessionCancelled
+		n = Notification{Title: "Тренировка отменена", Body: user.Name + " отменил(а) тренировку " + session.Date + " в " + session.Time}
+	}
+	session.UpdatedAt = now
+
+	n.Data = map[string]string{
+		"type":      "session",
+		"sessionId": session.ID,
+		"matchId":   session.MatchID,
+		"status":    session.Status,
+	}
+	c.notifyUser(partnerID, n)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, *session)
+}
+
+// forgetSessions removes the sessions uid takes part in. The caller must
+// hold c.mu and save data.
+func (c *Controller) forgetSessions(uid string) {
+	sessions := c.storage.Sessions[:0]
+	for _, s := range c.storage.Sessions {
+		if !s.hasUser(uid) {
+			sessions = append(sessions, s)
+		}
+	}
+	c.storage.Sessions = sessions
+}
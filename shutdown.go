@@ -0,0 +1,26 @@
+package main
+
+import "context"
+
+// Shutdown waits for background work started by handlers, interrupts an
+// image migration so it resumes on the next start, and writes the storage
+// one last time. Call it after the HTTP server and scheduler have
+// stopped so nothing modifies storage afterwards.
+func (c *Controller) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	c.stopImageMigration()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.saveData()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+const defaultDailyLikeLimit = 100
+
+// likeQuotaReset returns when the current daily like quota window ends.
+func likeQuotaReset(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// likesToday counts the likes swiperID sent since midnight UTC.
+func (c *Controller) likesToday(swiperID string, now time.Time) int {
+	dayStart := likeQuotaReset(now).Add(-24 * time.Hour)
+
+	count := 0
+	for _, swipe := range c.storage.Swipes {
+		if swipe.SwiperID == swiperID && swipe.IsLike && !swipe.CreatedAt.Before(dayStart) {
+			count++
+		}
+	}
+	return count
+}
+
+// likeQuotaError returns a DAILY_LIKE_LIMIT error when swiperID used up the
+// daily like quota, and nil otherwise.
+func (c *Controller) likeQuotaError(swiperID string) *ServiceError {
+	if c.dailyLikeLimit <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if c.likesToday(swiperID, now) < c.dailyLikeLimit {
+		return nil
+	}
+
+	resetAt := likeQuotaReset(now)
+	return &ServiceError{
+		Status:  http.StatusTooManyRequests,
+		Code:    "DAILY_LIKE_LIMIT",
+		Message: "Daily like limit reached",
+		Details: map[string]interface{}{
+			"limit":   c.dailyLikeLimit,
+			"resetAt": resetAt,
+		},
+		RetryAfter: resetAt.Sub(now),
+	}
+}
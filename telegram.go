@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	telegramAPIURL  = "https://api.telegram.org"
+	telegramTimeout = 10 * time.Second
+	// telegramLinkTTL is how long a link code can be redeemed in the bot.
+	telegramLinkTTL = 15 * time.Minute
+
+	telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+// TelegramBot sends direct messages through the Telegram Bot API. Users link
+// their chat by opening the bot with a one-time code, which the bot receives
+// on the webhook.
+type TelegramBot struct {
+	Token    string
+	Username string
+	// WebhookSecret is the secret_token the webhook was registered with;
+	// Telegram sends it with every update.
+	WebhookSecret string
+	APIURL        string
+	Client        *http.Client
+}
+
+// SendMessage sends text to a chat. A user who blocked the bot or deleted
+// their account yields errUnregistered.
+func (b *TelegramBot) SendMessage(ctx context.Context, chatID int64, text string) error {
+	body, err := json.Marshal(map[string]any{
+		"chat_id": chatID,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.APIURL+"/bot"+b.Token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusForbidden:
+		return errUnregistered
+	default:
+		var result struct {
+			Description string `json:"description"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return fmt.Errorf("telegram returned %d: %s", resp.StatusCode, result.Description)
+	}
+}
+
+// telegramFromEnv returns the configured bot or nil when the Telegram
+// integration is disabled.
+func telegramFromEnv() *TelegramBot {
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	if token == "" {
+		return nil
+	}
+	bot := &TelegramBot{
+		Token:         token,
+		Username:      strings.TrimPrefix(os.Getenv("TELEGRAM_BOT_USERNAME"), "@"),
+		WebhookSecret: os.Getenv("TELEGRAM_WEBHOOK_SECRET"),
+		APIURL:        os.Getenv("TELEGRAM_API_URL"),
+		Client:        &http.Client{Timeout: telegramTimeout},
+	}
+	if bot.Username == "" || bot.WebhookSecret == "" {
+		slog.Warn("Telegram is disabled: TELEGRAM_BOT_USERNAME and TELEGRAM_WEBHOOK_SECRET are required")
+		return nil
+	}
+	if bot.APIURL == "" {
+		bot.APIURL = telegramAPIURL
+	}
+	return bot
+}
+
+// TelegramChat is the private chat with the bot a user linked.
+type TelegramChat struct {
+	UserID   string    `json:"userId"`
+	ChatID   int64     `json:"chatId"`
+	Username string    `json:"username,omitempty"`
+	LinkedAt time.Time `json:"linkedAt"`
+}
+
+// TelegramLinkCode binds the chat that sends it to the bot to UserID.
+type TelegramLinkCode struct {
+	Code      string    `json:"code"`
+	UserID    string    `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type TelegramLinkRequest struct {
+	UserID string `json:"userId"`
+}
+
+// TelegramLinkResponse is returned when linking starts. URL opens the bot,
+// which sends the code as soon as the user taps Start.
+type TelegramLinkResponse struct {
+	Code      string    `json:"code"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (c *Controller) findTelegramChat(uid string) (int, bool) {
+	for i, chat := range c.storage.TelegramChats {
+		if chat.UserID == uid {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// telegramNotification reports whether n is worth a Telegram message: new
+// matches and proposals the user has to answer.
+func telegramNotification(n Notification) bool {
+	switch n.Data["type"] {
+	case "match":
+		return true
+	case "session":
+		return n.Data["status"] == SessionProposed
+	}
+	return false
+}
+
+// pushTelegram messages n to userID's linked chat when it is worth it. The
+// caller must hold c.mu.
+func (c *Controller) pushTelegram(userID string, n Notification) {
+	if c.telegram == nil || !telegramNotification(n) {
+		return
+	}
+	i, ok := c.findTelegramChat(userID)
+	if !ok {
+		return
+	}
+	c.meterNotification(userID)
+	c.sendTelegram(userID, c.storage.TelegramChats[i].ChatID, n.Title+"\n"+n.Body)
+}
+
+// sendTelegram sends text in the background, moving it to the retry queue
+// when sending fails. Chats that blocked the bot are unlinked. The caller
+// must hold c.mu.
+func (c *Controller) sendTelegram(userID string, chatID int64, text string) {
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		err := c.telegram.SendMessage(ctx, chatID, text)
+		if errors.Is(err, errUnregistered) {
+			c.mu.Lock()
+			c.unlinkTelegramChat(chatID)
+			if err := c.saveData(); err != nil {
+				slog.Error("Failed to save data", "err", err)
+			}
+			c.mu.Unlock()
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to send Telegram message, queueing for retry", "err", err)
+			c.enqueueDelivery(Delivery{
+				Channel: DeliveryTelegram,
+				UserID:  userID,
+				Target:  strconv.FormatInt(chatID, 10),
+				Message: text,
+			}, 1, err)
+		}
+	}()
+}
+
+func (c *Controller) sendTelegramDelivery(ctx context.Context, d Delivery) error {
+	if c.telegram == nil {
+		return errors.New("telegram is not configured")
+	}
+	chatID, err := strconv.ParseInt(d.Target, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid chat id: %w", err)
+	}
+	return c.telegram.SendMessage(ctx, chatID, d.Message)
+}
+
+// unlinkTelegramChat forgets chatID. The caller must hold c.mu and save data.
+func (c *Controller) unlinkTelegramChat(chatID int64) {
+	chats := c.storage.TelegramChats[:0]
+	for _, chat := range c.storage.TelegramChats {
+		if chat.ChatID != chatID {
+			chats = append(chats, chat)
+		}
+	}
+	c.storage.TelegramChats = chats
+}
+
+// forgetTelegram removes uid's linked chat and link codes. The caller must
+// hold c.mu and save data.
+func (c *Controller) forgetTelegram(uid string) {
+	if i, ok := c.findTelegramChat(uid); ok {
+		c.unlinkTelegramChat(c.storage.TelegramChats[i].ChatID)
+	}
+	codes := c.storage.TelegramLinkCodes[:0]
+	for _, lc := range c.storage.TelegramLinkCodes {
+		if lc.UserID != uid {
+			codes = append(codes, lc)
+		}
+	}
+	c.storage.TelegramLinkCodes = codes
+}
+
+// TelegramLink handles /api/telegram/link: POST {userId} starts linking and
+// returns the bot link to open, GET ?userId= shows the linked chat and
+// DELETE ?userId= unlinks it.
+func (c *Controller) TelegramLink(w http.ResponseWriter, r *http.Request) {
+	if c.telegram == nil {
+		writeError(w, http.StatusServiceUnavailable, "TELEGRAM_UNAVAILABLE", "Telegram is not available")
+		return
+	}
+
+	var userID string
+	if r.Method == http.MethodPost {
+		var req TelegramLinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return
+		}
+		userID = req.UserID
+	} else {
+		userID = r.URL.Query().Get("userId")
+	}
+	if token, ok := UserTokenFromContext(r.Context()); ok && token.UserID != userID {
+		writeError(w, http.StatusForbidden, "TOKEN_USER_MISMATCH", "Token does not belong to this user")
+		return
+	}
+	if _, ok := c.findUser(userID); !ok {
+		writeError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+	i, linked := c.findTelegramChat(userID)
+
+	switch r.Method {
+	case http.MethodGet:
+		if !linked {
+			writeError(w, http.StatusNotFound, "TELEGRAM_NOT_LINKED", "Telegram is not linked")
+			return
+		}
+		writeJSON(w, http.StatusOK, c.storage.TelegramChats[i])
+		return
+
+	case http.MethodPost:
+		now := time.Now()
+		// Only the latest code of a user stays valid; expired ones of others
+		// are dropped on the way.
+		codes := c.storage.TelegramLinkCodes[:0]
+		for _, lc := range c.storage.TelegramLinkCodes {
+			if lc.UserID != userID && now.Before(lc.ExpiresAt) {
+				codes = append(codes, lc)
+			}
+		}
+		code := TelegramLinkCode{Code: newID(), UserID: userID, ExpiresAt: now.Add(telegramLinkTTL)}
+		c.storage.TelegramLinkCodes = append(codes, code)
+
+	case http.MethodDelete:
+		if !linked {
+			writeError(w, http.StatusNotFound, "TELEGRAM_NOT_LINKED", "Telegram is not linked")
+			return
+		}
+		c.unlinkTelegramChat(c.storage.TelegramChats[i].ChatID)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	code := c.storage.TelegramLinkCodes[len(c.storage.TelegramLinkCodes)-1]
+	writeJSON(w, http.StatusCreated, TelegramLinkResponse{
+		Code:      code.Code,
+		URL:       "https://t.me/" + c.telegram.Username + "?start=" + code.Code,
+		ExpiresAt: code.ExpiresAt,
+	})
+}
+
+// telegramUpdate is the part of a Bot API update the webhook reads.
+type telegramUpdate struct {
+	Message *struct {
+		Text string `json:"text"`
+		From struct {
+			Username string `json:"username"`
+		} `json:"from"`
+		Chat struct {
+			ID   int64  `json:"id"`
+			Type string `json:"type"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// TelegramWebhook handles POST /api/telegram/webhook, the updates Telegram
+// delivers to the bot. "/start <code>" links the chat to the code's user and
+// "/stop" unlinks it; anything else is ignored. Updates are acknowledged
+// even when they are not understood, or Telegram would keep redelivering
+// them.
+func (c *Controller) TelegramWebhook(w http.ResponseWriter, r *http.Request) {
+	if c.telegram == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+		return
+	}
+	secret := r.Header.Get(telegramSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(c.telegram.WebhookSecret)) != 1 {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+		return
+	}
+
+	var update telegramUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	msg := update.Message
+	if msg == nil || msg.Chat.Type != "private" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	chatID := msg.Chat.ID
+	command, arg, _ := strings.Cut(strings.TrimSpace(msg.Text), " ")
+
+	switch command {
+	case "/start":
+		now := time.Now()
+		userID := ""
+		codes := c.storage.TelegramLinkCodes[:0]
+		for _, lc := range c.storage.TelegramLinkCodes {
+			if lc.Code == strings.TrimSpace(arg) && now.Before(lc.ExpiresAt) {
+				userID = lc.UserID
+				continue
+			}
+			codes = append(codes, lc)
+		}
+		c.storage.TelegramLinkCodes = codes
+
+		if _, ok := c.findUser(userID); !ok {
+			c.sendTelegram("", chatID, "Ссылка устарела. Откройте GymBro и подключите Telegram заново.")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// A chat belongs to one user and a user has one chat.
+		c.unlinkTelegramChat(chatID)
+		if i, ok := c.findTelegramChat(userID); ok {
+			c.unlinkTelegramChat(c.storage.TelegramChats[i].ChatID)
+		}
+		c.storage.TelegramChats = append(c.storage.TelegramChats, TelegramChat{
+			UserID:   userID,
+			ChatID:   chatID,
+			Username: msg.From.Username,
+			LinkedAt: now,
+		})
+		c.sendTelegram(userID, chatID, "Готово! Буду присылать сюда новые мэтчи и приглашения на тренировки. Отключить: /stop")
+
+	case "/stop":
+		c.unlinkTelegramChat(chatID)
+		c.sendTelegram("", chatID, "Уведомления отключены. Подключить снова можно в приложении GymBro.")
+
+	default:
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	tenantHeader    = "X-Tenant-ID"
+	defaultTenantID = "default"
+)
+
+var (
+	validTenantID = regexp.MustCompile(`^[a-z0-9-]{1,40}$`)
+	validColor    = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// TenantConfig is the white-label configuration of a gym chain deployment.
+// Welcome copy is keyed by language like maintenance messages.
+type TenantConfig struct {
+	ID              string            `json:"id"`
+	AppName         string            `json:"appName"`
+	PrimaryColor    string            `json:"primaryColor"`
+	AccentColor     string            `json:"accentColor"`
+	WelcomeCopy     map[string]string `json:"welcomeCopy,omitempty"`
+	EnabledFeatures map[string]bool   `json:"enabledFeatures,omitempty"`
+}
+
+func defaultTenantConfig() TenantConfig {
+	return TenantConfig{
+		ID:           defaultTenantID,
+		AppName:      "Gym Bro",
+		PrimaryColor: "#1E1E1E",
+		AccentColor:  "#FF6B00",
+		WelcomeCopy: map[string]string{
+			"ru": "Найди напарника для тренировок",
+			"en": "Find your training partner",
+		},
+	}
+}
+
+func (t TenantConfig) valid() bool {
+	return strings.TrimSpace(t.AppName) != "" &&
+		validColor.MatchString(t.PrimaryColor) &&
+		validColor.MatchString(t.AccentColor)
+}
+
+// tenantID returns the tenant a request belongs to, taken from the
+// X-Tenant-ID header the white-label apps send.
+func tenantID(r *http.Request) string {
+	id := strings.ToLower(r.Header.Get(tenantHeader))
+	if !validTenantID.MatchString(id) {
+		return defaultTenantID
+	}
+	return id
+}
+
+func (c *Controller) findTenant(id string) (int, bool) {
+	for i, t := range c.storage.Tenants {
+		if t.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (c *Controller) tenantConfig(id string) TenantConfig {
+	if i, ok := c.findTenant(id); ok {
+		return c.storage.Tenants[i]
+	}
+	if i, ok := c.findTenant(defaultTenantID); ok {
+		return c.storage.Tenants[i]
+	}
+	return defaultTenantConfig()
+}
+
+// GetTenantConfig handles GET /api/tenant/config.
+func (c *Controller) GetTenantConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.tenantConfig(tenantID(r)))
+}
+
+// AdminTenants handles GET /admin/tenants.
+func (c *Controller) AdminTenants(w http.ResponseWriter, r *http.Request) {
+	tenants := c.storage.Tenants
+	if tenants == nil {
+		tenants = []TenantConfig{}
+	}
+	writeJSON(w, http.StatusOK, tenants)
+}
+
+// AdminPutTenant handles PUT /admin/tenants/{id}, creating or replacing the
+// tenant's config.
+func (c *Controller) AdminPutTenant(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !validTenantID.MatchString(id) {
+		writeError(w, http.StatusBadRequest, "INVALID_TENANT_ID", "Invalid tenant ID")
+		return
+	}
+
+	var tenant TenantConfig
+	if err := json.NewDecoder(r.Body).Decode(&tenant); err != nil || !tenant.valid() {
+		writeError(w, http.StatusBadRequest, "INVALID_TENANT_CONFIG", "Invalid tenant config")
+		return
+	}
+	tenant.ID = id
+
+	if i, found := c.findTenant(id); found {
+		c.storage.Tenants[i] = tenant
+	} else {
+		c.storage.Tenants = append(c.storage.Tenants, tenant)
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminDeleteTenant handles DELETE /admin/tenants/{id}.
+func (c *Controller) AdminDeleteTenant(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !validTenantID.MatchString(id) {
+		writeError(w, http.StatusBadRequest, "INVALID_TENANT_ID", "Invalid tenant ID")
+		return
+	}
+	i, found := c.findTenant(id)
+	if !found {
+		writeError(w, http.StatusNotFound, "TENANT_NOT_FOUND", "Tenant not found")
+		return
+	}
+
+	plan := DestructivePlan{Affected: map[string][]string{"tenants": {id}}}
+	if !c.confirmDestructive(w, r, plan) {
+		return
+	}
+	c.storage.Tenants = append(c.storage.Tenants[:i], c.storage.Tenants[i+1:]...)
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
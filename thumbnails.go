@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// thumbnailSizes are the longest-side pixel sizes generated for every upload.
+// Thumbnails are stored under thumb/{size}/ with the original file name and
+// are served from /images/thumb/{size}/{name}.
+var thumbnailSizes = []int{128, 512}
+
+// resizeImage scales img down so its longest side is at most size, averaging
+// the source pixels covered by each target pixel.
+func resizeImage(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := bounds.Min.Y+y*h/th, bounds.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := bounds.Min.X+x*w/tw, bounds.Min.X+(x+1)*w/tw
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+func thumbnailKey(size int, name string) string {
+	return "thumb/" + strconv.Itoa(size) + "/" + imageKey(name)
+}
+
+// generateThumbnails stores the thumbnail variants of the uploaded image
+// name with the given contents. Formats the standard library cannot decode,
+// such as WebP, are skipped and served at full size.
+func (c *Controller) generateThumbnails(name string, data []byte) {
+	if err := checkImageSize(data); err != nil {
+		slog.Warn("Skipping thumbnails for undecodable image", "name", name, "err", err)
+		return
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("Skipping thumbnails for undecodable image", "name", name, "err", err)
+		return
+	}
+
+	contentType := "image/jpeg"
+	if format == "png" {
+		contentType = "image/png"
+	}
+
+	for _, size := range thumbnailSizes {
+		key := thumbnailKey(size, name)
+		thumb := resizeImage(img, size)
+
+		var buf bytes.Buffer
+		if format == "png" {
+			err = png.Encode(&buf, thumb)
+		} else {
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		}
+		if err != nil {
+			slog.Error("Failed to encode thumbnail", "key", key, "err", err)
+			continue
+		}
+
+		if err := c.images.Put(context.Background(), key, buf.Bytes(), contentType); err != nil {
+			slog.Error("Failed to store thumbnail", "key", key, "err", err)
+		}
+	}
+}
+
+func (c *Controller) removeThumbnails(name string) {
+	for _, size := range thumbnailSizes {
+		key := thumbnailKey(size, name)
+		if err := c.images.Delete(context.Background(), key); err != nil {
+			slog.Error("Failed to remove thumbnail", "key", key, "err", err)
+		}
+	}
+}
+
+// Thumbnail handles GET /images/thumb/{size}/{name}, falling back to the
+// original image when no thumbnail exists.
+func (c *Controller) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.Atoi(r.PathValue("size"))
+	if err != nil || !slices.Contains(thumbnailSizes, size) {
+		http.NotFound(w, r)
+		return
+	}
+
+	key := thumbnailKey(size, r.PathValue("name"))
+	if _, err := c.images.Stat(r.Context(), key); err != nil {
+		key = imageKey(r.PathValue("name"))
+	}
+
+	c.serveImage(w, r, key)
+}
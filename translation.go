@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDailyTranslations = 50
+
+// Translator translates text into the target language (ISO 639-1 code). An
+// empty sourceLang leaves detecting it to the provider.
+type Translator interface {
+	Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error)
+}
+
+// LibreTranslator talks to a LibreTranslate-compatible HTTP API.
+type LibreTranslator struct {
+	Endpoint string
+	APIKey   string
+	Client   *http.Client
+}
+
+func (t *LibreTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	if sourceLang == "" {
+		sourceLang = "auto"
+	}
+	body, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  sourceLang,
+		"target":  targetLang,
+		"format":  "text",
+		"api_key": t.APIKey,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshaling translate request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("building translate request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("calling translate provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translate provider returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding translate response: %w", err)
+	}
+
+	return result.TranslatedText, nil
+}
+
+// translatorFromEnv returns the configured provider or nil when translation
+// is disabled.
+func translatorFromEnv() Translator {
+	endpoint := os.Getenv("TRANSLATE_URL")
+	if endpoint == "" {
+		return nil
+	}
+	return &LibreTranslator{
+		Endpoint: endpoint,
+		APIKey:   os.Getenv("TRANSLATE_API_KEY"),
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+var errTranslationLimit = errors.New("daily translation limit reached")
+
+// TranslationService caches translations per message and language and
+// enforces a per-user daily quota.
+type TranslationService struct {
+	provider   Translator
+	dailyLimit int
+
+	mu    sync.Mutex
+	cache map[string]string
+	usage map[string]translationUsage
+}
+
+type translationUsage struct {
+	day   string
+	count int
+}
+
+func NewTranslationService(provider Translator, dailyLimit int) *TranslationService {
+	return &TranslationService{
+		provider:   provider,
+		dailyLimit: dailyLimit,
+		cache:      make(map[string]string),
+		usage:      make(map[string]translationUsage),
+	}
+}
+
+// Translate returns m's text in targetLang. Text already in targetLang is
+// returned as is, without using the provider or the user's quota.
+func (s *TranslationService) Translate(ctx context.Context, userID string, m Message, targetLang string) (string, error) {
+	sourceLang := detectLanguage(m.Text)
+	if sourceLang != "" && strings.EqualFold(sourceLang, targetLang) {
+		return m.Text, nil
+	}
+
+	key := m.ID + "|" + targetLang
+	if m.EditedAt != nil {
+		key += "|" + m.EditedAt.Format(time.RFC3339Nano)
+	}
+
+	s.mu.Lock()
+	if cached, ok := s.cache[key]; ok {
+		s.mu.Unlock()
+		return cached, nil
+	}
+
+	today := time.Now().Format("2006-01-02")
+	usage := s.usage[userID]
+	if usage.day != today {
+		usage = translationUsage{day: today}
+	}
+	if s.dailyLimit > 0 && usage.count >= s.dailyLimit {
+		s.mu.Unlock()
+		return "", errTranslationLimit
+	}
+	usage.count++
+	s.usage[userID] = usage
+	s.mu.Unlock()
+
+	translated, err := s.provider.Translate(ctx, m.Text, sourceLang, targetLang)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.cache[key] = translated
+	s.mu.Unlock()
+
+	return translated, nil
+}
+
+type TranslateMessageRequest struct {
+	UserID     string `json:"userId"`
+	TargetLang string `json:"targetLang"`
+}
+
+// TranslateMessage handles POST /api/messages/{id}/translate.
+func (c *Controller) TranslateMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if c.translations == nil || c.translations.provider == nil {
+		writeError(w, http.StatusServiceUnavailable, "TRANSLATION_UNAVAILABLE", "Translation is not available")
+		return
+	}
+
+	var req TranslateMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TargetLang == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	i, ok := c.findMessage(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "MESSAGE_NOT_FOUND", "Message not found")
+		return
+	}
+
+	m := c.storage.Messages[i]
+	match, ok := c.findMatch(m.MatchID)
+	if !ok || !match.Has(req.UserID) {
+		writeError(w, http.StatusForbidden, "NOT_MATCH_PARTICIPANT", "Not a participant of this chat")
+		return
+	}
+	if m.DeletedAt != nil {
+		writeError(w, http.StatusConflict, "MESSAGE_DELETED", "Message was deleted")
+		return
+	}
+
+	var (
+		translated string
+		err        error
+	)
+	// The provider is a remote service; m is a copy, so nothing is read
+	// from storage afterwards.
+	c.unlocked(r.Context(), func() {
+		translated, err = c.translations.Translate(r.Context(), req.UserID, m, req.TargetLang)
+	})
+	if errors.Is(err, errTranslationLimit) {
+		writeError(w, http.StatusTooManyRequests, "TRANSLATION_LIMIT", err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "TRANSLATION_FAILED", "Translation failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"messageId":  m.ID,
+		"targetLang": req.TargetLang,
+		"text":       translated,
+	})
+}
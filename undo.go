@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const defaultUndoWindow = 5 * time.Minute
+
+type UndoSwipeRequest struct {
+	SwiperID string `json:"swiperId"`
+}
+
+// UndoSwipe removes the caller's most recent swipe and the match it created,
+// as long as the swipe is younger than the undo window. Each swipe can be
+// undone once: the swipes before it stay, so an undo cannot walk back
+// through the deck.
+func (c *Controller) UndoSwipe(w http.ResponseWriter, r *http.Request) {
+	var req UndoSwipeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SwiperID == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	last := -1
+	for i, swipe := range c.storage.Swipes {
+		if swipe.SwiperID != req.SwiperID {
+			continue
+		}
+		if last == -1 || !swipe.CreatedAt.Before(c.storage.Swipes[last].CreatedAt) {
+			last = i
+		}
+	}
+
+	if last == -1 {
+		writeError(w, http.StatusNotFound, "NOTHING_TO_UNDO", "Nothing to undo")
+		return
+	}
+
+	swipe := c.storage.Swipes[last]
+	if !swipe.CreatedAt.After(c.storage.SwipeUndos[req.SwiperID]) {
+		writeError(w, http.StatusConflict, "ALREADY_UNDONE", "Only the latest swipe can be undone")
+		return
+	}
+	if time.Since(swipe.CreatedAt) > c.undoWindow {
+		writeError(w, http.StatusConflict, "UNDO_WINDOW_PASSED", "Undo window has passed")
+		return
+	}
+
+	c.storage.Swipes = append(c.storage.Swipes[:last], c.storage.Swipes[last+1:]...)
+	if c.storage.SwipeUndos == nil {
+		c.storage.SwipeUndos = make(map[string]time.Time)
+	}
+	c.storage.SwipeUndos[req.SwiperID] = time.Now()
+
+	matchRemoved := false
+	if swipe.IsLike && c.likedBefore(swipe.TargetID, swipe.SwiperID, swipe.CreatedAt) {
+		for i, match := range c.storage.Matches {
+			if (match.User1ID == swipe.SwiperID && match.User2ID == swipe.TargetID) ||
+				(match.User1ID == swipe.TargetID && match.User2ID == swipe.SwiperID) {
+				c.storage.Matches = append(c.storage.Matches[:i], c.storage.Matches[i+1:]...)
+				c.removeMatchActivity(match)
+				matchRemoved = true
+				break
+			}
+		}
+	}
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"swipe":        swipe,
+		"matchRemoved": matchRemoved,
+	})
+}
+
+// likedBefore reports whether swiperID liked targetID no later than at, i.e.
+// whether a like from targetID at that moment completed the match.
+func (c *Controller) likedBefore(swiperID, targetID string, at time.Time) bool {
+	for _, swipe := range c.storage.Swipes {
+		if swipe.SwiperID == swiperID && swipe.TargetID == targetID && swipe.IsLike {
+			return !swipe.CreatedAt.After(at)
+		}
+	}
+	return false
+}
+
+// removeMatchActivity deletes what the two users of an undone match did in
+// it: chat messages and their edits, scheduled messages, sessions, the
+// message request and shared plans. The caller must hold c.mu and save data.
+func (c *Controller) removeMatchActivity(match Match) {
+	s := &c.storage
+	id := match.ID()
+
+	removed := make(map[string]bool)
+	messages := s.Messages[:0]
+	for _, m := range s.Messages {
+		if m.MatchID == id {
+			removed[m.ID] = true
+			continue
+		}
+		messages = append(messages, m)
+	}
+	s.Messages = messages
+
+	edits := s.MessageEdits[:0]
+	for _, e := range s.MessageEdits {
+		if !removed[e.MessageID] {
+			edits = append(edits, e)
+		}
+	}
+	s.MessageEdits = edits
+
+	scheduled := s.ScheduledMessages[:0]
+	for _, m := range s.ScheduledMessages {
+		if m.MatchID != id {
+			scheduled = append(scheduled, m)
+		}
+	}
+	s.ScheduledMessages = scheduled
+
+	sessions := s.Sessions[:0]
+	for _, session := range s.Sessions {
+		if session.MatchID != id {
+			sessions = append(sessions, session)
+		}
+	}
+	s.Sessions = sessions
+
+	requests := s.MessageRequests[:0]
+	for _, req := range s.MessageRequests {
+		if !match.Has(req.FromID) || !match.Has(req.ToID) {
+			requests = append(requests, req)
+		}
+	}
+	s.MessageRequests = requests
+
+	plans := s.SharedPlans[:0]
+	for _, p := range s.SharedPlans {
+		if !match.Has(p.UserID) || !match.Has(p.PartnerID) {
+			plans = append(plans, p)
+		}
+	}
+	s.SharedPlans = plans
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+var (
+	errNoImage          = errors.New("no image uploaded")
+	errUnsupportedImage = errors.New("image must be a JPEG, PNG or WebP file")
+	errImageTooLarge    = errors.New("image is too large")
+	errImageSave        = errors.New("failed to save image")
+)
+
+func uploadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errNoImage), errors.Is(err, errUnsupportedImage), errors.Is(err, errImageTooLarge):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// sniffImageType detects the image type from the file's magic bytes rather
+// than the client-supplied content type, and rewinds the file.
+func sniffImageType(file io.ReadSeeker) (string, error) {
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", errUnsupportedImage
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	contentType := http.DetectContentType(header[:n])
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		return "", errUnsupportedImage
+	}
+	return contentType, nil
+}
+
+// saveUpload validates the image in the "image" form field, strips its
+// metadata, stores it under a random name, so users never overwrite each
+// other's photos, and generates its thumbnails. It returns the image URL, or
+// errNoImage when the form has no image.
+func (c *Controller) saveUpload(r *http.Request) (string, error) {
+	file, handler, err := r.FormFile("image")
+	if err != nil {
+		return "", errNoImage
+	}
+	defer file.Close()
+
+	if handler.Size > c.maxImageBytes {
+		return "", fmt.Errorf("%w: the limit is %d bytes", errImageTooLarge, c.maxImageBytes)
+	}
+	contentType, err := sniffImageType(file)
+	if err != nil {
+		return "", err
+	}
+
+	c.faults.SlowImageSave()
+
+	var clean bytes.Buffer
+	if err := stripMetadata(contentType, file, &clean); err != nil {
+		return "", err
+	}
+
+	filename := newID() + allowedImageTypes[contentType]
+	if err := c.images.Put(r.Context(), filename, clean.Bytes(), contentType); err != nil {
+		return "", fmt.Errorf("%w: %v", errImageSave, err)
+	}
+
+	c.generateThumbnails(filename, clean.Bytes())
+	return "/images/" + filename, nil
+}
+
+const (
+	orphanSweepInterval = 6 * time.Hour
+	// orphanGracePeriod keeps files written by uploads still in progress.
+	orphanGracePeriod = time.Hour
+)
+
+// uploadKey matches the names saveUpload gives images. Only such files are
+// ever swept; anything else in the store, such as logos and other static
+// assets, was put there by hand.
+var uploadKey = regexp.MustCompile(`^[0-9a-f]{32}\.(jpg|png|webp)$`)
+
+// sweepOrphanImages deletes uploaded images that no profile references,
+// such as photos left behind by failed uploads. It does nothing while the
+// server runs on default data, where every real photo would look orphaned.
+func (c *Controller) sweepOrphanImages() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.storageLoaded {
+		return
+	}
+
+	referenced := map[string]bool{imageKey(defaultImageURL): true}
+	for _, u := range c.storage.Users {
+		for _, url := range u.Photos {
+			if strings.HasPrefix(url, "/images/") {
+				referenced[imageKey(url)] = true
+			}
+		}
+	}
+	for _, p := range c.storage.PendingImages {
+		referenced[imageKey(p.ImageURL)] = true
+	}
+
+	ctx := context.Background()
+	images, err := c.images.List(ctx)
+	if err != nil {
+		slog.Error("Failed to list images", "err", err)
+		return
+	}
+
+	removed := 0
+	for _, img := range images {
+		// Thumbnails go together with their original.
+		if !uploadKey.MatchString(img.Key) || referenced[img.Key] ||
+			time.Since(img.ModTime) < orphanGracePeriod {
+			continue
+		}
+
+		if err := c.images.Delete(ctx, img.Key); err != nil {
+			slog.Error("Failed to remove orphaned image", "key", img.Key, "err", err)
+			continue
+		}
+		removed++
+		c.removeThumbnails(img.Key)
+		c.removeImageVariants(img.Key)
+	}
+
+	if removed > 0 {
+		slog.Info("Removed orphaned images", "count", removed)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSweepOrphanImagesKeepsUnrelatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalImageStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphan := newID() + ".jpg"
+	kept := newID() + ".png"
+	files := []string{
+		orphan,
+		kept,
+		thumbnailKey(thumbnailSizes[0], orphan),
+		"gymbro_logo.png",
+		"cat.jpeg",
+		"1000000018.jpg",
+		"icons/nav_account.png",
+	}
+	old := time.Now().Add(-2 * orphanGracePeriod)
+	for _, key := range files {
+		if err := store.Put(context.Background(), key, []byte("image"), "image/png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(key)), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &Controller{images: store, storageLoaded: true}
+	c.storage.Users = []User{{FirebaseUID: "u1", Photos: []string{"/images/" + kept}}}
+	c.sweepOrphanImages()
+
+	for _, key := range files {
+		_, err := store.Stat(context.Background(), key)
+		removed := err == errImageNotFound
+		wantRemoved := key == orphan || key == thumbnailKey(thumbnailSizes[0], orphan)
+		if removed != wantRemoved {
+			t.Errorf("%s: removed = %v, want %v", key, removed, wantRemoved)
+		}
+	}
+}
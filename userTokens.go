@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeProfileWrite = "profile:write"
+	ScopeSwipe        = "swipe"
+	ScopeChat         = "chat"
+	ScopeAdminRead    = "admin:read"
+	ScopeAdminWrite   = "admin:write"
+	ScopeAdminAll     = "admin:*"
+
+	userTokenPrefix = "gbu_"
+)
+
+var userTokenScopes = []string{ScopeProfileWrite, ScopeSwipe, ScopeChat, ScopeAdminRead, ScopeAdminWrite, ScopeAdminAll}
+
+// apiScopes lists the scope a user token needs for /api/ routes, keyed by
+// path prefix. Routes that are not listed accept any valid token.
+var apiScopes = map[string]string{
+	"/api/profiles":            ScopeProfileWrite,
+	"/api/profiles/":           ScopeProfileWrite,
+	"/api/devices":             ScopeProfileWrite,
+	"/api/telegram/":           ScopeProfileWrite,
+	"/api/account/merge":       ScopeProfileWrite,
+	"/api/users/":              ScopeProfileWrite,
+	"/api/swipe":               ScopeSwipe,
+	"/api/swipe/undo":          ScopeSwipe,
+	"/api/goals":               ScopeSwipe,
+	"/api/goals/":              ScopeSwipe,
+	"/api/matches/":            ScopeChat,
+	"/api/messages/":           ScopeChat,
+	"/api/message-requests/":   ScopeChat,
+	"/api/scheduled-messages/": ScopeChat,
+	"/api/sessions/":           ScopeChat,
+	// The calendar feed is read-only, unlike the rest of /api/users/.
+	"/api/users/{uid}/sessions.ics": ScopeChat,
+}
+
+// routeScope returns the scope of the longest apiScopes prefix of path.
+func routeScope(path string) string {
+	best := ""
+	for prefix := range apiScopes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	return apiScopes[best]
+}
+
+// UserToken is a server-issued API token acting for one user, for example a
+// Telegram bridge or a watch app. Only the SHA-256 hash is stored.
+type UserToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"userId"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type storedUserToken struct {
+	UserToken
+	Hash string `json:"hash"`
+}
+
+type UserTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// Allows reports whether the token grants scope. A scope ending in ":*"
+// grants every scope with that prefix.
+func (t UserToken) Allows(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(s, "*"); ok && strings.HasPrefix(scope, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Controller) findUserToken(secret string) (UserToken, bool) {
+	hash := hashToken(secret)
+	for _, t := range c.storage.UserTokens {
+		if t.Hash == hash && t.RevokedAt == nil {
+			return t.UserToken, true
+		}
+	}
+	return UserToken{}, false
+}
+
+// tokenUser returns the user of a request carrying a valid user token. It
+// takes c.mu, so it is meant for middleware running before the handler.
+func (c *Controller) tokenUser(r *http.Request) (string, bool) {
+	secret, ok := bearerUserToken(r)
+	if !ok {
+		return "", false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	token, found := c.findUserToken(secret)
+	return token.UserID, found
+}
+
+func bearerUserToken(r *http.Request) (string, bool) {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(secret, userTokenPrefix) {
+		return "", false
+	}
+	return secret, true
+}
+
+// UserTokenFromContext returns the user token the request was made with.
+func UserTokenFromContext(ctx context.Context) (UserToken, bool) {
+	token, ok := ctx.Value(userTokenKey).(UserToken)
+	return token, ok
+}
+
+// requireScope checks the user token of requests that carry one: it must
+// grant scope and belong to every user the request acts for. Requests
+// without a user token are passed through unchanged. The caller must hold
+// c.mu.
+func (c *Controller) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearerUserToken(r)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		token, found := c.findUserToken(secret)
+		if !found {
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+			return
+		}
+		if scope != "" && !token.Allows(scope) {
+			writeError(w, http.StatusForbidden, "MISSING_SCOPE", "Token lacks scope "+scope)
+			return
+		}
+		actors, ok := c.requestActors(w, r)
+		if !ok {
+			return
+		}
+		for _, uid := range actors {
+			if token.UserID != uid {
+				writeError(w, http.StatusForbidden, "TOKEN_USER_MISMATCH", "Token does not belong to this user")
+				return
+			}
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userTokenKey, token)))
+	}
+}
+
+// actorFields name the user a client request acts for, in its query or body.
+var actorFields = []string{"userId", "firebaseUid", "swiperId", "senderId", "blockerId", "reporterId"}
+
+// requestActors returns the users r acts for: the {uid} of the path and any
+// actorFields of the query, JSON body or form. JSON and url-encoded bodies
+// are restored for the handler; multipart forms stay parsed on r. On a bad
+// multipart form it writes the error and returns false.
+func (c *Controller) requestActors(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var actors []string
+	if uid := r.PathValue("uid"); uid != "" {
+		actors = append(actors, uid)
+	}
+	values := []url.Values{r.URL.Query()}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "multipart/form-data":
+		if !c.parseUpload(w, r) {
+			return nil, false
+		}
+		values = append(values, r.MultipartForm.Value)
+	case r.Body != nil && r.Body != http.NoBody:
+		body, err := io.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyTooLarge(w, tooLarge.Limit)
+			return nil, false
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+			return nil, false
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if mediaType == "application/x-www-form-urlencoded" {
+			form, _ := url.ParseQuery(string(body))
+			values = append(values, form)
+			break
+		}
+		var fields map[string]any
+		if json.Unmarshal(body, &fields) == nil {
+			form := url.Values{}
+			for _, key := range actorFields {
+				if s, ok := fields[key].(string); ok {
+					form.Set(key, s)
+				}
+			}
+			values = append(values, form)
+		}
+	}
+
+	for _, v := range values {
+		for _, key := range actorFields {
+			if uid := v.Get(key); uid != "" {
+				actors = append(actors, uid)
+			}
+		}
+	}
+	return actors, true
+}
+
+// AdminUserTokens handles GET /admin/users/{uid}/tokens.
+func (c *Controller) AdminUserTokens(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathUser(w, r)
+	if !ok {
+		return
+	}
+
+	tokens := []UserToken{}
+	for _, t := range c.storage.UserTokens {
+		if t.UserID == c.storage.Users[i].FirebaseUID {
+			tokens = append(tokens, t.UserToken)
+		}
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// AdminCreateUserToken handles POST /admin/users/{uid}/tokens.
+func (c *Controller) AdminCreateUserToken(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req UserTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Scopes) == 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(userTokenScopes, scope) {
+			writeError(w, http.StatusBadRequest, "UNKNOWN_SCOPE", "Unknown scope "+scope)
+			return
+		}
+	}
+
+	secret := userTokenPrefix + newID()
+	token := UserToken{
+		ID:        newID(),
+		UserID:    c.storage.Users[i].FirebaseUID,
+		Name:      strings.TrimSpace(req.Name),
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now(),
+	}
+	c.storage.UserTokens = append(c.storage.UserTokens, storedUserToken{
+		UserToken: token,
+		Hash:      hashToken(secret),
+	})
+
+	if err := c.saveData(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+		writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+		return
+	}
+
+	// The secret is only ever returned here.
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":  secret,
+		"detail": token,
+	})
+}
+
+// AdminRevokeUserToken handles DELETE /admin/users/{uid}/tokens/{tokenId}.
+func (c *Controller) AdminRevokeUserToken(w http.ResponseWriter, r *http.Request) {
+	i, ok := c.pathUser(w, r)
+	if !ok {
+		return
+	}
+
+	for j, t := range c.storage.UserTokens {
+		if t.UserID != c.storage.Users[i].FirebaseUID || t.ID != r.PathValue("tokenId") {
+			continue
+		}
+
+		now := time.Now()
+		c.storage.UserTokens[j].RevokedAt = &now
+		if err := c.saveData(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save data", "err", err)
+			writeError(w, http.StatusInternalServerError, "STORAGE_ERROR", "Failed to save data")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeError(w, http.StatusNotFound, "TOKEN_NOT_FOUND", "Token not found")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequireScopeRejectsOtherUsers(t *testing.T) {
+	c := newTestController(t, DefaultConfig())
+
+	const kot, dog = "firebase_uid_AAAAACAT", "firebase_uid_AAAADOG"
+	secret := userTokenPrefix + newID()
+	c.storage.UserTokens = append(c.storage.UserTokens, storedUserToken{
+		UserToken: UserToken{ID: newID(), UserID: kot, Scopes: []string{ScopeSwipe}, CreatedAt: time.Now()},
+		Hash:      hashToken(secret),
+	})
+
+	var called bool
+	h := c.requireScope(ScopeSwipe, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for _, tc := range []struct {
+		swiperID string
+		want     int
+	}{
+		{kot, http.StatusNoContent},
+		{dog, http.StatusForbidden},
+	} {
+		called = false
+		body := `{"swiperId":"` + tc.swiperID + `","targetId":"u3","isLike":true}`
+		r := httptest.NewRequest(http.MethodPost, "/api/swipe", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+secret)
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != tc.want || called != (tc.want == http.StatusNoContent) {
+			t.Errorf("swipe as %s: status %d, handler called %v; want %d", tc.swiperID, w.Code, called, tc.want)
+		}
+	}
+}
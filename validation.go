@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// profileDays are the training days a profile may pick.
+var profileDays = []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
+
+// FieldError describes why one request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every offending field of a request, so clients can
+// highlight them all at once instead of fixing one per round trip.
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// validator collects field errors of a request.
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) fail(field, message string) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: message})
+}
+
+// check records message for field unless ok.
+func (v *validator) check(field string, ok bool, message string) {
+	if !ok {
+		v.fail(field, message)
+	}
+}
+
+// required records an error if value is blank and reports whether it wasn't.
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+		return false
+	}
+	return true
+}
+
+// err returns the collected errors as a *ValidationError, or nil.
+func (v *validator) err(message string) *ValidationError {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Message: message, Fields: v.fields}
+}
+
+func writeValidationError(w http.ResponseWriter, err *ValidationError) {
+	writeErrorDetails(w, http.StatusBadRequest, "VALIDATION_FAILED", err.Message, err.Fields)
+}
+
+// validContact reports whether contact is an email, a phone number or a
+// Telegram handle, optionally written as "tg: handle".
+func validContact(contact string) bool {
+	contact = strings.TrimSpace(contact)
+	if handle, ok := strings.CutPrefix(contact, "tg:"); ok {
+		return contactFormats[ContactTelegram].MatchString(strings.TrimSpace(handle))
+	}
+	for _, format := range contactFormats {
+		if format.MatchString(contact) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateProfileForm checks the fields of a POST /api/profiles form. Time,
+// day and contact are optional, but must be well-formed when given.
+func validateProfileForm(r *http.Request) *ValidationError {
+	var v validator
+	v.required("firebaseUid", r.FormValue("firebaseUid"))
+	if v.required("name", r.FormValue("name")) {
+		if err := validateDisplayName(normalizeDisplayName(r.FormValue("name"))); err != nil {
+			v.fail("name", err.Error())
+		}
+	}
+	if t := r.FormValue("time"); t != "" {
+		_, err := parseClock(t)
+		v.check("time", err == nil, "must be HH:MM")
+	}
+	if day := r.FormValue("day"); day != "" {
+		v.check("day", slices.Contains(profileDays, day), "must be one of "+strings.Join(profileDays, ", "))
+	}
+	if _, ok := r.Form["availability"]; ok {
+		if _, err := parseAvailability(r.FormValue("availability")); err != nil {
+			v.fail("availability", err.Error())
+		}
+	}
+	if contact := r.FormValue("contact"); contact != "" {
+		v.check("contact", validContact(contact), "must be an email, an international phone number or a Telegram handle")
+	}
+	return v.err("Invalid profile")
+}
@@ -0,0 +1,740 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"math/bits"
+	"path"
+	"slices"
+	"strings"
+)
+
+// webpEncoder writes lossless WebP (VP8L, RFC 9649). The standard library
+// only decodes images, so the bitstream is produced here: subtract-green
+// and predictor transforms, LZ77 with a color cache, and one set of prefix
+// codes for the whole image.
+type webpEncoder struct{}
+
+func (webpEncoder) ContentType() string { return "image/webp" }
+func (webpEncoder) Extension() string   { return ".webp" }
+
+// Improves reports whether key is a PNG or GIF. Lossless WebP is usually
+// well under half their size, but larger than a JPEG of the same photo.
+func (webpEncoder) Improves(key string) bool {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+func (webpEncoder) Encode(w io.Writer, img image.Image) error {
+	data, err := encodeVP8L(img)
+	if err != nil {
+		return err
+	}
+
+	size := len(data)
+	pad := size & 1
+	header := make([]byte, 0, 20)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(4+8+size+pad))
+	header = append(header, "WEBPVP8L"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(size))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if pad == 1 {
+		data = append(data, 0)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+const (
+	vp8lMaxSize       = 1 << 14
+	vp8lPredictorBits = 4
+	vp8lCacheBits     = 10
+	vp8lHashBits      = 16
+	vp8lWindow        = 1 << 16
+	vp8lChainDepth    = 16
+	vp8lMinMatch      = 3
+	vp8lMaxMatch      = 4096
+	vp8lMaxCodeLength = 15
+
+	vp8lLiteralCodes = 256
+	vp8lLengthCodes  = 24
+	vp8lDistCodes    = 40
+)
+
+// vp8lDistanceMap is the table of short two-dimensional distances from
+// section 4.2.2: entry i is distance code i+1, yOffset<<4 | (8-xOffset).
+var vp8lDistanceMap = [120]uint8{
+	0x18, 0x07, 0x17, 0x19, 0x28, 0x06, 0x27, 0x29, 0x16, 0x1a,
+	0x26, 0x2a, 0x38, 0x05, 0x37, 0x39, 0x15, 0x1b, 0x36, 0x3a,
+	0x25, 0x2b, 0x48, 0x04, 0x47, 0x49, 0x14, 0x1c, 0x35, 0x3b,
+	0x46, 0x4a, 0x24, 0x2c, 0x58, 0x45, 0x4b, 0x34, 0x3c, 0x03,
+	0x57, 0x59, 0x13, 0x1d, 0x56, 0x5a, 0x23, 0x2d, 0x44, 0x4c,
+	0x55, 0x5b, 0x33, 0x3d, 0x68, 0x02, 0x67, 0x69, 0x12, 0x1e,
+	0x66, 0x6a, 0x22, 0x2e, 0x54, 0x5c, 0x43, 0x4d, 0x65, 0x6b,
+	0x32, 0x3e, 0x78, 0x01, 0x77, 0x79, 0x53, 0x5d, 0x11, 0x1f,
+	0x64, 0x6c, 0x42, 0x4e, 0x76, 0x7a, 0x21, 0x2f, 0x75, 0x7b,
+	0x31, 0x3f, 0x63, 0x6d, 0x52, 0x5e, 0x00, 0x74, 0x7c, 0x41,
+	0x4f, 0x10, 0x20, 0x62, 0x6e, 0x30, 0x73, 0x7d, 0x51, 0x5f,
+	0x40, 0x72, 0x7e, 0x61, 0x6f, 0x50, 0x71, 0x7f, 0x60, 0x70,
+}
+
+// vp8lCodeLengthOrder is the order code length code lengths are written in.
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+func encodeVP8L(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSize || height > vp8lMaxSize {
+		return nil, errors.New("webp: image size out of range")
+	}
+
+	argb, hasAlpha := vp8lPixels(img)
+
+	var bw vp8lBitWriter
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if hasAlpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+
+	// Transforms are undone in reverse order, so subtract green is applied
+	// first and written first.
+	subtractGreen(argb)
+	bw.write(1, 1)
+	bw.write(2, 2)
+
+	modes := predict(argb, width, height)
+	bw.write(1, 1)
+	bw.write(0, 2)
+	bw.write(vp8lPredictorBits-2, 3)
+	writeVP8LImage(&bw, modes, vp8lTiles(width), false, 0)
+	bw.write(0, 1)
+
+	writeVP8LImage(&bw, argb, width, true, vp8lCacheBits)
+	return bw.flush(), nil
+}
+
+// vp8lPixels returns img as non-premultiplied ARGB, reporting whether any
+// pixel is not opaque.
+func vp8lPixels(img image.Image) ([]uint32, bool) {
+	b := img.Bounds()
+	var pix []byte
+	premultiplied := false
+	if n, ok := img.(*image.NRGBA); ok && n.Stride == 4*b.Dx() {
+		pix = n.Pix[:4*b.Dx()*b.Dy()]
+	} else {
+		rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+		pix, premultiplied = rgba.Pix, true
+	}
+
+	argb := make([]uint32, len(pix)/4)
+	hasAlpha := false
+	for i := range argb {
+		p := pix[4*i : 4*i+4 : 4*i+4]
+		r, g, bl, a := uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+		if a != 0xff {
+			hasAlpha = true
+			if premultiplied && a != 0 {
+				r, g, bl = (r*0xff+a/2)/a, (g*0xff+a/2)/a, (bl*0xff+a/2)/a
+			}
+		}
+		argb[i] = a<<24 | r<<16 | g<<8 | bl
+	}
+	return argb, hasAlpha
+}
+
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		g := p >> 8 & 0xff
+		rb := (p>>16 - g) & 0xff << 16
+		rb |= (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | rb
+	}
+}
+
+func vp8lTiles(size int) int {
+	return (size + 1<<vp8lPredictorBits - 1) >> vp8lPredictorBits
+}
+
+// predict replaces argb with its residuals under the predictor transform
+// and returns the sub-image of modes, one per tile, in the green channel.
+// Each tile gets the mode with the smallest residuals.
+func predict(argb []uint32, width, height int) []uint32 {
+	tilesX, tilesY := vp8lTiles(width), vp8lTiles(height)
+	modes := make([]uint32, tilesX*tilesY)
+	for ty := range tilesY {
+		for tx := range tilesX {
+			best, bestCost := 0, -1
+			for mode := range 14 {
+				cost := 0
+				for y := max(ty<<vp8lPredictorBits, 1); y < min((ty+1)<<vp8lPredictorBits, height); y++ {
+					for x := max(tx<<vp8lPredictorBits, 1); x < min((tx+1)<<vp8lPredictorBits, width); x++ {
+						pos := y*width + x
+						cost += residualCost(subPixels(argb[pos], predictPixel(mode, argb, pos, width)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesX+tx] = uint32(best) << 8
+		}
+	}
+
+	// Predictions use the original neighbours, so residuals are written
+	// back to front.
+	for pos := len(argb) - 1; pos >= 0; pos-- {
+		x, y := pos%width, pos/width
+		var pred uint32
+		switch {
+		case pos == 0:
+			pred = 0xff000000
+		case y == 0:
+			pred = argb[pos-1]
+		case x == 0:
+			pred = argb[pos-width]
+		default:
+			mode := modes[(y>>vp8lPredictorBits)*tilesX+x>>vp8lPredictorBits] >> 8
+			pred = predictPixel(int(mode), argb, pos, width)
+		}
+		argb[pos] = subPixels(argb[pos], pred)
+	}
+	return modes
+}
+
+// predictPixel returns the prediction of mode for the pixel at pos, which is
+// neither in the first row nor in the first column. The top-right neighbour
+// of the last column is the first pixel of the current row, as it is for
+// the decoder.
+func predictPixel(mode int, argb []uint32, pos, width int) uint32 {
+	l, t, tl, tr := argb[pos-1], argb[pos-width], argb[pos-width-1], argb[pos-width+1]
+	switch mode {
+	case 0:
+		return 0xff000000
+	case 1:
+		return l
+	case 2:
+		return t
+	case 3:
+		return tr
+	case 4:
+		return tl
+	case 5:
+		return average2(average2(l, tr), t)
+	case 6:
+		return average2(l, tl)
+	case 7:
+		return average2(l, t)
+	case 8:
+		return average2(tl, t)
+	case 9:
+		return average2(t, tr)
+	case 10:
+		return average2(average2(l, tl), average2(t, tr))
+	case 11:
+		if channelDistance(tl, t) < channelDistance(tl, l) {
+			return l
+		}
+		return t
+	case 12:
+		return perChannel(func(s int) uint32 {
+			return clampByte(channel(l, s) + channel(t, s) - channel(tl, s))
+		})
+	default:
+		avg := average2(l, t)
+		return perChannel(func(s int) uint32 {
+			a := channel(avg, s)
+			return clampByte(a + (a-channel(tl, s))/2)
+		})
+	}
+}
+
+func channel(p uint32, shift int) int {
+	return int(p >> shift & 0xff)
+}
+
+func perChannel(f func(shift int) uint32) uint32 {
+	return f(24)<<24 | f(16)<<16 | f(8)<<8 | f(0)
+}
+
+func clampByte(v int) uint32 {
+	return uint32(min(max(v, 0), 0xff))
+}
+
+func channelDistance(a, b uint32) int {
+	d := 0
+	for shift := 0; shift < 32; shift += 8 {
+		d += abs(channel(a, shift) - channel(b, shift))
+	}
+	return d
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func average2(a, b uint32) uint32 {
+	return (a^b)&0xfefefefe>>1 + a&b
+}
+
+// subPixels subtracts b from a channel by channel, modulo 256.
+func subPixels(a, b uint32) uint32 {
+	ag := 0x00ff00ff + a&0xff00ff00 - b&0xff00ff00
+	rb := 0xff00ff00 + a&0x00ff00ff - b&0x00ff00ff
+	return ag&0xff00ff00 | rb&0x00ff00ff
+}
+
+// residualCost estimates how many bits a residual takes: small values in
+// either direction are cheap.
+func residualCost(p uint32) int {
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		v := channel(p, shift)
+		cost += min(v, 256-v)
+	}
+	return cost
+}
+
+// vp8lToken is one symbol run of the entropy-coded image: a literal pixel,
+// a color cache hit or a backward reference.
+type vp8lToken struct {
+	kind  uint8
+	value uint32 // ARGB, cache index or copy length
+	dist  uint32 // distance code of a copy
+}
+
+const (
+	tokenLiteral = iota
+	tokenCache
+	tokenCopy
+)
+
+// writeVP8LImage writes argb as an entropy-coded image. Only the main image
+// carries the meta prefix code bit; this encoder uses a single prefix code
+// group for all of it.
+func writeVP8LImage(bw *vp8lBitWriter, argb []uint32, width int, main bool, cacheBits int) {
+	tokens := vp8lTokens(argb, width, cacheBits)
+
+	greenCodes := vp8lLiteralCodes + vp8lLengthCodes
+	if cacheBits > 0 {
+		greenCodes += 1 << cacheBits
+	}
+	hists := [5][]uint32{
+		make([]uint32, greenCodes),
+		make([]uint32, 256),
+		make([]uint32, 256),
+		make([]uint32, 256),
+		make([]uint32, vp8lDistCodes),
+	}
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenLiteral:
+			hists[0][t.value>>8&0xff]++
+			hists[1][t.value>>16&0xff]++
+			hists[2][t.value&0xff]++
+			hists[3][t.value>>24]++
+		case tokenCache:
+			hists[0][vp8lLiteralCodes+vp8lLengthCodes+t.value]++
+		case tokenCopy:
+			prefix, _, _ := prefixEncode(t.value)
+			hists[0][vp8lLiteralCodes+prefix]++
+			prefix, _, _ = prefixEncode(t.dist)
+			hists[4][prefix]++
+		}
+	}
+
+	if cacheBits > 0 {
+		bw.write(1, 1)
+		bw.write(uint32(cacheBits), 4)
+	} else {
+		bw.write(0, 1)
+	}
+	if main {
+		bw.write(0, 1)
+	}
+	var codes [5]prefixCode
+	for i, h := range hists {
+		codes[i] = writePrefixCode(bw, h)
+	}
+
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenLiteral:
+			codes[0].write(bw, int(t.value>>8&0xff))
+			codes[1].write(bw, int(t.value>>16&0xff))
+			codes[2].write(bw, int(t.value&0xff))
+			codes[3].write(bw, int(t.value>>24))
+		case tokenCache:
+			codes[0].write(bw, vp8lLiteralCodes+vp8lLengthCodes+int(t.value))
+		case tokenCopy:
+			prefix, n, extra := prefixEncode(t.value)
+			codes[0].write(bw, vp8lLiteralCodes+prefix)
+			bw.write(extra, n)
+			prefix, n, extra = prefixEncode(t.dist)
+			codes[4].write(bw, prefix)
+			bw.write(extra, n)
+		}
+	}
+}
+
+// vp8lTokens runs greedy LZ77 over argb with hash chains, and turns
+// literals found in the color cache into cache hits.
+func vp8lTokens(argb []uint32, width, cacheBits int) []vp8lToken {
+	distCodes := make(map[int]uint32, len(vp8lDistanceMap))
+	for i := len(vp8lDistanceMap) - 1; i >= 0; i-- {
+		c := int(vp8lDistanceMap[i])
+		if d := (c>>4)*width + 8 - c&0xf; d >= 1 {
+			distCodes[d] = uint32(i + 1)
+		}
+	}
+
+	var cache []uint32
+	if cacheBits > 0 {
+		cache = make([]uint32, 1<<cacheBits)
+	}
+	addToCache := func(p uint32) {
+		if cache != nil {
+			cache[p*0x1e35a7bd>>(32-cacheBits)] = p
+		}
+	}
+
+	head := make([]int32, 1<<vp8lHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	chain := make([]int32, len(argb))
+	hash := func(i int) uint32 {
+		return (argb[i]*0x1e35a7bd ^ argb[i+1]*0x9e3779b1) >> (32 - vp8lHashBits)
+	}
+	insert := func(i int) {
+		if i+1 < len(argb) {
+			h := hash(i)
+			chain[i], head[h] = head[h], int32(i)
+		}
+	}
+
+	var tokens []vp8lToken
+	for i := 0; i < len(argb); {
+		bestLen, bestDist := 0, 0
+		if i+1 < len(argb) {
+			limit := min(vp8lMaxMatch, len(argb)-i)
+			for cand, depth := head[hash(i)], 0; cand >= 0 && i-int(cand) <= vp8lWindow && depth < vp8lChainDepth; cand, depth = chain[cand], depth+1 {
+				n := 0
+				for n < limit && argb[int(cand)+n] == argb[i+n] {
+					n++
+				}
+				if n > bestLen {
+					bestLen, bestDist = n, i-int(cand)
+				}
+			}
+		}
+
+		if bestLen >= vp8lMinMatch {
+			dist, ok := distCodes[bestDist]
+			if !ok {
+				dist = uint32(bestDist + len(vp8lDistanceMap))
+			}
+			tokens = append(tokens, vp8lToken{kind: tokenCopy, value: uint32(bestLen), dist: dist})
+			for range bestLen {
+				addToCache(argb[i])
+				insert(i)
+				i++
+			}
+			continue
+		}
+
+		p := argb[i]
+		if key := p * 0x1e35a7bd >> (32 - cacheBits); cache != nil && cache[key] == p {
+			tokens = append(tokens, vp8lToken{kind: tokenCache, value: key})
+		} else {
+			tokens = append(tokens, vp8lToken{kind: tokenLiteral, value: p})
+		}
+		addToCache(p)
+		insert(i)
+		i++
+	}
+	return tokens
+}
+
+// prefixEncode splits a length or distance code v >= 1 into its prefix
+// symbol and extra bits.
+func prefixEncode(v uint32) (prefix int, n int, extra uint32) {
+	d := v - 1
+	if d < 4 {
+		return int(d), 0, 0
+	}
+	high := bits.Len32(d) - 1
+	second := int(d>>(high-1)) & 1
+	n = high - 1
+	return 2*high + second, n, d & (1<<n - 1)
+}
+
+// prefixCode is a canonical prefix code, with codes bit-reversed for the
+// LSB-first bit writer. A code with a single symbol takes no bits.
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+func (c prefixCode) write(bw *vp8lBitWriter, symbol int) {
+	bw.write(c.codes[symbol], int(c.lengths[symbol]))
+}
+
+// writePrefixCode writes the code for hist and returns it. One or two
+// symbols below 256 use the simple code; anything else is written as code
+// lengths, themselves prefix coded.
+func writePrefixCode(bw *vp8lBitWriter, hist []uint32) prefixCode {
+	var used []int
+	for s, n := range hist {
+		if n > 0 {
+			used = append(used, s)
+		}
+	}
+	code := prefixCode{lengths: make([]uint8, len(hist)), codes: make([]uint32, len(hist))}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		bw.write(1, 1)
+		if len(used) == 0 {
+			bw.write(0, 3)
+			return code
+		}
+		bw.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(used[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.write(uint32(used[1]), 8)
+			code.lengths[used[0]], code.lengths[used[1]] = 1, 1
+			code.codes[used[1]] = 1
+		}
+		return code
+	}
+
+	lengths := huffmanLengths(hist, vp8lMaxCodeLength)
+	writeCodeLengths(bw, lengths)
+	if len(used) > 1 {
+		code.lengths = lengths
+		code.codes = canonicalCodes(lengths)
+	}
+	return code
+}
+
+// writeCodeLengths writes lengths with the code length code, run-length
+// encoding repeats with symbols 16 to 18.
+func writeCodeLengths(bw *vp8lBitWriter, lengths []uint8) {
+	type rleToken struct{ symbol, extra uint8 }
+	var tokens []rleToken
+	prev := uint8(8)
+	for i := 0; i < len(lengths); {
+		v := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == v {
+			run++
+		}
+		i += run
+		if v == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				tokens = append(tokens, rleToken{18, uint8(n - 11)})
+				run -= n
+			}
+			if run >= 3 {
+				tokens = append(tokens, rleToken{17, uint8(run - 3)})
+				run = 0
+			}
+		} else {
+			if v != prev {
+				tokens = append(tokens, rleToken{v, 0})
+				prev = v
+				run--
+			}
+			for run >= 3 {
+				n := min(run, 6)
+				tokens = append(tokens, rleToken{16, uint8(n - 3)})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, rleToken{v, 0})
+		}
+	}
+
+	hist := make([]uint32, len(vp8lCodeLengthOrder))
+	for _, t := range tokens {
+		hist[t.symbol]++
+	}
+	clLengths := huffmanLengths(hist, 7)
+	used := 0
+	for _, n := range hist {
+		if n > 0 {
+			used++
+		}
+	}
+	clCodes := canonicalCodes(clLengths)
+
+	count := len(vp8lCodeLengthOrder)
+	for count > 4 && clLengths[vp8lCodeLengthOrder[count-1]] == 0 {
+		count--
+	}
+	bw.write(0, 1)
+	bw.write(uint32(count-4), 4)
+	for _, s := range vp8lCodeLengthOrder[:count] {
+		bw.write(uint32(clLengths[s]), 3)
+	}
+	bw.write(0, 1)
+
+	extraBits := [3]int{2, 3, 7}
+	for _, t := range tokens {
+		if used > 1 {
+			bw.write(clCodes[t.symbol], int(clLengths[t.symbol]))
+		}
+		if t.symbol >= 16 {
+			bw.write(uint32(t.extra), extraBits[t.symbol-16])
+		}
+	}
+}
+
+// huffmanLengths returns code lengths of at most limit bits for hist. When
+// the optimal code is too deep, the smallest counts are raised until it
+// fits. A single used symbol gets length 1.
+func huffmanLengths(hist []uint32, limit int) []uint8 {
+	lengths := make([]uint8, len(hist))
+	var used []int
+	for s, n := range hist {
+		if n > 0 {
+			used = append(used, s)
+		}
+	}
+	switch len(used) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[used[0]] = 1
+		return lengths
+	}
+
+	type node struct {
+		weight      uint64
+		left, right int
+	}
+	for floor := uint64(1); ; floor *= 2 {
+		nodes := make([]node, 0, 2*len(used))
+		for _, s := range used {
+			nodes = append(nodes, node{weight: max(uint64(hist[s]), floor), left: -1, right: -1})
+		}
+		leaves := make([]int, len(used))
+		for i := range leaves {
+			leaves[i] = i
+		}
+		slices.SortStableFunc(leaves, func(a, b int) int {
+			return cmp.Compare(nodes[a].weight, nodes[b].weight)
+		})
+
+		// Two-queue construction: leaves in weight order, and merged
+		// nodes, which are created in weight order too.
+		var merged []int
+		take := func() int {
+			if len(merged) == 0 || (len(leaves) > 0 && nodes[leaves[0]].weight <= nodes[merged[0]].weight) {
+				n := leaves[0]
+				leaves = leaves[1:]
+				return n
+			}
+			n := merged[0]
+			merged = merged[1:]
+			return n
+		}
+		for len(leaves)+len(merged) > 1 {
+			a, b := take(), take()
+			nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, left: a, right: b})
+			merged = append(merged, len(nodes)-1)
+		}
+
+		depth := make([]int, len(nodes))
+		deepest := 0
+		for n := len(nodes) - 1; n >= len(used); n-- {
+			for _, child := range []int{nodes[n].left, nodes[n].right} {
+				depth[child] = depth[n] + 1
+				deepest = max(deepest, depth[child])
+			}
+		}
+		if deepest > limit {
+			continue
+		}
+		for i, s := range used {
+			lengths[s] = uint8(depth[i])
+		}
+		return lengths
+	}
+}
+
+// canonicalCodes assigns canonical codes to lengths, bit-reversed so the
+// first bit of a code is written first.
+func canonicalCodes(lengths []uint8) []uint32 {
+	var count [vp8lMaxCodeLength + 1]uint32
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [vp8lMaxCodeLength + 1]uint32
+	code := uint32(0)
+	for l := 1; l <= vp8lMaxCodeLength; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for s, l := range lengths {
+		if l > 0 {
+			codes[s] = bits.Reverse32(next[l]) >> (32 - l)
+			next[l]++
+		}
+	}
+	return codes
+}
+
+// vp8lBitWriter packs values least significant bit first.
+type vp8lBitWriter struct {
+	buf  []byte
+	acc  uint64
+	nacc int
+}
+
+func (bw *vp8lBitWriter) write(v uint32, n int) {
+	bw.acc |= uint64(v) << bw.nacc
+	bw.nacc += n
+	for bw.nacc >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nacc -= 8
+	}
+}
+
+func (bw *vp8lBitWriter) flush() []byte {
+	if bw.nacc > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nacc = 0, 0
+	}
+	return bw.buf
+}